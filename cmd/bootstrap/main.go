@@ -0,0 +1,143 @@
+// Command bootstrap creates the first admin of a hospital, now that staff can only be
+// created by an admin. It prints a generated initial password, which must be changed
+// on first login. It refuses to run while the hospital has an active admin; further
+// staff are created or invited through the API.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/database"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/repositories"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/joho/godotenv"
+)
+
+// maxPasswordTries bounds the attempts at generating a password that meets the policy
+const maxPasswordTries = 100
+
+func main() {
+	hospitalCode := flag.String("hospital", "", "code of the hospital to create the admin in (default DEFAULT_HOSPITAL_CODE)")
+	username := flag.String("username", "admin", "username of the admin")
+	flag.Parse()
+
+	// Load environment variables
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(); err != nil {
+			log.Printf("Warning: failed to load .env: %v", err)
+		}
+	}
+
+	// Initialize configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Route log output through the redacting application logger
+	logger := utils.NewLogger(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	slog.SetDefault(logger)
+
+	if *hospitalCode == "" {
+		*hospitalCode = cfg.Tenancy.DefaultHospitalCode
+	}
+
+	// Initialize database connection
+	db, err := database.NewConnection(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	// Run migrations, so a fresh database can be bootstrapped before the server first starts
+	if err := database.RunMigrations(cfg.Database); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	hospitalRepo := repositories.NewHospitalRepository(db, logger)
+	staffRepo := repositories.NewStaffRepository(db, logger)
+	roleRepo := repositories.NewRoleRepository(db, logger)
+	auditService := services.NewAuditService(repositories.NewAuditRepository(db, logger))
+
+	ctx := context.Background()
+	hospital, err := hospitalRepo.FindByCode(ctx, *hospitalCode)
+	if err != nil {
+		log.Fatalf("Failed to find hospital %s: %v", *hospitalCode, err)
+	}
+	ctx = utils.WithHospitalID(ctx, hospital.ID)
+
+	admins, err := staffRepo.CountByRole(ctx, models.RoleAdmin)
+	if err != nil {
+		log.Fatalf("Failed to count admins: %v", err)
+	}
+	if admins > 0 {
+		log.Fatalf("%s already has an active admin; create further staff through the API", hospital.Code)
+	}
+
+	role, err := roleRepo.FindByName(ctx, models.RoleAdmin)
+	if err != nil {
+		log.Fatalf("Failed to find the admin role: %v", err)
+	}
+
+	password, err := generatePassword(cfg.Password, *username)
+	if err != nil {
+		log.Fatalf("Failed to generate a password: %v", err)
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		log.Fatalf("Failed to hash the password: %v", err)
+	}
+
+	staff := &models.Staff{
+		Username:           *username,
+		Password:           hashedPassword,
+		RoleID:             role.ID,
+		Role:               role.Name,
+		MustChangePassword: true,
+	}
+	if err := staffRepo.Create(ctx, staff); err != nil {
+		log.Fatalf("Failed to create the admin: %v", err)
+	}
+
+	err = auditService.Record(ctx, &models.AuditEvent{
+		Action:  models.AuditActionStaffCreate,
+		Source:  models.AuditSourceLocal,
+		Details: map[string]string{"staff_id": fmt.Sprint(staff.ID), "role": staff.Role, "bootstrap": "true"},
+	})
+	if err != nil {
+		log.Fatalf("Failed to audit the admin's creation: %v", err)
+	}
+
+	// The password goes to stdout only, so it stays out of logs collected from stderr
+	log.Printf("Created admin %s (ID %d) in %s", staff.Username, staff.ID, hospital.Code)
+	fmt.Printf("Initial password for %s: %s\n", staff.Username, password)
+	fmt.Println("It must be changed on first login.")
+}
+
+// generatePassword returns a random password that meets the password policy
+func generatePassword(policy config.PasswordPolicyConfig, username string) (string, error) {
+	// At least 24 characters; every 3 random bytes encode to 4
+	size := 18
+	if minSize := (policy.MinLength*3 + 3) / 4; minSize > size {
+		size = minSize
+	}
+
+	for i := 0; i < maxPasswordTries; i++ {
+		password, err := utils.GenerateRandomToken(size)
+		if err != nil {
+			return "", err
+		}
+		if services.ValidatePassword(policy, username, password) == nil {
+			return password, nil
+		}
+	}
+	return "", fmt.Errorf("no password met the policy after %d tries", maxPasswordTries)
+}
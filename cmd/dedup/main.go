@@ -0,0 +1,73 @@
+// Command dedup scans every hospital's patients for likely duplicates and records them
+// as candidates for review at GET /api/v1/patients/duplicates. Run it periodically,
+// e.g. from cron; pairs already recorded or dismissed are not raised again.
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/database"
+	"github.com/DingDong039/hms/internal/encryption"
+	"github.com/DingDong039/hms/internal/repositories"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(); err != nil {
+			log.Printf("Warning: failed to load .env: %v", err)
+		}
+	}
+
+	// Initialize configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Route log output through the redacting application logger
+	logger := utils.NewLogger(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	slog.SetDefault(logger)
+
+	// Initialize database connection
+	db, err := database.NewConnection(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	keyProvider, err := encryption.LoadLocalKeyProvider(cfg.Encryption.KeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load encryption keys: %v", err)
+	}
+
+	hospitalRepo := repositories.NewHospitalRepository(db, logger)
+	mergeRepo := repositories.NewPatientMergeRepository(db, encryption.NewFieldEncryptor(keyProvider), logger)
+
+	ctx := context.Background()
+	hospitals, err := hospitalRepo.FindAll(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list hospitals: %v", err)
+	}
+
+	failed := false
+	for _, hospital := range hospitals {
+		detected, err := mergeRepo.DetectCandidates(utils.WithHospitalID(ctx, hospital.ID))
+		if err != nil {
+			log.Printf("Failed to detect duplicates in %s: %v", hospital.Code, err)
+			failed = true
+			continue
+		}
+		log.Printf("Found %d new duplicate candidates in %s", detected, hospital.Code)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,86 @@
+// Command jwtkeys manages the key file access tokens are signed with. Without flags it
+// lists the keys. With -add it generates a new key, creating the file if it doesn't
+// exist, and makes it current unless -publish-only is set.
+//
+// To rotate without breaking partners that cache the JWKS, add the new key with
+// -publish-only, wait for their caches to expire, make it current with -set-current,
+// and remove the old key with -remove once the tokens it signed have expired. The
+// server reads the key file at startup, so restart it after each step.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	add := flag.Bool("add", false, "add a new key to the key file")
+	algorithm := flag.String("alg", utils.JWTAlgorithmRS256, "algorithm of the new key: RS256 or EdDSA")
+	publishOnly := flag.Bool("publish-only", false, "with -add, publish the new key without making it current")
+	setCurrent := flag.String("set-current", "", "make the key with this ID current")
+	remove := flag.String("remove", "", "remove the retired key with this ID")
+	flag.Parse()
+
+	// Load environment variables
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(); err != nil {
+			log.Printf("Warning: failed to load .env: %v", err)
+		}
+	}
+
+	// Initialize configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	path := cfg.JWT.KeyFile
+
+	switch {
+	case *add:
+		keyID, err := utils.AddJWTKey(path, *algorithm, !*publishOnly)
+		if err != nil {
+			log.Fatalf("Failed to add key: %v", err)
+		}
+		log.Printf("Added %s key %s to %s", *algorithm, keyID, path)
+	case *setCurrent != "":
+		if err := utils.SetCurrentJWTKey(path, *setCurrent); err != nil {
+			log.Fatalf("Failed to set the current key: %v", err)
+		}
+		log.Printf("Made key %s current in %s", *setCurrent, path)
+	case *remove != "":
+		if err := utils.RemoveJWTKey(path, *remove); err != nil {
+			log.Fatalf("Failed to remove key: %v", err)
+		}
+		log.Printf("Removed key %s from %s", *remove, path)
+	}
+
+	file, err := utils.ReadJWTKeyFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read key file: %v", err)
+	}
+
+	ids := make([]string, 0, len(file.Keys))
+	for id := range file.Keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		keyAlgorithm, err := utils.JWTKeyAlgorithm(file.Keys[id])
+		if err != nil {
+			keyAlgorithm = "invalid: " + err.Error()
+		}
+		marker := ""
+		if id == file.CurrentKeyID {
+			marker = " (current)"
+		}
+		fmt.Printf("%s\t%s%s\n", id, keyAlgorithm, marker)
+	}
+}
@@ -3,7 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,6 +15,7 @@ import (
 	"github.com/DingDong039/hms/internal/database"
 	"github.com/DingDong039/hms/internal/handlers"
 	"github.com/DingDong039/hms/internal/middleware"
+	"github.com/DingDong039/hms/internal/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
@@ -22,16 +24,21 @@ func main() {
 	// Load environment variables
 	if _, err := os.Stat(".env"); err == nil {
 		if err := godotenv.Load(); err != nil {
-			log.Printf("Warning: failed to load .env: %v", err)
+			slog.Warn("failed to load .env", slog.Any("error", err))
 		}
 	}
 
 	// Initialize configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		slog.Error("failed to load configuration", slog.Any("error", err))
+		os.Exit(1)
 	}
 
+	// Initialize the logger; it also receives whatever is still written through the log package
+	logger := utils.NewLogger(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	slog.SetDefault(logger)
+
 	// Set Gin mode
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -40,36 +47,54 @@ func main() {
 	// Initialize database connection
 	db, err := database.NewConnection(cfg.Database)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		logger.Error("failed to connect to database", slog.Any("error", err))
+		os.Exit(1)
 	}
 	defer db.Close()
 
 	// Run migrations
 	if err := database.RunMigrations(cfg.Database); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		logger.Error("failed to run migrations", slog.Any("error", err))
+		os.Exit(1)
 	}
 
-	// Initialize router
-	router := gin.Default()
+	// Every request context and background job derives from baseCtx, so
+	// cancelling it stops whatever is still running at shutdown
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+
+	// Initialize router; gin.Default's own logger and recovery would write unredacted output
+	router := gin.New()
 
 	// Apply global middleware
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Recovery(logger))
 	router.Use(middleware.CORS())
-	router.Use(middleware.Logger())
+	router.Use(middleware.Logger(logger))
+	router.Use(middleware.RequestContext())
+	router.Use(middleware.Timeout(cfg.Server.RequestTimeout))
 
 	// Register routes
-	handlers.RegisterRoutes(router, db, cfg)
+	if err := handlers.RegisterRoutes(baseCtx, router, db, cfg, logger); err != nil {
+		logger.Error("failed to register routes", slog.Any("error", err))
+		os.Exit(1)
+	}
 
 	// Create HTTP server
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler: router,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Server starting on port %d", cfg.Server.Port)
+		logger.Info("server starting", slog.Int("port", cfg.Server.Port))
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			logger.Error("failed to start server", slog.Any("error", err))
+			os.Exit(1)
 		}
 	}()
 
@@ -77,16 +102,19 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	logger.Info("shutting down server")
 
 	// Create a deadline for server shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Attempt graceful shutdown
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	// Attempt graceful shutdown, then cancel requests that outlived the grace period
+	err = server.Shutdown(ctx)
+	cancelBase()
+	if err != nil {
+		logger.Error("server forced to shutdown", slog.Any("error", err))
+		os.Exit(1)
 	}
 
-	log.Println("Server exited properly")
+	logger.Info("server exited properly")
 }
@@ -0,0 +1,120 @@
+// Command rotatekeys re-encrypts every hospital's patient PII and staff TOTP secrets
+// under the current key-encryption key and backfills blind indexes, including for rows written before
+// encryption was enabled. With -add-key it first adds a new key to the local key file
+// and makes it current, creating the file if it doesn't exist.
+//
+// Old keys must stay in the key file until a run has completed without errors;
+// after that they can be removed.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/database"
+	"github.com/DingDong039/hms/internal/encryption"
+	"github.com/DingDong039/hms/internal/repositories"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	addKey := flag.Bool("add-key", false, "add a new key to the key file and make it current")
+	reencrypt := flag.Bool("reencrypt", true, "re-encrypt existing rows under the current key")
+	batchSize := flag.Int("batch-size", 100, "rows re-encrypted per transaction")
+	flag.Parse()
+
+	if *batchSize < 1 {
+		log.Fatalf("-batch-size must be at least 1")
+	}
+
+	// Load environment variables
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(); err != nil {
+			log.Printf("Warning: failed to load .env: %v", err)
+		}
+	}
+
+	// Initialize configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Route log output through the redacting application logger
+	logger := utils.NewLogger(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	slog.SetDefault(logger)
+
+	if *addKey {
+		keyID, err := encryption.AddLocalKey(cfg.Encryption.KeyFile)
+		if err != nil {
+			log.Fatalf("Failed to add key: %v", err)
+		}
+		log.Printf("Added key %s to %s", keyID, cfg.Encryption.KeyFile)
+	}
+
+	if !*reencrypt {
+		return
+	}
+
+	keyProvider, err := encryption.LoadLocalKeyProvider(cfg.Encryption.KeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load encryption keys: %v", err)
+	}
+	encryptor := encryption.NewFieldEncryptor(keyProvider)
+
+	// Initialize database connection
+	db, err := database.NewConnection(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	hospitalRepo := repositories.NewHospitalRepository(db, logger)
+	patientRepo := repositories.NewPatientRepository(db, encryptor, logger)
+	mergeRepo := repositories.NewPatientMergeRepository(db, encryptor, logger)
+	mfaRepo := repositories.NewMFARepository(db, encryptor, logger)
+
+	ctx := context.Background()
+	hospitals, err := hospitalRepo.FindAll(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list hospitals: %v", err)
+	}
+
+	failed := false
+	for _, hospital := range hospitals {
+		hospitalCtx := utils.WithHospitalID(ctx, hospital.ID)
+
+		patients, err := patientRepo.Reencrypt(hospitalCtx, *batchSize)
+		if err != nil {
+			log.Printf("Failed to re-encrypt patients in %s after %d rows: %v", hospital.Code, patients, err)
+			failed = true
+			continue
+		}
+
+		links, err := mergeRepo.ReencryptLinks(hospitalCtx, *batchSize)
+		if err != nil {
+			log.Printf("Failed to re-encrypt merged patient snapshots in %s after %d rows: %v", hospital.Code, links, err)
+			failed = true
+			continue
+		}
+
+		secrets, err := mfaRepo.Reencrypt(hospitalCtx, *batchSize)
+		if err != nil {
+			log.Printf("Failed to re-encrypt TOTP secrets in %s after %d rows: %v", hospital.Code, secrets, err)
+			failed = true
+			continue
+		}
+
+		log.Printf("Re-encrypted %d patients, %d merged patient snapshots and %d TOTP secrets in %s under key %s",
+			patients, links, secrets, hospital.Code, keyProvider.CurrentKeyID())
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -2,8 +2,11 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration for the application
@@ -13,11 +16,23 @@ type Config struct {
 	Database    DatabaseConfig
 	JWT         JWTConfig
 	HospitalAPI HospitalAPIConfig
+	Tenancy     TenancyConfig
+	PatientSync PatientSyncConfig
+	Encryption  EncryptionConfig
+	Login       LoginProtectionConfig
+	Password    PasswordPolicyConfig
+	MFA         MFAConfig
+	Staff       StaffConfig
+	OIDC        OIDCConfig
+	APIClients  APIClientConfig
+	RateLimit   RateLimitConfig
+	Log         LogConfig
 }
 
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
-	Port int
+	Port           int
+	RequestTimeout time.Duration // Deadline for handling one request, including upstream calls; 0 disables it
 }
 
 // DatabaseConfig holds database connection configuration
@@ -33,13 +48,167 @@ type DatabaseConfig struct {
 
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
-	Secret     string
-	ExpireTime int // in hours
+	KeyFile         string // Key file holding the RS256 or EdDSA keys tokens are signed and verified with
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
 }
 
 // HospitalAPIConfig holds configuration for external hospital APIs
 type HospitalAPIConfig struct {
-	HospitalABaseURL string
+	Mode      string // "live" calls the configured hospitals, "mock" serves canned data
+	Hospitals []HospitalEndpointConfig
+}
+
+// HospitalEndpointConfig holds connection settings for one upstream hospital API
+type HospitalEndpointConfig struct {
+	Name      string
+	BaseURL   string
+	AuthType  string // "none", "api_key" or "bearer"
+	AuthToken string
+	Timeout   time.Duration
+	CacheTTL  time.Duration // How long a patient cached from this hospital is served before it is re-fetched
+	Retry     RetryConfig
+	Breaker   CircuitBreakerConfig
+}
+
+// RetryConfig holds the retry policy for idempotent upstream calls.
+// Delays grow exponentially from BaseDelay up to MaxDelay, with full jitter.
+type RetryConfig struct {
+	MaxRetries int // Retries after the first attempt; 0 disables retries
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// CircuitBreakerConfig holds the settings of an upstream's circuit breaker
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // Consecutive failures that open the circuit; 0 disables the breaker
+	Cooldown         time.Duration // How long the circuit stays open before a trial call is let through
+}
+
+// TenancyConfig holds configuration for serving several hospitals from one deployment
+type TenancyConfig struct {
+	DefaultHospitalCode string // Used when a login or staff creation request names no hospital
+}
+
+// PatientSyncConfig holds configuration for refreshing patients cached from upstream hospitals
+type PatientSyncConfig struct {
+	Interval  time.Duration // How often the background sync runs; 0 disables it and stale records are only refreshed on read
+	BatchSize int           // Stale records refreshed per hospital per run
+}
+
+// EncryptionConfig holds configuration for encrypting patient PII at rest
+type EncryptionConfig struct {
+	KeyFile string // Local key file holding the key-encryption keys and the blind index key
+}
+
+// LoginProtectionConfig holds the brute-force protection settings of staff login
+type LoginProtectionConfig struct {
+	MaxFailedAttempts   int           // Consecutive failed logins that lock an account; 0 disables lockout
+	LockoutDuration     time.Duration // How long a locked account stays locked
+	IPMaxFailedAttempts int           // Failed logins from one IP address within IPWindow after which it is refused; 0 disables the limit
+	IPWindow            time.Duration
+	FailureDelay        time.Duration // Delay before answering the first failed login, doubled for each further failure
+	MaxFailureDelay     time.Duration
+}
+
+// PasswordPolicyConfig holds the rules staff passwords must follow
+type PasswordPolicyConfig struct {
+	MinLength           int
+	MinCharacterClasses int           // Of lowercase, uppercase, digits and symbols
+	HistorySize         int           // Previous passwords, besides the current one, that can't be reused
+	ResetTokenTTL       time.Duration // How long an admin-issued password reset token is valid
+}
+
+// StaffConfig holds the settings of staff account management
+type StaffConfig struct {
+	InviteTTL time.Duration // How long an invite can be redeemed to create an account
+}
+
+// APIClientConfig holds the settings of API keys issued to integration clients
+type APIClientConfig struct {
+	MaxKeyTTL time.Duration // How long an API key lasts at most, and by default
+}
+
+// RateLimitConfig holds the settings of per-caller request rate limiting
+type RateLimitConfig struct {
+	Backend     string                   // "memory", or "postgres" to share limits between instances
+	Default     RateLimitRule            // Applies to route groups without a rule of their own
+	Groups      map[string]RateLimitRule // Keyed by route group, the first path segment after the API version
+	IdleTimeout time.Duration            // How long an unused bucket is kept; longer than any bucket takes to refill
+}
+
+// RateLimitRule is a token bucket limit: a caller may make Burst requests at once and
+// PerMinute requests a minute after that. A zero PerMinute turns the limit off.
+type RateLimitRule struct {
+	PerMinute int
+	Burst     int
+}
+
+// Rate returns how many requests a second the rule allows once the burst is used up
+func (r RateLimitRule) Rate() float64 {
+	return float64(r.PerMinute) / 60
+}
+
+// RuleFor returns the rule of a route group
+func (c RateLimitConfig) RuleFor(group string) RateLimitRule {
+	if rule, ok := c.Groups[group]; ok {
+		return rule
+	}
+	return c.Default
+}
+
+// OIDCConfig holds the settings of single sign-on through the hospitals' OpenID Connect
+// identity providers
+type OIDCConfig struct {
+	StateTTL  time.Duration // How long a sign-in started at an identity provider can be completed
+	Providers []OIDCProviderConfig
+}
+
+// OIDCProviderConfig holds the settings of one hospital's identity provider
+type OIDCProviderConfig struct {
+	HospitalCode  string
+	IssuerURL     string
+	ClientID      string
+	ClientSecret  string // Empty for a public client, which relies on PKCE alone
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string            // ID token claim used as the HMS username
+	GroupsClaim   string            // ID token claim listing the user's groups
+	RoleMappings  []OIDCRoleMapping // In priority order: the first mapping matching one of the user's groups wins
+	DefaultRole   string            // Role of users no mapping matches; empty refuses them
+	AutoProvision bool              // Create staff on their first sign-in
+	LinkExisting  bool              // Link a first sign-in to an existing staff member with the same username
+	Timeout       time.Duration
+}
+
+// OIDCRoleMapping grants an HMS role to members of an identity provider group
+type OIDCRoleMapping struct {
+	Group string
+	Role  string
+}
+
+// MFAConfig holds the settings of TOTP two-factor authentication
+type MFAConfig struct {
+	Issuer            string        // Shown by authenticator apps next to the account
+	RequiredRoles     []string      // Roles that must enroll before they can use the API
+	ChallengeTTL      time.Duration // How long the second step of a login may take
+	RecoveryCodeCount int
+}
+
+// RequiredFor reports whether staff with the given role must use two-factor authentication
+func (c MFAConfig) RequiredFor(role string) bool {
+	for _, required := range c.RequiredRoles {
+		if required == role {
+			return true
+		}
+	}
+	return false
+}
+
+// LogConfig holds the settings of the application logger
+type LogConfig struct {
+	Level  slog.Level // Records below this level are dropped
+	Format string     // "json" or "text"
 }
 
 // Load reads configuration from environment variables
@@ -54,9 +223,80 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid database port: %v", err)
 	}
 
-	jwtExpireTime, err := strconv.Atoi(getEnv("JWT_EXPIRE_TIME", "24"))
+	requestTimeout, err := getEnvDuration("SERVER_REQUEST_TIMEOUT", "30s")
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens can't be signed without keys; JWT_SECRET is no longer supported
+	jwtKeyFile := getEnv("JWT_KEY_FILE", "./keys/jwt.json")
+	if jwtKeyFile == "" {
+		return nil, fmt.Errorf("JWT_KEY_FILE is required")
+	}
+
+	accessTokenTTL, err := time.ParseDuration(getEnv("JWT_ACCESS_TOKEN_TTL", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT access token TTL: %v", err)
+	}
+
+	refreshTokenTTL, err := time.ParseDuration(getEnv("JWT_REFRESH_TOKEN_TTL", "168h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT refresh token TTL: %v", err)
+	}
+
+	syncInterval, err := getEnvDuration("PATIENT_SYNC_INTERVAL", "0")
+	if err != nil {
+		return nil, err
+	}
+
+	syncBatchSize, err := getEnvInt("PATIENT_SYNC_BATCH_SIZE", "100")
+	if err != nil {
+		return nil, err
+	}
+
+	login, err := loadLoginProtection()
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := loadPasswordPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := loadMFA()
+	if err != nil {
+		return nil, err
+	}
+
+	inviteTTL, err := getEnvDuration("STAFF_INVITE_TTL", "72h")
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeyTTL, err := getEnvDuration("API_CLIENT_KEY_TTL", "8760h")
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimit, err := loadRateLimit()
+	if err != nil {
+		return nil, err
+	}
+
+	logConfig, err := loadLog()
+	if err != nil {
+		return nil, err
+	}
+
+	hospitalAPIs, err := loadHospitalEndpoints(getEnv("HOSPITAL_APIS", "hospital-a"))
 	if err != nil {
-		return nil, fmt.Errorf("invalid JWT expire time: %v", err)
+		return nil, err
+	}
+
+	oidc, err := loadOIDC()
+	if err != nil {
+		return nil, err
 	}
 
 	dbHost := getEnv("DB_HOST", "localhost")
@@ -72,7 +312,8 @@ func Load() (*Config, error) {
 	return &Config{
 		Environment: getEnv("ENVIRONMENT", "development"),
 		Server: ServerConfig{
-			Port: port,
+			Port:           port,
+			RequestTimeout: requestTimeout,
 		},
 		Database: DatabaseConfig{
 			Host:     dbHost,
@@ -84,15 +325,293 @@ func Load() (*Config, error) {
 			URL:      dbURL,
 		},
 		JWT: JWTConfig{
-			Secret:     getEnv("JWT_SECRET", ""),
-			ExpireTime: jwtExpireTime,
+			KeyFile:         jwtKeyFile,
+			AccessTokenTTL:  accessTokenTTL,
+			RefreshTokenTTL: refreshTokenTTL,
 		},
 		HospitalAPI: HospitalAPIConfig{
-			HospitalABaseURL: getEnv("HOSPITAL_A_BASE_URL", "https://hospital-a.api.co.th"),
+			Mode:      getEnv("HOSPITAL_API_MODE", "mock"),
+			Hospitals: hospitalAPIs,
+		},
+		Tenancy: TenancyConfig{
+			DefaultHospitalCode: getEnv("DEFAULT_HOSPITAL_CODE", "hospital-a"),
+		},
+		PatientSync: PatientSyncConfig{
+			Interval:  syncInterval,
+			BatchSize: syncBatchSize,
 		},
+		Encryption: EncryptionConfig{
+			KeyFile: getEnv("ENCRYPTION_KEY_FILE", "./keys/encryption.json"),
+		},
+		Login:    login,
+		Password: password,
+		MFA:      mfa,
+		Staff: StaffConfig{
+			InviteTTL: inviteTTL,
+		},
+		OIDC: oidc,
+		APIClients: APIClientConfig{
+			MaxKeyTTL: apiKeyTTL,
+		},
+		RateLimit: rateLimit,
+		Log:       logConfig,
 	}, nil
 }
 
+// loadPasswordPolicy reads the rules staff passwords must follow
+func loadPasswordPolicy() (PasswordPolicyConfig, error) {
+	var policy PasswordPolicyConfig
+	var err error
+	if policy.MinLength, err = getEnvInt("PASSWORD_MIN_LENGTH", "12"); err != nil {
+		return policy, err
+	}
+	if policy.MinCharacterClasses, err = getEnvInt("PASSWORD_MIN_CHARACTER_CLASSES", "3"); err != nil {
+		return policy, err
+	}
+	if policy.HistorySize, err = getEnvInt("PASSWORD_HISTORY_SIZE", "5"); err != nil {
+		return policy, err
+	}
+	if policy.ResetTokenTTL, err = getEnvDuration("PASSWORD_RESET_TOKEN_TTL", "24h"); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
+// loadMFA reads the settings of TOTP two-factor authentication
+func loadMFA() (MFAConfig, error) {
+	mfa := MFAConfig{
+		Issuer: getEnv("MFA_ISSUER", "HMS"),
+	}
+	for _, role := range strings.Split(getEnv("MFA_REQUIRED_ROLES", "admin"), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			mfa.RequiredRoles = append(mfa.RequiredRoles, role)
+		}
+	}
+
+	var err error
+	if mfa.ChallengeTTL, err = getEnvDuration("MFA_CHALLENGE_TTL", "5m"); err != nil {
+		return mfa, err
+	}
+	if mfa.RecoveryCodeCount, err = getEnvInt("MFA_RECOVERY_CODE_COUNT", "10"); err != nil {
+		return mfa, err
+	}
+	return mfa, nil
+}
+
+// loadOIDC reads the settings of the identity provider of each hospital named in OIDC_HOSPITALS.
+// Settings for a hospital are read from variables prefixed with its upper-cased code,
+// e.g. "hospital-a" reads HOSPITAL_A_OIDC_ISSUER_URL, HOSPITAL_A_OIDC_CLIENT_ID,
+// HOSPITAL_A_OIDC_CLIENT_SECRET, HOSPITAL_A_OIDC_REDIRECT_URL, HOSPITAL_A_OIDC_SCOPES,
+// HOSPITAL_A_OIDC_USERNAME_CLAIM, HOSPITAL_A_OIDC_GROUPS_CLAIM, HOSPITAL_A_OIDC_ROLE_MAPPING,
+// HOSPITAL_A_OIDC_DEFAULT_ROLE, HOSPITAL_A_OIDC_AUTO_PROVISION, HOSPITAL_A_OIDC_LINK_EXISTING
+// and HOSPITAL_A_OIDC_TIMEOUT.
+func loadOIDC() (OIDCConfig, error) {
+	var oidc OIDCConfig
+	var err error
+	if oidc.StateTTL, err = getEnvDuration("OIDC_STATE_TTL", "10m"); err != nil {
+		return oidc, err
+	}
+
+	for _, code := range strings.Split(getEnv("OIDC_HOSPITALS", ""), ",") {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+
+		prefix := strings.ToUpper(strings.ReplaceAll(code, "-", "_")) + "_OIDC"
+
+		provider := OIDCProviderConfig{
+			HospitalCode:  code,
+			IssuerURL:     strings.TrimSuffix(getEnv(prefix+"_ISSUER_URL", ""), "/"),
+			ClientID:      getEnv(prefix+"_CLIENT_ID", ""),
+			ClientSecret:  getEnv(prefix+"_CLIENT_SECRET", ""),
+			RedirectURL:   getEnv(prefix+"_REDIRECT_URL", ""),
+			Scopes:        strings.Fields(getEnv(prefix+"_SCOPES", "openid profile email")),
+			UsernameClaim: getEnv(prefix+"_USERNAME_CLAIM", "preferred_username"),
+			GroupsClaim:   getEnv(prefix+"_GROUPS_CLAIM", "groups"),
+			DefaultRole:   getEnv(prefix+"_DEFAULT_ROLE", ""),
+		}
+		if provider.IssuerURL == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return oidc, fmt.Errorf("%s_ISSUER_URL, %s_CLIENT_ID and %s_REDIRECT_URL are required", prefix, prefix, prefix)
+		}
+
+		for _, entry := range strings.Split(getEnv(prefix+"_ROLE_MAPPING", ""), ",") {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+			group, role, ok := strings.Cut(entry, "=")
+			if !ok || strings.TrimSpace(group) == "" || strings.TrimSpace(role) == "" {
+				return oidc, fmt.Errorf("invalid %s_ROLE_MAPPING entry %q, expected group=role", prefix, entry)
+			}
+			provider.RoleMappings = append(provider.RoleMappings, OIDCRoleMapping{
+				Group: strings.TrimSpace(group),
+				Role:  strings.TrimSpace(role),
+			})
+		}
+
+		if provider.AutoProvision, err = getEnvBool(prefix+"_AUTO_PROVISION", "true"); err != nil {
+			return oidc, err
+		}
+		if provider.LinkExisting, err = getEnvBool(prefix+"_LINK_EXISTING", "false"); err != nil {
+			return oidc, err
+		}
+		if provider.Timeout, err = getEnvDuration(prefix+"_TIMEOUT", "10s"); err != nil {
+			return oidc, err
+		}
+
+		oidc.Providers = append(oidc.Providers, provider)
+	}
+	return oidc, nil
+}
+
+// defaultRateLimitGroups are the limits of route groups named in RATE_LIMIT_GROUPS that
+// don't set their own: public auth routes and patient lookups get tighter limits
+var defaultRateLimitGroups = map[string]RateLimitRule{
+	"auth":     {PerMinute: 30, Burst: 10},
+	"patients": {PerMinute: 60, Burst: 20},
+}
+
+// loadLog reads the level and format of the application logger
+func loadLog() (LogConfig, error) {
+	logConfig := LogConfig{Format: getEnv("LOG_FORMAT", "json")}
+	if logConfig.Format != "json" && logConfig.Format != "text" {
+		return logConfig, fmt.Errorf("invalid LOG_FORMAT %q, expected json or text", logConfig.Format)
+	}
+	if err := logConfig.Level.UnmarshalText([]byte(getEnv("LOG_LEVEL", "info"))); err != nil {
+		return logConfig, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+	return logConfig, nil
+}
+
+// loadRateLimit reads the request rate limits. Route groups named in RATE_LIMIT_GROUPS
+// read their limits from variables prefixed with the upper-cased group, e.g. "patients"
+// reads RATE_LIMIT_PATIENTS_PER_MINUTE and RATE_LIMIT_PATIENTS_BURST.
+func loadRateLimit() (RateLimitConfig, error) {
+	rateLimit := RateLimitConfig{
+		Backend: getEnv("RATE_LIMIT_BACKEND", "memory"),
+		Groups:  map[string]RateLimitRule{},
+	}
+	if rateLimit.Backend != "memory" && rateLimit.Backend != "postgres" {
+		return rateLimit, fmt.Errorf("invalid RATE_LIMIT_BACKEND %q, expected memory or postgres", rateLimit.Backend)
+	}
+
+	var err error
+	if rateLimit.IdleTimeout, err = getEnvDuration("RATE_LIMIT_IDLE_TIMEOUT", "10m"); err != nil {
+		return rateLimit, err
+	}
+	if rateLimit.Default, err = loadRateLimitRule("RATE_LIMIT", RateLimitRule{PerMinute: 300, Burst: 60}); err != nil {
+		return rateLimit, err
+	}
+
+	for _, group := range strings.Split(getEnv("RATE_LIMIT_GROUPS", "auth,patients"), ",") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+
+		fallback, ok := defaultRateLimitGroups[group]
+		if !ok {
+			fallback = rateLimit.Default
+		}
+		prefix := "RATE_LIMIT_" + strings.ToUpper(strings.ReplaceAll(group, "-", "_"))
+		if rateLimit.Groups[group], err = loadRateLimitRule(prefix, fallback); err != nil {
+			return rateLimit, err
+		}
+	}
+	return rateLimit, nil
+}
+
+// loadRateLimitRule reads the <prefix>_PER_MINUTE and <prefix>_BURST variables of a rate limit
+func loadRateLimitRule(prefix string, fallback RateLimitRule) (RateLimitRule, error) {
+	var rule RateLimitRule
+	var err error
+	if rule.PerMinute, err = getEnvInt(prefix+"_PER_MINUTE", strconv.Itoa(fallback.PerMinute)); err != nil {
+		return rule, err
+	}
+	if rule.Burst, err = getEnvInt(prefix+"_BURST", strconv.Itoa(fallback.Burst)); err != nil {
+		return rule, err
+	}
+	if rule.PerMinute < 0 || (rule.PerMinute > 0 && rule.Burst < 1) {
+		return rule, fmt.Errorf("invalid %s_PER_MINUTE or %s_BURST: the burst must be at least 1", prefix, prefix)
+	}
+	return rule, nil
+}
+
+// loadLoginProtection reads the brute-force protection settings of staff login
+func loadLoginProtection() (LoginProtectionConfig, error) {
+	var login LoginProtectionConfig
+	var err error
+	if login.MaxFailedAttempts, err = getEnvInt("LOGIN_MAX_FAILED_ATTEMPTS", "5"); err != nil {
+		return login, err
+	}
+	if login.LockoutDuration, err = getEnvDuration("LOGIN_LOCKOUT_DURATION", "15m"); err != nil {
+		return login, err
+	}
+	if login.IPMaxFailedAttempts, err = getEnvInt("LOGIN_IP_MAX_FAILED_ATTEMPTS", "20"); err != nil {
+		return login, err
+	}
+	if login.IPWindow, err = getEnvDuration("LOGIN_IP_WINDOW", "15m"); err != nil {
+		return login, err
+	}
+	if login.FailureDelay, err = getEnvDuration("LOGIN_FAILURE_DELAY", "200ms"); err != nil {
+		return login, err
+	}
+	if login.MaxFailureDelay, err = getEnvDuration("LOGIN_MAX_FAILURE_DELAY", "3s"); err != nil {
+		return login, err
+	}
+	return login, nil
+}
+
+// loadHospitalEndpoints reads the settings of each hospital named in a comma-separated list.
+// Settings for a hospital are read from variables prefixed with its upper-cased name,
+// e.g. "hospital-a" reads HOSPITAL_A_BASE_URL, HOSPITAL_A_AUTH_TYPE, HOSPITAL_A_AUTH_TOKEN,
+// HOSPITAL_A_TIMEOUT, HOSPITAL_A_CACHE_TTL, HOSPITAL_A_MAX_RETRIES, HOSPITAL_A_RETRY_BASE_DELAY,
+// HOSPITAL_A_RETRY_MAX_DELAY, HOSPITAL_A_BREAKER_FAILURE_THRESHOLD and HOSPITAL_A_BREAKER_COOLDOWN.
+func loadHospitalEndpoints(names string) ([]HospitalEndpointConfig, error) {
+	var endpoints []HospitalEndpointConfig
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+
+		endpoint := HospitalEndpointConfig{
+			Name:      name,
+			BaseURL:   getEnv(prefix+"_BASE_URL", ""),
+			AuthType:  getEnv(prefix+"_AUTH_TYPE", "none"),
+			AuthToken: getEnv(prefix+"_AUTH_TOKEN", ""),
+		}
+
+		var err error
+		if endpoint.Timeout, err = getEnvDuration(prefix+"_TIMEOUT", "10s"); err != nil {
+			return nil, err
+		}
+		if endpoint.CacheTTL, err = getEnvDuration(prefix+"_CACHE_TTL", "24h"); err != nil {
+			return nil, err
+		}
+		if endpoint.Retry.MaxRetries, err = getEnvInt(prefix+"_MAX_RETRIES", "2"); err != nil {
+			return nil, err
+		}
+		if endpoint.Retry.BaseDelay, err = getEnvDuration(prefix+"_RETRY_BASE_DELAY", "100ms"); err != nil {
+			return nil, err
+		}
+		if endpoint.Retry.MaxDelay, err = getEnvDuration(prefix+"_RETRY_MAX_DELAY", "2s"); err != nil {
+			return nil, err
+		}
+		if endpoint.Breaker.FailureThreshold, err = getEnvInt(prefix+"_BREAKER_FAILURE_THRESHOLD", "5"); err != nil {
+			return nil, err
+		}
+		if endpoint.Breaker.Cooldown, err = getEnvDuration(prefix+"_BREAKER_COOLDOWN", "30s"); err != nil {
+			return nil, err
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
 // getEnv reads an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -100,3 +619,30 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvDuration reads an environment variable as a duration, falling back to a default value
+func getEnvDuration(key, defaultValue string) (time.Duration, error) {
+	value, err := time.ParseDuration(getEnv(key, defaultValue))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return value, nil
+}
+
+// getEnvInt reads an environment variable as an integer, falling back to a default value
+func getEnvInt(key, defaultValue string) (int, error) {
+	value, err := strconv.Atoi(getEnv(key, defaultValue))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return value, nil
+}
+
+// getEnvBool reads an environment variable as a boolean, falling back to a default value
+func getEnvBool(key, defaultValue string) (bool, error) {
+	value, err := strconv.ParseBool(getEnv(key, defaultValue))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,178 @@
+package encryption
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ciphertextPrefix marks an encrypted value. Values without it are legacy
+// plaintext written before encryption was enabled.
+const ciphertextPrefix = "enc:v1:"
+
+// FieldEncryptor encrypts individual field values with envelope encryption.
+// An encrypted value has the form enc:v1:<key ID>:<wrapped data key>:<nonce and ciphertext>,
+// so it can be decrypted on its own, wherever it is copied to. The field name is bound
+// to the ciphertext, so a value can't be moved to another field unnoticed.
+type FieldEncryptor struct {
+	provider KeyProvider
+
+	mu        sync.Mutex
+	dataKey   *dataKey               // Data key new values are encrypted with
+	unwrapped map[string]cipher.AEAD // Data keys already unwrapped, by key ID and wrapped key
+}
+
+// dataKey is a data key along with its wrapped form
+type dataKey struct {
+	keyID   string
+	wrapped string
+	aead    cipher.AEAD
+}
+
+// NewFieldEncryptor creates a new FieldEncryptor
+func NewFieldEncryptor(provider KeyProvider) *FieldEncryptor {
+	return &FieldEncryptor{
+		provider:  provider,
+		unwrapped: make(map[string]cipher.AEAD),
+	}
+}
+
+// Encrypt encrypts a field value. Empty values stay empty.
+func (e *FieldEncryptor) Encrypt(ctx context.Context, field, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	key, err := e.currentDataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := seal(key.aead, []byte(plaintext), []byte(field))
+	if err != nil {
+		return "", err
+	}
+
+	return ciphertextPrefix + key.keyID + ":" + key.wrapped + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a field value produced by Encrypt. Empty and legacy plaintext
+// values are returned unchanged.
+func (e *FieldEncryptor) Decrypt(ctx context.Context, field, value string) (string, error) {
+	if !strings.HasPrefix(value, ciphertextPrefix) {
+		return value, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, ciphertextPrefix), ":", 3)
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+	keyID, wrapped, payload := parts[0], parts[1], parts[2]
+
+	aead, err := e.unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	plaintext, err := open(aead, sealed, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", field, err)
+	}
+
+	return string(plaintext), nil
+}
+
+// NeedsReencryption reports whether a stored value is plaintext or was encrypted
+// under a key other than the provider's current key
+func (e *FieldEncryptor) NeedsReencryption(value string) bool {
+	if value == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, ciphertextPrefix+e.provider.CurrentKeyID()+":")
+}
+
+// BlindIndex returns a keyed hash of a field value that can be stored alongside the
+// encrypted value and compared for equality. Empty values have an empty index.
+func (e *FieldEncryptor) BlindIndex(field, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, e.provider.BlindIndexKey())
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// currentDataKey returns the data key for the provider's current key, generating
+// and wrapping a new one when there is none yet or the current key has changed
+func (e *FieldEncryptor) currentDataKey(ctx context.Context) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	keyID := e.provider.CurrentKeyID()
+	if e.dataKey != nil && e.dataKey.keyID == keyID {
+		return e.dataKey, nil
+	}
+
+	plain := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, plain); err != nil {
+		return nil, err
+	}
+	wrapped, err := e.provider.WrapKey(ctx, keyID, plain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	aead, err := newGCM(plain)
+	if err != nil {
+		return nil, err
+	}
+
+	e.dataKey = &dataKey{keyID: keyID, wrapped: base64.StdEncoding.EncodeToString(wrapped), aead: aead}
+	e.unwrapped[keyID+":"+e.dataKey.wrapped] = aead
+	return e.dataKey, nil
+}
+
+// unwrap returns the cipher for a wrapped data key, asking the provider to unwrap it
+// the first time it is seen
+func (e *FieldEncryptor) unwrap(ctx context.Context, keyID, wrapped string) (cipher.AEAD, error) {
+	cacheKey := keyID + ":" + wrapped
+
+	e.mu.Lock()
+	aead, ok := e.unwrapped[cacheKey]
+	e.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	plain, err := e.provider.UnwrapKey(ctx, keyID, wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	if aead, err = newGCM(plain); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.unwrapped[cacheKey] = aead
+	e.mu.Unlock()
+	return aead, nil
+}
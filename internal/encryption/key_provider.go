@@ -0,0 +1,241 @@
+// Package encryption implements envelope encryption of sensitive fields.
+// Values are encrypted with a data key that is itself encrypted ("wrapped") by a
+// key-encryption key held by a KeyProvider, so key-encryption keys never leave
+// the provider and can be rotated by re-encrypting the data under a new one.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// keySize is the size in bytes of every AES-256 key used by this package
+const keySize = 32
+
+// KeyProvider holds the key-encryption keys used to wrap data keys.
+// A local key file is used in development; a KMS-backed provider can implement
+// the same interface.
+type KeyProvider interface {
+	// CurrentKeyID returns the ID of the key new data keys are wrapped with
+	CurrentKeyID() string
+	// WrapKey encrypts a data key with the given key-encryption key
+	WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key wrapped with the given key-encryption key
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+	// BlindIndexKey returns the key blind indexes are computed with. Changing it
+	// invalidates every stored blind index, so it is not rotated with the other keys.
+	BlindIndexKey() []byte
+}
+
+// LocalKeyFile is the JSON layout of a local key file. Keys are base64-encoded
+// 32-byte AES keys; old keys stay listed until no data is wrapped with them.
+type LocalKeyFile struct {
+	CurrentKeyID  string            `json:"current_key_id"`
+	Keys          map[string]string `json:"keys"`
+	BlindIndexKey string            `json:"blind_index_key"`
+}
+
+// LocalKeyProvider implements KeyProvider with keys read from a local file
+type LocalKeyProvider struct {
+	currentKeyID  string
+	keys          map[string]cipher.AEAD
+	blindIndexKey []byte
+}
+
+// LoadLocalKeyProvider reads a local key file and creates a LocalKeyProvider from it
+func LoadLocalKeyProvider(path string) (*LocalKeyProvider, error) {
+	file, err := readLocalKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalKeyProvider(file)
+}
+
+// NewLocalKeyProvider creates a LocalKeyProvider from the contents of a key file
+func NewLocalKeyProvider(file *LocalKeyFile) (*LocalKeyProvider, error) {
+	if _, ok := file.Keys[file.CurrentKeyID]; !ok {
+		return nil, fmt.Errorf("current key %q is not in the key file", file.CurrentKeyID)
+	}
+
+	blindIndexKey, err := decodeKey(file.BlindIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blind index key: %w", err)
+	}
+
+	provider := &LocalKeyProvider{
+		currentKeyID:  file.CurrentKeyID,
+		keys:          make(map[string]cipher.AEAD, len(file.Keys)),
+		blindIndexKey: blindIndexKey,
+	}
+	for id, encoded := range file.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+		if provider.keys[id], err = newGCM(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return provider, nil
+}
+
+// CurrentKeyID returns the ID of the key new data keys are wrapped with
+func (p *LocalKeyProvider) CurrentKeyID() string {
+	return p.currentKeyID
+}
+
+// WrapKey encrypts a data key with the given key-encryption key
+func (p *LocalKeyProvider) WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+	return seal(aead, dataKey, []byte(keyID))
+}
+
+// UnwrapKey decrypts a data key wrapped with the given key-encryption key
+func (p *LocalKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+// BlindIndexKey returns the key blind indexes are computed with
+func (p *LocalKeyProvider) BlindIndexKey() []byte {
+	return p.blindIndexKey
+}
+
+// AddLocalKey generates a new key-encryption key, makes it the current key of the
+// key file at path and returns its ID. The file, along with a blind index key, is
+// created if it doesn't exist. Existing keys are kept so data wrapped with them
+// can still be read until it has been re-encrypted.
+func AddLocalKey(path string) (string, error) {
+	file, err := readLocalKeyFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		blindIndexKey, err := generateKey()
+		if err != nil {
+			return "", err
+		}
+		file = &LocalKeyFile{Keys: map[string]string{}, BlindIndexKey: blindIndexKey}
+	} else if err != nil {
+		return "", err
+	}
+
+	key, err := generateKey()
+	if err != nil {
+		return "", err
+	}
+	// IDs sort by creation time; the random suffix keeps keys added in the same second apart
+	suffix := make([]byte, 4)
+	if _, err := io.ReadFull(rand.Reader, suffix); err != nil {
+		return "", err
+	}
+	id := time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+	if _, exists := file.Keys[id]; exists {
+		return "", fmt.Errorf("key %q already exists", id)
+	}
+	file.Keys[id] = key
+	file.CurrentKeyID = id
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so a failed write can't lose the existing keys
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// readLocalKeyFile reads and parses a local key file
+func readLocalKeyFile(path string) (*LocalKeyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	file := &LocalKeyFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("invalid key file: %w", err)
+	}
+	if file.Keys == nil {
+		file.Keys = map[string]string{}
+	}
+	return file, nil
+}
+
+// generateKey returns a new random base64-encoded key
+func generateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// decodeKey decodes a base64-encoded key and checks its size
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+// newGCM creates an AES-GCM cipher from a key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with a random nonce, which is prepended to the result
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the output of seal
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
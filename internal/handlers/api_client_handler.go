@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/DingDong039/hms/internal/middleware"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// APIClientHandler handles API client management requests
+type APIClientHandler struct {
+	apiClientService services.APIClientService
+	authService      services.AuthService
+}
+
+// NewAPIClientHandler creates a new APIClientHandler
+func NewAPIClientHandler(apiClientService services.APIClientService, authService services.AuthService) *APIClientHandler {
+	return &APIClientHandler{
+		apiClientService: apiClientService,
+		authService:      authService,
+	}
+}
+
+// RegisterRoutes registers the API client management routes
+func (h *APIClientHandler) RegisterRoutes(router *gin.RouterGroup) {
+	// Protected routes (require the api_client:manage permission; API keys can't manage keys)
+	clients := router.Group("/api-clients")
+	clients.Use(middleware.StaffAuthMiddleware(h.authService), middleware.RequirePermission(models.PermissionAPIClientManage))
+	{
+		clients.GET("", h.ListClients)
+		clients.GET("/:id", h.GetClient)
+		clients.POST("", h.CreateClient)
+		clients.POST("/:id/revoke", h.RevokeClient)
+	}
+}
+
+// ListClients handles requests listing the API clients of the caller's hospital
+func (h *APIClientHandler) ListClients(c *gin.Context) {
+	clients, err := h.apiClientService.ListClients(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(clients))
+}
+
+// GetClient handles requests retrieving an API client by ID
+func (h *APIClientHandler) GetClient(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	client, err := h.apiClientService.GetClient(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(client))
+}
+
+// CreateClient handles requests registering an API client and issuing its key
+func (h *APIClientHandler) CreateClient(c *gin.Context) {
+	var req models.APIClientCreateRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	created, err := h.apiClientService.CreateClient(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return the key; it is not stored and can't be retrieved again
+	c.JSON(http.StatusCreated, models.NewSuccessResponse(created))
+}
+
+// RevokeClient handles requests revoking an API client's key
+func (h *APIClientHandler) RevokeClient(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	client, err := h.apiClientService.RevokeClient(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(client))
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/DingDong039/hms/internal/middleware"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler handles audit trail requests
+type AuditHandler struct {
+	auditService services.AuditService
+	authService  services.AuthService
+}
+
+// NewAuditHandler creates a new AuditHandler
+func NewAuditHandler(auditService services.AuditService, authService services.AuthService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+		authService:  authService,
+	}
+}
+
+// RegisterRoutes registers the audit routes
+func (h *AuditHandler) RegisterRoutes(router *gin.RouterGroup) {
+	// Protected routes (require the audit:read permission)
+	audit := router.Group("/audit")
+	audit.Use(middleware.AuthMiddleware(h.authService))
+	{
+		audit.GET("", middleware.RequirePermission(models.PermissionAuditRead), h.QueryEvents)
+	}
+}
+
+// QueryEvents handles audit trail queries
+func (h *AuditHandler) QueryEvents(c *gin.Context) {
+	var req models.AuditQueryRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateQuery(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	// Query the audit trail
+	events, err := h.auditService.Query(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, http.StatusInternalServerError, "failed to query audit events"))
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(events))
+}
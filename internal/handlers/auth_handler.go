@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/DingDong039/hms/internal/middleware"
 	"github.com/DingDong039/hms/internal/models"
 	"github.com/DingDong039/hms/internal/services"
 	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
 	"github.com/gin-gonic/gin"
 )
 
@@ -25,61 +28,278 @@ func NewAuthHandler(authService services.AuthService) *AuthHandler {
 func (h *AuthHandler) RegisterRoutes(router *gin.RouterGroup) {
 	auth := router.Group("/auth")
 	{
-		auth.POST("/staff/create", h.CreateStaff)
 		auth.POST("/staff/login", h.Login)
+		auth.POST("/staff/login/mfa", h.VerifyMFA)
+		auth.GET("/oidc/login", h.StartOIDCLogin)
+		auth.GET("/oidc/callback", h.CompleteOIDCLogin)
+		auth.POST("/staff/password", middleware.PasswordChangeAuthMiddleware(h.authService), h.ChangePassword)
+		auth.POST("/staff/password/reset", h.ResetPassword)
+		auth.POST("/refresh", h.Refresh)
+		auth.POST("/logout", middleware.PasswordChangeAuthMiddleware(h.authService), h.Logout)
+		auth.POST("/mfa/enroll", middleware.MFAEnrollmentAuthMiddleware(h.authService), h.EnrollMFA)
+		auth.POST("/mfa/confirm", middleware.MFAEnrollmentAuthMiddleware(h.authService), h.ConfirmMFA)
+		auth.POST("/mfa/recovery-codes", middleware.StaffAuthMiddleware(h.authService), h.RegenerateRecoveryCodes)
+		auth.POST("/mfa/disable", middleware.StaffAuthMiddleware(h.authService), h.DisableMFA)
 	}
 }
 
-// CreateStaff handles staff creation requests
-func (h *AuthHandler) CreateStaff(c *gin.Context) {
-	var req models.StaffCreateRequest
+// Login handles staff login requests
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req models.StaffLoginRequest
 
 	// Validate request
 	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse(http.StatusBadRequest, "validation failed"))
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
 		return
 	}
 
-	// Create staff
-	staff, err := h.authService.CreateStaff(c.Request.Context(), req)
+	// Authenticate staff
+	response, err := h.authService.Login(c.Request.Context(), req)
 	if err != nil {
 		// Handle specific error types
-		switch err.(type) {
-		case *services.ValidationError:
-			c.JSON(http.StatusBadRequest, models.NewErrorResponse(http.StatusBadRequest, err.Error()))
+		switch {
+		case errors.As(err, new(*services.ValidationError)):
+			c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, err.Error()))
+		case errors.Is(err, apperrors.ErrTooManyRequests):
+			respondError(c, err)
 		default:
-			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(http.StatusInternalServerError, "failed to create staff"))
+			c.JSON(http.StatusUnauthorized, errorResponse(c, http.StatusUnauthorized, "invalid username or password"))
 		}
 		return
 	}
 
-	// Return success response
-	c.JSON(http.StatusCreated, models.NewSuccessResponse(staff))
+	// Return success response with JWT token, or with an MFA token for the second step
+	c.JSON(http.StatusOK, models.NewSuccessResponse(response))
 }
 
-// Login handles staff login requests
-func (h *AuthHandler) Login(c *gin.Context) {
-	var req models.StaffLoginRequest
+// VerifyMFA handles the second step of a two-step login
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req models.MFAVerifyRequest
 
 	// Validate request
 	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse(http.StatusBadRequest, "validation failed"))
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
 		return
 	}
 
-	// Authenticate staff
-	response, err := h.authService.Login(c.Request.Context(), req)
+	// Check the code
+	response, err := h.authService.VerifyMFA(c.Request.Context(), req)
 	if err != nil {
-		// Handle specific error types
-		switch err.(type) {
-		case *services.ValidationError:
-			c.JSON(http.StatusBadRequest, models.NewErrorResponse(http.StatusBadRequest, err.Error()))
-		default:
-			c.JSON(http.StatusUnauthorized, models.NewErrorResponse(http.StatusUnauthorized, "invalid username or password"))
+		if errors.Is(err, apperrors.ErrLocked) {
+			respondError(c, err)
+			return
 		}
+		c.JSON(http.StatusUnauthorized, errorResponse(c, http.StatusUnauthorized, "invalid or expired code"))
 		return
 	}
 
 	// Return success response with JWT token
 	c.JSON(http.StatusOK, models.NewSuccessResponse(response))
 }
+
+// StartOIDCLogin handles requests to sign in through a hospital's identity provider by
+// redirecting to its sign-in page
+func (h *AuthHandler) StartOIDCLogin(c *gin.Context) {
+	var req models.OIDCLoginRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateQuery(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	authURL, err := h.authService.StartOIDCLogin(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// CompleteOIDCLogin handles the identity provider's redirect back after signing in
+func (h *AuthHandler) CompleteOIDCLogin(c *gin.Context) {
+	var req models.OIDCCallbackRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateQuery(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	response, err := h.authService.CompleteOIDCLogin(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response with JWT token, or with an MFA token for the second step
+	c.JSON(http.StatusOK, models.NewSuccessResponse(response))
+}
+
+// Refresh handles access token refresh requests
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshTokenRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	// Rotate the refresh token
+	response, err := h.authService.Refresh(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, errorResponse(c, http.StatusUnauthorized, "invalid or expired refresh token"))
+		return
+	}
+
+	// Return success response with the new tokens
+	c.JSON(http.StatusOK, models.NewSuccessResponse(response))
+}
+
+// Logout handles logout requests by revoking the caller's session
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+
+	// The body is optional; an empty body logs out the current session only
+	if c.Request.ContentLength > 0 {
+		if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+			return
+		}
+	}
+
+	claims := c.MustGet("claims").(*utils.JWTClaims)
+
+	// Revoke the session
+	if err := h.authService.Logout(c.Request.Context(), claims, req); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, http.StatusInternalServerError, "failed to log out"))
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(gin.H{"logged_out": true}))
+}
+
+// ChangePassword handles requests from staff changing their own password
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req models.PasswordChangeRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	claims := c.MustGet("claims").(*utils.JWTClaims)
+
+	// Change the password; the caller's sessions are replaced by a new one
+	response, err := h.authService.ChangePassword(c.Request.Context(), claims, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response with the new tokens
+	c.JSON(http.StatusOK, models.NewSuccessResponse(response))
+}
+
+// ResetPassword handles requests setting a new password with a reset token
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.PasswordResetRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), req); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(gin.H{"password_reset": true}))
+}
+
+// EnrollMFA handles requests starting two-factor enrollment for the caller
+func (h *AuthHandler) EnrollMFA(c *gin.Context) {
+	claims := c.MustGet("claims").(*utils.JWTClaims)
+
+	response, err := h.authService.EnrollMFA(c.Request.Context(), claims)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return the secret and the URI to show as a QR code
+	c.JSON(http.StatusOK, models.NewSuccessResponse(response))
+}
+
+// ConfirmMFA handles requests enabling the caller's pending two-factor enrollment
+func (h *AuthHandler) ConfirmMFA(c *gin.Context) {
+	var req models.MFACodeRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	claims := c.MustGet("claims").(*utils.JWTClaims)
+
+	// Enable two-factor authentication; the caller's sessions are replaced by a new one
+	response, err := h.authService.ConfirmMFA(c.Request.Context(), claims, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return the recovery codes; they are not stored and can't be retrieved again
+	c.JSON(http.StatusOK, models.NewSuccessResponse(response))
+}
+
+// RegenerateRecoveryCodes handles requests replacing the caller's recovery codes
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.MFACodeRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	claims := c.MustGet("claims").(*utils.JWTClaims)
+
+	response, err := h.authService.RegenerateRecoveryCodes(c.Request.Context(), claims, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return the new recovery codes; they are not stored and can't be retrieved again
+	c.JSON(http.StatusOK, models.NewSuccessResponse(response))
+}
+
+// DisableMFA handles requests turning off the caller's two-factor authentication
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	var req models.MFACodeRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	claims := c.MustGet("claims").(*utils.JWTClaims)
+
+	if err := h.authService.DisableMFA(c.Request.Context(), claims, req); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(gin.H{"mfa_enabled": false}))
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// respondError writes the error response matching an application error's status code.
+// Server-side errors are reported with a generic message so internals don't leak to clients.
+func respondError(c *gin.Context, err error) {
+	var validationErr *services.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, validationErr.Error()))
+		return
+	}
+
+	var appErr *apperrors.AppError
+	if !errors.As(err, &appErr) {
+		c.JSON(http.StatusInternalServerError, errorResponse(c, http.StatusInternalServerError, "internal server error"))
+		return
+	}
+
+	message := appErr.Message
+	if appErr.StatusCode >= http.StatusInternalServerError {
+		message = http.StatusText(appErr.StatusCode)
+	}
+
+	c.JSON(appErr.StatusCode, errorResponse(c, appErr.StatusCode, message))
+}
+
+// parseIDParam reads a positive integer path parameter, writing a 400 response if it is invalid
+func parseIDParam(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "invalid "+name))
+		return 0, false
+	}
+	return id, true
+}
+
+// errorResponse builds an error response carrying the request's ID
+func errorResponse(c *gin.Context, code int, message string) models.APIResponse {
+	return models.NewErrorResponse(code, message).WithRequestID(utils.RequestIDFromContext(c.Request.Context()))
+}
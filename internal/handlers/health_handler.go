@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler handles health check requests
+type HealthHandler struct {
+	hospitalAPIs *services.HospitalAPIRegistry
+}
+
+// NewHealthHandler creates a new HealthHandler
+func NewHealthHandler(hospitalAPIs *services.HospitalAPIRegistry) *HealthHandler {
+	return &HealthHandler{
+		hospitalAPIs: hospitalAPIs,
+	}
+}
+
+// RegisterRoutes registers the health check routes
+func (h *HealthHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/health", h.Health)
+}
+
+// Health reports whether the API is running and the circuit breaker state of each upstream hospital.
+// The status is "degraded" while any upstream circuit is open; the API itself still serves requests.
+func (h *HealthHandler) Health(c *gin.Context) {
+	hospitalAPIs := h.hospitalAPIs.Health()
+
+	status := "ok"
+	for _, hospitalAPI := range hospitalAPIs {
+		if hospitalAPI.Circuit == models.CircuitOpen {
+			status = "degraded"
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse(gin.H{
+		"status":        status,
+		"hospital_apis": hospitalAPIs,
+	}))
+}
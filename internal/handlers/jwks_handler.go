@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// jwksMaxAge is how long clients may cache the key set. A new key should be published
+// at least this long before it is made current.
+const jwksMaxAge = "public, max-age=300"
+
+// JWKSHandler publishes the public keys access tokens are signed with, so partner
+// hospital systems can verify our tokens
+type JWKSHandler struct {
+	keys *utils.JWTKeySet
+}
+
+// NewJWKSHandler creates a new JWKSHandler
+func NewJWKSHandler(keys *utils.JWTKeySet) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+// RegisterRoutes registers the JWKS route. It belongs at the root of the server, where
+// clients look for well-known documents.
+func (h *JWKSHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/.well-known/jwks.json", h.GetJWKS)
+}
+
+// GetJWKS returns the JSON Web Key Set. It is served as a bare key set rather than in
+// the API response envelope, since JWT libraries read it directly.
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", jwksMaxAge)
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -28,9 +28,16 @@ func NewPatientHandler(patientService services.PatientService, authService servi
 func (h *PatientHandler) RegisterRoutes(router *gin.RouterGroup) {
 	// Protected routes (require authentication)
 	patients := router.Group("/patients")
-	patients.Use(middleware.AuthMiddleware(h.authService))
+	patients.Use(middleware.AuthMiddleware(h.authService), middleware.PIIAccess())
 	{
-		patients.POST("/search", h.SearchPatient)
+		patients.GET("", middleware.RequirePermission(models.PermissionPatientRead), h.QueryPatients)
+		patients.POST("/search/fuzzy", middleware.RequirePermission(models.PermissionPatientRead), h.FuzzySearchPatients)
+		patients.POST("/search", middleware.RequirePermission(models.PermissionPatientRead), h.SearchPatient)
+		patients.POST("", middleware.RequirePermission(models.PermissionPatientWrite), h.CreatePatient)
+		patients.GET("/:id", middleware.RequirePermission(models.PermissionPatientRead), h.GetPatient)
+		patients.PUT("/:id", middleware.RequirePermission(models.PermissionPatientWrite), h.UpdatePatient)
+		patients.PATCH("/:id", middleware.RequirePermission(models.PermissionPatientWrite), h.PatchPatient)
+		patients.DELETE("/:id", middleware.RequirePermission(models.PermissionPatientDelete), h.DeletePatient)
 	}
 }
 
@@ -40,18 +47,170 @@ func (h *PatientHandler) SearchPatient(c *gin.Context) {
 
 	// Validate request
 	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse(http.StatusBadRequest, "validation failed"))
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
 		return
 	}
 
 	// Search for patient
 	patient, err := h.patientService.SearchPatient(c.Request.Context(), req)
 	if err != nil {
-		// Handle specific error types
-		c.JSON(http.StatusNotFound, models.NewErrorResponse(http.StatusNotFound, "patient not found"))
+		respondError(c, err)
 		return
 	}
 
 	// Return success response
 	c.JSON(http.StatusOK, models.NewSuccessResponse(patient))
 }
+
+// QueryPatients handles multi-criteria patient searches
+func (h *PatientHandler) QueryPatients(c *gin.Context) {
+	var req models.PatientQueryRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateQuery(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = models.DefaultPatientQueryLimit
+	}
+
+	// Search patients
+	patients, total, err := h.patientService.QueryPatients(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewPaginatedResponse(patients, total, req.Limit, req.Offset))
+}
+
+// FuzzySearchPatients handles approximate patient name searches
+func (h *PatientHandler) FuzzySearchPatients(c *gin.Context) {
+	var req models.PatientFuzzySearchRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	// Search patients
+	matches, err := h.patientService.FuzzySearchPatients(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(matches))
+}
+
+// CreatePatient handles patient registration requests
+func (h *PatientHandler) CreatePatient(c *gin.Context) {
+	var req models.PatientCreateRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	// Create patient
+	patient, err := h.patientService.CreatePatient(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusCreated, models.NewSuccessResponse(patient))
+}
+
+// GetPatient handles requests for a single patient record
+func (h *PatientHandler) GetPatient(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	// Find patient
+	patient, err := h.patientService.GetPatient(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(patient))
+}
+
+// UpdatePatient handles requests replacing a patient record
+func (h *PatientHandler) UpdatePatient(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.PatientUpdateRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	// Update patient
+	patient, err := h.patientService.UpdatePatient(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(patient))
+}
+
+// PatchPatient handles requests changing some fields of a patient record
+func (h *PatientHandler) PatchPatient(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.PatientPatchRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	// Patch patient
+	patient, err := h.patientService.PatchPatient(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(patient))
+}
+
+// DeletePatient handles patient deletion requests
+func (h *PatientHandler) DeletePatient(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	// Delete patient
+	if err := h.patientService.DeletePatient(c.Request.Context(), id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(gin.H{"deleted": true}))
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/DingDong039/hms/internal/middleware"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// PatientMergeHandler handles duplicate patient review and merge requests
+type PatientMergeHandler struct {
+	mergeService services.PatientMergeService
+	authService  services.AuthService
+}
+
+// NewPatientMergeHandler creates a new PatientMergeHandler
+func NewPatientMergeHandler(mergeService services.PatientMergeService, authService services.AuthService) *PatientMergeHandler {
+	return &PatientMergeHandler{
+		mergeService: mergeService,
+		authService:  authService,
+	}
+}
+
+// RegisterRoutes registers the duplicate review and merge routes
+func (h *PatientMergeHandler) RegisterRoutes(router *gin.RouterGroup) {
+	patients := router.Group("/patients")
+	patients.Use(middleware.AuthMiddleware(h.authService), middleware.RequirePermission(models.PermissionPatientMerge), middleware.PIIAccess())
+	{
+		patients.GET("/duplicates", h.ListDuplicates)
+		patients.POST("/duplicates/detect", h.DetectDuplicates)
+		patients.POST("/duplicates/:id/dismiss", h.DismissDuplicate)
+		patients.POST("/merge", h.MergePatients)
+	}
+}
+
+// ListDuplicates handles requests listing duplicate candidates
+func (h *PatientMergeHandler) ListDuplicates(c *gin.Context) {
+	var req models.PatientDuplicateQueryRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateQuery(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	// List candidates
+	candidates, err := h.mergeService.ListDuplicates(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(candidates))
+}
+
+// DetectDuplicates handles requests to run duplicate detection now
+func (h *PatientMergeHandler) DetectDuplicates(c *gin.Context) {
+	detected, err := h.mergeService.DetectDuplicates(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(gin.H{"detected": detected}))
+}
+
+// DismissDuplicate handles requests marking a candidate as not a duplicate
+func (h *PatientMergeHandler) DismissDuplicate(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.mergeService.DismissDuplicate(c.Request.Context(), id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(gin.H{"dismissed": true}))
+}
+
+// MergePatients handles requests merging a duplicate patient into a survivor
+func (h *PatientMergeHandler) MergePatients(c *gin.Context) {
+	var req models.PatientMergeRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	// Merge patients
+	result, err := h.mergeService.MergePatients(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(result))
+}
@@ -1,40 +1,101 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
-	"net/http"
+	"fmt"
+	"log/slog"
 
 	"github.com/DingDong039/hms/internal/config"
-	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/encryption"
+	"github.com/DingDong039/hms/internal/middleware"
 	"github.com/DingDong039/hms/internal/repositories"
 	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
-// RegisterRoutes registers all API routes
-func RegisterRoutes(router *gin.Engine, db *sql.DB, cfg *config.Config) {
+// RegisterRoutes registers all API routes, wiring the logger into the repositories and services.
+// Background work the services need, such as the patient sync, runs until ctx is cancelled.
+func RegisterRoutes(ctx context.Context, router *gin.Engine, db *sql.DB, cfg *config.Config, logger *slog.Logger) error {
+	// Load the keys patient PII and TOTP secrets are encrypted with
+	keyProvider, err := encryption.LoadLocalKeyProvider(cfg.Encryption.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load encryption keys: %w", err)
+	}
+	encryptor := encryption.NewFieldEncryptor(keyProvider)
+
+	// Load the keys access tokens are signed with; there is no fallback to a shared secret
+	jwtKeys, err := utils.LoadJWTKeySet(cfg.JWT.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load JWT signing keys: %w", err)
+	}
+
 	// Create repositories
-	staffRepo := repositories.NewStaffRepository(db)
-	patientRepo := repositories.NewPatientRepository(db)
+	staffRepo := repositories.NewStaffRepository(db, logger)
+	patientRepo := repositories.NewPatientRepository(db, encryptor, logger)
+	roleRepo := repositories.NewRoleRepository(db, logger)
+	hospitalRepo := repositories.NewHospitalRepository(db, logger)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(db, logger)
+	auditRepo := repositories.NewAuditRepository(db, logger)
+	patientMergeRepo := repositories.NewPatientMergeRepository(db, encryptor, logger)
+	loginAttemptRepo := repositories.NewLoginAttemptRepository(db, logger)
+	passwordResetRepo := repositories.NewPasswordResetTokenRepository(db, logger)
+	mfaRepo := repositories.NewMFARepository(db, encryptor, logger)
+	staffInviteRepo := repositories.NewStaffInviteRepository(db, logger)
+	staffIdentityRepo := repositories.NewStaffIdentityRepository(db, logger)
+	oidcStateRepo := repositories.NewOIDCLoginStateRepository(db, logger)
+	apiClientRepo := repositories.NewAPIClientRepository(db, logger)
+	rateLimitRepo := repositories.NewRateLimitRepository(db, logger)
 
 	// Create services
-	hospitalAPIService := services.NewMockHospitalAAPIService() // Use mock for now
-	authService := services.NewAuthService(staffRepo, cfg)
-	patientService := services.NewPatientService(patientRepo, hospitalAPIService)
+	hospitalAPIs, err := services.NewHospitalAPIRegistryFromConfig(cfg.HospitalAPI)
+	if err != nil {
+		return err
+	}
+	auditService := services.NewAuditService(auditRepo)
+	oidcProviders := services.NewOIDCProviders(cfg.OIDC)
+	authService := services.NewAuthService(staffRepo, roleRepo, hospitalRepo, refreshTokenRepo, loginAttemptRepo, passwordResetRepo, mfaRepo, staffIdentityRepo, oidcStateRepo, apiClientRepo, oidcProviders, auditService, jwtKeys, cfg)
+	patientSyncService := services.NewPatientSyncService(patientRepo, hospitalRepo, hospitalAPIs, cfg, logger)
+	patientService := services.NewPatientService(patientRepo, hospitalAPIs, patientSyncService, auditService)
+	patientMergeService := services.NewPatientMergeService(patientMergeRepo, auditService)
+	staffService := services.NewStaffService(staffRepo, roleRepo, refreshTokenRepo, passwordResetRepo, mfaRepo, staffInviteRepo, auditService, cfg)
+	apiClientService := services.NewAPIClientService(apiClientRepo, auditService, cfg)
+	rateLimiter := services.NewRateLimiter(cfg.RateLimit, rateLimitRepo)
+
+	// Start background work
+	if cfg.PatientSync.Interval > 0 {
+		go patientSyncService.Run(ctx, cfg.PatientSync.Interval)
+	}
+	if cfg.RateLimit.IdleTimeout > 0 {
+		go services.RunRateLimitPruning(ctx, rateLimiter, cfg.RateLimit.IdleTimeout, logger)
+	}
 
 	// Create handlers
+	healthHandler := NewHealthHandler(hospitalAPIs)
+	jwksHandler := NewJWKSHandler(jwtKeys)
 	authHandler := NewAuthHandler(authService)
 	patientHandler := NewPatientHandler(patientService, authService)
+	patientMergeHandler := NewPatientMergeHandler(patientMergeService, authService)
+	auditHandler := NewAuditHandler(auditService, authService)
+	staffHandler := NewStaffHandler(staffService, authService)
+	apiClientHandler := NewAPIClientHandler(apiClientService, authService)
+
+	// Well-known documents live outside the API version
+	jwksHandler.RegisterRoutes(&router.RouterGroup)
 
 	// API version group
 	v1 := router.Group("/api/v1")
-
-	// Health check endpoint
-	v1.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, models.NewSuccessResponse(gin.H{"status": "ok"}))
-	})
+	v1.Use(middleware.RateLimit(rateLimiter, authService, cfg.RateLimit, logger))
 
 	// Register routes for each handler
+	healthHandler.RegisterRoutes(v1)
 	authHandler.RegisterRoutes(v1)
 	patientHandler.RegisterRoutes(v1)
+	patientMergeHandler.RegisterRoutes(v1)
+	auditHandler.RegisterRoutes(v1)
+	staffHandler.RegisterRoutes(v1)
+	apiClientHandler.RegisterRoutes(v1)
+
+	return nil
 }
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/DingDong039/hms/internal/middleware"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// StaffHandler handles staff management requests
+type StaffHandler struct {
+	staffService services.StaffService
+	authService  services.AuthService
+}
+
+// NewStaffHandler creates a new StaffHandler
+func NewStaffHandler(staffService services.StaffService, authService services.AuthService) *StaffHandler {
+	return &StaffHandler{
+		staffService: staffService,
+		authService:  authService,
+	}
+}
+
+// RegisterRoutes registers the staff management routes
+func (h *StaffHandler) RegisterRoutes(router *gin.RouterGroup) {
+	// Public route: new staff redeem their invite before they have an account
+	router.POST("/staff/invites/accept", h.AcceptInvite)
+
+	// Protected routes (viewing requires staff:read, changes require staff:manage)
+	read := middleware.RequirePermission(models.PermissionStaffRead)
+	manage := middleware.RequirePermission(models.PermissionStaffManage)
+	staff := router.Group("/staff")
+	staff.Use(middleware.AuthMiddleware(h.authService))
+	{
+		staff.GET("", read, h.ListStaff)
+		staff.GET("/:id", read, h.GetStaff)
+		staff.POST("", manage, h.CreateStaff)
+		staff.PATCH("/:id", manage, h.UpdateStaff)
+		staff.POST("/invites", manage, h.InviteStaff)
+		staff.POST("/:id/deactivate", manage, h.DeactivateStaff)
+		staff.POST("/:id/reactivate", manage, h.ReactivateStaff)
+		staff.POST("/:id/unlock", manage, h.UnlockStaff)
+		staff.POST("/:id/password-reset", manage, h.ResetPassword)
+		staff.POST("/:id/mfa-reset", manage, h.ResetMFA)
+	}
+}
+
+// ListStaff handles requests listing the staff of the caller's hospital
+func (h *StaffHandler) ListStaff(c *gin.Context) {
+	var req models.StaffQueryRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateQuery(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = models.DefaultStaffQueryLimit
+	}
+
+	staff, total, err := h.staffService.ListStaff(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewPaginatedResponse(staff, total, req.Limit, req.Offset))
+}
+
+// GetStaff handles requests retrieving a staff member by ID
+func (h *StaffHandler) GetStaff(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	staff, err := h.staffService.GetStaff(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(staff))
+}
+
+// CreateStaff handles requests creating a staff member with an initial password
+func (h *StaffHandler) CreateStaff(c *gin.Context) {
+	var req models.StaffCreateRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	staff, err := h.staffService.CreateStaff(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusCreated, models.NewSuccessResponse(staff))
+}
+
+// InviteStaff handles requests issuing an invite for a new staff member
+func (h *StaffHandler) InviteStaff(c *gin.Context) {
+	var req models.StaffInviteRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	invite, err := h.staffService.InviteStaff(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return the token; it is not stored and can't be retrieved again
+	c.JSON(http.StatusCreated, models.NewSuccessResponse(invite))
+}
+
+// AcceptInvite handles requests creating an account with an invite token
+func (h *StaffHandler) AcceptInvite(c *gin.Context) {
+	var req models.StaffInviteAcceptRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	staff, err := h.staffService.AcceptInvite(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusCreated, models.NewSuccessResponse(staff))
+}
+
+// UpdateStaff handles requests changing some fields of a staff member
+func (h *StaffHandler) UpdateStaff(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.StaffPatchRequest
+
+	// Validate request
+	if validationErrors := utils.ValidateRequest(c, &req); validationErrors != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(c, http.StatusBadRequest, "validation failed"))
+		return
+	}
+
+	staff, err := h.staffService.UpdateStaff(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(staff))
+}
+
+// DeactivateStaff handles requests deactivating a staff member
+func (h *StaffHandler) DeactivateStaff(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	staff, err := h.staffService.DeactivateStaff(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(staff))
+}
+
+// ReactivateStaff handles requests reactivating a deactivated staff member
+func (h *StaffHandler) ReactivateStaff(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	staff, err := h.staffService.ReactivateStaff(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(staff))
+}
+
+// UnlockStaff handles requests unlocking a staff member locked out after failed logins
+func (h *StaffHandler) UnlockStaff(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.staffService.UnlockStaff(c.Request.Context(), id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(gin.H{"unlocked": true}))
+}
+
+// ResetPassword handles requests issuing a password reset token for a staff member
+func (h *StaffHandler) ResetPassword(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	token, err := h.staffService.ResetPassword(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return the token; it is not stored and can't be retrieved again
+	c.JSON(http.StatusCreated, models.NewSuccessResponse(token))
+}
+
+// ResetMFA handles requests turning off two-factor authentication for a staff member
+// who lost their authenticator and recovery codes
+func (h *StaffHandler) ResetMFA(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.staffService.ResetMFA(c.Request.Context(), id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Return success response
+	c.JSON(http.StatusOK, models.NewSuccessResponse(gin.H{"mfa_reset": true}))
+}
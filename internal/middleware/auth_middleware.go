@@ -3,40 +3,150 @@ package middleware
 import (
 	"strings"
 
-	"github.com/DingDong039/hms/internal/models"
 	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware creates a middleware for JWT authentication
+// APIKeyHeader carries the key of an API client authenticating instead of a staff member
+const APIKeyHeader = "X-API-Key"
+
+// callerIdentityKey is the gin context key a request's checked credentials are kept under
+const callerIdentityKey = "callerIdentity"
+
+// callerIdentity is the outcome of checking a request's credentials
+type callerIdentity struct {
+	claims  *utils.JWTClaims // Nil when the credentials are missing or invalid
+	apiKey  bool             // The caller presented an API key rather than a token
+	message string           // Why the caller couldn't be identified
+}
+
+// AuthMiddleware creates a middleware for JWT authentication that also accepts API keys.
+// Tokens of staff who must change their password or enroll in two-factor
+// authentication are refused.
 func AuthMiddleware(authService services.AuthService) gin.HandlerFunc {
+	return authenticate(authService, false, false, true)
+}
+
+// StaffAuthMiddleware creates a middleware for JWT authentication that refuses API keys,
+// for the routes that act on the calling staff member's own account or manage access
+func StaffAuthMiddleware(authService services.AuthService) gin.HandlerFunc {
+	return authenticate(authService, false, false, false)
+}
+
+// PasswordChangeAuthMiddleware creates a middleware for JWT authentication that also
+// accepts tokens of staff who must change their password or enroll in two-factor
+// authentication, for the routes they need to change their password
+func PasswordChangeAuthMiddleware(authService services.AuthService) gin.HandlerFunc {
+	return authenticate(authService, true, true, false)
+}
+
+// MFAEnrollmentAuthMiddleware creates a middleware for JWT authentication that also
+// accepts tokens of staff who must enroll in two-factor authentication, for the routes
+// they need to enroll
+func MFAEnrollmentAuthMiddleware(authService services.AuthService) gin.HandlerFunc {
+	return authenticate(authService, false, true, false)
+}
+
+// authenticate validates the bearer token, or the API key where allowed, and identifies the caller
+func authenticate(authService services.AuthService, allowPasswordChange, allowMFAEnrollment, allowAPIKey bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get the Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(401, models.NewErrorResponse(401, "authorization header is required"))
+		// Refuse API keys where they can't be used, before looking them up
+		if !allowAPIKey && c.GetHeader("Authorization") == "" && c.GetHeader(APIKeyHeader) != "" {
+			c.AbortWithStatusJSON(403, errorResponse(c, 403, "API keys can't be used here"))
 			return
 		}
 
-		// Check if the header format is valid
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(401, models.NewErrorResponse(401, "invalid authorization header format"))
+		identity := identifyCaller(c, authService)
+		if identity.claims == nil {
+			c.AbortWithStatusJSON(401, errorResponse(c, 401, identity.message))
 			return
 		}
+		claims := identity.claims
 
-		// Extract the token
-		tokenString := parts[1]
+		// An API client has no staff ID; its scopes are the permissions in its claims
+		if identity.apiKey {
+			c.Set("apiClientID", claims.APIClientID)
+			c.Set("hospitalID", claims.HospitalID)
+			c.Set("claims", claims)
 
-		// Validate the token
-		claims, err := authService.ValidateToken(tokenString)
-		if err != nil {
-			c.AbortWithStatusJSON(401, models.NewErrorResponse(401, "invalid or expired token"))
+			// Scope every downstream query to the client's hospital and identify the client
+			ctx := utils.WithHospitalID(c.Request.Context(), claims.HospitalID)
+			ctx = utils.WithAPIClientID(ctx, claims.APIClientID)
+			c.Request = c.Request.WithContext(ctx)
+			c.Next()
+			return
+		}
+
+		if claims.PasswordChangeRequired && !allowPasswordChange {
+			c.AbortWithStatusJSON(403, errorResponse(c, 403, "password change required"))
+			return
+		}
+		if claims.MFAEnrollmentRequired && !allowMFAEnrollment {
+			c.AbortWithStatusJSON(403, errorResponse(c, 403, "two-factor enrollment required"))
 			return
 		}
 
 		// Set user information in the context
 		c.Set("userID", claims.UserID)
+		c.Set("role", claims.Role)
+		c.Set("hospitalID", claims.HospitalID)
+		c.Set("claims", claims)
+
+		// Scope every downstream query to the caller's hospital and identify the caller
+		ctx := utils.WithHospitalID(c.Request.Context(), claims.HospitalID)
+		ctx = utils.WithStaffID(ctx, claims.UserID)
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
+
+// hasCredentials reports whether the request carries a bearer token or an API key
+func hasCredentials(c *gin.Context) bool {
+	return c.GetHeader("Authorization") != "" || c.GetHeader(APIKeyHeader) != ""
+}
+
+// identifyCaller checks the request's credentials, once per request: middlewares that
+// need the caller before authentication, such as RateLimit, share the result with it
+func identifyCaller(c *gin.Context, authService services.AuthService) *callerIdentity {
+	if value, exists := c.Get(callerIdentityKey); exists {
+		if identity, ok := value.(*callerIdentity); ok {
+			return identity
+		}
+	}
+
+	identity := checkCredentials(c, authService)
+	c.Set(callerIdentityKey, identity)
+	return identity
+}
+
+// checkCredentials validates the bearer token, or the API key when there is no token
+func checkCredentials(c *gin.Context, authService services.AuthService) *callerIdentity {
+	// Get the Authorization header
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		key := c.GetHeader(APIKeyHeader)
+		if key == "" {
+			return &callerIdentity{message: "authorization header is required"}
+		}
+
+		claims, err := authService.AuthenticateAPIKey(c.Request.Context(), key)
+		if err != nil {
+			return &callerIdentity{apiKey: true, message: "invalid or expired API key"}
+		}
+		return &callerIdentity{claims: claims, apiKey: true}
+	}
+
+	// Check if the header format is valid
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return &callerIdentity{message: "invalid authorization header format"}
+	}
+
+	// Validate the token
+	claims, err := authService.ValidateToken(c.Request.Context(), parts[1])
+	if err != nil {
+		return &callerIdentity{message: "invalid or expired token"}
+	}
+	return &callerIdentity{claims: claims}
+}
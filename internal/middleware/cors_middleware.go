@@ -34,8 +34,8 @@ func CORS() gin.HandlerFunc {
     // Read configuration from environment
     originsRaw := getEnv("CORS_ALLOWED_ORIGINS", "*")
     methods := splitCSV(getEnv("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE,OPTIONS"))
-    headers := splitCSV(getEnv("CORS_ALLOWED_HEADERS", "Origin,Content-Type,Accept,Authorization"))
-    expose := splitCSV(getEnv("CORS_EXPOSE_HEADERS", "Content-Length"))
+    headers := splitCSV(getEnv("CORS_ALLOWED_HEADERS", "Origin,Content-Type,Accept,Authorization,X-Request-ID"))
+    expose := splitCSV(getEnv("CORS_EXPOSE_HEADERS", "Content-Length,X-Request-ID"))
     allowCreds := strings.EqualFold(getEnv("CORS_ALLOW_CREDENTIALS", "true"), "true")
     maxAgeStr := getEnv("CORS_MAX_AGE", "12h")
     maxAge, err := time.ParseDuration(maxAgeStr)
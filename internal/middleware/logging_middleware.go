@@ -1,14 +1,16 @@
 package middleware
 
 import (
-	"fmt"
+	"log/slog"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Logger returns a middleware for logging request/response details
-func Logger() gin.HandlerFunc {
+// Logger returns a middleware logging one record per request, at warn level for client
+// errors and error level for server errors. The request ID is added by the logger.
+func Logger(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Start timer
 		start := time.Now()
@@ -18,28 +20,27 @@ func Logger() gin.HandlerFunc {
 		// Process request
 		c.Next()
 
-		// Stop timer
-		end := time.Now()
-		latency := end.Sub(start)
-
-		// Get status code and client IP
-		statusCode := c.Writer.Status()
-		clientIP := c.ClientIP()
-		method := c.Request.Method
-
 		// Format the raw query if it exists
 		if raw != "" {
 			path = path + "?" + raw
 		}
 
-		// Log the request details
-		fmt.Printf("[HMS] %v | %3d | %13v | %15s | %-7s %#v\n",
-			end.Format("2006/01/02 - 15:04:05"),
-			statusCode,
-			latency,
-			clientIP,
-			method,
-			path,
+		statusCode := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case statusCode >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case statusCode >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		// Log the request details; paths and queries may hold national IDs, which the logger redacts
+		logger.LogAttrs(c.Request.Context(), level, "request",
+			slog.Int("status", statusCode),
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
 		)
 	}
 }
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission creates a middleware that only lets requests through when the
+// authenticated caller holds every one of the given permissions.
+// It must be registered after AuthMiddleware.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get the claims set by AuthMiddleware
+		value, exists := c.Get("claims")
+		if !exists {
+			c.AbortWithStatusJSON(401, errorResponse(c, 401, "authentication is required"))
+			return
+		}
+
+		claims, ok := value.(*utils.JWTClaims)
+		if !ok {
+			c.AbortWithStatusJSON(401, errorResponse(c, 401, "authentication is required"))
+			return
+		}
+
+		// Check every required permission
+		for _, permission := range permissions {
+			if !claims.HasPermission(permission) {
+				c.AbortWithStatusJSON(403, errorResponse(c, 403, "insufficient permissions"))
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
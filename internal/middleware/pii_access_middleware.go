@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// BreakGlassReasonHeader carries a caller's reason for seeing patient PII unmasked.
+// The break_glass_reason query parameter may be used instead.
+const BreakGlassReasonHeader = "X-Break-Glass-Reason"
+
+// Bounds on the length of a break-glass reason, so it says something an auditor can review
+const (
+	minBreakGlassReasonLength = 10
+	maxBreakGlassReasonLength = 255
+)
+
+// PIIAccess creates a middleware that decides whether the caller sees patient PII
+// unmasked and records the decision in the request context. Callers with the
+// patient:unmask permission always do; callers with patient:break_glass do when
+// they state a reason. Everyone else sees masked PII.
+// It must be registered after AuthMiddleware.
+func PIIAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get the claims set by AuthMiddleware
+		value, exists := c.Get("claims")
+		if !exists {
+			c.AbortWithStatusJSON(401, errorResponse(c, 401, "authentication is required"))
+			return
+		}
+
+		claims, ok := value.(*utils.JWTClaims)
+		if !ok {
+			c.AbortWithStatusJSON(401, errorResponse(c, 401, "authentication is required"))
+			return
+		}
+
+		reason := strings.TrimSpace(c.GetHeader(BreakGlassReasonHeader))
+		if reason == "" {
+			reason = strings.TrimSpace(c.Query("break_glass_reason"))
+		}
+
+		var access utils.PIIAccess
+		switch {
+		case reason != "":
+			if !claims.HasPermission(models.PermissionPatientBreakGlass) && !claims.HasPermission(models.PermissionPatientUnmask) {
+				c.AbortWithStatusJSON(403, errorResponse(c, 403, "insufficient permissions to break the glass"))
+				return
+			}
+			if n := len([]rune(reason)); n < minBreakGlassReasonLength || n > maxBreakGlassReasonLength {
+				c.AbortWithStatusJSON(400, errorResponse(c, 400, "break-glass reason must be between 10 and 255 characters"))
+				return
+			}
+			access = utils.PIIAccess{Unmasked: true, BreakGlassReason: reason}
+		case claims.HasPermission(models.PermissionPatientUnmask):
+			access = utils.PIIAccess{Unmasked: true}
+		}
+
+		c.Request = c.Request.WithContext(utils.WithPIIAccess(c.Request.Context(), access))
+		c.Next()
+	}
+}
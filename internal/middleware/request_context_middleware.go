@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestContext returns a middleware that copies request metadata (the client IP) into
+// the request context, so services can record it without depending on gin
+func RequestContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.WithClientIP(c.Request.Context(), c.ClientIP())
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
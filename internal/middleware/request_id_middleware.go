@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// maxRequestIDLength is the size of the audit_events column request IDs are stored in
+const maxRequestIDLength = 100
+
+// RequestID returns a middleware that identifies each request by the caller's X-Request-ID,
+// or by a generated ID when the caller sends none or one that isn't safe to log, so a
+// request can be followed through the logs, the audit trail and the hospital APIs it
+// calls. The ID is stored in the request context and returned in the X-Request-ID header.
+// It must be registered first, so every other middleware sees the ID.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(utils.RequestIDHeader)
+		if !validRequestID(requestID) {
+			generated, err := utils.GenerateRandomToken(16)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, models.NewErrorResponse(http.StatusInternalServerError, "internal server error"))
+				return
+			}
+			requestID = generated
+		}
+
+		c.Request = c.Request.WithContext(utils.WithRequestID(c.Request.Context(), requestID))
+		c.Header(utils.RequestIDHeader, requestID)
+
+		c.Next()
+	}
+}
+
+// validRequestID reports whether a caller-supplied request ID fits the audit trail and
+// only holds characters that can't forge log lines or headers
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// errorResponse builds an error response carrying the request's ID
+func errorResponse(c *gin.Context, code int, message string) models.APIResponse {
+	return models.NewErrorResponse(code, message).WithRequestID(utils.RequestIDFromContext(c.Request.Context()))
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// APIClientKeyPrefix starts every API key, so keys are recognizable in logs and secret scanners
+const APIClientKeyPrefix = "hms_"
+
+// APIClientScopes are the permissions an API client may be granted. Integration clients
+// read and register patients; managing staff, merging or deleting records and breaking
+// the glass are left to people.
+var APIClientScopes = []string{
+	PermissionPatientRead,
+	PermissionPatientWrite,
+	PermissionPatientUnmask,
+	PermissionAuditRead,
+}
+
+// APIClient represents a machine-to-machine caller, such as a lab system or a kiosk,
+// authenticating with a long-lived API key. Only the key's hash is stored.
+type APIClient struct {
+	ID         int        `json:"id"`
+	HospitalID int        `json:"hospital_id"`
+	Name       string     `json:"name"`
+	KeyPrefix  string     `json:"key_prefix"` // The start of the key, to tell keys apart
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedBy  *int       `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IsUsable reports whether the client's key may still be used at the given time
+func (c *APIClient) IsUsable(now time.Time) bool {
+	return c.RevokedAt == nil && now.Before(c.ExpiresAt)
+}
+
+// APIClientCreateRequest represents a request to issue an API key for a new client.
+// Scopes must be among APIClientScopes. Without an expiry the key lasts as long as the
+// configured maximum.
+type APIClientCreateRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIClientCreateResponse carries a newly issued API key, to be installed in the client
+type APIClientCreateResponse struct {
+	Key    string     `json:"key"`
+	Client *APIClient `json:"client"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Audit actions
+const (
+	AuditActionPatientRead        = "patient.read"
+	AuditActionPatientCreate      = "patient.create"
+	AuditActionPatientUpdate      = "patient.update"
+	AuditActionPatientDelete      = "patient.delete"
+	AuditActionPatientMerge       = "patient.merge"
+	AuditActionStaffCreate        = "staff.create"
+	AuditActionStaffInvite        = "staff.invite"
+	AuditActionStaffUpdate        = "staff.update"
+	AuditActionStaffDeactivate    = "staff.deactivate"
+	AuditActionStaffReactivate    = "staff.reactivate"
+	AuditActionStaffUnlock        = "staff.unlock"
+	AuditActionStaffPasswordReset = "staff.password_reset"
+	AuditActionStaffMFAReset      = "staff.mfa_reset"
+	AuditActionAPIClientCreate    = "api_client.create"
+	AuditActionAPIClientRevoke    = "api_client.revoke"
+)
+
+// AuditSourceLocal marks records served from the local database.
+// Records fetched from an upstream hospital use the hospital's name as their source.
+const AuditSourceLocal = "local"
+
+// AuditEvent represents one entry in the immutable audit trail
+type AuditEvent struct {
+	ID          int64             `json:"id"`
+	HospitalID  int               `json:"hospital_id"`
+	StaffID     *int              `json:"staff_id,omitempty"`
+	APIClientID *int              `json:"api_client_id,omitempty"`
+	PatientID   *int              `json:"patient_id,omitempty"`
+	Action      string            `json:"action"`
+	Source      string            `json:"source"`
+	ClientIP    string            `json:"client_ip"`
+	RequestID   string            `json:"request_id"`
+	Details     map[string]string `json:"details,omitempty"`
+	OccurredAt  time.Time         `json:"occurred_at"`
+}
+
+// AuditQueryRequest represents the filters of an audit trail query
+type AuditQueryRequest struct {
+	StaffID     int       `form:"staff_id"`
+	APIClientID int       `form:"api_client_id"`
+	PatientID   int       `form:"patient_id"`
+	Action      string    `form:"action"`
+	From        time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To          time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit       int       `form:"limit" binding:"omitempty,min=1,max=500"`
+	Offset      int       `form:"offset" binding:"omitempty,min=0"`
+}
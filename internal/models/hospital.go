@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Hospital represents a hospital (tenant) served by this HMS deployment
+type Hospital struct {
+	ID        int       `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Circuit breaker states of an upstream hospital API
+const (
+	CircuitClosed   = "closed"    // Calls go through
+	CircuitOpen     = "open"      // Calls fail fast until the cooldown ends
+	CircuitHalfOpen = "half_open" // One trial call decides whether the circuit closes again
+)
+
+// HospitalAPIHealth represents the health of an upstream hospital API as seen by its circuit breaker
+type HospitalAPIHealth struct {
+	Name                string `json:"name"`
+	Circuit             string `json:"circuit"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+}
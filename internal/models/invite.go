@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// StaffInvite represents an invitation for a new staff member, redeemed with a one-time
+// token to create the account with a password of their own. Only the token's hash is stored.
+type StaffInvite struct {
+	ID         int        `json:"id"`
+	HospitalID int        `json:"hospital_id"`
+	Username   string     `json:"username"`
+	RoleID     int        `json:"role_id"`
+	Role       string     `json:"role"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedBy  *int       `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// StaffInviteRequest represents a request to invite a new staff member
+type StaffInviteRequest struct {
+	Username string `json:"username" binding:"required,max=50"`
+	Role     string `json:"role" binding:"omitempty,oneof=admin doctor nurse registrar auditor"`
+}
+
+// StaffInviteResponse carries a newly issued invite token, to be handed to the new
+// staff member out of band
+type StaffInviteResponse struct {
+	Token     string    `json:"token"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// StaffInviteAcceptRequest represents a request to create an account with an invite token
+type StaffInviteAcceptRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,max=72"` // Checked against the password policy
+}
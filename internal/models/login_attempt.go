@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginAttempt represents one staff login attempt, kept to throttle guessing from one IP address
+type LoginAttempt struct {
+	ID          int64     `json:"id"`
+	HospitalID  *int      `json:"hospital_id,omitempty"` // Unset when the login named an unknown hospital
+	Username    string    `json:"username"`
+	ClientIP    string    `json:"client_ip"`
+	Succeeded   bool      `json:"succeeded"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// StaffMFA represents a staff member's TOTP two-factor authentication enrollment.
+// The enrollment is pending until a first code confirms it.
+type StaffMFA struct {
+	StaffID         int        `json:"staff_id"`
+	Secret          string     `json:"-"`
+	EnabledAt       *time.Time `json:"enabled_at,omitempty"`
+	LastUsedCounter int64      `json:"-"` // Time step of the last accepted code, which can't be used again
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// Enabled reports whether the enrollment has been confirmed
+func (m *StaffMFA) Enabled() bool {
+	return m.EnabledAt != nil
+}
+
+// MFAVerifyRequest represents the second step of a login: the MFA token returned by the
+// password step and a TOTP or recovery code
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// MFACodeRequest represents a request confirmed with a TOTP or recovery code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFAEnrollResponse carries a new TOTP secret to add to an authenticator app
+type MFAEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI, usually shown as a QR code
+}
+
+// MFAConfirmResponse carries the recovery codes of a confirmed enrollment and the
+// session that replaces the caller's previous ones
+type MFAConfirmResponse struct {
+	RecoveryCodes []string            `json:"recovery_codes"`
+	Session       *StaffLoginResponse `json:"session"`
+}
+
+// MFARecoveryCodesResponse carries newly generated recovery codes
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// OIDCLoginState represents a sign-in started at a hospital's identity provider and not yet
+// completed. It is redeemed once by the callback; only the hash of the state is stored.
+type OIDCLoginState struct {
+	ID           int       `json:"id"`
+	HospitalID   int       `json:"hospital_id"`
+	StateHash    string    `json:"-"`
+	CodeVerifier string    `json:"-"` // PKCE code verifier, sent with the code to the token endpoint
+	Nonce        string    `json:"-"` // Must be echoed in the ID token
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// StaffIdentity links an identity provider account, named by its issuer and subject,
+// to a staff member
+type StaffIdentity struct {
+	ID         int       `json:"id"`
+	HospitalID int       `json:"hospital_id"`
+	StaffID    int       `json:"staff_id"`
+	Issuer     string    `json:"issuer"`
+	Subject    string    `json:"subject"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OIDCIdentity holds the claims of a verified ID token that HMS uses
+type OIDCIdentity struct {
+	Issuer   string
+	Subject  string
+	Username string
+	FullName string
+	Groups   []string
+}
+
+// OIDCLoginRequest represents a request to sign in through a hospital's identity provider
+type OIDCLoginRequest struct {
+	HospitalCode string `form:"hospital_code"` // Defaults to the deployment's default hospital
+}
+
+// OIDCCallbackRequest represents the redirect back from the identity provider, carrying
+// either an authorization code or an error
+type OIDCCallbackRequest struct {
+	State            string `form:"state" binding:"required"`
+	Code             string `form:"code" binding:"required_without=Error"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
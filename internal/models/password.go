@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// PasswordResetToken represents a one-time token an administrator issues so a staff
+// member can set a new password. Only the token's hash is stored.
+type PasswordResetToken struct {
+	ID         int        `json:"id"`
+	StaffID    int        `json:"staff_id"`
+	HospitalID int        `json:"hospital_id"` // Hospital of the staff member, filled in on lookup
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+	CreatedBy  *int       `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// PasswordChangeRequest represents a staff member's request to change their own password
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,max=72"`
+}
+
+// PasswordResetRequest represents a request to set a new password with a reset token
+type PasswordResetRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,max=72"`
+}
+
+// PasswordResetTokenResponse carries a newly issued password reset token, to be handed
+// to the staff member out of band
+type PasswordResetTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
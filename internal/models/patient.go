@@ -5,6 +5,7 @@ import "time"
 // Patient represents a patient in the system
 type Patient struct {
 	ID           int       `json:"id"`
+	HospitalID   int       `json:"hospital_id"`
 	NationalID   string    `json:"national_id"`
 	PassportID   string    `json:"passport_id"`
 	FirstNameTH  string    `json:"first_name_th"`
@@ -20,26 +21,116 @@ type Patient struct {
 	Gender       string    `json:"gender"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+
+	// SourceHospital and LastSyncedAt are set on records cached from an upstream hospital
+	SourceHospital string     `json:"source_hospital,omitempty"`
+	LastSyncedAt   *time.Time `json:"last_synced_at,omitempty"`
 }
 
 // PatientSearchRequest represents a request to search for patients
 type PatientSearchRequest struct {
-	ID string `json:"id" binding:"required"` // Can be either national_id or passport_id
+	ID       string `json:"id" binding:"required"` // Can be either national_id or passport_id
+	Hospital string `json:"hospital"`              // Upstream hospital to query; empty queries all of them
+}
+
+// DefaultPatientQueryLimit is the page size used when a patient query doesn't set a limit
+const DefaultPatientQueryLimit = 20
+
+// PatientQueryRequest represents a multi-criteria patient search.
+// Names match the Thai or English name by case-insensitive prefix; the other
+// criteria must match exactly. Criteria are combined with AND.
+type PatientQueryRequest struct {
+	FirstName   string    `form:"first_name" binding:"omitempty,max=100"`
+	LastName    string    `form:"last_name" binding:"omitempty,max=100"`
+	DateOfBirth time.Time `form:"date_of_birth" time_format:"2006-01-02" time_utc:"1"`
+	PhoneNumber string    `form:"phone_number" binding:"omitempty,max=20"`
+	Email       string    `form:"email" binding:"omitempty,max=100"`
+	PatientHN   string    `form:"patient_hn" binding:"omitempty,max=50"`
+	Sort        string    `form:"sort" binding:"omitempty,oneof=created_at updated_at date_of_birth patient_hn first_name_th last_name_th first_name_en last_name_en"`
+	Order       string    `form:"order" binding:"omitempty,oneof=asc desc"`
+	Limit       int       `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset      int       `form:"offset" binding:"omitempty,min=0"`
+}
+
+// Defaults applied to a fuzzy patient search that doesn't set them
+const (
+	DefaultPatientFuzzyLimit    = 10
+	DefaultPatientFuzzyMinScore = 0.3
+)
+
+// PatientFuzzySearchRequest represents a search for patients by approximate name.
+// Names are matched against both the Thai and English name columns.
+type PatientFuzzySearchRequest struct {
+	FirstName string  `json:"first_name" binding:"required_without=LastName,max=100"`
+	LastName  string  `json:"last_name" binding:"required_without=FirstName,max=100"`
+	MinScore  float64 `json:"min_score" binding:"omitempty,gt=0,lte=1"` // Minimum match score between 0 and 1
+	Limit     int     `json:"limit" binding:"omitempty,min=1,max=50"`
+}
+
+// PatientMatch represents a fuzzy search candidate and how well it matched, from 0 to 1
+type PatientMatch struct {
+	Patient *Patient `json:"patient"`
+	Score   float64  `json:"score"`
+}
+
+// PatientCreateRequest represents a request to register a new patient
+type PatientCreateRequest struct {
+	NationalID   string    `json:"national_id" binding:"required_without=PassportID,omitempty,thai_national_id"`
+	PassportID   string    `json:"passport_id" binding:"required_without=NationalID,omitempty,alphanum,max=20"`
+	FirstNameTH  string    `json:"first_name_th" binding:"max=100"`
+	MiddleNameTH string    `json:"middle_name_th" binding:"max=100"`
+	LastNameTH   string    `json:"last_name_th" binding:"max=100"`
+	FirstNameEN  string    `json:"first_name_en" binding:"max=100"`
+	MiddleNameEN string    `json:"middle_name_en" binding:"max=100"`
+	LastNameEN   string    `json:"last_name_en" binding:"max=100"`
+	DateOfBirth  time.Time `json:"date_of_birth" binding:"required,past_date"`
+	PatientHN    string    `json:"patient_hn" binding:"required,max=50"`
+	PhoneNumber  string    `json:"phone_number" binding:"omitempty,max=20"`
+	Email        string    `json:"email" binding:"omitempty,email,max=100"`
+	Gender       string    `json:"gender" binding:"required,oneof=M F"`
+}
+
+// PatientUpdateRequest represents a request to replace a patient record (PUT).
+// UpdatedAt must echo the record's current updated_at; a mismatch means someone
+// else changed the record first and the update is rejected.
+type PatientUpdateRequest struct {
+	PatientCreateRequest
+	UpdatedAt time.Time `json:"updated_at" binding:"required"`
+}
+
+// PatientPatchRequest represents a request to change some fields of a patient record (PATCH).
+// Omitted fields are left unchanged. UpdatedAt works as in PatientUpdateRequest.
+type PatientPatchRequest struct {
+	NationalID   *string    `json:"national_id" binding:"omitempty,thai_national_id"`
+	PassportID   *string    `json:"passport_id" binding:"omitempty,alphanum,max=20"`
+	FirstNameTH  *string    `json:"first_name_th" binding:"omitempty,max=100"`
+	MiddleNameTH *string    `json:"middle_name_th" binding:"omitempty,max=100"`
+	LastNameTH   *string    `json:"last_name_th" binding:"omitempty,max=100"`
+	FirstNameEN  *string    `json:"first_name_en" binding:"omitempty,max=100"`
+	MiddleNameEN *string    `json:"middle_name_en" binding:"omitempty,max=100"`
+	LastNameEN   *string    `json:"last_name_en" binding:"omitempty,max=100"`
+	DateOfBirth  *time.Time `json:"date_of_birth" binding:"omitempty,past_date"`
+	PatientHN    *string    `json:"patient_hn" binding:"omitempty,min=1,max=50"`
+	PhoneNumber  *string    `json:"phone_number" binding:"omitempty,max=20"`
+	Email        *string    `json:"email" binding:"omitempty,email,max=100"`
+	Gender       *string    `json:"gender" binding:"omitempty,oneof=M F"`
+	UpdatedAt    time.Time  `json:"updated_at" binding:"required"`
 }
 
 // PatientSearchResponse represents the response from the Hospital API
 type PatientSearchResponse struct {
-	FirstNameTH  string    `json:"first_name_th"`
-	MiddleNameTH string    `json:"middle_name_th"`
-	LastNameTH   string    `json:"last_name_th"`
-	FirstNameEN  string    `json:"first_name_en"`
-	MiddleNameEN string    `json:"middle_name_en"`
-	LastNameEN   string    `json:"last_name_en"`
-	DateOfBirth  time.Time `json:"date_of_birth"`
-	PatientHN    string    `json:"patient_hn"`
-	NationalID   string    `json:"national_id"`
-	PassportID   string    `json:"passport_id"`
-	PhoneNumber  string    `json:"phone_number"`
-	Email        string    `json:"email"`
-	Gender       string    `json:"gender"`
+	FirstNameTH    string    `json:"first_name_th"`
+	MiddleNameTH   string    `json:"middle_name_th"`
+	LastNameTH     string    `json:"last_name_th"`
+	FirstNameEN    string    `json:"first_name_en"`
+	MiddleNameEN   string    `json:"middle_name_en"`
+	LastNameEN     string    `json:"last_name_en"`
+	DateOfBirth    time.Time `json:"date_of_birth"`
+	PatientHN      string    `json:"patient_hn"`
+	NationalID     string    `json:"national_id"`
+	PassportID     string    `json:"passport_id"`
+	PhoneNumber    string    `json:"phone_number"`
+	Email          string    `json:"email"`
+	Gender         string    `json:"gender"`
+	SourceHospital string    `json:"source_hospital,omitempty"` // Upstream hospital the record came from, empty for local records
 }
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Reasons a pair of patients is suspected to be the same person
+const (
+	DuplicateReasonNationalID = "national_id" // Same national ID
+	DuplicateReasonPassportID = "passport_id" // Same passport ID
+	DuplicateReasonNameDOB    = "name_dob"    // Same date of birth and a similar name
+)
+
+// Statuses of a duplicate candidate
+const (
+	DuplicateStatusOpen      = "open"
+	DuplicateStatusDismissed = "dismissed"
+)
+
+// PatientDuplicateCandidate represents a pair of patients that may be the same person
+type PatientDuplicateCandidate struct {
+	ID         int       `json:"id"`
+	HospitalID int       `json:"hospital_id"`
+	PatientA   *Patient  `json:"patient_a"`
+	PatientB   *Patient  `json:"patient_b"`
+	Reason     string    `json:"reason"`
+	Score      float64   `json:"score"`
+	Status     string    `json:"status"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// PatientDuplicateQueryRequest represents the filters of a duplicate candidate listing
+type PatientDuplicateQueryRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=open dismissed"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int    `form:"offset" binding:"omitempty,min=0"`
+}
+
+// PatientMergeRequest represents a request to merge a duplicate patient into a survivor
+type PatientMergeRequest struct {
+	SurvivorID  int    `json:"survivor_id" binding:"required"`
+	DuplicateID int    `json:"duplicate_id" binding:"required,nefield=SurvivorID"`
+	Reason      string `json:"reason" binding:"max=255"`
+}
+
+// PatientLink records a patient merged into a survivor. The merged patient's record is
+// kept as a snapshot, and its ID and HN resolve to the survivor.
+type PatientLink struct {
+	ID              int       `json:"id"`
+	HospitalID      int       `json:"hospital_id"`
+	SurvivorID      int       `json:"survivor_id"`
+	MergedPatientID int       `json:"merged_patient_id"`
+	MergedPatientHN string    `json:"merged_patient_hn"`
+	Snapshot        *Patient  `json:"snapshot"`
+	MergedBy        *int      `json:"merged_by,omitempty"`
+	Reason          string    `json:"reason,omitempty"`
+	MergedAt        time.Time `json:"merged_at"`
+}
+
+// PatientMergeResponse represents the outcome of a merge
+type PatientMergeResponse struct {
+	Survivor *Patient     `json:"survivor"`
+	Link     *PatientLink `json:"link"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// TokenBucket is the state of one caller's rate limit: requests take tokens, which refill
+// at a steady rate up to the burst size
+type TokenBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// RateLimitDecision is the outcome of taking a token from a bucket
+type RateLimitDecision struct {
+	Allowed    bool
+	Remaining  int           // Whole tokens left after the request
+	RetryAfter time.Duration // How long until a token is available, when not allowed
+}
+
+// NewTokenBucket returns a full bucket
+func NewTokenBucket(burst int, now time.Time) *TokenBucket {
+	return &TokenBucket{Tokens: float64(burst), UpdatedAt: now}
+}
+
+// Take refills the bucket for the time since it was last updated, at rate tokens per
+// second up to burst, and takes a token if a whole one is available
+func (b *TokenBucket) Take(now time.Time, rate float64, burst int) RateLimitDecision {
+	if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens += elapsed * rate
+	}
+	b.Tokens = math.Min(b.Tokens, float64(burst))
+	b.UpdatedAt = now
+
+	if b.Tokens < 1 {
+		wait := (1 - b.Tokens) / rate
+		return RateLimitDecision{RetryAfter: time.Duration(math.Ceil(wait * float64(time.Second)))}
+	}
+
+	b.Tokens--
+	return RateLimitDecision{Allowed: true, Remaining: int(b.Tokens)}
+}
@@ -5,12 +5,21 @@ type APIResponse struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   *APIError   `json:"error,omitempty"`
+	Meta    *Pagination `json:"meta,omitempty"`
+}
+
+// Pagination describes which page of a list response was returned
+type Pagination struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
 }
 
 // APIError represents an error response
 type APIError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"` // Quote it when reporting the error
 }
 
 // NewSuccessResponse creates a new success response
@@ -21,6 +30,19 @@ func NewSuccessResponse(data interface{}) APIResponse {
 	}
 }
 
+// NewPaginatedResponse creates a new success response for one page of a list
+func NewPaginatedResponse(data interface{}, total, limit, offset int) APIResponse {
+	return APIResponse{
+		Success: true,
+		Data:    data,
+		Meta: &Pagination{
+			Total:  total,
+			Limit:  limit,
+			Offset: offset,
+		},
+	}
+}
+
 // NewErrorResponse creates a new error response
 func NewErrorResponse(code int, message string) APIResponse {
 	return APIResponse{
@@ -31,3 +53,13 @@ func NewErrorResponse(code int, message string) APIResponse {
 		},
 	}
 }
+
+// WithRequestID returns a copy of an error response carrying the ID of the request that failed
+func (r APIResponse) WithRequestID(requestID string) APIResponse {
+	if r.Error != nil {
+		apiError := *r.Error
+		apiError.RequestID = requestID
+		r.Error = &apiError
+	}
+	return r
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Role names
+const (
+	RoleAdmin     = "admin"
+	RoleDoctor    = "doctor"
+	RoleNurse     = "nurse"
+	RoleRegistrar = "registrar"
+	RoleAuditor   = "auditor"
+)
+
+// DefaultRole is assigned to staff members created without an explicit role
+const DefaultRole = RoleRegistrar
+
+// Permission names
+const (
+	PermissionPatientRead       = "patient:read"
+	PermissionPatientWrite      = "patient:write"
+	PermissionPatientDelete     = "patient:delete"
+	PermissionPatientMerge      = "patient:merge"
+	PermissionPatientUnmask     = "patient:unmask"      // See patient PII unmasked
+	PermissionPatientBreakGlass = "patient:break_glass" // See patient PII unmasked after stating a reason
+	PermissionStaffRead         = "staff:read"
+	PermissionStaffManage       = "staff:manage"
+	PermissionAuditRead         = "audit:read"
+	PermissionAPIClientManage   = "api_client:manage"
+)
+
+// Role represents a staff role and the permissions granted to it
+type Role struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -4,27 +4,94 @@ import "time"
 
 // Staff represents a hospital staff member
 type Staff struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"` // Password is not exposed in JSON responses
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         int       `json:"id"`
+	HospitalID int       `json:"hospital_id"`
+	Username   string    `json:"username"`
+	Password   string    `json:"-"` // Password is not exposed in JSON responses
+	FullName   string    `json:"full_name"`
+	Department string    `json:"department"`
+	RoleID     int       `json:"role_id"`
+	Role       string    `json:"role"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// IsActive is cleared when the staff member is deactivated; deactivated staff can't log in
+	IsActive      bool       `json:"is_active"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+
+	// FailedAttempts counts consecutive failed logins; the account is locked until LockedUntil
+	FailedAttempts int        `json:"failed_attempts"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+
+	// MustChangePassword is set for a password chosen by someone else, such as an initial password
+	MustChangePassword bool       `json:"must_change_password"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`
+
+	MFAEnabled bool `json:"mfa_enabled"`
 }
 
-// StaffCreateRequest represents a request to create a new staff member
+// IsLocked reports whether the staff member's account is locked at the given time
+func (s *Staff) IsLocked(now time.Time) bool {
+	return s.LockedUntil != nil && now.Before(*s.LockedUntil)
+}
+
+// StaffCreateRequest represents an administrator's request to create a new staff member
+// in their own hospital with an initial password
 type StaffCreateRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required,min=8"`
+	Username   string `json:"username" binding:"required,max=50"`
+	Password   string `json:"password" binding:"required,max=72"` // Checked against the password policy
+	FullName   string `json:"full_name" binding:"max=100"`
+	Department string `json:"department" binding:"max=100"`
+	Role       string `json:"role" binding:"omitempty,oneof=admin doctor nurse registrar auditor"`
+}
+
+// DefaultStaffQueryLimit is the page size used when a staff query doesn't set a limit
+const DefaultStaffQueryLimit = 20
+
+// StaffQueryRequest represents a search of the caller's hospital's staff.
+// Username and FullName match by case-insensitive prefix; the other criteria must
+// match exactly. Criteria are combined with AND.
+type StaffQueryRequest struct {
+	Username   string `form:"username" binding:"omitempty,max=50"`
+	FullName   string `form:"full_name" binding:"omitempty,max=100"`
+	Department string `form:"department" binding:"omitempty,max=100"`
+	Role       string `form:"role" binding:"omitempty,oneof=admin doctor nurse registrar auditor"`
+	IsActive   *bool  `form:"is_active"`
+	Limit      int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset     int    `form:"offset" binding:"omitempty,min=0"`
+}
+
+// StaffPatchRequest represents a request to change some fields of a staff member (PATCH).
+// Omitted fields are left unchanged.
+type StaffPatchRequest struct {
+	FullName   *string `json:"full_name" binding:"omitempty,max=100"`
+	Department *string `json:"department" binding:"omitempty,max=100"`
+	Role       *string `json:"role" binding:"omitempty,oneof=admin doctor nurse registrar auditor"`
+	IsActive   *bool   `json:"is_active"`
 }
 
 // StaffLoginRequest represents a login request
 type StaffLoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	HospitalCode string `json:"hospital_code"` // Defaults to the deployment's default hospital
+	Username     string `json:"username" binding:"required"`
+	Password     string `json:"password" binding:"required"`
 }
 
-// StaffLoginResponse represents a successful login response
+// StaffLoginResponse represents a successful login response. For staff using two-factor
+// authentication the password step only returns an MFA token, which VerifyMFA exchanges
+// for the access and refresh tokens.
 type StaffLoginResponse struct {
-	Token     string `json:"token"`
-	ExpiresAt int64  `json:"expires_at"`
+	Token            string `json:"token,omitempty"`
+	ExpiresAt        int64  `json:"expires_at,omitempty"`
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at,omitempty"`
+
+	// PasswordChangeRequired means the token can only be used to change the password
+	PasswordChangeRequired bool `json:"password_change_required,omitempty"`
+	// MFAEnrollmentRequired means the token can only be used to enroll in two-factor authentication
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+	MFAExpiresAt int64  `json:"mfa_expires_at,omitempty"`
 }
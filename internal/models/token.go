@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// RefreshToken represents a persisted refresh token.
+// Only the SHA-256 hash of the token is stored.
+type RefreshToken struct {
+	ID           int        `json:"id"`
+	StaffID      int        `json:"staff_id"`
+	HospitalID   int        `json:"hospital_id"` // Hospital of the staff member, loaded on lookup
+	FamilyID     string     `json:"family_id"`   // Shared by every token rotated from the same login
+	TokenHash    string     `json:"-"`
+	AccessJTI    string     `json:"access_jti"` // ID of the access token issued with this refresh token
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ReplacedByID *int       `json:"replaced_by_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// RefreshTokenRequest represents a request to exchange a refresh token for new tokens
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest represents a logout request
+type LogoutRequest struct {
+	All bool `json:"all"` // Revoke every session of the staff member, not just the current one
+}
@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/lib/pq"
+)
+
+// APIClientRepository defines the interface for API client database operations.
+// Clients are managed within the caller's hospital, but a key is looked up before its
+// client is authenticated, so lookups by key are not scoped to a hospital.
+type APIClientRepository interface {
+	Create(ctx context.Context, client *models.APIClient) error
+	FindByID(ctx context.Context, id int) (*models.APIClient, error)
+	FindByHash(ctx context.Context, keyHash string) (*models.APIClient, error)
+	List(ctx context.Context) ([]*models.APIClient, error)
+	Revoke(ctx context.Context, client *models.APIClient) error
+	TouchLastUsed(ctx context.Context, id int) error
+}
+
+// APIClientRepositoryImpl implements APIClientRepository
+type APIClientRepositoryImpl struct {
+	*BaseRepositoryImpl
+}
+
+// NewAPIClientRepository creates a new APIClientRepositoryImpl
+func NewAPIClientRepository(db *sql.DB, logger *slog.Logger) *APIClientRepositoryImpl {
+	return &APIClientRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+	}
+}
+
+// lastUsedResolution is how stale last_used_at may get, so busy clients don't cost a
+// write on every request
+const lastUsedResolution = time.Minute
+
+// apiClientColumns are the columns scanned by scanAPIClient
+const apiClientColumns = `
+	id, hospital_id, name, key_prefix, key_hash, scopes, expires_at,
+	revoked_at, last_used_at, created_by, created_at
+`
+
+// Create inserts a new API client in the caller's hospital
+func (r *APIClientRepositoryImpl) Create(ctx context.Context, client *models.APIClient) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+	client.HospitalID = hospitalID
+
+	query := `
+		INSERT INTO api_clients (hospital_id, name, key_prefix, key_hash, scopes, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+
+	err = r.DB.QueryRowContext(
+		ctx,
+		query,
+		client.HospitalID,
+		client.Name,
+		client.KeyPrefix,
+		client.KeyHash,
+		pq.Array(client.Scopes),
+		client.ExpiresAt,
+		client.CreatedBy,
+	).Scan(&client.ID, &client.CreatedAt)
+
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// FindByID finds an API client of the caller's hospital by ID
+func (r *APIClientRepositoryImpl) FindByID(ctx context.Context, id int) (*models.APIClient, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + apiClientColumns + ` FROM api_clients WHERE id = $1 AND hospital_id = $2`
+
+	return scanAPIClient(r.DB.QueryRowContext(ctx, query, id, hospitalID))
+}
+
+// FindByHash finds an API client by the hash of its key
+func (r *APIClientRepositoryImpl) FindByHash(ctx context.Context, keyHash string) (*models.APIClient, error) {
+	query := `SELECT ` + apiClientColumns + ` FROM api_clients WHERE key_hash = $1`
+
+	return scanAPIClient(r.DB.QueryRowContext(ctx, query, keyHash))
+}
+
+// List returns the API clients of the caller's hospital, newest first
+func (r *APIClientRepositoryImpl) List(ctx context.Context) ([]*models.APIClient, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + apiClientColumns + ` FROM api_clients WHERE hospital_id = $1 ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.DB.QueryContext(ctx, query, hospitalID)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	clients := []*models.APIClient{}
+	for rows.Next() {
+		client, err := scanAPIClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return clients, nil
+}
+
+// Revoke marks an API client of the caller's hospital as revoked, after which its key is
+// refused. It fails with a conflict error if the client was already revoked.
+func (r *APIClientRepositoryImpl) Revoke(ctx context.Context, client *models.APIClient) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE api_clients SET revoked_at = $1
+		WHERE id = $2 AND hospital_id = $3 AND revoked_at IS NULL
+		RETURNING revoked_at
+	`
+
+	var revokedAt time.Time
+	err = r.DB.QueryRowContext(ctx, query, time.Now(), client.ID, hospitalID).Scan(&revokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.NewConflictError("API client is already revoked")
+		}
+		return apperrors.NewInternalServerError(err)
+	}
+	client.RevokedAt = &revokedAt
+
+	return nil
+}
+
+// TouchLastUsed records that an API client of the caller's hospital used its key
+func (r *APIClientRepositoryImpl) TouchLastUsed(ctx context.Context, id int) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	query := `
+		UPDATE api_clients SET last_used_at = $1
+		WHERE id = $2 AND hospital_id = $3 AND (last_used_at IS NULL OR last_used_at < $4)
+	`
+
+	if _, err := r.DB.ExecContext(ctx, query, now, id, hospitalID, now.Add(-lastUsedResolution)); err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// scanAPIClient scans the apiClientColumns of a row into an API client
+func scanAPIClient(row rowScanner) (*models.APIClient, error) {
+	client := &models.APIClient{}
+	var revokedAt, lastUsedAt sql.NullTime
+	var createdBy sql.NullInt64
+	err := row.Scan(
+		&client.ID,
+		&client.HospitalID,
+		&client.Name,
+		&client.KeyPrefix,
+		&client.KeyHash,
+		pq.Array(&client.Scopes),
+		&client.ExpiresAt,
+		&revokedAt,
+		&lastUsedAt,
+		&createdBy,
+		&client.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("API client not found")
+		}
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	if revokedAt.Valid {
+		client.RevokedAt = &revokedAt.Time
+	}
+	if lastUsedAt.Valid {
+		client.LastUsedAt = &lastUsedAt.Time
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		client.CreatedBy = &id
+	}
+
+	return client, nil
+}
@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// AuditRepository defines the interface for audit trail database operations.
+// The audit trail is append-only: there is no update or delete.
+type AuditRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+	Find(ctx context.Context, query models.AuditQueryRequest) ([]*models.AuditEvent, error)
+}
+
+// AuditRepositoryImpl implements AuditRepository
+type AuditRepositoryImpl struct {
+	*BaseRepositoryImpl
+}
+
+// NewAuditRepository creates a new AuditRepositoryImpl
+func NewAuditRepository(db *sql.DB, logger *slog.Logger) *AuditRepositoryImpl {
+	return &AuditRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+	}
+}
+
+// Create appends an audit event for the caller's hospital
+func (r *AuditRepositoryImpl) Create(ctx context.Context, event *models.AuditEvent) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+	event.HospitalID = hospitalID
+
+	// Details are stored as JSONB; pass them as text since lib/pq sends []byte as bytea
+	var details interface{}
+	if len(event.Details) > 0 {
+		encoded, err := json.Marshal(event.Details)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+		details = string(encoded)
+	}
+
+	query := `
+		INSERT INTO audit_events (
+			hospital_id, staff_id, api_client_id, patient_id, action, source, client_ip, request_id, details
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, occurred_at
+	`
+
+	err = r.DB.QueryRowContext(
+		ctx,
+		query,
+		event.HospitalID,
+		event.StaffID,
+		event.APIClientID,
+		event.PatientID,
+		event.Action,
+		event.Source,
+		event.ClientIP,
+		event.RequestID,
+		details,
+	).Scan(&event.ID, &event.OccurredAt)
+
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// Find returns the caller's hospital's audit events matching the query, newest first
+func (r *AuditRepositoryImpl) Find(ctx context.Context, query models.AuditQueryRequest) ([]*models.AuditEvent, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"hospital_id = $1"}
+	args := []interface{}{hospitalID}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if query.StaffID != 0 {
+		addCondition("staff_id = $%d", query.StaffID)
+	}
+	if query.APIClientID != 0 {
+		addCondition("api_client_id = $%d", query.APIClientID)
+	}
+	if query.PatientID != 0 {
+		addCondition("patient_id = $%d", query.PatientID)
+	}
+	if query.Action != "" {
+		addCondition("action = $%d", query.Action)
+	}
+	if !query.From.IsZero() {
+		addCondition("occurred_at >= $%d", query.From)
+	}
+	if !query.To.IsZero() {
+		addCondition("occurred_at < $%d", query.To)
+	}
+
+	args = append(args, query.Limit, query.Offset)
+	sqlQuery := fmt.Sprintf(`
+		SELECT id, hospital_id, staff_id, api_client_id, patient_id, action, source,
+			COALESCE(client_ip, ''), COALESCE(request_id, ''), details, occurred_at
+		FROM audit_events
+		WHERE %s
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, strings.Join(conditions, " AND "), len(args)-1, len(args))
+
+	rows, err := r.DB.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	events := []*models.AuditEvent{}
+	for rows.Next() {
+		event := &models.AuditEvent{}
+		var staffID, clientID, patientID sql.NullInt64
+		var details []byte
+		if err := rows.Scan(
+			&event.ID,
+			&event.HospitalID,
+			&staffID,
+			&clientID,
+			&patientID,
+			&event.Action,
+			&event.Source,
+			&event.ClientIP,
+			&event.RequestID,
+			&details,
+			&event.OccurredAt,
+		); err != nil {
+			return nil, apperrors.NewInternalServerError(err)
+		}
+
+		if staffID.Valid {
+			id := int(staffID.Int64)
+			event.StaffID = &id
+		}
+		if clientID.Valid {
+			id := int(clientID.Int64)
+			event.APIClientID = &id
+		}
+		if patientID.Valid {
+			id := int(patientID.Int64)
+			event.PatientID = &id
+		}
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &event.Details); err != nil {
+				return nil, apperrors.NewInternalServerError(err)
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return events, nil
+}
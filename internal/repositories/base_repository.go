@@ -3,6 +3,11 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
 )
 
 // BaseRepository defines common database operations
@@ -31,13 +36,15 @@ type BaseRepository interface {
 
 // BaseRepositoryImpl is a base implementation of BaseRepository
 type BaseRepositoryImpl struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Logger *slog.Logger
 }
 
 // NewBaseRepository creates a new BaseRepositoryImpl
-func NewBaseRepository(db *sql.DB) *BaseRepositoryImpl {
+func NewBaseRepository(db *sql.DB, logger *slog.Logger) *BaseRepositoryImpl {
 	return &BaseRepositoryImpl{
-		DB: db,
+		DB:     db,
+		Logger: logger,
 	}
 }
 
@@ -50,15 +57,34 @@ func (r *BaseRepositoryImpl) ExecuteInTransaction(ctx context.Context, fn func(t
 
 	defer func() {
 		if p := recover(); p != nil {
-			_ = tx.Rollback()
+			r.rollback(ctx, tx)
 			panic(p) // re-throw panic after rollback
 		}
 	}()
 
 	if err := fn(tx); err != nil {
-		_ = tx.Rollback()
+		r.rollback(ctx, tx)
 		return err
 	}
 
 	return tx.Commit()
 }
+
+// rollback rolls a transaction back, logging failures since the caller is already
+// returning another error. A transaction already ended by a cancelled context is not a failure.
+func (r *BaseRepositoryImpl) rollback(ctx context.Context, tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		r.Logger.ErrorContext(ctx, "transaction rollback failed", slog.Any("error", err))
+	}
+}
+
+// scopedHospitalID returns the hospital the caller is scoped to.
+// Queries on tenant-owned tables must be filtered by this ID; a context without
+// a hospital scope is rejected rather than allowed to see every hospital's data.
+func scopedHospitalID(ctx context.Context) (int, error) {
+	hospitalID, ok := utils.HospitalIDFromContext(ctx)
+	if !ok {
+		return 0, apperrors.NewForbiddenError("hospital scope is required")
+	}
+	return hospitalID, nil
+}
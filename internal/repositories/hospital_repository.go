@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// HospitalRepository defines the interface for hospital database operations
+type HospitalRepository interface {
+	FindByCode(ctx context.Context, code string) (*models.Hospital, error)
+	FindByID(ctx context.Context, id int) (*models.Hospital, error)
+	FindAll(ctx context.Context) ([]*models.Hospital, error)
+}
+
+// HospitalRepositoryImpl implements HospitalRepository
+type HospitalRepositoryImpl struct {
+	*BaseRepositoryImpl
+}
+
+// NewHospitalRepository creates a new HospitalRepositoryImpl
+func NewHospitalRepository(db *sql.DB, logger *slog.Logger) *HospitalRepositoryImpl {
+	return &HospitalRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+	}
+}
+
+// FindByCode finds a hospital by its code
+func (r *HospitalRepositoryImpl) FindByCode(ctx context.Context, code string) (*models.Hospital, error) {
+	query := `
+		SELECT id, code, name, created_at, updated_at
+		FROM hospitals
+		WHERE code = $1
+	`
+
+	hospital := &models.Hospital{}
+	err := r.DB.QueryRowContext(ctx, query, code).Scan(
+		&hospital.ID,
+		&hospital.Code,
+		&hospital.Name,
+		&hospital.CreatedAt,
+		&hospital.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("hospital not found")
+		}
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return hospital, nil
+}
+
+// FindByID finds a hospital by ID
+func (r *HospitalRepositoryImpl) FindByID(ctx context.Context, id int) (*models.Hospital, error) {
+	query := `
+		SELECT id, code, name, created_at, updated_at
+		FROM hospitals
+		WHERE id = $1
+	`
+
+	hospital := &models.Hospital{}
+	err := r.DB.QueryRowContext(ctx, query, id).Scan(
+		&hospital.ID,
+		&hospital.Code,
+		&hospital.Name,
+		&hospital.CreatedAt,
+		&hospital.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("hospital not found")
+		}
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return hospital, nil
+}
+
+// FindAll returns every hospital ordered by ID
+func (r *HospitalRepositoryImpl) FindAll(ctx context.Context) ([]*models.Hospital, error) {
+	query := `
+		SELECT id, code, name, created_at, updated_at
+		FROM hospitals
+		ORDER BY id
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	hospitals := []*models.Hospital{}
+	for rows.Next() {
+		hospital := &models.Hospital{}
+		if err := rows.Scan(
+			&hospital.ID,
+			&hospital.Code,
+			&hospital.Name,
+			&hospital.CreatedAt,
+			&hospital.UpdatedAt,
+		); err != nil {
+			return nil, apperrors.NewInternalServerError(err)
+		}
+		hospitals = append(hospitals, hospital)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return hospitals, nil
+}
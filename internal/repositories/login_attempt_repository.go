@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// LoginAttemptRepository defines the interface for login attempt database operations.
+// Attempts are recorded before the caller is authenticated, and one client may try
+// several hospitals, so these operations are not scoped to a hospital.
+type LoginAttemptRepository interface {
+	Create(ctx context.Context, attempt *models.LoginAttempt) error
+	CountFailuresByIP(ctx context.Context, clientIP string, since time.Time) (int, error)
+	CountFailuresByUsername(ctx context.Context, hospitalID *int, username string, since time.Time) (int, error)
+}
+
+// LoginAttemptRepositoryImpl implements LoginAttemptRepository
+type LoginAttemptRepositoryImpl struct {
+	*BaseRepositoryImpl
+}
+
+// NewLoginAttemptRepository creates a new LoginAttemptRepositoryImpl
+func NewLoginAttemptRepository(db *sql.DB, logger *slog.Logger) *LoginAttemptRepositoryImpl {
+	return &LoginAttemptRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+	}
+}
+
+// Create records a login attempt
+func (r *LoginAttemptRepositoryImpl) Create(ctx context.Context, attempt *models.LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (hospital_id, username, client_ip, succeeded)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, attempted_at
+	`
+
+	err := r.DB.QueryRowContext(
+		ctx,
+		query,
+		attempt.HospitalID,
+		attempt.Username,
+		attempt.ClientIP,
+		attempt.Succeeded,
+	).Scan(&attempt.ID, &attempt.AttemptedAt)
+
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// CountFailuresByIP counts the failed login attempts made from an IP address since the given time
+func (r *LoginAttemptRepositoryImpl) CountFailuresByIP(ctx context.Context, clientIP string, since time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM login_attempts
+		WHERE client_ip = $1 AND attempted_at >= $2 AND NOT succeeded
+	`
+
+	var count int
+	if err := r.DB.QueryRowContext(ctx, query, clientIP, since).Scan(&count); err != nil {
+		return 0, apperrors.NewInternalServerError(err)
+	}
+
+	return count, nil
+}
+
+// CountFailuresByUsername counts the failed login attempts made for a username at a hospital
+// since the given time. A nil hospital ID counts attempts naming an unknown hospital.
+func (r *LoginAttemptRepositoryImpl) CountFailuresByUsername(ctx context.Context, hospitalID *int, username string, since time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM login_attempts
+		WHERE hospital_id IS NOT DISTINCT FROM $1 AND username = $2 AND attempted_at >= $3 AND NOT succeeded
+	`
+
+	var count int
+	if err := r.DB.QueryRowContext(ctx, query, hospitalID, username, since).Scan(&count); err != nil {
+		return 0, apperrors.NewInternalServerError(err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,383 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/DingDong039/hms/internal/encryption"
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// fieldMFASecret is the name the TOTP secret is encrypted under
+const fieldMFASecret = "mfa_secret"
+
+// MFARepository defines the interface for two-factor authentication database operations.
+// Every operation is limited to staff of the caller's hospital.
+type MFARepository interface {
+	FindByStaffID(ctx context.Context, staffID int) (*models.StaffMFA, error)
+	CreatePending(ctx context.Context, staffID int, secret string) error
+	Enable(ctx context.Context, staffID int, counter int64, recoveryCodeHashes []string) error
+	UseCounter(ctx context.Context, staffID int, counter int64) error
+	UseRecoveryCode(ctx context.Context, staffID int, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, staffID int, codeHashes []string) error
+	Delete(ctx context.Context, staffID int) error
+	Reencrypt(ctx context.Context, batchSize int) (int, error)
+}
+
+// MFARepositoryImpl implements MFARepository
+type MFARepositoryImpl struct {
+	*BaseRepositoryImpl
+	encryptor *encryption.FieldEncryptor
+}
+
+// NewMFARepository creates a new MFARepositoryImpl
+func NewMFARepository(db *sql.DB, encryptor *encryption.FieldEncryptor, logger *slog.Logger) *MFARepositoryImpl {
+	return &MFARepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+		encryptor:          encryptor,
+	}
+}
+
+// FindByStaffID finds a staff member's enrollment, pending or enabled
+func (r *MFARepositoryImpl) FindByStaffID(ctx context.Context, staffID int) (*models.StaffMFA, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT m.staff_id, m.secret, m.enabled_at, m.last_used_counter, m.created_at
+		FROM staff_mfa m
+		JOIN staff s ON s.id = m.staff_id
+		WHERE m.staff_id = $1 AND s.hospital_id = $2
+	`
+
+	mfa := &models.StaffMFA{}
+	var enabledAt sql.NullTime
+	err = r.DB.QueryRowContext(ctx, query, staffID, hospitalID).Scan(
+		&mfa.StaffID,
+		&mfa.Secret,
+		&enabledAt,
+		&mfa.LastUsedCounter,
+		&mfa.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("two-factor authentication is not set up")
+		}
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	if enabledAt.Valid {
+		mfa.EnabledAt = &enabledAt.Time
+	}
+	if mfa.Secret, err = r.encryptor.Decrypt(ctx, fieldMFASecret, mfa.Secret); err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return mfa, nil
+}
+
+// CreatePending stores a new secret for a staff member whose enrollment isn't enabled,
+// replacing a pending one. It fails with a conflict error if the enrollment is enabled.
+func (r *MFARepositoryImpl) CreatePending(ctx context.Context, staffID int, secret string) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := r.encryptor.Encrypt(ctx, fieldMFASecret, secret)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	query := `
+		INSERT INTO staff_mfa (staff_id, secret)
+		SELECT id, $1 FROM staff WHERE id = $2 AND hospital_id = $3
+		ON CONFLICT (staff_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_counter = 0, created_at = CURRENT_TIMESTAMP
+		WHERE staff_mfa.enabled_at IS NULL
+	`
+
+	result, err := r.DB.ExecContext(ctx, query, sealed, staffID, hospitalID)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.NewConflictError("two-factor authentication is already enabled")
+	}
+
+	return nil
+}
+
+// Enable confirms a pending enrollment with the time step of its first code and stores
+// its recovery codes
+func (r *MFARepositoryImpl) Enable(ctx context.Context, staffID int, counter int64, recoveryCodeHashes []string) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE staff_mfa m
+			SET enabled_at = $1, last_used_counter = $2
+			FROM staff s
+			WHERE s.id = m.staff_id AND m.staff_id = $3 AND s.hospital_id = $4 AND m.enabled_at IS NULL
+		`
+
+		result, err := tx.ExecContext(ctx, query, time.Now(), counter, staffID, hospitalID)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		if rowsAffected == 0 {
+			return apperrors.NewConflictError("no two-factor enrollment is pending")
+		}
+
+		return replaceRecoveryCodes(ctx, tx, staffID, recoveryCodeHashes)
+	})
+}
+
+// UseCounter records the time step of an accepted TOTP code. It fails with an
+// unauthorized error if a code of the same or a later step was already accepted, so
+// every code can only be used once.
+func (r *MFARepositoryImpl) UseCounter(ctx context.Context, staffID int, counter int64) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE staff_mfa m
+		SET last_used_counter = $1
+		FROM staff s
+		WHERE s.id = m.staff_id AND m.staff_id = $2 AND s.hospital_id = $3
+			AND m.enabled_at IS NOT NULL AND m.last_used_counter < $1
+	`
+
+	result, err := r.DB.ExecContext(ctx, query, counter, staffID, hospitalID)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.NewUnauthorizedError("code has already been used")
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks one of a staff member's recovery codes as used. It fails with
+// an unauthorized error if the code is unknown or was already used.
+func (r *MFARepositoryImpl) UseRecoveryCode(ctx context.Context, staffID int, codeHash string) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE staff_recovery_codes c
+		SET used_at = $1
+		FROM staff s
+		WHERE s.id = c.staff_id AND c.staff_id = $2 AND s.hospital_id = $3
+			AND c.code_hash = $4 AND c.used_at IS NULL
+	`
+
+	result, err := r.DB.ExecContext(ctx, query, time.Now(), staffID, hospitalID, codeHash)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.NewUnauthorizedError("invalid recovery code")
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces all of a staff member's recovery codes
+func (r *MFARepositoryImpl) ReplaceRecoveryCodes(ctx context.Context, staffID int, codeHashes []string) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM staff WHERE id = $1 AND hospital_id = $2)`,
+			staffID, hospitalID,
+		).Scan(&exists)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+		if !exists {
+			return apperrors.NewNotFoundError("staff member not found")
+		}
+
+		return replaceRecoveryCodes(ctx, tx, staffID, codeHashes)
+	})
+}
+
+// Delete removes a staff member's enrollment and recovery codes
+func (r *MFARepositoryImpl) Delete(ctx context.Context, staffID int) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		query := `
+			DELETE FROM staff_mfa m
+			USING staff s
+			WHERE s.id = m.staff_id AND m.staff_id = $1 AND s.hospital_id = $2
+		`
+
+		result, err := tx.ExecContext(ctx, query, staffID, hospitalID)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		if rowsAffected == 0 {
+			return apperrors.NewNotFoundError("two-factor authentication is not set up")
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM staff_recovery_codes WHERE staff_id = $1`, staffID); err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		return nil
+	})
+}
+
+// Reencrypt re-encrypts the TOTP secrets of the caller's hospital's staff that are
+// encrypted under an old key, batchSize rows per transaction, and returns how many
+// rows were rewritten
+func (r *MFARepositoryImpl) Reencrypt(ctx context.Context, batchSize int) (int, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		SELECT m.staff_id, m.secret
+		FROM staff_mfa m
+		JOIN staff s ON s.id = m.staff_id
+		WHERE s.hospital_id = $1 AND m.staff_id > $2
+		ORDER BY m.staff_id
+		LIMIT $3
+		FOR UPDATE OF m
+	`
+
+	rewritten, lastID := 0, 0
+	for {
+		count, updated := 0, 0
+		err := r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+			rows, err := tx.QueryContext(ctx, query, hospitalID, lastID, batchSize)
+			if err != nil {
+				return apperrors.NewInternalServerError(err)
+			}
+
+			type storedSecret struct {
+				staffID int
+				secret  string
+			}
+			var batch []storedSecret
+			for rows.Next() {
+				var stored storedSecret
+				if err := rows.Scan(&stored.staffID, &stored.secret); err != nil {
+					rows.Close()
+					return apperrors.NewInternalServerError(err)
+				}
+				batch = append(batch, stored)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return apperrors.NewInternalServerError(err)
+			}
+
+			for _, stored := range batch {
+				count++
+				lastID = stored.staffID
+
+				if !r.encryptor.NeedsReencryption(stored.secret) {
+					continue
+				}
+
+				secret, err := r.encryptor.Decrypt(ctx, fieldMFASecret, stored.secret)
+				if err != nil {
+					return apperrors.NewInternalServerError(err)
+				}
+				sealed, err := r.encryptor.Encrypt(ctx, fieldMFASecret, secret)
+				if err != nil {
+					return apperrors.NewInternalServerError(err)
+				}
+
+				if _, err := tx.ExecContext(ctx, `UPDATE staff_mfa SET secret = $1 WHERE staff_id = $2`, sealed, stored.staffID); err != nil {
+					return apperrors.NewInternalServerError(err)
+				}
+				updated++
+			}
+			return nil
+		})
+		if err != nil {
+			return rewritten, err
+		}
+		rewritten += updated
+
+		if count < batchSize {
+			return rewritten, nil
+		}
+	}
+}
+
+// replaceRecoveryCodes replaces a staff member's recovery codes within a transaction
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, staffID int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM staff_recovery_codes WHERE staff_id = $1`, staffID); err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO staff_recovery_codes (staff_id, code_hash) VALUES ($1, $2)`,
+			staffID, hash,
+		)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// OIDCLoginStateRepository defines the interface for pending single sign-on database
+// operations. States are redeemed before the caller is authenticated, so lookups are
+// not scoped to a hospital.
+type OIDCLoginStateRepository interface {
+	Create(ctx context.Context, state *models.OIDCLoginState) error
+	Consume(ctx context.Context, stateHash string) (*models.OIDCLoginState, error)
+}
+
+// OIDCLoginStateRepositoryImpl implements OIDCLoginStateRepository
+type OIDCLoginStateRepositoryImpl struct {
+	*BaseRepositoryImpl
+}
+
+// NewOIDCLoginStateRepository creates a new OIDCLoginStateRepositoryImpl
+func NewOIDCLoginStateRepository(db *sql.DB, logger *slog.Logger) *OIDCLoginStateRepositoryImpl {
+	return &OIDCLoginStateRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+	}
+}
+
+// Create inserts a new pending sign-in in the caller's hospital, clearing out expired
+// ones that were never completed
+func (r *OIDCLoginStateRepositoryImpl) Create(ctx context.Context, state *models.OIDCLoginState) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+	state.HospitalID = hospitalID
+
+	return r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM oidc_login_states WHERE expires_at < $1`, time.Now())
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		query := `
+			INSERT INTO oidc_login_states (hospital_id, state_hash, code_verifier, nonce, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at
+		`
+
+		err = tx.QueryRowContext(
+			ctx,
+			query,
+			state.HospitalID,
+			state.StateHash,
+			state.CodeVerifier,
+			state.Nonce,
+			state.ExpiresAt,
+		).Scan(&state.ID, &state.CreatedAt)
+
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		return nil
+	})
+}
+
+// Consume deletes a pending sign-in by the hash of its state and returns it, so each
+// state can only ever be redeemed once
+func (r *OIDCLoginStateRepositoryImpl) Consume(ctx context.Context, stateHash string) (*models.OIDCLoginState, error) {
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1
+		RETURNING id, hospital_id, state_hash, code_verifier, nonce, expires_at, created_at
+	`
+
+	state := &models.OIDCLoginState{}
+	err := r.DB.QueryRowContext(ctx, query, stateHash).Scan(
+		&state.ID,
+		&state.HospitalID,
+		&state.StateHash,
+		&state.CodeVerifier,
+		&state.Nonce,
+		&state.ExpiresAt,
+		&state.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("sign-in not found")
+		}
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return state, nil
+}
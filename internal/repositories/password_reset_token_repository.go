@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// PasswordResetTokenRepository defines the interface for password reset token database
+// operations. Tokens are redeemed before the caller is authenticated, so lookups are
+// not scoped to a hospital.
+type PasswordResetTokenRepository interface {
+	Create(ctx context.Context, token *models.PasswordResetToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error)
+	MarkUsed(ctx context.Context, id int) error
+}
+
+// PasswordResetTokenRepositoryImpl implements PasswordResetTokenRepository
+type PasswordResetTokenRepositoryImpl struct {
+	*BaseRepositoryImpl
+}
+
+// NewPasswordResetTokenRepository creates a new PasswordResetTokenRepositoryImpl
+func NewPasswordResetTokenRepository(db *sql.DB, logger *slog.Logger) *PasswordResetTokenRepositoryImpl {
+	return &PasswordResetTokenRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+	}
+}
+
+// Create inserts a new reset token, replacing the staff member's unused ones so only
+// the newest token can be redeemed
+func (r *PasswordResetTokenRepositoryImpl) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`DELETE FROM password_reset_tokens WHERE staff_id = $1 AND used_at IS NULL`,
+			token.StaffID,
+		)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		query := `
+			INSERT INTO password_reset_tokens (staff_id, token_hash, expires_at, created_by)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at
+		`
+
+		err = tx.QueryRowContext(
+			ctx,
+			query,
+			token.StaffID,
+			token.TokenHash,
+			token.ExpiresAt,
+			token.CreatedBy,
+		).Scan(&token.ID, &token.CreatedAt)
+
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		return nil
+	})
+}
+
+// FindByHash finds a reset token by the hash of its value
+func (r *PasswordResetTokenRepositoryImpl) FindByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	query := `
+		SELECT t.id, t.staff_id, s.hospital_id, t.token_hash, t.expires_at, t.used_at, t.created_by, t.created_at
+		FROM password_reset_tokens t
+		JOIN staff s ON s.id = t.staff_id
+		WHERE t.token_hash = $1
+	`
+
+	token := &models.PasswordResetToken{}
+	var usedAt sql.NullTime
+	var createdBy sql.NullInt64
+	err := r.DB.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.StaffID,
+		&token.HospitalID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&usedAt,
+		&createdBy,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("password reset token not found")
+		}
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		token.CreatedBy = &id
+	}
+
+	return token, nil
+}
+
+// MarkUsed marks a reset token as used. It fails with an unauthorized error if the
+// token was already used, so a token can only ever be redeemed once.
+func (r *PasswordResetTokenRepositoryImpl) MarkUsed(ctx context.Context, id int) error {
+	query := `UPDATE password_reset_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL`
+
+	result, err := r.DB.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.NewUnauthorizedError("password reset token has already been used")
+	}
+
+	return nil
+}
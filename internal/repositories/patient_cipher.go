@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+
+	"github.com/DingDong039/hms/internal/encryption"
+	"github.com/DingDong039/hms/internal/models"
+)
+
+// Names of the encrypted patient columns, bound to their ciphertext and blind index
+const (
+	fieldNationalID  = "national_id"
+	fieldPassportID  = "passport_id"
+	fieldPhoneNumber = "phone_number"
+	fieldEmail       = "email"
+)
+
+// sealedPatient holds the encrypted PII columns of a patient and their blind indexes.
+// An empty value has an empty index, which is stored as NULL.
+type sealedPatient struct {
+	NationalID, NationalIDIndex   string
+	PassportID, PassportIDIndex   string
+	PhoneNumber, PhoneNumberIndex string
+	Email, EmailIndex             string
+}
+
+// patientCipher encrypts the PII columns of patient records on write and decrypts them on read
+type patientCipher struct {
+	encryptor *encryption.FieldEncryptor
+}
+
+// seal encrypts a patient's PII columns and computes their blind indexes
+func (c patientCipher) seal(ctx context.Context, patient *models.Patient) (*sealedPatient, error) {
+	sealed := &sealedPatient{
+		NationalIDIndex:  c.index(fieldNationalID, patient.NationalID),
+		PassportIDIndex:  c.index(fieldPassportID, patient.PassportID),
+		PhoneNumberIndex: c.index(fieldPhoneNumber, patient.PhoneNumber),
+		EmailIndex:       c.index(fieldEmail, patient.Email),
+	}
+
+	var err error
+	if sealed.NationalID, err = c.encryptor.Encrypt(ctx, fieldNationalID, patient.NationalID); err != nil {
+		return nil, err
+	}
+	if sealed.PassportID, err = c.encryptor.Encrypt(ctx, fieldPassportID, patient.PassportID); err != nil {
+		return nil, err
+	}
+	if sealed.PhoneNumber, err = c.encryptor.Encrypt(ctx, fieldPhoneNumber, patient.PhoneNumber); err != nil {
+		return nil, err
+	}
+	if sealed.Email, err = c.encryptor.Encrypt(ctx, fieldEmail, patient.Email); err != nil {
+		return nil, err
+	}
+
+	return sealed, nil
+}
+
+// open decrypts a patient's PII columns in place
+func (c patientCipher) open(ctx context.Context, patient *models.Patient) error {
+	var err error
+	if patient.NationalID, err = c.encryptor.Decrypt(ctx, fieldNationalID, patient.NationalID); err != nil {
+		return err
+	}
+	if patient.PassportID, err = c.encryptor.Decrypt(ctx, fieldPassportID, patient.PassportID); err != nil {
+		return err
+	}
+	if patient.PhoneNumber, err = c.encryptor.Decrypt(ctx, fieldPhoneNumber, patient.PhoneNumber); err != nil {
+		return err
+	}
+	if patient.Email, err = c.encryptor.Decrypt(ctx, fieldEmail, patient.Email); err != nil {
+		return err
+	}
+	return nil
+}
+
+// scan scans a row selected with patientColumns into a Patient and decrypts it
+func (c patientCipher) scan(ctx context.Context, row rowScanner, extra ...interface{}) (*models.Patient, error) {
+	patient, err := scanPatient(row, extra...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.open(ctx, patient); err != nil {
+		return nil, err
+	}
+	return patient, nil
+}
+
+// sealedCopy returns a copy of a patient with its PII columns encrypted, for storing
+// a whole record, such as a merge snapshot, outside the patients table
+func (c patientCipher) sealedCopy(ctx context.Context, patient *models.Patient) (*models.Patient, error) {
+	sealed, err := c.seal(ctx, patient)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := *patient
+	copied.NationalID = sealed.NationalID
+	copied.PassportID = sealed.PassportID
+	copied.PhoneNumber = sealed.PhoneNumber
+	copied.Email = sealed.Email
+	return &copied, nil
+}
+
+// needsReencryption reports whether any of a stored patient's PII columns is plaintext
+// or encrypted under an old key
+func (c patientCipher) needsReencryption(patient *models.Patient) bool {
+	for _, value := range []string{patient.NationalID, patient.PassportID, patient.PhoneNumber, patient.Email} {
+		if c.encryptor.NeedsReencryption(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// index returns the blind index of a PII value. Emails are matched case-insensitively.
+func (c patientCipher) index(field, value string) string {
+	value = strings.TrimSpace(value)
+	if field == fieldEmail {
+		value = strings.ToLower(value)
+	}
+	return c.encryptor.BlindIndex(field, value)
+}
@@ -0,0 +1,420 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/DingDong039/hms/internal/encryption"
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/lib/pq"
+)
+
+// duplicateNameSimilarity is the minimum trigram similarity of two full names,
+// together with the same date of birth, for patients to be flagged as duplicates
+const duplicateNameSimilarity = 0.6
+
+// PatientMergeRepository defines the interface for duplicate detection and patient merge operations.
+// Every operation is scoped to the hospital carried in the context.
+type PatientMergeRepository interface {
+	DetectCandidates(ctx context.Context) (int, error)
+	FindCandidates(ctx context.Context, query models.PatientDuplicateQueryRequest) ([]*models.PatientDuplicateCandidate, error)
+	DismissCandidate(ctx context.Context, id int) error
+	Merge(ctx context.Context, survivorID, duplicateID int, link *models.PatientLink) (*models.Patient, error)
+	ReencryptLinks(ctx context.Context, batchSize int) (int, error)
+}
+
+// PatientMergeRepositoryImpl implements PatientMergeRepository
+type PatientMergeRepositoryImpl struct {
+	*BaseRepositoryImpl
+	cipher patientCipher
+}
+
+// NewPatientMergeRepository creates a new PatientMergeRepositoryImpl
+func NewPatientMergeRepository(db *sql.DB, encryptor *encryption.FieldEncryptor, logger *slog.Logger) *PatientMergeRepositoryImpl {
+	return &PatientMergeRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+		cipher:             patientCipher{encryptor: encryptor},
+	}
+}
+
+// DetectCandidates records every pair of the caller's hospital's patients sharing a
+// national ID or passport ID, compared by blind index, or a date of birth and a similar
+// name, as a duplicate candidate. Pairs already recorded, including dismissed ones, are left alone.
+// It returns the number of new candidates.
+func (r *PatientMergeRepositoryImpl) DetectCandidates(ctx context.Context) (int, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO patient_duplicate_candidates (hospital_id, patient_id_a, patient_id_b, reason, score)
+		SELECT hospital_id, a_id, b_id, reason, score
+		FROM (
+			SELECT a.hospital_id, a.id AS a_id, b.id AS b_id,
+				CASE
+					WHEN a.national_id_bidx = b.national_id_bidx THEN 'national_id'
+					WHEN a.passport_id_bidx = b.passport_id_bidx THEN 'passport_id'
+					ELSE 'name_dob'
+				END AS reason,
+				CASE
+					WHEN a.national_id_bidx = b.national_id_bidx
+						OR a.passport_id_bidx = b.passport_id_bidx THEN 1.0
+					ELSE GREATEST(
+						similarity(hms_normalize_name(a.first_name_en || ' ' || a.last_name_en),
+							hms_normalize_name(b.first_name_en || ' ' || b.last_name_en)),
+						similarity(hms_normalize_name(a.first_name_th || ' ' || a.last_name_th),
+							hms_normalize_name(b.first_name_th || ' ' || b.last_name_th))
+					)
+				END AS score
+			FROM patients a
+			JOIN patients b ON b.hospital_id = a.hospital_id AND b.id > a.id
+			WHERE a.hospital_id = $1
+				AND (
+					a.national_id_bidx = b.national_id_bidx
+					OR a.passport_id_bidx = b.passport_id_bidx
+					OR a.date_of_birth = b.date_of_birth
+				)
+		) pairs
+		WHERE score >= $2
+		ON CONFLICT (patient_id_a, patient_id_b) DO NOTHING
+	`
+
+	result, err := r.DB.ExecContext(ctx, query, hospitalID, duplicateNameSimilarity)
+	if err != nil {
+		return 0, apperrors.NewInternalServerError(err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return 0, apperrors.NewInternalServerError(err)
+	}
+
+	return int(inserted), nil
+}
+
+// FindCandidates returns the caller's hospital's duplicate candidates with the given status,
+// most likely duplicates first
+func (r *PatientMergeRepositoryImpl) FindCandidates(ctx context.Context, query models.PatientDuplicateQueryRequest) ([]*models.PatientDuplicateCandidate, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQuery := `
+		SELECT id, hospital_id, patient_id_a, patient_id_b, reason, score, status, detected_at
+		FROM patient_duplicate_candidates
+		WHERE hospital_id = $1 AND status = $2
+		ORDER BY score DESC, id
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := r.DB.QueryContext(ctx, sqlQuery, hospitalID, query.Status, query.Limit, query.Offset)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	candidates := []*models.PatientDuplicateCandidate{}
+	var patientIDs []int64
+	for rows.Next() {
+		candidate := &models.PatientDuplicateCandidate{PatientA: &models.Patient{}, PatientB: &models.Patient{}}
+		if err := rows.Scan(
+			&candidate.ID,
+			&candidate.HospitalID,
+			&candidate.PatientA.ID,
+			&candidate.PatientB.ID,
+			&candidate.Reason,
+			&candidate.Score,
+			&candidate.Status,
+			&candidate.DetectedAt,
+		); err != nil {
+			return nil, apperrors.NewInternalServerError(err)
+		}
+		candidates = append(candidates, candidate)
+		patientIDs = append(patientIDs, int64(candidate.PatientA.ID), int64(candidate.PatientB.ID))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	// Load both patients of every candidate
+	patients, err := r.findPatients(ctx, hospitalID, patientIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range candidates {
+		candidate.PatientA = patients[candidate.PatientA.ID]
+		candidate.PatientB = patients[candidate.PatientB.ID]
+	}
+
+	return candidates, nil
+}
+
+// DismissCandidate marks a duplicate candidate of the caller's hospital as not a duplicate
+func (r *PatientMergeRepositoryImpl) DismissCandidate(ctx context.Context, id int) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE patient_duplicate_candidates SET status = $1 WHERE id = $2 AND hospital_id = $3`
+
+	result, err := r.DB.ExecContext(ctx, query, models.DuplicateStatusDismissed, id, hospitalID)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.NewNotFoundError("duplicate candidate not found")
+	}
+
+	return nil
+}
+
+// Merge merges a duplicate patient of the caller's hospital into a survivor in one transaction.
+// The survivor's empty fields are filled from the duplicate, the duplicate is deleted and
+// a link holding its snapshot is stored; links that pointed at the duplicate are moved to
+// the survivor. link must carry MergedBy and Reason; the rest of it is filled in.
+func (r *PatientMergeRepositoryImpl) Merge(ctx context.Context, survivorID, duplicateID int, link *models.PatientLink) (*models.Patient, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var survivor *models.Patient
+	err = r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		// Lock both patients, in ID order so concurrent merges can't deadlock
+		lockQuery := `SELECT id FROM patients WHERE id IN ($1, $2) AND hospital_id = $3 ORDER BY id FOR UPDATE`
+		if _, err := tx.ExecContext(ctx, lockQuery, survivorID, duplicateID, hospitalID); err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		selectQuery := `SELECT ` + patientColumns + ` FROM patients WHERE id = $1 AND hospital_id = $2`
+		if _, err := r.cipher.scan(ctx, tx.QueryRowContext(ctx, selectQuery, survivorID, hospitalID)); err != nil {
+			return notFoundOrInternal(err, "survivor patient not found")
+		}
+		duplicate, err := r.cipher.scan(ctx, tx.QueryRowContext(ctx, selectQuery, duplicateID, hospitalID))
+		if err != nil {
+			return notFoundOrInternal(err, "duplicate patient not found")
+		}
+
+		// Fill the survivor's empty fields from the duplicate. Encrypted values are
+		// self-contained, so they are copied as they are along with their blind indexes.
+		fillQuery := `
+			UPDATE patients s SET
+				national_id = COALESCE(NULLIF(s.national_id, ''), d.national_id),
+				national_id_bidx = COALESCE(s.national_id_bidx, d.national_id_bidx),
+				passport_id = COALESCE(NULLIF(s.passport_id, ''), d.passport_id),
+				passport_id_bidx = COALESCE(s.passport_id_bidx, d.passport_id_bidx),
+				first_name_th = COALESCE(NULLIF(s.first_name_th, ''), d.first_name_th),
+				middle_name_th = COALESCE(NULLIF(s.middle_name_th, ''), d.middle_name_th),
+				last_name_th = COALESCE(NULLIF(s.last_name_th, ''), d.last_name_th),
+				first_name_en = COALESCE(NULLIF(s.first_name_en, ''), d.first_name_en),
+				middle_name_en = COALESCE(NULLIF(s.middle_name_en, ''), d.middle_name_en),
+				last_name_en = COALESCE(NULLIF(s.last_name_en, ''), d.last_name_en),
+				phone_number = COALESCE(NULLIF(s.phone_number, ''), d.phone_number),
+				phone_number_bidx = COALESCE(s.phone_number_bidx, d.phone_number_bidx),
+				email = COALESCE(NULLIF(s.email, ''), d.email),
+				email_bidx = COALESCE(s.email_bidx, d.email_bidx),
+				updated_at = CURRENT_TIMESTAMP
+			FROM patients d
+			WHERE s.id = $1 AND d.id = $2 AND s.hospital_id = $3
+		`
+		if _, err := tx.ExecContext(ctx, fillQuery, survivorID, duplicateID, hospitalID); err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		// Keep the duplicate's record, encrypted like the patient it was, and resolve
+		// its ID and HN to the survivor
+		sealedDuplicate, err := r.cipher.sealedCopy(ctx, duplicate)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+		snapshot, err := json.Marshal(sealedDuplicate)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+		link.HospitalID = hospitalID
+		link.SurvivorID = survivorID
+		link.MergedPatientID = duplicate.ID
+		link.MergedPatientHN = duplicate.PatientHN
+		link.Snapshot = duplicate
+
+		linkQuery := `
+			INSERT INTO patient_links (
+				hospital_id, survivor_id, merged_patient_id, merged_patient_hn, snapshot, merged_by, reason
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+			RETURNING id, merged_at
+		`
+		if err := tx.QueryRowContext(
+			ctx,
+			linkQuery,
+			link.HospitalID,
+			link.SurvivorID,
+			link.MergedPatientID,
+			link.MergedPatientHN,
+			string(snapshot),
+			link.MergedBy,
+			link.Reason,
+		).Scan(&link.ID, &link.MergedAt); err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		// Patients previously merged into the duplicate now resolve to the survivor
+		if _, err := tx.ExecContext(ctx, `UPDATE patient_links SET survivor_id = $1 WHERE survivor_id = $2`, survivorID, duplicateID); err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM patients WHERE id = $1`, duplicateID); err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		survivor, err = r.cipher.scan(ctx, tx.QueryRowContext(ctx, selectQuery, survivorID, hospitalID))
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return survivor, nil
+}
+
+// ReencryptLinks re-encrypts the snapshots of the caller's hospital's merged-away patients
+// that are still plaintext or encrypted under an old key, one transaction per batch.
+// It returns the number of snapshots rewritten.
+func (r *PatientMergeRepositoryImpl) ReencryptLinks(ctx context.Context, batchSize int) (int, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		SELECT id, snapshot
+		FROM patient_links
+		WHERE hospital_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+		FOR UPDATE
+	`
+
+	rewritten, lastID := 0, 0
+	for {
+		count, updated := 0, 0
+		err := r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+			rows, err := tx.QueryContext(ctx, query, hospitalID, lastID, batchSize)
+			if err != nil {
+				return apperrors.NewInternalServerError(err)
+			}
+
+			snapshots := make(map[int]*models.Patient)
+			var ids []int
+			for rows.Next() {
+				var id int
+				var data []byte
+				if err := rows.Scan(&id, &data); err != nil {
+					rows.Close()
+					return apperrors.NewInternalServerError(err)
+				}
+				snapshot := &models.Patient{}
+				if err := json.Unmarshal(data, snapshot); err != nil {
+					rows.Close()
+					return apperrors.NewInternalServerError(err)
+				}
+				snapshots[id] = snapshot
+				ids = append(ids, id)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return apperrors.NewInternalServerError(err)
+			}
+
+			for _, id := range ids {
+				count++
+				lastID = id
+
+				snapshot := snapshots[id]
+				if !r.cipher.needsReencryption(snapshot) {
+					continue
+				}
+				if err := r.cipher.open(ctx, snapshot); err != nil {
+					return apperrors.NewInternalServerError(err)
+				}
+				sealed, err := r.cipher.sealedCopy(ctx, snapshot)
+				if err != nil {
+					return apperrors.NewInternalServerError(err)
+				}
+				data, err := json.Marshal(sealed)
+				if err != nil {
+					return apperrors.NewInternalServerError(err)
+				}
+
+				if _, err := tx.ExecContext(ctx, `UPDATE patient_links SET snapshot = $1 WHERE id = $2`, string(data), id); err != nil {
+					return apperrors.NewInternalServerError(err)
+				}
+				updated++
+			}
+			return nil
+		})
+		if err != nil {
+			return rewritten, err
+		}
+		rewritten += updated
+
+		if count < batchSize {
+			return rewritten, nil
+		}
+	}
+}
+
+// findPatients loads the given patients of a hospital by ID
+func (r *PatientMergeRepositoryImpl) findPatients(ctx context.Context, hospitalID int, ids []int64) (map[int]*models.Patient, error) {
+	query := `SELECT ` + patientColumns + ` FROM patients WHERE hospital_id = $1 AND id = ANY($2)`
+
+	rows, err := r.DB.QueryContext(ctx, query, hospitalID, pq.Array(ids))
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	patients := make(map[int]*models.Patient)
+	for rows.Next() {
+		patient, err := r.cipher.scan(ctx, rows)
+		if err != nil {
+			return nil, apperrors.NewInternalServerError(err)
+		}
+		patients[patient.ID] = patient
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return patients, nil
+}
+
+// notFoundOrInternal maps a missing row to a not found error and anything else to an internal error
+func notFoundOrInternal(err error, message string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.NewNotFoundError(message)
+	}
+	return apperrors.NewInternalServerError(err)
+}
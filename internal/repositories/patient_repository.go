@@ -4,51 +4,100 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/DingDong039/hms/internal/encryption"
 	"github.com/DingDong039/hms/internal/models"
 	apperrors "github.com/DingDong039/hms/pkg/errors"
 )
 
-// PatientRepository defines the interface for patient database operations
+// PatientRepository defines the interface for patient database operations.
+// Every operation is scoped to the hospital carried in the context.
+// National ID, passport ID, phone number and email are encrypted at rest.
 type PatientRepository interface {
 	Create(ctx context.Context, patient *models.Patient) error
 	FindByID(ctx context.Context, id int) (*models.Patient, error)
 	FindByNationalID(ctx context.Context, nationalID string) (*models.Patient, error)
 	FindByPassportID(ctx context.Context, passportID string) (*models.Patient, error)
+	FindByMergedID(ctx context.Context, mergedID int) (*models.Patient, error)
+	Search(ctx context.Context, query models.PatientQueryRequest) ([]*models.Patient, int, error)
+	FuzzySearch(ctx context.Context, query models.PatientFuzzySearchRequest) ([]*models.PatientMatch, error)
+	FindStale(ctx context.Context, sourceHospital string, syncedBefore time.Time, limit int) ([]*models.Patient, error)
+	Upsert(ctx context.Context, patient *models.Patient) error
+	MarkSynced(ctx context.Context, id int, syncedAt time.Time) error
 	Update(ctx context.Context, patient *models.Patient) error
 	Delete(ctx context.Context, id int) error
+	Reencrypt(ctx context.Context, batchSize int) (int, error)
+}
+
+// patientColumns is the column list shared by every patient SELECT, in scanPatient order
+const patientColumns = `
+	id, hospital_id, national_id, passport_id, first_name_th, middle_name_th, last_name_th,
+	first_name_en, middle_name_en, last_name_en, date_of_birth, patient_hn,
+	phone_number, email, gender, created_at, updated_at,
+	COALESCE(source_hospital, ''), last_synced_at
+`
+
+// patientSortColumns whitelists the columns a patient search can be sorted by
+var patientSortColumns = map[string]string{
+	"created_at":    "created_at",
+	"updated_at":    "updated_at",
+	"date_of_birth": "date_of_birth",
+	"patient_hn":    "patient_hn",
+	"first_name_th": "first_name_th",
+	"last_name_th":  "last_name_th",
+	"first_name_en": "LOWER(first_name_en)",
+	"last_name_en":  "LOWER(last_name_en)",
 }
 
 // PatientRepositoryImpl implements PatientRepository
 type PatientRepositoryImpl struct {
 	*BaseRepositoryImpl
+	cipher patientCipher
 }
 
 // NewPatientRepository creates a new PatientRepositoryImpl
-func NewPatientRepository(db *sql.DB) *PatientRepositoryImpl {
+func NewPatientRepository(db *sql.DB, encryptor *encryption.FieldEncryptor, logger *slog.Logger) *PatientRepositoryImpl {
 	return &PatientRepositoryImpl{
-		BaseRepositoryImpl: NewBaseRepository(db),
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+		cipher:             patientCipher{encryptor: encryptor},
 	}
 }
 
-// Create inserts a new patient record into the database
+// Create inserts a new patient record into the caller's hospital
 func (r *PatientRepositoryImpl) Create(ctx context.Context, patient *models.Patient) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+	patient.HospitalID = hospitalID
+
+	sealed, err := r.cipher.seal(ctx, patient)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
 	query := `
 		INSERT INTO patients (
-			national_id, passport_id, first_name_th, middle_name_th, last_name_th,
+			hospital_id, national_id, passport_id, first_name_th, middle_name_th, last_name_th,
 			first_name_en, middle_name_en, last_name_en, date_of_birth, patient_hn,
-			phone_number, email, gender
+			phone_number, email, gender, source_hospital, last_synced_at,
+			national_id_bidx, passport_id_bidx, phone_number_bidx, email_bidx
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16,
+			NULLIF($17, ''), NULLIF($18, ''), NULLIF($19, ''), NULLIF($20, ''))
 		RETURNING id, created_at, updated_at
 	`
 
-	err := r.DB.QueryRowContext(
+	err = r.DB.QueryRowContext(
 		ctx,
 		query,
-		patient.NationalID,
-		patient.PassportID,
+		patient.HospitalID,
+		sealed.NationalID,
+		sealed.PassportID,
 		patient.FirstNameTH,
 		patient.MiddleNameTH,
 		patient.LastNameTH,
@@ -57,9 +106,15 @@ func (r *PatientRepositoryImpl) Create(ctx context.Context, patient *models.Pati
 		patient.LastNameEN,
 		patient.DateOfBirth,
 		patient.PatientHN,
-		patient.PhoneNumber,
-		patient.Email,
+		sealed.PhoneNumber,
+		sealed.Email,
 		patient.Gender,
+		patient.SourceHospital,
+		patient.LastSyncedAt,
+		sealed.NationalIDIndex,
+		sealed.PassportIDIndex,
+		sealed.PhoneNumberIndex,
+		sealed.EmailIndex,
 	).Scan(&patient.ID, &patient.CreatedAt, &patient.UpdatedAt)
 
 	if err != nil {
@@ -69,144 +124,347 @@ func (r *PatientRepositoryImpl) Create(ctx context.Context, patient *models.Pati
 	return nil
 }
 
-// FindByID finds a patient by ID
-func (r *PatientRepositoryImpl) FindByID(ctx context.Context, id int) (*models.Patient, error) {
+// Upsert caches a patient fetched from an upstream hospital into the caller's hospital.
+// patient.SourceHospital and patient.PatientHN identify the record: a record already
+// cached from the same hospital under the same HN is updated in place.
+func (r *PatientRepositoryImpl) Upsert(ctx context.Context, patient *models.Patient) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+	patient.HospitalID = hospitalID
+
+	sealed, err := r.cipher.seal(ctx, patient)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
 	query := `
-		SELECT id, national_id, passport_id, first_name_th, middle_name_th, last_name_th,
+		INSERT INTO patients (
+			hospital_id, national_id, passport_id, first_name_th, middle_name_th, last_name_th,
 			first_name_en, middle_name_en, last_name_en, date_of_birth, patient_hn,
-			phone_number, email, gender, created_at, updated_at
-		FROM patients
-		WHERE id = $1
+			phone_number, email, gender, source_hospital, last_synced_at,
+			national_id_bidx, passport_id_bidx, phone_number_bidx, email_bidx
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16,
+			NULLIF($17, ''), NULLIF($18, ''), NULLIF($19, ''), NULLIF($20, ''))
+		ON CONFLICT (hospital_id, source_hospital, patient_hn) WHERE source_hospital IS NOT NULL
+		DO UPDATE SET
+			national_id = EXCLUDED.national_id, passport_id = EXCLUDED.passport_id,
+			first_name_th = EXCLUDED.first_name_th, middle_name_th = EXCLUDED.middle_name_th,
+			last_name_th = EXCLUDED.last_name_th, first_name_en = EXCLUDED.first_name_en,
+			middle_name_en = EXCLUDED.middle_name_en, last_name_en = EXCLUDED.last_name_en,
+			date_of_birth = EXCLUDED.date_of_birth, phone_number = EXCLUDED.phone_number,
+			email = EXCLUDED.email, gender = EXCLUDED.gender,
+			national_id_bidx = EXCLUDED.national_id_bidx, passport_id_bidx = EXCLUDED.passport_id_bidx,
+			phone_number_bidx = EXCLUDED.phone_number_bidx, email_bidx = EXCLUDED.email_bidx,
+			last_synced_at = EXCLUDED.last_synced_at, updated_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at, updated_at
 	`
 
-	patient := &models.Patient{}
-	err := r.DB.QueryRowContext(ctx, query, id).Scan(
-		&patient.ID,
-		&patient.NationalID,
-		&patient.PassportID,
-		&patient.FirstNameTH,
-		&patient.MiddleNameTH,
-		&patient.LastNameTH,
-		&patient.FirstNameEN,
-		&patient.MiddleNameEN,
-		&patient.LastNameEN,
-		&patient.DateOfBirth,
-		&patient.PatientHN,
-		&patient.PhoneNumber,
-		&patient.Email,
-		&patient.Gender,
-		&patient.CreatedAt,
-		&patient.UpdatedAt,
-	)
+	err = r.DB.QueryRowContext(
+		ctx,
+		query,
+		patient.HospitalID,
+		sealed.NationalID,
+		sealed.PassportID,
+		patient.FirstNameTH,
+		patient.MiddleNameTH,
+		patient.LastNameTH,
+		patient.FirstNameEN,
+		patient.MiddleNameEN,
+		patient.LastNameEN,
+		patient.DateOfBirth,
+		patient.PatientHN,
+		sealed.PhoneNumber,
+		sealed.Email,
+		patient.Gender,
+		patient.SourceHospital,
+		patient.LastSyncedAt,
+		sealed.NationalIDIndex,
+		sealed.PassportIDIndex,
+		sealed.PhoneNumberIndex,
+		sealed.EmailIndex,
+	).Scan(&patient.ID, &patient.CreatedAt, &patient.UpdatedAt)
 
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, apperrors.NewNotFoundError("patient not found")
-		}
-		return nil, apperrors.NewInternalServerError(err)
+		return apperrors.NewInternalServerError(err)
 	}
 
-	return patient, nil
+	return nil
 }
 
-// FindByNationalID finds a patient by national ID and hospital ID
-func (r *PatientRepositoryImpl) FindByNationalID(ctx context.Context, nationalID string) (*models.Patient, error) {
-	query := `
-		SELECT id, national_id, passport_id, first_name_th, middle_name_th, last_name_th,
-			first_name_en, middle_name_en, last_name_en, date_of_birth, patient_hn,
-			phone_number, email, gender, created_at, updated_at
+// MarkSynced records that a cached patient was checked against its upstream hospital
+// without changing its data, e.g. because the hospital no longer knows the patient
+func (r *PatientRepositoryImpl) MarkSynced(ctx context.Context, id int, syncedAt time.Time) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE patients SET last_synced_at = $1 WHERE id = $2 AND hospital_id = $3`
+
+	if _, err := r.DB.ExecContext(ctx, query, syncedAt, id, hospitalID); err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// FindStale returns up to limit patients of the caller's hospital cached from the given
+// upstream hospital and last synced before the given time, least recently synced first
+func (r *PatientRepositoryImpl) FindStale(ctx context.Context, sourceHospital string, syncedBefore time.Time, limit int) ([]*models.Patient, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + patientColumns + `
 		FROM patients
-		WHERE national_id = $1
+		WHERE hospital_id = $1 AND source_hospital = $2
+			AND (last_synced_at IS NULL OR last_synced_at < $3)
+		ORDER BY last_synced_at NULLS FIRST, id
+		LIMIT $4
 	`
 
-	patient := &models.Patient{}
-	err := r.DB.QueryRowContext(ctx, query, nationalID).Scan(
-		&patient.ID,
-		&patient.NationalID,
-		&patient.PassportID,
-		&patient.FirstNameTH,
-		&patient.MiddleNameTH,
-		&patient.LastNameTH,
-		&patient.FirstNameEN,
-		&patient.MiddleNameEN,
-		&patient.LastNameEN,
-		&patient.DateOfBirth,
-		&patient.PatientHN,
-		&patient.PhoneNumber,
-		&patient.Email,
-		&patient.Gender,
-		&patient.CreatedAt,
-		&patient.UpdatedAt,
-	)
-
+	rows, err := r.DB.QueryContext(ctx, query, hospitalID, sourceHospital, syncedBefore, limit)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, apperrors.NewNotFoundError("patient not found")
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	patients := []*models.Patient{}
+	for rows.Next() {
+		patient, err := r.cipher.scan(ctx, rows)
+		if err != nil {
+			return nil, apperrors.NewInternalServerError(err)
 		}
+		patients = append(patients, patient)
+	}
+
+	if err := rows.Err(); err != nil {
 		return nil, apperrors.NewInternalServerError(err)
 	}
 
-	return patient, nil
+	return patients, nil
 }
 
-// FindByPassportID finds a patient by passport ID and hospital ID
+// FindByID finds a patient by ID within the caller's hospital
+func (r *PatientRepositoryImpl) FindByID(ctx context.Context, id int) (*models.Patient, error) {
+	return r.findOne(ctx, "id = $1", id)
+}
+
+// FindByNationalID finds a patient by national ID within the caller's hospital
+func (r *PatientRepositoryImpl) FindByNationalID(ctx context.Context, nationalID string) (*models.Patient, error) {
+	return r.findOne(ctx, "national_id_bidx = $1", r.cipher.index(fieldNationalID, nationalID))
+}
+
+// FindByPassportID finds a patient by passport ID within the caller's hospital
 func (r *PatientRepositoryImpl) FindByPassportID(ctx context.Context, passportID string) (*models.Patient, error) {
-	query := `
-		SELECT id, national_id, passport_id, first_name_th, middle_name_th, last_name_th,
-			first_name_en, middle_name_en, last_name_en, date_of_birth, patient_hn,
-			phone_number, email, gender, created_at, updated_at
+	return r.findOne(ctx, "passport_id_bidx = $1", r.cipher.index(fieldPassportID, passportID))
+}
+
+// FindByMergedID finds the patient that the given merged-away patient was merged into
+func (r *PatientRepositoryImpl) FindByMergedID(ctx context.Context, mergedID int) (*models.Patient, error) {
+	return r.findOne(ctx, "id = (SELECT survivor_id FROM patient_links WHERE merged_patient_id = $1)", mergedID)
+}
+
+// Search returns one page of the caller's hospital's patients matching the query,
+// along with the total number of matches
+func (r *PatientRepositoryImpl) Search(ctx context.Context, query models.PatientQueryRequest) ([]*models.Patient, int, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conditions := []string{"hospital_id = $1"}
+	args := []interface{}{hospitalID}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "$?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if query.FirstName != "" {
+		addCondition(`(first_name_th ILIKE $? || '%' OR first_name_en ILIKE $? || '%')`, escapeLike(query.FirstName))
+	}
+	if query.LastName != "" {
+		addCondition(`(last_name_th ILIKE $? || '%' OR last_name_en ILIKE $? || '%')`, escapeLike(query.LastName))
+	}
+	if !query.DateOfBirth.IsZero() {
+		addCondition("date_of_birth = $?", query.DateOfBirth)
+	}
+	if query.PhoneNumber != "" {
+		addCondition("phone_number_bidx = $?", r.cipher.index(fieldPhoneNumber, query.PhoneNumber))
+	}
+	if query.Email != "" {
+		addCondition("email_bidx = $?", r.cipher.index(fieldEmail, query.Email))
+	}
+	if query.PatientHN != "" {
+		// The HN of a merged-away patient finds the patient it was merged into
+		addCondition(`(patient_hn = $? OR id IN (
+			SELECT survivor_id FROM patient_links WHERE hospital_id = $1 AND merged_patient_hn = $?
+		))`, query.PatientHN)
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM patients WHERE ` + where
+	if err := r.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, apperrors.NewInternalServerError(err)
+	}
+
+	sortColumn, ok := patientSortColumns[query.Sort]
+	if !ok {
+		sortColumn = patientSortColumns["created_at"]
+	}
+	order := "DESC"
+	if query.Order == "asc" {
+		order = "ASC"
+	}
+
+	args = append(args, query.Limit, query.Offset)
+	sqlQuery := fmt.Sprintf(`
+		SELECT %s
 		FROM patients
-		WHERE passport_id = $1
-	`
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT $%d OFFSET $%d
+	`, patientColumns, where, sortColumn, order, order, len(args)-1, len(args))
 
-	patient := &models.Patient{}
-	err := r.DB.QueryRowContext(ctx, query, passportID).Scan(
-		&patient.ID,
-		&patient.NationalID,
-		&patient.PassportID,
-		&patient.FirstNameTH,
-		&patient.MiddleNameTH,
-		&patient.LastNameTH,
-		&patient.FirstNameEN,
-		&patient.MiddleNameEN,
-		&patient.LastNameEN,
-		&patient.DateOfBirth,
-		&patient.PatientHN,
-		&patient.PhoneNumber,
-		&patient.Email,
-		&patient.Gender,
-		&patient.CreatedAt,
-		&patient.UpdatedAt,
-	)
+	rows, err := r.DB.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, 0, apperrors.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	patients := []*models.Patient{}
+	for rows.Next() {
+		patient, err := r.cipher.scan(ctx, rows)
+		if err != nil {
+			return nil, 0, apperrors.NewInternalServerError(err)
+		}
+		patients = append(patients, patient)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, apperrors.NewInternalServerError(err)
+	}
 
+	return patients, total, nil
+}
+
+// FuzzySearch returns the caller's hospital's patients whose names are similar to
+// the query's, best match first. Names in the query must already be normalized
+// with utils.NormalizeName. Each given name scores the better of its Thai and
+// English trigram similarity; a patient's score is the average over the given names.
+func (r *PatientRepositoryImpl) FuzzySearch(ctx context.Context, query models.PatientFuzzySearchRequest) ([]*models.PatientMatch, error) {
+	hospitalID, err := scopedHospitalID(ctx)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, apperrors.NewNotFoundError("patient not found")
+		return nil, err
+	}
+
+	args := []interface{}{hospitalID}
+	var candidates, scores []string
+	addName := func(columnTH, columnEN, name string) {
+		args = append(args, name)
+		n := len(args)
+		candidates = append(candidates, fmt.Sprintf(
+			"hms_normalize_name(%s) %% $%d OR hms_normalize_name(%s) %% $%d", columnTH, n, columnEN, n))
+		scores = append(scores, fmt.Sprintf(
+			"GREATEST(similarity(hms_normalize_name(%s), $%d), similarity(hms_normalize_name(%s), $%d))", columnTH, n, columnEN, n))
+	}
+
+	if query.FirstName != "" {
+		addName("first_name_th", "first_name_en", query.FirstName)
+	}
+	if query.LastName != "" {
+		addName("last_name_th", "last_name_en", query.LastName)
+	}
+	if len(scores) == 0 {
+		return []*models.PatientMatch{}, nil
+	}
+
+	args = append(args, query.MinScore, query.Limit)
+	sqlQuery := fmt.Sprintf(`
+		SELECT %s, score
+		FROM (
+			SELECT *, (%s) / %d.0 AS score
+			FROM patients
+			WHERE hospital_id = $1 AND (%s)
+		) candidates
+		WHERE score >= $%d
+		ORDER BY score DESC, id
+		LIMIT $%d
+	`, patientColumns, strings.Join(scores, " + "), len(scores), strings.Join(candidates, " OR "), len(args)-1, len(args))
+
+	matches := []*models.PatientMatch{}
+	err = r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		// The % operator only uses the trigram indexes with its session threshold,
+		// so lower it to the requested minimum for this transaction
+		if _, err := tx.ExecContext(ctx, `SELECT set_config('pg_trgm.similarity_threshold', $1, true)`, fmt.Sprintf("%g", query.MinScore)); err != nil {
+			return apperrors.NewInternalServerError(err)
 		}
-		return nil, apperrors.NewInternalServerError(err)
+
+		rows, err := tx.QueryContext(ctx, sqlQuery, args...)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			match := &models.PatientMatch{}
+			patient, err := r.cipher.scan(ctx, rows, &match.Score)
+			if err != nil {
+				return apperrors.NewInternalServerError(err)
+			}
+			match.Patient = patient
+			matches = append(matches, match)
+		}
+
+		if err := rows.Err(); err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return patient, nil
+	return matches, nil
 }
 
-// Update updates a patient record
+// Update updates a patient record within the caller's hospital.
+// patient.UpdatedAt must hold the updated_at value the caller last read; if the
+// record has changed since, a conflict error is returned and nothing is written.
 func (r *PatientRepositoryImpl) Update(ctx context.Context, patient *models.Patient) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := r.cipher.seal(ctx, patient)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
 	query := `
 		UPDATE patients
-		SET national_id = $1, passport_id = $2, first_name_th = $3, middle_name_th = $4, 
-			last_name_th = $5, first_name_en = $6, middle_name_en = $7, last_name_en = $8, 
-			date_of_birth = $9, patient_hn = $10, phone_number = $11, email = $12, 
-			gender = $13, updated_at = $14
-		WHERE id = $15
+		SET national_id = $1, passport_id = $2, first_name_th = $3, middle_name_th = $4,
+			last_name_th = $5, first_name_en = $6, middle_name_en = $7, last_name_en = $8,
+			date_of_birth = $9, patient_hn = $10, phone_number = $11, email = $12,
+			gender = $13, updated_at = $14,
+			national_id_bidx = NULLIF($18, ''), passport_id_bidx = NULLIF($19, ''),
+			phone_number_bidx = NULLIF($20, ''), email_bidx = NULLIF($21, '')
+		WHERE id = $15 AND hospital_id = $16 AND updated_at = $17
 		RETURNING updated_at
 	`
 
 	now := time.Now()
-	err := r.DB.QueryRowContext(
+	err = r.DB.QueryRowContext(
 		ctx,
 		query,
-		patient.NationalID,
-		patient.PassportID,
+		sealed.NationalID,
+		sealed.PassportID,
 		patient.FirstNameTH,
 		patient.MiddleNameTH,
 		patient.LastNameTH,
@@ -215,16 +473,26 @@ func (r *PatientRepositoryImpl) Update(ctx context.Context, patient *models.Pati
 		patient.LastNameEN,
 		patient.DateOfBirth,
 		patient.PatientHN,
-		patient.PhoneNumber,
-		patient.Email,
+		sealed.PhoneNumber,
+		sealed.Email,
 		patient.Gender,
 		now,
 		patient.ID,
+		hospitalID,
+		patient.UpdatedAt,
+		sealed.NationalIDIndex,
+		sealed.PassportIDIndex,
+		sealed.PhoneNumberIndex,
+		sealed.EmailIndex,
 	).Scan(&patient.UpdatedAt)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return apperrors.NewNotFoundError("patient not found")
+			// Tell a missing record apart from a stale version
+			if _, findErr := r.FindByID(ctx, patient.ID); findErr != nil {
+				return findErr
+			}
+			return apperrors.NewConflictError("patient was modified by another request")
 		}
 		return apperrors.NewInternalServerError(err)
 	}
@@ -232,12 +500,22 @@ func (r *PatientRepositoryImpl) Update(ctx context.Context, patient *models.Pati
 	return nil
 }
 
-// Delete deletes a patient by ID
+// Delete deletes a patient by ID within the caller's hospital. A patient other patients
+// were merged into can't be deleted.
 func (r *PatientRepositoryImpl) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM patients WHERE id = $1`
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
 
-	result, err := r.DB.ExecContext(ctx, query, id)
+	query := `DELETE FROM patients WHERE id = $1 AND hospital_id = $2`
+
+	result, err := r.DB.ExecContext(ctx, query, id, hospitalID)
 	if err != nil {
+		if isForeignKeyViolation(err) {
+			// Deleting it would lose the merge history of the patients merged into it
+			return apperrors.NewConflictError("patient has merged records and can't be deleted")
+		}
 		return apperrors.NewInternalServerError(err)
 	}
 
@@ -252,3 +530,181 @@ func (r *PatientRepositoryImpl) Delete(ctx context.Context, id int) error {
 
 	return nil
 }
+
+// Reencrypt re-encrypts the PII columns of the caller's hospital's patients that are
+// still plaintext or encrypted under an old key, and refreshes their blind indexes,
+// one transaction per batch. It returns the number of patients rewritten.
+func (r *PatientRepositoryImpl) Reencrypt(ctx context.Context, batchSize int) (int, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		SELECT id, COALESCE(national_id, ''), COALESCE(passport_id, ''),
+			COALESCE(phone_number, ''), COALESCE(email, ''),
+			COALESCE(national_id_bidx, ''), COALESCE(passport_id_bidx, ''),
+			COALESCE(phone_number_bidx, ''), COALESCE(email_bidx, '')
+		FROM patients
+		WHERE hospital_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+		FOR UPDATE
+	`
+	updateQuery := `
+		UPDATE patients
+		SET national_id = $1, passport_id = $2, phone_number = $3, email = $4,
+			national_id_bidx = NULLIF($5, ''), passport_id_bidx = NULLIF($6, ''),
+			phone_number_bidx = NULLIF($7, ''), email_bidx = NULLIF($8, '')
+		WHERE id = $9
+	`
+
+	rewritten, lastID := 0, 0
+	for {
+		count, updated := 0, 0
+		err := r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+			rows, err := tx.QueryContext(ctx, query, hospitalID, lastID, batchSize)
+			if err != nil {
+				return apperrors.NewInternalServerError(err)
+			}
+
+			type storedPatient struct {
+				patient models.Patient
+				indexes sealedPatient
+			}
+			var batch []storedPatient
+			for rows.Next() {
+				var stored storedPatient
+				if err := rows.Scan(
+					&stored.patient.ID,
+					&stored.patient.NationalID,
+					&stored.patient.PassportID,
+					&stored.patient.PhoneNumber,
+					&stored.patient.Email,
+					&stored.indexes.NationalIDIndex,
+					&stored.indexes.PassportIDIndex,
+					&stored.indexes.PhoneNumberIndex,
+					&stored.indexes.EmailIndex,
+				); err != nil {
+					rows.Close()
+					return apperrors.NewInternalServerError(err)
+				}
+				batch = append(batch, stored)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return apperrors.NewInternalServerError(err)
+			}
+
+			for _, stored := range batch {
+				patient := stored.patient
+				count++
+				lastID = patient.ID
+
+				needsReencryption := r.cipher.needsReencryption(&patient)
+				if err := r.cipher.open(ctx, &patient); err != nil {
+					return apperrors.NewInternalServerError(err)
+				}
+				sealed, err := r.cipher.seal(ctx, &patient)
+				if err != nil {
+					return apperrors.NewInternalServerError(err)
+				}
+				if !needsReencryption &&
+					sealed.NationalIDIndex == stored.indexes.NationalIDIndex &&
+					sealed.PassportIDIndex == stored.indexes.PassportIDIndex &&
+					sealed.PhoneNumberIndex == stored.indexes.PhoneNumberIndex &&
+					sealed.EmailIndex == stored.indexes.EmailIndex {
+					continue
+				}
+
+				if _, err := tx.ExecContext(
+					ctx,
+					updateQuery,
+					sealed.NationalID,
+					sealed.PassportID,
+					sealed.PhoneNumber,
+					sealed.Email,
+					sealed.NationalIDIndex,
+					sealed.PassportIDIndex,
+					sealed.PhoneNumberIndex,
+					sealed.EmailIndex,
+					patient.ID,
+				); err != nil {
+					return apperrors.NewInternalServerError(err)
+				}
+				updated++
+			}
+			return nil
+		})
+		if err != nil {
+			return rewritten, err
+		}
+		rewritten += updated
+
+		if count < batchSize {
+			return rewritten, nil
+		}
+	}
+}
+
+// findOne finds a single patient in the caller's hospital matching the given condition.
+// The condition must use $1 for its argument.
+func (r *PatientRepositoryImpl) findOne(ctx context.Context, condition string, arg interface{}) (*models.Patient, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + patientColumns + ` FROM patients WHERE ` + condition + ` AND hospital_id = $2`
+
+	patient, err := r.cipher.scan(ctx, r.DB.QueryRowContext(ctx, query, arg, hospitalID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("patient not found")
+		}
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return patient, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a user-supplied pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPatient scans a row selected with patientColumns into a Patient.
+// Any extra destinations receive the columns selected after patientColumns.
+func scanPatient(row rowScanner, extra ...interface{}) (*models.Patient, error) {
+	patient := &models.Patient{}
+	dest := []interface{}{
+		&patient.ID,
+		&patient.HospitalID,
+		&patient.NationalID,
+		&patient.PassportID,
+		&patient.FirstNameTH,
+		&patient.MiddleNameTH,
+		&patient.LastNameTH,
+		&patient.FirstNameEN,
+		&patient.MiddleNameEN,
+		&patient.LastNameEN,
+		&patient.DateOfBirth,
+		&patient.PatientHN,
+		&patient.PhoneNumber,
+		&patient.Email,
+		&patient.Gender,
+		&patient.CreatedAt,
+		&patient.UpdatedAt,
+		&patient.SourceHospital,
+		&patient.LastSyncedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return patient, nil
+}
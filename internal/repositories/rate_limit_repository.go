@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// RateLimitRepository defines the interface for rate limit bucket database operations.
+// Buckets are keyed by caller rather than owned by a hospital, so they are not scoped.
+type RateLimitRepository interface {
+	Take(ctx context.Context, key string, rate float64, burst int) (models.RateLimitDecision, error)
+	DeleteIdle(ctx context.Context, before time.Time) error
+}
+
+// RateLimitRepositoryImpl implements RateLimitRepository
+type RateLimitRepositoryImpl struct {
+	*BaseRepositoryImpl
+}
+
+// NewRateLimitRepository creates a new RateLimitRepositoryImpl
+func NewRateLimitRepository(db *sql.DB, logger *slog.Logger) *RateLimitRepositoryImpl {
+	return &RateLimitRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+	}
+}
+
+// Take takes a token from a bucket, creating it full if it doesn't exist. The bucket's row
+// is locked while it is updated, and the database's clock is used, so instances sharing
+// the table agree on every bucket.
+func (r *RateLimitRepositoryImpl) Take(ctx context.Context, key string, rate float64, burst int) (models.RateLimitDecision, error) {
+	var decision models.RateLimitDecision
+
+	err := r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, now()) ON CONFLICT (key) DO NOTHING`,
+			key, burst,
+		)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		bucket := &models.TokenBucket{}
+		var now time.Time
+		err = tx.QueryRowContext(ctx,
+			`SELECT tokens, updated_at, now() FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`,
+			key,
+		).Scan(&bucket.Tokens, &bucket.UpdatedAt, &now)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		decision = bucket.Take(now, rate, burst)
+
+		_, err = tx.ExecContext(ctx,
+			`UPDATE rate_limit_buckets SET tokens = $1, updated_at = $2 WHERE key = $3`,
+			bucket.Tokens, bucket.UpdatedAt, key,
+		)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		return nil
+	})
+
+	return decision, err
+}
+
+// DeleteIdle deletes the buckets not used since before, which have refilled by then
+func (r *RateLimitRepositoryImpl) DeleteIdle(ctx context.Context, before time.Time) error {
+	if _, err := r.DB.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < $1`, before); err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+	return nil
+}
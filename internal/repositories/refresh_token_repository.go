@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// RefreshTokenRepository defines the interface for refresh token database operations.
+// Tokens are looked up by hash before the caller is authenticated, so these
+// operations are not scoped to a hospital.
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	Rotate(ctx context.Context, oldID int, next *models.RefreshToken) error
+	RevokeByAccessJTI(ctx context.Context, accessJTI string) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForStaff(ctx context.Context, staffID int) error
+	IsAccessTokenRevoked(ctx context.Context, accessJTI string) (bool, error)
+}
+
+// RefreshTokenRepositoryImpl implements RefreshTokenRepository
+type RefreshTokenRepositoryImpl struct {
+	*BaseRepositoryImpl
+}
+
+// NewRefreshTokenRepository creates a new RefreshTokenRepositoryImpl
+func NewRefreshTokenRepository(db *sql.DB, logger *slog.Logger) *RefreshTokenRepositoryImpl {
+	return &RefreshTokenRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+	}
+}
+
+// Create inserts a new refresh token
+func (r *RefreshTokenRepositoryImpl) Create(ctx context.Context, token *models.RefreshToken) error {
+	return insertRefreshToken(ctx, r.DB, token)
+}
+
+// FindByHash finds a refresh token by the hash of its value
+func (r *RefreshTokenRepositoryImpl) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	query := `
+		SELECT t.id, t.staff_id, s.hospital_id, t.family_id, t.token_hash, t.access_jti,
+			t.expires_at, t.revoked_at, t.replaced_by_id, t.created_at
+		FROM refresh_tokens t
+		JOIN staff s ON s.id = t.staff_id
+		WHERE t.token_hash = $1
+	`
+
+	token := &models.RefreshToken{}
+	var replacedByID sql.NullInt64
+	var revokedAt sql.NullTime
+	err := r.DB.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.StaffID,
+		&token.HospitalID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.AccessJTI,
+		&token.ExpiresAt,
+		&revokedAt,
+		&replacedByID,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("refresh token not found")
+		}
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	if replacedByID.Valid {
+		id := int(replacedByID.Int64)
+		token.ReplacedByID = &id
+	}
+
+	return token, nil
+}
+
+// Rotate revokes the old refresh token and inserts its replacement in one transaction.
+// It fails with an unauthorized error if the old token was already revoked, so a
+// refresh token can only ever be rotated once.
+func (r *RefreshTokenRepositoryImpl) Rotate(ctx context.Context, oldID int, next *models.RefreshToken) error {
+	return r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		if err := insertRefreshToken(ctx, tx, next); err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE refresh_tokens
+			SET revoked_at = $1, replaced_by_id = $2
+			WHERE id = $3 AND revoked_at IS NULL
+		`
+
+		result, err := tx.ExecContext(ctx, query, time.Now(), next.ID, oldID)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		if rowsAffected == 0 {
+			return apperrors.NewUnauthorizedError("refresh token has already been used")
+		}
+
+		return nil
+	})
+}
+
+// RevokeByAccessJTI revokes the session the given access token belongs to
+func (r *RefreshTokenRepositoryImpl) RevokeByAccessJTI(ctx context.Context, accessJTI string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE access_jti = $2 AND revoked_at IS NULL`
+
+	if _, err := r.DB.ExecContext(ctx, query, time.Now(), accessJTI); err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// RevokeFamily revokes every token rotated from the same login
+func (r *RefreshTokenRepositoryImpl) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`
+
+	if _, err := r.DB.ExecContext(ctx, query, time.Now(), familyID); err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// RevokeAllForStaff revokes every session of a staff member
+func (r *RefreshTokenRepositoryImpl) RevokeAllForStaff(ctx context.Context, staffID int) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE staff_id = $2 AND revoked_at IS NULL`
+
+	if _, err := r.DB.ExecContext(ctx, query, time.Now(), staffID); err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether the session an access token was issued with has been
+// revoked. A session that no longer exists, such as one deleted along with its staff member,
+// counts as revoked.
+func (r *RefreshTokenRepositoryImpl) IsAccessTokenRevoked(ctx context.Context, accessJTI string) (bool, error) {
+	query := `
+		SELECT NOT EXISTS (
+			SELECT 1 FROM refresh_tokens WHERE access_jti = $1 AND revoked_at IS NULL
+		)
+	`
+
+	var revoked bool
+	if err := r.DB.QueryRowContext(ctx, query, accessJTI).Scan(&revoked); err != nil {
+		return false, apperrors.NewInternalServerError(err)
+	}
+
+	return revoked, nil
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertRefreshToken inserts a refresh token using the given database or transaction
+func insertRefreshToken(ctx context.Context, db queryRower, token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (staff_id, family_id, token_hash, access_jti, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err := db.QueryRowContext(
+		ctx,
+		query,
+		token.StaffID,
+		token.FamilyID,
+		token.TokenHash,
+		token.AccessJTI,
+		token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// RoleRepository defines the interface for role database operations
+type RoleRepository interface {
+	FindByName(ctx context.Context, name string) (*models.Role, error)
+	FindByID(ctx context.Context, id int) (*models.Role, error)
+	FindPermissionsByRoleID(ctx context.Context, roleID int) ([]string, error)
+}
+
+// RoleRepositoryImpl implements RoleRepository
+type RoleRepositoryImpl struct {
+	*BaseRepositoryImpl
+}
+
+// NewRoleRepository creates a new RoleRepositoryImpl
+func NewRoleRepository(db *sql.DB, logger *slog.Logger) *RoleRepositoryImpl {
+	return &RoleRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+	}
+}
+
+// FindByName finds a role by name, including its permissions
+func (r *RoleRepositoryImpl) FindByName(ctx context.Context, name string) (*models.Role, error) {
+	query := `
+		SELECT id, name, COALESCE(description, ''), created_at
+		FROM roles
+		WHERE name = $1
+	`
+
+	return r.findOne(ctx, query, name)
+}
+
+// FindByID finds a role by ID, including its permissions
+func (r *RoleRepositoryImpl) FindByID(ctx context.Context, id int) (*models.Role, error) {
+	query := `
+		SELECT id, name, COALESCE(description, ''), created_at
+		FROM roles
+		WHERE id = $1
+	`
+
+	return r.findOne(ctx, query, id)
+}
+
+// FindPermissionsByRoleID returns the names of the permissions granted to a role
+func (r *RoleRepositoryImpl) FindPermissionsByRoleID(ctx context.Context, roleID int) ([]string, error) {
+	query := `
+		SELECT p.name
+		FROM role_permissions rp
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE rp.role_id = $1
+		ORDER BY p.name
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, apperrors.NewInternalServerError(err)
+		}
+		permissions = append(permissions, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return permissions, nil
+}
+
+// findOne scans a single role row and loads its permissions
+func (r *RoleRepositoryImpl) findOne(ctx context.Context, query string, arg interface{}) (*models.Role, error) {
+	role := &models.Role{}
+	err := r.DB.QueryRowContext(ctx, query, arg).Scan(
+		&role.ID,
+		&role.Name,
+		&role.Description,
+		&role.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("role not found")
+		}
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	permissions, err := r.FindPermissionsByRoleID(ctx, role.ID)
+	if err != nil {
+		return nil, err
+	}
+	role.Permissions = permissions
+
+	return role, nil
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// StaffIdentityRepository defines the interface for database operations on the identity
+// provider accounts linked to staff
+type StaffIdentityRepository interface {
+	Create(ctx context.Context, identity *models.StaffIdentity) error
+	FindStaffID(ctx context.Context, issuer, subject string) (int, error)
+}
+
+// StaffIdentityRepositoryImpl implements StaffIdentityRepository
+type StaffIdentityRepositoryImpl struct {
+	*BaseRepositoryImpl
+}
+
+// NewStaffIdentityRepository creates a new StaffIdentityRepositoryImpl
+func NewStaffIdentityRepository(db *sql.DB, logger *slog.Logger) *StaffIdentityRepositoryImpl {
+	return &StaffIdentityRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+	}
+}
+
+// Create links an identity provider account to a staff member of the caller's hospital
+func (r *StaffIdentityRepositoryImpl) Create(ctx context.Context, identity *models.StaffIdentity) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+	identity.HospitalID = hospitalID
+
+	query := `
+		INSERT INTO staff_identities (hospital_id, staff_id, issuer, subject)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err = r.DB.QueryRowContext(
+		ctx,
+		query,
+		identity.HospitalID,
+		identity.StaffID,
+		identity.Issuer,
+		identity.Subject,
+	).Scan(&identity.ID, &identity.CreatedAt)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			return apperrors.NewDuplicateResourceError("identity is already linked to a staff member")
+		}
+		return apperrors.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// FindStaffID returns the ID of the staff member of the caller's hospital an identity
+// provider account is linked to
+func (r *StaffIdentityRepositoryImpl) FindStaffID(ctx context.Context, issuer, subject string) (int, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		SELECT staff_id
+		FROM staff_identities
+		WHERE hospital_id = $1 AND issuer = $2 AND subject = $3
+	`
+
+	var staffID int
+	err = r.DB.QueryRowContext(ctx, query, hospitalID, issuer, subject).Scan(&staffID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, apperrors.NewNotFoundError("identity is not linked to a staff member")
+		}
+		return 0, apperrors.NewInternalServerError(err)
+	}
+
+	return staffID, nil
+}
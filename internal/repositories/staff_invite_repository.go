@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// StaffInviteRepository defines the interface for staff invite database operations.
+// Invites are created within the caller's hospital but redeemed before the new staff
+// member is authenticated, so lookups by token are not scoped to a hospital.
+type StaffInviteRepository interface {
+	Create(ctx context.Context, invite *models.StaffInvite) error
+	FindByHash(ctx context.Context, tokenHash string) (*models.StaffInvite, error)
+	MarkAccepted(ctx context.Context, id int) error
+}
+
+// StaffInviteRepositoryImpl implements StaffInviteRepository
+type StaffInviteRepositoryImpl struct {
+	*BaseRepositoryImpl
+}
+
+// NewStaffInviteRepository creates a new StaffInviteRepositoryImpl
+func NewStaffInviteRepository(db *sql.DB, logger *slog.Logger) *StaffInviteRepositoryImpl {
+	return &StaffInviteRepositoryImpl{
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
+	}
+}
+
+// Create inserts a new invite in the caller's hospital, replacing pending invites for
+// the same username so only the newest one can be redeemed
+func (r *StaffInviteRepositoryImpl) Create(ctx context.Context, invite *models.StaffInvite) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+	invite.HospitalID = hospitalID
+
+	return r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`DELETE FROM staff_invites WHERE hospital_id = $1 AND username = $2 AND accepted_at IS NULL`,
+			invite.HospitalID, invite.Username,
+		)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		query := `
+			INSERT INTO staff_invites (hospital_id, username, role_id, token_hash, expires_at, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at
+		`
+
+		err = tx.QueryRowContext(
+			ctx,
+			query,
+			invite.HospitalID,
+			invite.Username,
+			invite.RoleID,
+			invite.TokenHash,
+			invite.ExpiresAt,
+			invite.CreatedBy,
+		).Scan(&invite.ID, &invite.CreatedAt)
+
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		return nil
+	})
+}
+
+// FindByHash finds an invite by the hash of its token
+func (r *StaffInviteRepositoryImpl) FindByHash(ctx context.Context, tokenHash string) (*models.StaffInvite, error) {
+	query := `
+		SELECT i.id, i.hospital_id, i.username, i.role_id, r.name, i.token_hash,
+			i.expires_at, i.accepted_at, i.created_by, i.created_at
+		FROM staff_invites i
+		JOIN roles r ON r.id = i.role_id
+		WHERE i.token_hash = $1
+	`
+
+	invite := &models.StaffInvite{}
+	var acceptedAt sql.NullTime
+	var createdBy sql.NullInt64
+	err := r.DB.QueryRowContext(ctx, query, tokenHash).Scan(
+		&invite.ID,
+		&invite.HospitalID,
+		&invite.Username,
+		&invite.RoleID,
+		&invite.Role,
+		&invite.TokenHash,
+		&invite.ExpiresAt,
+		&acceptedAt,
+		&createdBy,
+		&invite.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("invite not found")
+		}
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	if acceptedAt.Valid {
+		invite.AcceptedAt = &acceptedAt.Time
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		invite.CreatedBy = &id
+	}
+
+	return invite, nil
+}
+
+// MarkAccepted marks an invite as accepted. It fails with an unauthorized error if the
+// invite was already accepted, so an invite can only ever be redeemed once.
+func (r *StaffInviteRepositoryImpl) MarkAccepted(ctx context.Context, id int) error {
+	query := `UPDATE staff_invites SET accepted_at = $1 WHERE id = $2 AND accepted_at IS NULL`
+
+	result, err := r.DB.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.NewUnauthorizedError("invite has already been accepted")
+	}
+
+	return nil
+}
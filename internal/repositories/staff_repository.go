@@ -4,10 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/DingDong039/hms/internal/models"
 	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/lib/pq"
+)
+
+// PostgreSQL error codes of constraint violations
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
 )
 
 // StaffRepository defines the interface for staff database operations
@@ -15,40 +25,71 @@ type StaffRepository interface {
 	Create(ctx context.Context, staff *models.Staff) error
 	FindByUsername(ctx context.Context, username string) (*models.Staff, error)
 	FindByID(ctx context.Context, id int) (*models.Staff, error)
+	Search(ctx context.Context, query models.StaffQueryRequest) ([]*models.Staff, int, error)
 	Update(ctx context.Context, staff *models.Staff) error
 	Delete(ctx context.Context, id int) error
+	RecordFailedLogin(ctx context.Context, staff *models.Staff, maxAttempts int, lockout time.Duration) error
+	ResetFailedLogins(ctx context.Context, id int) error
+	UpdatePassword(ctx context.Context, id int, passwordHash string, mustChange bool, historySize int) error
+	FindPasswordHistory(ctx context.Context, id int, limit int) ([]string, error)
+	CountByRole(ctx context.Context, role string) (int, error)
 }
 
+// staffColumns are the columns scanStaff reads, selected from staffTables
+const staffColumns = `
+	s.id, s.hospital_id, s.username, s.password, s.full_name, s.department, s.role_id, r.name,
+	s.is_active, s.deactivated_at, s.failed_attempts, s.locked_until, s.must_change_password, s.password_changed_at,
+	m.enabled_at IS NOT NULL, s.created_at, s.updated_at
+`
+
+// staffTables joins staff s with their role r and two-factor enrollment m
+const staffTables = `
+	staff s
+	JOIN roles r ON r.id = s.role_id
+	LEFT JOIN staff_mfa m ON m.staff_id = s.id
+`
+
 // StaffRepositoryImpl implements StaffRepository
 type StaffRepositoryImpl struct {
 	*BaseRepositoryImpl
 }
 
 // NewStaffRepository creates a new StaffRepositoryImpl
-func NewStaffRepository(db *sql.DB) *StaffRepositoryImpl {
+func NewStaffRepository(db *sql.DB, logger *slog.Logger) *StaffRepositoryImpl {
 	return &StaffRepositoryImpl{
-		BaseRepositoryImpl: NewBaseRepository(db),
+		BaseRepositoryImpl: NewBaseRepository(db, logger),
 	}
 }
 
 // Create inserts a new staff record into the database
 func (r *StaffRepositoryImpl) Create(ctx context.Context, staff *models.Staff) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+	staff.HospitalID = hospitalID
+
 	query := `
-		INSERT INTO staff (username, password)
-		VALUES ($1, $2)
-		RETURNING id, created_at, updated_at
+		INSERT INTO staff (hospital_id, username, password, full_name, department, role_id, must_change_password)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, is_active, created_at, updated_at
 	`
 
-	err := r.DB.QueryRowContext(
+	err = r.DB.QueryRowContext(
 		ctx,
 		query,
+		staff.HospitalID,
 		staff.Username,
 		staff.Password,
-	).Scan(&staff.ID, &staff.CreatedAt, &staff.UpdatedAt)
+		staff.FullName,
+		staff.Department,
+		staff.RoleID,
+		staff.MustChangePassword,
+	).Scan(&staff.ID, &staff.IsActive, &staff.CreatedAt, &staff.UpdatedAt)
 
 	if err != nil {
-		// Check for duplicate key error
-		if err.Error() == "pq: duplicate key value violates unique constraint" {
+		// Usernames are unique per hospital
+		if isUniqueViolation(err) {
 			return apperrors.NewDuplicateResourceError("staff member already exists")
 		}
 		return apperrors.NewInternalServerError(err)
@@ -57,23 +98,20 @@ func (r *StaffRepositoryImpl) Create(ctx context.Context, staff *models.Staff) e
 	return nil
 }
 
-// FindByUsername finds a staff member by username
+// FindByUsername finds a staff member by username within the caller's hospital
 func (r *StaffRepositoryImpl) FindByUsername(ctx context.Context, username string) (*models.Staff, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, username, password, created_at, updated_at
-		FROM staff
-		WHERE username = $1
+		SELECT ` + staffColumns + `
+		FROM ` + staffTables + `
+		WHERE s.username = $1 AND s.hospital_id = $2
 	`
 
-	staff := &models.Staff{}
-	err := r.DB.QueryRowContext(ctx, query, username).Scan(
-		&staff.ID,
-		&staff.Username,
-		&staff.Password,
-		&staff.CreatedAt,
-		&staff.UpdatedAt,
-	)
-
+	staff, err := scanStaff(r.DB.QueryRowContext(ctx, query, username, hospitalID))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, apperrors.NewNotFoundError("staff member not found")
@@ -86,21 +124,18 @@ func (r *StaffRepositoryImpl) FindByUsername(ctx context.Context, username strin
 
 // FindByID finds a staff member by ID
 func (r *StaffRepositoryImpl) FindByID(ctx context.Context, id int) (*models.Staff, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, username, password, created_at, updated_at
-		FROM staff
-		WHERE id = $1
+		SELECT ` + staffColumns + `
+		FROM ` + staffTables + `
+		WHERE s.id = $1 AND s.hospital_id = $2
 	`
 
-	staff := &models.Staff{}
-	err := r.DB.QueryRowContext(ctx, query, id).Scan(
-		&staff.ID,
-		&staff.Username,
-		&staff.Password,
-		&staff.CreatedAt,
-		&staff.UpdatedAt,
-	)
-
+	staff, err := scanStaff(r.DB.QueryRowContext(ctx, query, id, hospitalID))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, apperrors.NewNotFoundError("staff member not found")
@@ -111,23 +146,102 @@ func (r *StaffRepositoryImpl) FindByID(ctx context.Context, id int) (*models.Sta
 	return staff, nil
 }
 
-// Update updates a staff member record
+// Search returns one page of the caller's hospital's staff matching the query, ordered
+// by username, and the total number of matches
+func (r *StaffRepositoryImpl) Search(ctx context.Context, query models.StaffQueryRequest) ([]*models.Staff, int, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conditions := []string{"s.hospital_id = $1"}
+	args := []interface{}{hospitalID}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if query.Username != "" {
+		addCondition(`s.username ILIKE $%d || '%%'`, escapeLike(query.Username))
+	}
+	if query.FullName != "" {
+		addCondition(`s.full_name ILIKE $%d || '%%'`, escapeLike(query.FullName))
+	}
+	if query.Department != "" {
+		addCondition("s.department = $%d", query.Department)
+	}
+	if query.Role != "" {
+		addCondition("r.name = $%d", query.Role)
+	}
+	if query.IsActive != nil {
+		addCondition("s.is_active = $%d", *query.IsActive)
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM staff s JOIN roles r ON r.id = s.role_id WHERE ` + where
+	if err := r.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, apperrors.NewInternalServerError(err)
+	}
+
+	args = append(args, query.Limit, query.Offset)
+	sqlQuery := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s
+		ORDER BY s.username, s.id
+		LIMIT $%d OFFSET $%d
+	`, staffColumns, staffTables, where, len(args)-1, len(args))
+
+	rows, err := r.DB.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, 0, apperrors.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	staffList := []*models.Staff{}
+	for rows.Next() {
+		staff, err := scanStaff(rows)
+		if err != nil {
+			return nil, 0, apperrors.NewInternalServerError(err)
+		}
+		staffList = append(staffList, staff)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, apperrors.NewInternalServerError(err)
+	}
+
+	return staffList, total, nil
+}
+
+// Update updates a staff member's profile, role and active flag. The username and
+// password are left alone; passwords are changed with UpdatePassword.
 func (r *StaffRepositoryImpl) Update(ctx context.Context, staff *models.Staff) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE staff
-		SET username = $1, password = $2, updated_at = $4
-		WHERE id = $5
+		SET full_name = $1, department = $2, role_id = $3, is_active = $4, deactivated_at = $5, updated_at = $6
+		WHERE id = $7 AND hospital_id = $8
 		RETURNING updated_at
 	`
 
 	now := time.Now()
-	err := r.DB.QueryRowContext(
+	err = r.DB.QueryRowContext(
 		ctx,
 		query,
-		staff.Username,
-		staff.Password,
+		staff.FullName,
+		staff.Department,
+		staff.RoleID,
+		staff.IsActive,
+		staff.DeactivatedAt,
 		now,
 		staff.ID,
+		hospitalID,
 	).Scan(&staff.UpdatedAt)
 
 	if err != nil {
@@ -140,11 +254,94 @@ func (r *StaffRepositoryImpl) Update(ctx context.Context, staff *models.Staff) e
 	return nil
 }
 
-// Delete deletes a staff member by ID
+// Delete deletes a staff member by ID. A staff member with audit events can't be deleted
+// and can only be deactivated.
 func (r *StaffRepositoryImpl) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM staff WHERE id = $1`
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM staff WHERE id = $1 AND hospital_id = $2`
+
+	result, err := r.DB.ExecContext(ctx, query, id, hospitalID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			// The audit trail must keep referring to them
+			return apperrors.NewConflictError("staff member has audit history and can only be deactivated")
+		}
+		return apperrors.NewInternalServerError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.NewNotFoundError("staff member not found")
+	}
+
+	return nil
+}
+
+// RecordFailedLogin counts a failed login against a staff member and locks the account
+// for lockout once maxAttempts consecutive logins have failed. The count starts over
+// after an expired lockout. A maxAttempts of 0 never locks the account.
+// The staff member's FailedAttempts and LockedUntil are updated from the stored row.
+func (r *StaffRepositoryImpl) RecordFailedLogin(ctx context.Context, staff *models.Staff, maxAttempts int, lockout time.Duration) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Counting in a single statement keeps concurrent failures from being lost
+	query := `
+		UPDATE staff
+		SET failed_attempts = CASE WHEN locked_until <= $1 THEN 1 ELSE failed_attempts + 1 END,
+			locked_until = CASE
+				WHEN $2 > 0 AND CASE WHEN locked_until <= $1 THEN 1 ELSE failed_attempts + 1 END >= $2 THEN $3
+				WHEN locked_until <= $1 THEN NULL
+				ELSE locked_until
+			END
+		WHERE id = $4 AND hospital_id = $5
+		RETURNING failed_attempts, locked_until
+	`
+
+	now := time.Now()
+	var lockedUntil sql.NullTime
+	err = r.DB.QueryRowContext(ctx, query, now, maxAttempts, now.Add(lockout), staff.ID, hospitalID).
+		Scan(&staff.FailedAttempts, &lockedUntil)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.NewNotFoundError("staff member not found")
+		}
+		return apperrors.NewInternalServerError(err)
+	}
+
+	staff.LockedUntil = nil
+	if lockedUntil.Valid {
+		staff.LockedUntil = &lockedUntil.Time
+	}
+
+	return nil
+}
+
+// ResetFailedLogins clears a staff member's failed login count and unlocks the account
+func (r *StaffRepositoryImpl) ResetFailedLogins(ctx context.Context, id int) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE staff
+		SET failed_attempts = 0, locked_until = NULL
+		WHERE id = $1 AND hospital_id = $2
+	`
 
-	result, err := r.DB.ExecContext(ctx, query, id)
+	result, err := r.DB.ExecContext(ctx, query, id, hospitalID)
 	if err != nil {
 		return apperrors.NewInternalServerError(err)
 	}
@@ -160,3 +357,179 @@ func (r *StaffRepositoryImpl) Delete(ctx context.Context, id int) error {
 
 	return nil
 }
+
+// UpdatePassword replaces a staff member's password hash, moving the old hash into the
+// password history, of which only the newest historySize entries are kept
+func (r *StaffRepositoryImpl) UpdatePassword(ctx context.Context, id int, passwordHash string, mustChange bool, historySize int) error {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		// Lock the row so concurrent changes can't both record the same old hash
+		var oldHash string
+		err := tx.QueryRowContext(ctx,
+			`SELECT password FROM staff WHERE id = $1 AND hospital_id = $2 FOR UPDATE`,
+			id, hospitalID,
+		).Scan(&oldHash)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return apperrors.NewNotFoundError("staff member not found")
+			}
+			return apperrors.NewInternalServerError(err)
+		}
+
+		now := time.Now()
+		query := `
+			UPDATE staff
+			SET password = $1, must_change_password = $2, password_changed_at = $3, updated_at = $3
+			WHERE id = $4
+		`
+		if _, err := tx.ExecContext(ctx, query, passwordHash, mustChange, now, id); err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		if historySize <= 0 {
+			_, err = tx.ExecContext(ctx, `DELETE FROM password_history WHERE staff_id = $1`, id)
+			if err != nil {
+				return apperrors.NewInternalServerError(err)
+			}
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO password_history (staff_id, password_hash, created_at) VALUES ($1, $2, $3)`,
+			id, oldHash, now,
+		)
+		if err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		query = `
+			DELETE FROM password_history
+			WHERE staff_id = $1 AND id NOT IN (
+				SELECT id FROM password_history
+				WHERE staff_id = $1
+				ORDER BY created_at DESC, id DESC
+				LIMIT $2
+			)
+		`
+		if _, err := tx.ExecContext(ctx, query, id, historySize); err != nil {
+			return apperrors.NewInternalServerError(err)
+		}
+
+		return nil
+	})
+}
+
+// FindPasswordHistory returns a staff member's previous password hashes, newest first
+func (r *StaffRepositoryImpl) FindPasswordHistory(ctx context.Context, id int, limit int) ([]string, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT h.password_hash
+		FROM password_history h
+		JOIN staff s ON s.id = h.staff_id
+		WHERE h.staff_id = $1 AND s.hospital_id = $2
+		ORDER BY h.created_at DESC, h.id DESC
+		LIMIT $3
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, id, hospitalID, limit)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, apperrors.NewInternalServerError(err)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return hashes, nil
+}
+
+// CountByRole counts the active staff of the caller's hospital with the given role
+func (r *StaffRepositoryImpl) CountByRole(ctx context.Context, role string) (int, error) {
+	hospitalID, err := scopedHospitalID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		SELECT COUNT(*)
+		FROM staff s
+		JOIN roles r ON r.id = s.role_id
+		WHERE s.hospital_id = $1 AND r.name = $2 AND s.is_active
+	`
+
+	var count int
+	if err := r.DB.QueryRowContext(ctx, query, hospitalID, role).Scan(&count); err != nil {
+		return 0, apperrors.NewInternalServerError(err)
+	}
+
+	return count, nil
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation
+}
+
+// isForeignKeyViolation reports whether err is a PostgreSQL foreign key violation,
+// such as deleting a row other rows still reference
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqForeignKeyViolation
+}
+
+// scanStaff scans a row selected with staffColumns into a Staff
+func scanStaff(row rowScanner) (*models.Staff, error) {
+	staff := &models.Staff{}
+	var deactivatedAt, lockedUntil, passwordChangedAt sql.NullTime
+	err := row.Scan(
+		&staff.ID,
+		&staff.HospitalID,
+		&staff.Username,
+		&staff.Password,
+		&staff.FullName,
+		&staff.Department,
+		&staff.RoleID,
+		&staff.Role,
+		&staff.IsActive,
+		&deactivatedAt,
+		&staff.FailedAttempts,
+		&lockedUntil,
+		&staff.MustChangePassword,
+		&passwordChangedAt,
+		&staff.MFAEnabled,
+		&staff.CreatedAt,
+		&staff.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if deactivatedAt.Valid {
+		staff.DeactivatedAt = &deactivatedAt.Time
+	}
+	if lockedUntil.Valid {
+		staff.LockedUntil = &lockedUntil.Time
+	}
+	if passwordChangedAt.Valid {
+		staff.PasswordChangedAt = &passwordChangedAt.Time
+	}
+	return staff, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/repositories"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// apiKeyDisplayLength is how much of an API key is kept in the clear to tell keys apart
+const apiKeyDisplayLength = len(models.APIClientKeyPrefix) + 8
+
+// APIClientService defines the interface for managing the API clients of the caller's hospital
+type APIClientService interface {
+	CreateClient(ctx context.Context, req models.APIClientCreateRequest) (*models.APIClientCreateResponse, error)
+	ListClients(ctx context.Context) ([]*models.APIClient, error)
+	GetClient(ctx context.Context, id int) (*models.APIClient, error)
+	RevokeClient(ctx context.Context, id int) (*models.APIClient, error)
+}
+
+// APIClientServiceImpl implements APIClientService
+type APIClientServiceImpl struct {
+	apiClientRepo repositories.APIClientRepository
+	auditService  AuditService
+	config        *config.Config
+}
+
+// NewAPIClientService creates a new APIClientServiceImpl
+func NewAPIClientService(apiClientRepo repositories.APIClientRepository, auditService AuditService, config *config.Config) *APIClientServiceImpl {
+	return &APIClientServiceImpl{
+		apiClientRepo: apiClientRepo,
+		auditService:  auditService,
+		config:        config,
+	}
+}
+
+// CreateClient registers an API client of the caller's hospital and issues its key.
+// Only the key's hash is stored, so the key is returned once, to be installed in the client.
+func (s *APIClientServiceImpl) CreateClient(ctx context.Context, req models.APIClientCreateRequest) (*models.APIClientCreateResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, apperrors.NewInvalidInputError("name is required")
+	}
+
+	for _, scope := range req.Scopes {
+		if !slices.Contains(models.APIClientScopes, scope) {
+			return nil, apperrors.NewInvalidInputError(fmt.Sprintf("scope %q can't be granted to API clients", scope))
+		}
+	}
+
+	now := time.Now()
+	maxExpiry := now.Add(s.config.APIClients.MaxKeyTTL)
+	expiresAt := maxExpiry
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			return nil, apperrors.NewInvalidInputError("expiry must be in the future")
+		}
+		if req.ExpiresAt.After(maxExpiry) {
+			return nil, apperrors.NewInvalidInputError("expiry is further away than API keys may last")
+		}
+		expiresAt = *req.ExpiresAt
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	key := models.APIClientKeyPrefix + secret
+
+	client := &models.APIClient{
+		Name:      name,
+		KeyPrefix: key[:apiKeyDisplayLength],
+		KeyHash:   utils.HashToken(key),
+		Scopes:    dedupeScopes(req.Scopes),
+		ExpiresAt: expiresAt,
+	}
+	if staffID, ok := utils.StaffIDFromContext(ctx); ok {
+		client.CreatedBy = &staffID
+	}
+
+	if err := s.apiClientRepo.Create(ctx, client); err != nil {
+		return nil, err
+	}
+
+	err = s.auditService.Record(ctx, &models.AuditEvent{
+		Action: models.AuditActionAPIClientCreate,
+		Source: models.AuditSourceLocal,
+		Details: map[string]string{
+			"api_client_id": strconv.Itoa(client.ID),
+			"name":          client.Name,
+			"scopes":        strings.Join(client.Scopes, " "),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.APIClientCreateResponse{Key: key, Client: client}, nil
+}
+
+// ListClients returns the API clients of the caller's hospital, including revoked ones
+func (s *APIClientServiceImpl) ListClients(ctx context.Context) ([]*models.APIClient, error) {
+	return s.apiClientRepo.List(ctx)
+}
+
+// GetClient returns an API client of the caller's hospital
+func (s *APIClientServiceImpl) GetClient(ctx context.Context, id int) (*models.APIClient, error) {
+	return s.apiClientRepo.FindByID(ctx, id)
+}
+
+// RevokeClient revokes an API client of the caller's hospital. Its key is refused from
+// the next request on; a revoked client can't be restored, a new one is issued instead.
+func (s *APIClientServiceImpl) RevokeClient(ctx context.Context, id int) (*models.APIClient, error) {
+	client, err := s.apiClientRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.apiClientRepo.Revoke(ctx, client); err != nil {
+		return nil, err
+	}
+
+	err = s.auditService.Record(ctx, &models.AuditEvent{
+		Action: models.AuditActionAPIClientRevoke,
+		Source: models.AuditSourceLocal,
+		Details: map[string]string{
+			"api_client_id": strconv.Itoa(client.ID),
+			"name":          client.Name,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// dedupeScopes returns scopes without repeats, in the order first given
+func dedupeScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/repositories"
+	"github.com/DingDong039/hms/internal/utils"
+)
+
+// defaultAuditQueryLimit is used when an audit query doesn't set a limit
+const defaultAuditQueryLimit = 50
+
+// AuditService defines the interface for recording and querying the audit trail
+type AuditService interface {
+	Record(ctx context.Context, event *models.AuditEvent) error
+	Query(ctx context.Context, req models.AuditQueryRequest) ([]*models.AuditEvent, error)
+}
+
+// AuditServiceImpl implements AuditService
+type AuditServiceImpl struct {
+	auditRepo repositories.AuditRepository
+}
+
+// NewAuditService creates a new AuditServiceImpl
+func NewAuditService(auditRepo repositories.AuditRepository) *AuditServiceImpl {
+	return &AuditServiceImpl{
+		auditRepo: auditRepo,
+	}
+}
+
+// Record appends an event to the audit trail.
+// The acting staff member or API client, client IP and request ID are taken from the context.
+func (s *AuditServiceImpl) Record(ctx context.Context, event *models.AuditEvent) error {
+	if staffID, ok := utils.StaffIDFromContext(ctx); ok {
+		event.StaffID = &staffID
+	}
+	if clientID, ok := utils.APIClientIDFromContext(ctx); ok {
+		event.APIClientID = &clientID
+	}
+	event.ClientIP = utils.ClientIPFromContext(ctx)
+	event.RequestID = utils.RequestIDFromContext(ctx)
+
+	return s.auditRepo.Create(ctx, event)
+}
+
+// Query returns the audit events of the caller's hospital matching the request
+func (s *AuditServiceImpl) Query(ctx context.Context, req models.AuditQueryRequest) ([]*models.AuditEvent, error) {
+	if req.Limit == 0 {
+		req.Limit = defaultAuditQueryLimit
+	}
+
+	return s.auditRepo.Find(ctx, req)
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// recoveryCodeSize is the number of random bytes in a recovery code
+const recoveryCodeSize = 10
+
+// recoveryCodeEncoding encodes recovery codes, which are shown in lowercase
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// VerifyMFA completes a two-step login: it checks a TOTP or recovery code against the
+// staff member the MFA token was issued to, and returns an access token and a refresh
+// token. Wrong codes count towards locking the account like wrong passwords.
+func (s *AuthServiceImpl) VerifyMFA(ctx context.Context, req models.MFAVerifyRequest) (*models.StaffLoginResponse, error) {
+	challenge, err := utils.ValidateMFAToken(req.MFAToken, s.jwtKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = utils.WithHospitalID(ctx, challenge.HospitalID)
+	staff, err := s.staffRepo.FindByID(ctx, challenge.UserID)
+	if err != nil || !staff.IsActive {
+		return nil, apperrors.NewUnauthorizedError("invalid MFA token")
+	}
+
+	if staff.IsLocked(time.Now()) {
+		return nil, apperrors.NewLockedError("account is temporarily locked after too many failed logins")
+	}
+
+	attempt := &models.LoginAttempt{
+		HospitalID: &staff.HospitalID,
+		Username:   staff.Username,
+		ClientIP:   utils.ClientIPFromContext(ctx),
+	}
+
+	if err := s.verifyMFACode(ctx, staff.ID, req.Code); err != nil {
+		protection := s.config.Login
+		if err := s.staffRepo.RecordFailedLogin(ctx, staff, protection.MaxFailedAttempts, protection.LockoutDuration); err != nil {
+			return nil, err
+		}
+		return nil, s.failLogin(ctx, attempt, staff.FailedAttempts)
+	}
+
+	if staff.FailedAttempts > 0 || staff.LockedUntil != nil {
+		if err := s.staffRepo.ResetFailedLogins(ctx, staff.ID); err != nil {
+			return nil, err
+		}
+	}
+	attempt.Succeeded = true
+	if err := s.loginAttemptRepo.Create(ctx, attempt); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, staff)
+}
+
+// EnrollMFA starts two-factor enrollment for the caller with a new TOTP secret, which
+// replaces any enrollment that hasn't been confirmed yet
+func (s *AuthServiceImpl) EnrollMFA(ctx context.Context, claims *utils.JWTClaims) (*models.MFAEnrollResponse, error) {
+	staff, err := s.staffRepo.FindByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if staff.MFAEnabled {
+		return nil, apperrors.NewConflictError("two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	if err := s.mfaRepo.CreatePending(ctx, staff.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.config.MFA.Issuer, staff.Username, secret),
+	}, nil
+}
+
+// ConfirmMFA enables the caller's pending enrollment with a first TOTP code and returns
+// their recovery codes. Every session of the caller is revoked, since they were started
+// without a second factor, and a new session is returned in place of the current one.
+func (s *AuthServiceImpl) ConfirmMFA(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) (*models.MFAConfirmResponse, error) {
+	staff, err := s.staffRepo.FindByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.mfaRepo.FindByStaffID(ctx, staff.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled() {
+		return nil, apperrors.NewConflictError("two-factor authentication is already enabled")
+	}
+
+	counter, ok := utils.ValidateTOTPCode(mfa.Secret, normalizeTOTPCode(req.Code), time.Now())
+	if !ok {
+		return nil, apperrors.NewInvalidInputError("invalid code")
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.Enable(ctx, staff.ID, counter, hashes); err != nil {
+		return nil, err
+	}
+	staff.MFAEnabled = true
+
+	if err := s.refreshTokenRepo.RevokeAllForStaff(ctx, staff.ID); err != nil {
+		return nil, err
+	}
+	session, err := s.startSession(ctx, staff)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MFAConfirmResponse{RecoveryCodes: codes, Session: session}, nil
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes, confirmed with a code
+func (s *AuthServiceImpl) RegenerateRecoveryCodes(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) (*models.MFARecoveryCodesResponse, error) {
+	if err := s.verifyMFACode(ctx, claims.UserID, req.Code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, claims.UserID, hashes); err != nil {
+		return nil, err
+	}
+
+	return &models.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableMFA turns off two-factor authentication for the caller, confirmed with a code.
+// Staff whose role requires it can't turn it off.
+func (s *AuthServiceImpl) DisableMFA(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) error {
+	if s.config.MFA.RequiredFor(claims.Role) {
+		return apperrors.NewForbiddenError("two-factor authentication is required for your role")
+	}
+
+	if err := s.verifyMFACode(ctx, claims.UserID, req.Code); err != nil {
+		return err
+	}
+
+	return s.mfaRepo.Delete(ctx, claims.UserID)
+}
+
+// verifyMFACode checks a TOTP or recovery code of a staff member with two-factor
+// authentication enabled and uses it up, so it can't be presented again
+func (s *AuthServiceImpl) verifyMFACode(ctx context.Context, staffID int, code string) error {
+	invalid := apperrors.NewUnauthorizedError("invalid code")
+
+	mfa, err := s.mfaRepo.FindByStaffID(ctx, staffID)
+	if err != nil || !mfa.Enabled() {
+		return invalid
+	}
+
+	// Six digits are a TOTP code; anything else can only be a recovery code
+	if totpCode := normalizeTOTPCode(code); len(totpCode) == 6 && isDigits(totpCode) {
+		counter, ok := utils.ValidateTOTPCode(mfa.Secret, totpCode, time.Now())
+		if !ok || counter <= mfa.LastUsedCounter {
+			return invalid
+		}
+		return s.mfaRepo.UseCounter(ctx, staffID, counter)
+	}
+
+	return s.mfaRepo.UseRecoveryCode(ctx, staffID, utils.HashToken(normalizeRecoveryCode(code)))
+}
+
+// mfaChallenge returns the response to the password step of a two-step login
+func (s *AuthServiceImpl) mfaChallenge(staff *models.Staff) (*models.StaffLoginResponse, error) {
+	token, expiresAt, err := utils.GenerateMFAToken(staff.ID, staff.HospitalID, s.config.MFA.ChallengeTTL, s.jwtKeys)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	return &models.StaffLoginResponse{
+		MFARequired:  true,
+		MFAToken:     token,
+		MFAExpiresAt: expiresAt,
+	}, nil
+}
+
+// mfaEnrollmentRequired reports whether a staff member must enroll in two-factor
+// authentication before they can use the API
+func (s *AuthServiceImpl) mfaEnrollmentRequired(staff *models.Staff) bool {
+	return s.config.MFA.RequiredFor(staff.Role) && !staff.MFAEnabled
+}
+
+// generateRecoveryCodes returns new recovery codes, formatted as xxxx-xxxx-xxxx-xxxx,
+// and the hashes they are stored as
+func (s *AuthServiceImpl) generateRecoveryCodes() ([]string, []string, error) {
+	count := s.config.MFA.RecoveryCodeCount
+	codes := make([]string, 0, count)
+	hashes := make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		raw := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, apperrors.NewInternalServerError(err)
+		}
+		encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))
+
+		groups := make([]string, 0, len(encoded)/4)
+		for j := 0; j < len(encoded); j += 4 {
+			groups = append(groups, encoded[j:j+4])
+		}
+
+		codes = append(codes, strings.Join(groups, "-"))
+		hashes = append(hashes, utils.HashToken(encoded))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeTOTPCode strips the spaces authenticator apps show in the middle of codes
+func normalizeTOTPCode(code string) string {
+	return strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+}
+
+// normalizeRecoveryCode reduces a recovery code to the form its hash is computed from,
+// so it can be entered in any case, with or without separators
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// isDigits reports whether s consists only of ASCII digits
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
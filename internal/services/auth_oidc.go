@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// maxUsernameLength and maxFullNameLength are the sizes of the staff columns identity
+// provider claims are stored in
+const (
+	maxUsernameLength = 50
+	maxFullNameLength = 100
+)
+
+// StartOIDCLogin starts a sign-in at the identity provider of the requested hospital and
+// returns the URL to send the user to. The state, nonce and PKCE verifier of the sign-in
+// stay on the server until the callback redeems them.
+func (s *AuthServiceImpl) StartOIDCLogin(ctx context.Context, req models.OIDCLoginRequest) (string, error) {
+	code := req.HospitalCode
+	if code == "" {
+		code = s.config.Tenancy.DefaultHospitalCode
+	}
+
+	provider, ok := s.oidcProviders[code]
+	if !ok {
+		return "", apperrors.NewNotFoundError("single sign-on is not configured for this hospital")
+	}
+
+	ctx, err := s.withHospital(ctx, code)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", apperrors.NewInternalServerError(err)
+	}
+	codeVerifier, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", apperrors.NewInternalServerError(err)
+	}
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", apperrors.NewInternalServerError(err)
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		return "", err
+	}
+
+	pending := &models.OIDCLoginState{
+		StateHash:    utils.HashToken(state),
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(s.config.OIDC.StateTTL),
+	}
+	if err := s.oidcStateRepo.Create(ctx, pending); err != nil {
+		return "", err
+	}
+
+	return authURL, nil
+}
+
+// CompleteOIDCLogin completes a sign-in when the identity provider redirects back: it
+// redeems the authorization code, verifies the ID token, and signs in the staff member
+// linked to the identity, linking or creating one on first sign-in. The identity
+// provider takes the place of the password; staff using two-factor authentication still
+// get an MFA token for the second step, as with Login.
+func (s *AuthServiceImpl) CompleteOIDCLogin(ctx context.Context, req models.OIDCCallbackRequest) (*models.StaffLoginResponse, error) {
+	invalid := apperrors.NewUnauthorizedError("invalid or expired sign-in")
+
+	// Redeeming the state first means it can't be used again, whatever the outcome
+	pending, err := s.oidcStateRepo.Consume(ctx, utils.HashToken(req.State))
+	if err != nil {
+		return nil, invalid
+	}
+	if time.Now().After(pending.ExpiresAt) {
+		return nil, invalid
+	}
+	if req.Error != "" {
+		return nil, apperrors.NewUnauthorizedError("sign-in was refused by the identity provider")
+	}
+
+	ctx = utils.WithHospitalID(ctx, pending.HospitalID)
+	hospital, err := s.hospitalRepo.FindByID(ctx, pending.HospitalID)
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := s.oidcProviders[hospital.Code]
+	if !ok {
+		return nil, invalid
+	}
+
+	identity, err := provider.Exchange(ctx, req.Code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	staff, err := s.oidcStaff(ctx, provider, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt := &models.LoginAttempt{
+		HospitalID: &staff.HospitalID,
+		Username:   staff.Username,
+		ClientIP:   utils.ClientIPFromContext(ctx),
+		Succeeded:  true,
+	}
+	if err := s.loginAttemptRepo.Create(ctx, attempt); err != nil {
+		return nil, err
+	}
+
+	if staff.MFAEnabled {
+		return s.mfaChallenge(staff)
+	}
+
+	return s.startSession(ctx, staff)
+}
+
+// oidcStaff returns the active staff member an identity signs in as, linking or creating
+// one on first sign-in, with their role brought in line with their identity provider groups
+func (s *AuthServiceImpl) oidcStaff(ctx context.Context, provider *OIDCProvider, identity *models.OIDCIdentity) (*models.Staff, error) {
+	// Staff whose groups no longer grant a role lose access, even if they signed in before
+	roleName := provider.RoleFor(identity.Groups)
+	if roleName == "" {
+		return nil, apperrors.NewForbiddenError("your identity provider groups don't grant access")
+	}
+	role, err := s.roleRepo.FindByName(ctx, roleName)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(fmt.Errorf("identity provider groups map to role %q: %w", roleName, err))
+	}
+
+	staffID, err := s.identityRepo.FindStaffID(ctx, identity.Issuer, identity.Subject)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return s.linkOIDCStaff(ctx, provider, identity, role)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	staff, err := s.staffRepo.FindByID(ctx, staffID)
+	if err != nil {
+		return nil, err
+	}
+	if !staff.IsActive {
+		return nil, apperrors.NewForbiddenError("staff account is deactivated")
+	}
+
+	if err := s.syncOIDCRole(ctx, staff, role, identity); err != nil {
+		return nil, err
+	}
+	return staff, nil
+}
+
+// linkOIDCStaff links an identity signing in for the first time to the staff member with
+// its username, if the provider allows linking, or else creates a staff member for it, if
+// the provider allows provisioning
+func (s *AuthServiceImpl) linkOIDCStaff(ctx context.Context, provider *OIDCProvider, identity *models.OIDCIdentity, role *models.Role) (*models.Staff, error) {
+	if identity.Username == "" || utf8.RuneCountInString(identity.Username) > maxUsernameLength {
+		return nil, apperrors.NewUnauthorizedError("ID token has no usable username")
+	}
+
+	staff, err := s.staffRepo.FindByUsername(ctx, identity.Username)
+	switch {
+	case err == nil:
+		// Linking by username trusts the identity provider with every local account
+		if !provider.config.LinkExisting {
+			return nil, apperrors.NewConflictError("a staff member with this username already exists")
+		}
+		if !staff.IsActive {
+			return nil, apperrors.NewForbiddenError("staff account is deactivated")
+		}
+		if err := s.syncOIDCRole(ctx, staff, role, identity); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, apperrors.ErrNotFound):
+		if !provider.config.AutoProvision {
+			return nil, apperrors.NewForbiddenError("no staff account exists for this identity")
+		}
+		if staff, err = s.provisionOIDCStaff(ctx, identity, role); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	link := &models.StaffIdentity{
+		StaffID: staff.ID,
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+	}
+	if err := s.identityRepo.Create(ctx, link); err != nil {
+		return nil, err
+	}
+
+	return staff, nil
+}
+
+// provisionOIDCStaff creates a staff member for an identity signing in for the first time.
+// They get a random password nobody knows, so they can only sign in through the identity
+// provider unless an administrator resets it.
+func (s *AuthServiceImpl) provisionOIDCStaff(ctx context.Context, identity *models.OIDCIdentity, role *models.Role) (*models.Staff, error) {
+	password, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	fullName := []rune(identity.FullName)
+	if len(fullName) > maxFullNameLength {
+		fullName = fullName[:maxFullNameLength]
+	}
+
+	staff := &models.Staff{
+		Username: identity.Username,
+		Password: hashedPassword,
+		FullName: string(fullName),
+		RoleID:   role.ID,
+		Role:     role.Name,
+	}
+	if err := s.staffRepo.Create(ctx, staff); err != nil {
+		return nil, err
+	}
+
+	err = s.auditService.Record(utils.WithStaffID(ctx, staff.ID), &models.AuditEvent{
+		Action: models.AuditActionStaffCreate,
+		Source: models.AuditSourceLocal,
+		Details: map[string]string{
+			"staff_id":          strconv.Itoa(staff.ID),
+			"role":              staff.Role,
+			"identity_provider": identity.Issuer,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return staff, nil
+}
+
+// syncOIDCRole gives a staff member the role their identity provider groups grant. Their
+// sessions are revoked on a change, since their tokens carry the old role's permissions.
+func (s *AuthServiceImpl) syncOIDCRole(ctx context.Context, staff *models.Staff, role *models.Role, identity *models.OIDCIdentity) error {
+	if staff.RoleID == role.ID {
+		return nil
+	}
+
+	staff.RoleID = role.ID
+	staff.Role = role.Name
+	if err := s.staffRepo.Update(ctx, staff); err != nil {
+		return err
+	}
+	if err := s.refreshTokenRepo.RevokeAllForStaff(ctx, staff.ID); err != nil {
+		return err
+	}
+
+	return s.auditService.Record(utils.WithStaffID(ctx, staff.ID), &models.AuditEvent{
+		Action: models.AuditActionStaffUpdate,
+		Source: models.AuditSourceLocal,
+		Details: map[string]string{
+			"staff_id":          strconv.Itoa(staff.ID),
+			"fields":            "role",
+			"role":              staff.Role,
+			"identity_provider": identity.Issuer,
+		},
+	})
+}
@@ -2,6 +2,10 @@ package services
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/DingDong039/hms/internal/config"
 	"github.com/DingDong039/hms/internal/models"
@@ -12,75 +16,486 @@ import (
 
 // AuthService defines the interface for authentication operations
 type AuthService interface {
-	CreateStaff(ctx context.Context, req models.StaffCreateRequest) (*models.Staff, error)
 	Login(ctx context.Context, req models.StaffLoginRequest) (*models.StaffLoginResponse, error)
-	ValidateToken(tokenString string) (*utils.JWTClaims, error)
+	VerifyMFA(ctx context.Context, req models.MFAVerifyRequest) (*models.StaffLoginResponse, error)
+	Refresh(ctx context.Context, req models.RefreshTokenRequest) (*models.StaffLoginResponse, error)
+	Logout(ctx context.Context, claims *utils.JWTClaims, req models.LogoutRequest) error
+	ChangePassword(ctx context.Context, claims *utils.JWTClaims, req models.PasswordChangeRequest) (*models.StaffLoginResponse, error)
+	ResetPassword(ctx context.Context, req models.PasswordResetRequest) error
+	EnrollMFA(ctx context.Context, claims *utils.JWTClaims) (*models.MFAEnrollResponse, error)
+	ConfirmMFA(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) (*models.MFAConfirmResponse, error)
+	RegenerateRecoveryCodes(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) (*models.MFARecoveryCodesResponse, error)
+	DisableMFA(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) error
+	StartOIDCLogin(ctx context.Context, req models.OIDCLoginRequest) (string, error)
+	CompleteOIDCLogin(ctx context.Context, req models.OIDCCallbackRequest) (*models.StaffLoginResponse, error)
+	ValidateToken(ctx context.Context, tokenString string) (*utils.JWTClaims, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (*utils.JWTClaims, error)
 }
 
 // AuthServiceImpl implements AuthService
 type AuthServiceImpl struct {
-	staffRepo repositories.StaffRepository
-	config    *config.Config
+	staffRepo         repositories.StaffRepository
+	roleRepo          repositories.RoleRepository
+	hospitalRepo      repositories.HospitalRepository
+	refreshTokenRepo  repositories.RefreshTokenRepository
+	loginAttemptRepo  repositories.LoginAttemptRepository
+	passwordResetRepo repositories.PasswordResetTokenRepository
+	mfaRepo           repositories.MFARepository
+	identityRepo      repositories.StaffIdentityRepository
+	oidcStateRepo     repositories.OIDCLoginStateRepository
+	apiClientRepo     repositories.APIClientRepository
+	oidcProviders     map[string]*OIDCProvider // Keyed by hospital code
+	auditService      AuditService
+	jwtKeys           *utils.JWTKeySet
+	config            *config.Config
 }
 
 // NewAuthService creates a new AuthServiceImpl
-func NewAuthService(staffRepo repositories.StaffRepository, config *config.Config) *AuthServiceImpl {
+func NewAuthService(
+	staffRepo repositories.StaffRepository,
+	roleRepo repositories.RoleRepository,
+	hospitalRepo repositories.HospitalRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	loginAttemptRepo repositories.LoginAttemptRepository,
+	passwordResetRepo repositories.PasswordResetTokenRepository,
+	mfaRepo repositories.MFARepository,
+	identityRepo repositories.StaffIdentityRepository,
+	oidcStateRepo repositories.OIDCLoginStateRepository,
+	apiClientRepo repositories.APIClientRepository,
+	oidcProviders map[string]*OIDCProvider,
+	auditService AuditService,
+	jwtKeys *utils.JWTKeySet,
+	config *config.Config,
+) *AuthServiceImpl {
 	return &AuthServiceImpl{
-		staffRepo: staffRepo,
-		config:    config,
+		staffRepo:         staffRepo,
+		roleRepo:          roleRepo,
+		hospitalRepo:      hospitalRepo,
+		refreshTokenRepo:  refreshTokenRepo,
+		loginAttemptRepo:  loginAttemptRepo,
+		passwordResetRepo: passwordResetRepo,
+		mfaRepo:           mfaRepo,
+		identityRepo:      identityRepo,
+		oidcStateRepo:     oidcStateRepo,
+		apiClientRepo:     apiClientRepo,
+		oidcProviders:     oidcProviders,
+		auditService:      auditService,
+		jwtKeys:           jwtKeys,
+		config:            config,
 	}
 }
 
-// CreateStaff creates a new staff member
-func (s *AuthServiceImpl) CreateStaff(ctx context.Context, req models.StaffCreateRequest) (*models.Staff, error) {
-	// Hash password
-	hashedPassword, err := utils.HashPassword(req.Password)
+// Login authenticates a staff member and returns an access token and a refresh token.
+// For staff using two-factor authentication it returns an MFA token instead, which
+// VerifyMFA exchanges for the tokens along with a code.
+// Failed logins are answered after a delay that grows with each failure; too many lock
+// the account for a while, and too many from one IP address refuse that address.
+func (s *AuthServiceImpl) Login(ctx context.Context, req models.StaffLoginRequest) (*models.StaffLoginResponse, error) {
+	protection := s.config.Login
+	clientIP := utils.ClientIPFromContext(ctx)
+
+	// Refuse addresses that have been guessing, before doing any work for them
+	if protection.IPMaxFailedAttempts > 0 {
+		failures, err := s.loginAttemptRepo.CountFailuresByIP(ctx, clientIP, time.Now().Add(-protection.IPWindow))
+		if err != nil {
+			return nil, err
+		}
+		if failures >= protection.IPMaxFailedAttempts {
+			return nil, apperrors.NewTooManyRequestsError("too many failed login attempts, try again later")
+		}
+	}
+
+	attempt := &models.LoginAttempt{Username: req.Username, ClientIP: clientIP}
+
+	// Scope the lookup to the requested hospital
+	ctx, err := s.withHospital(ctx, req.HospitalCode)
 	if err != nil {
-		return nil, apperrors.NewInternalServerError(err)
+		if !errors.Is(err, apperrors.ErrNotFound) {
+			return nil, err
+		}
+		return nil, s.refuseLogin(ctx, attempt, req.Password)
+	}
+	if hospitalID, ok := utils.HospitalIDFromContext(ctx); ok {
+		attempt.HospitalID = &hospitalID
+	}
+
+	// Find staff by username and hospital ID; deactivated staff are refused like unknown ones
+	staff, err := s.staffRepo.FindByUsername(ctx, req.Username)
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil || !staff.IsActive {
+		return nil, s.refuseLogin(ctx, attempt, req.Password)
 	}
 
-	// Create staff model
-	staff := &models.Staff{
-		Username: req.Username,
-		Password: hashedPassword,
+	// A locked account is refused whatever the password, so guessing can't continue. It is
+	// answered like an unknown username, or a lockout would reveal that the account exists.
+	if staff.IsLocked(time.Now()) {
+		return nil, s.refuseLogin(ctx, attempt, req.Password)
+	}
+
+	// Check password
+	if !utils.CheckPasswordHash(req.Password, staff.Password) {
+		if err := s.staffRepo.RecordFailedLogin(ctx, staff, protection.MaxFailedAttempts, protection.LockoutDuration); err != nil {
+			return nil, err
+		}
+		return nil, s.failLogin(ctx, attempt, staff.FailedAttempts)
 	}
 
-	// Save to database
-	if err := s.staffRepo.Create(ctx, staff); err != nil {
+	// A successful login starts the failure count over
+	if staff.FailedAttempts > 0 || staff.LockedUntil != nil {
+		if err := s.staffRepo.ResetFailedLogins(ctx, staff.ID); err != nil {
+			return nil, err
+		}
+	}
+	attempt.Succeeded = true
+	if err := s.loginAttemptRepo.Create(ctx, attempt); err != nil {
 		return nil, err
 	}
 
-	// Don't return the password
-	staff.Password = ""
-	return staff, nil
+	if staff.MFAEnabled {
+		return s.mfaChallenge(staff)
+	}
+
+	return s.startSession(ctx, staff)
 }
 
-// Login authenticates a staff member and returns a JWT token
-func (s *AuthServiceImpl) Login(ctx context.Context, req models.StaffLoginRequest) (*models.StaffLoginResponse, error) {
-	// Find staff by username and hospital ID
-	staff, err := s.staffRepo.FindByUsername(ctx, req.Username)
+// Refresh exchanges a refresh token for a new access token and a new refresh token.
+// Each refresh token can be used once; presenting an already-rotated token is treated
+// as theft and revokes every session descended from the same login.
+func (s *AuthServiceImpl) Refresh(ctx context.Context, req models.RefreshTokenRequest) (*models.StaffLoginResponse, error) {
+	// Find the stored token
+	current, err := s.refreshTokenRepo.FindByHash(ctx, utils.HashToken(req.RefreshToken))
 	if err != nil {
-		return nil, apperrors.NewUnauthorizedError("invalid credentials")
+		return nil, apperrors.NewUnauthorizedError("invalid refresh token")
 	}
 
-	// Check password
-	if !utils.CheckPasswordHash(req.Password, staff.Password) {
-		return nil, apperrors.NewUnauthorizedError("invalid credentials")
+	// Detect reuse of a rotated or revoked token
+	if current.RevokedAt != nil {
+		if err := s.refreshTokenRepo.RevokeFamily(ctx, current.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, apperrors.NewUnauthorizedError("invalid refresh token")
 	}
 
-	// Generate JWT token
-	token, expiresAt, err := utils.GenerateToken(staff.ID, s.config.JWT)
+	if time.Now().After(current.ExpiresAt) {
+		return nil, apperrors.NewUnauthorizedError("refresh token has expired")
+	}
+
+	// Reload the staff member so role changes take effect on refresh
+	ctx = utils.WithHospitalID(ctx, current.HospitalID)
+	staff, err := s.staffRepo.FindByID(ctx, current.StaffID)
+	if err != nil || !staff.IsActive {
+		return nil, apperrors.NewUnauthorizedError("invalid refresh token")
+	}
+
+	response, next, err := s.issueTokens(ctx, staff, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokenRepo.Rotate(ctx, current.ID, next); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Logout revokes the caller's current session, or all of their sessions
+func (s *AuthServiceImpl) Logout(ctx context.Context, claims *utils.JWTClaims, req models.LogoutRequest) error {
+	if req.All {
+		return s.refreshTokenRepo.RevokeAllForStaff(ctx, claims.UserID)
+	}
+	return s.refreshTokenRepo.RevokeByAccessJTI(ctx, claims.ID)
+}
+
+// ChangePassword changes the caller's own password. Every session of the caller is
+// revoked, since the old password may have been known to someone else, and a new
+// session is returned in place of the current one.
+func (s *AuthServiceImpl) ChangePassword(ctx context.Context, claims *utils.JWTClaims, req models.PasswordChangeRequest) (*models.StaffLoginResponse, error) {
+	staff, err := s.staffRepo.FindByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !utils.CheckPasswordHash(req.CurrentPassword, staff.Password) {
+		return nil, apperrors.NewInvalidInputError("current password is incorrect")
+	}
+
+	if err := s.checkNewPassword(ctx, staff, req.NewPassword); err != nil {
+		return nil, err
+	}
+	if err := s.storePassword(ctx, staff, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokenRepo.RevokeAllForStaff(ctx, staff.ID); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, staff)
+}
+
+// ResetPassword sets a new password with a one-time reset token issued by an administrator.
+// Every session of the staff member is revoked and a locked account is unlocked.
+func (s *AuthServiceImpl) ResetPassword(ctx context.Context, req models.PasswordResetRequest) error {
+	invalid := apperrors.NewUnauthorizedError("invalid or expired password reset token")
+
+	token, err := s.passwordResetRepo.FindByHash(ctx, utils.HashToken(req.Token))
+	if err != nil {
+		return invalid
+	}
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return invalid
+	}
+
+	ctx = utils.WithHospitalID(ctx, token.HospitalID)
+	staff, err := s.staffRepo.FindByID(ctx, token.StaffID)
+	if err != nil || !staff.IsActive {
+		return invalid
+	}
+
+	// Check the password before using up the token, so a rejected password can be retried
+	if err := s.checkNewPassword(ctx, staff, req.NewPassword); err != nil {
+		return err
+	}
+	if err := s.passwordResetRepo.MarkUsed(ctx, token.ID); err != nil {
+		return err
+	}
+	if err := s.storePassword(ctx, staff, req.NewPassword); err != nil {
+		return err
+	}
+
+	if err := s.refreshTokenRepo.RevokeAllForStaff(ctx, staff.ID); err != nil {
+		return err
+	}
+	if staff.FailedAttempts > 0 || staff.LockedUntil != nil {
+		return s.staffRepo.ResetFailedLogins(ctx, staff.ID)
+	}
+	return nil
+}
+
+// ValidateToken validates a JWT token, rejects revoked tokens, and returns the claims
+func (s *AuthServiceImpl) ValidateToken(ctx context.Context, tokenString string) (*utils.JWTClaims, error) {
+	claims, err := utils.ValidateToken(tokenString, s.jwtKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every access token is tied to a session and can be revoked by its ID
+	if claims.ID == "" {
+		return nil, apperrors.NewUnauthorizedError("token has no ID")
+	}
+
+	revoked, err := s.refreshTokenRepo.IsAccessTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, apperrors.NewUnauthorizedError("token has been revoked")
+	}
+
+	return claims, nil
+}
+
+// AuthenticateAPIKey identifies the API client a key belongs to and returns claims for it
+// carrying the client's scopes as permissions, so it passes the same permission checks
+// as staff. Revoked and expired keys are refused.
+func (s *AuthServiceImpl) AuthenticateAPIKey(ctx context.Context, key string) (*utils.JWTClaims, error) {
+	invalid := apperrors.NewUnauthorizedError("invalid or expired API key")
+
+	if !strings.HasPrefix(key, models.APIClientKeyPrefix) {
+		return nil, invalid
+	}
+
+	client, err := s.apiClientRepo.FindByHash(ctx, utils.HashToken(key))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	if !client.IsUsable(time.Now()) {
+		return nil, invalid
+	}
+
+	if err := s.apiClientRepo.TouchLastUsed(utils.WithHospitalID(ctx, client.HospitalID), client.ID); err != nil {
+		return nil, err
+	}
+
+	return &utils.JWTClaims{
+		HospitalID:  client.HospitalID,
+		Permissions: client.Scopes,
+		APIClientID: client.ID,
+	}, nil
+}
+
+// checkNewPassword checks a staff member's new password against the password policy
+// and rejects the current password and recent previous ones
+func (s *AuthServiceImpl) checkNewPassword(ctx context.Context, staff *models.Staff, password string) error {
+	if err := ValidatePassword(s.config.Password, staff.Username, password); err != nil {
+		return err
+	}
+
+	previous := []string{staff.Password}
+	if s.config.Password.HistorySize > 0 {
+		history, err := s.staffRepo.FindPasswordHistory(ctx, staff.ID, s.config.Password.HistorySize)
+		if err != nil {
+			return err
+		}
+		previous = append(previous, history...)
+	}
+
+	for _, hash := range previous {
+		if utils.CheckPasswordHash(password, hash) {
+			return NewValidationError("password was used recently")
+		}
+	}
+
+	return nil
+}
+
+// storePassword hashes and stores a staff member's new password, which they chose themselves
+func (s *AuthServiceImpl) storePassword(ctx context.Context, staff *models.Staff, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+
+	if err := s.staffRepo.UpdatePassword(ctx, staff.ID, hashedPassword, false, s.config.Password.HistorySize); err != nil {
+		return err
+	}
+
+	staff.Password = hashedPassword
+	staff.MustChangePassword = false
+	return nil
+}
+
+// startSession issues and stores the tokens of a new session for the staff member
+func (s *AuthServiceImpl) startSession(ctx context.Context, staff *models.Staff) (*models.StaffLoginResponse, error) {
+	familyID, err := utils.GenerateRandomToken(16)
 	if err != nil {
 		return nil, apperrors.NewInternalServerError(err)
 	}
 
+	response, refreshToken, err := s.issueTokens(ctx, staff, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokenRepo.Create(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// issueTokens signs an access token for the staff member and creates (but does not
+// store) the refresh token that belongs to it
+func (s *AuthServiceImpl) issueTokens(ctx context.Context, staff *models.Staff, familyID string) (*models.StaffLoginResponse, *models.RefreshToken, error) {
+	// Load the permissions granted by the staff member's role
+	permissions, err := s.roleRepo.FindPermissionsByRoleID(ctx, staff.RoleID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Generate JWT token
+	claims := &utils.JWTClaims{
+		UserID:                 staff.ID,
+		HospitalID:             staff.HospitalID,
+		Role:                   staff.Role,
+		Permissions:            permissions,
+		PasswordChangeRequired: staff.MustChangePassword,
+		MFAEnrollmentRequired:  s.mfaEnrollmentRequired(staff),
+	}
+	token, expiresAt, err := utils.GenerateToken(claims, s.config.JWT.AccessTokenTTL, s.jwtKeys)
+	if err != nil {
+		return nil, nil, apperrors.NewInternalServerError(err)
+	}
+
+	// Generate refresh token
+	refreshTokenValue, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, nil, apperrors.NewInternalServerError(err)
+	}
+
+	refreshToken := &models.RefreshToken{
+		StaffID:   staff.ID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(refreshTokenValue),
+		AccessJTI: claims.ID,
+		ExpiresAt: time.Now().Add(s.config.JWT.RefreshTokenTTL),
+	}
+
 	return &models.StaffLoginResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
-	}, nil
+		Token:            token,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshTokenValue,
+		RefreshExpiresAt: refreshToken.ExpiresAt.Unix(),
+
+		PasswordChangeRequired: claims.PasswordChangeRequired,
+		MFAEnrollmentRequired:  claims.MFAEnrollmentRequired,
+	}, refreshToken, nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
-func (s *AuthServiceImpl) ValidateToken(tokenString string) (*utils.JWTClaims, error) {
-	return utils.ValidateToken(tokenString, s.config.JWT)
+// failLogin records a failed login attempt and waits before returning the error to
+// report, longer for each consecutive failure
+func (s *AuthServiceImpl) failLogin(ctx context.Context, attempt *models.LoginAttempt, failures int) error {
+	if err := s.loginAttemptRepo.Create(ctx, attempt); err != nil {
+		return err
+	}
+
+	// The answer is the same whether or not the wait was cut short by the request ending
+	sleepContext(ctx, s.failureDelay(failures))
+	return apperrors.NewUnauthorizedError("invalid credentials")
+}
+
+// dummyPasswordHash is compared against when there is no account to check. It is hashed
+// like staff passwords, so the comparison takes as long as a real one.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := utils.HashPassword("no account has this password")
+	return hash
+})
+
+// refuseLogin refuses a login that can't succeed whatever the password, because there is no
+// active account behind it or the account is locked. It does the work a wrong password costs,
+// a bcrypt comparison and a delay growing with the username's recent failures, so neither
+// the answer nor its timing reveals which usernames exist.
+func (s *AuthServiceImpl) refuseLogin(ctx context.Context, attempt *models.LoginAttempt, password string) error {
+	utils.CheckPasswordHash(password, dummyPasswordHash())
+
+	since := time.Now().Add(-s.config.Login.LockoutDuration)
+	failures, err := s.loginAttemptRepo.CountFailuresByUsername(ctx, attempt.HospitalID, attempt.Username, since)
+	if err != nil {
+		return err
+	}
+	return s.failLogin(ctx, attempt, failures+1)
+}
+
+// failureDelay returns the delay before answering the given number of consecutive
+// failed logins: FailureDelay, doubled for each further failure up to MaxFailureDelay
+func (s *AuthServiceImpl) failureDelay(failures int) time.Duration {
+	protection := s.config.Login
+	delay := protection.FailureDelay
+	for i := 1; i < failures && delay < protection.MaxFailureDelay; i++ {
+		delay *= 2
+	}
+	if protection.MaxFailureDelay > 0 && delay > protection.MaxFailureDelay {
+		delay = protection.MaxFailureDelay
+	}
+	return delay
+}
+
+// withHospital resolves a hospital code (or the configured default) and scopes ctx to it
+func (s *AuthServiceImpl) withHospital(ctx context.Context, code string) (context.Context, error) {
+	if code == "" {
+		code = s.config.Tenancy.DefaultHospitalCode
+	}
+
+	hospital, err := s.hospitalRepo.FindByCode(ctx, code)
+	if err != nil {
+		return ctx, err
+	}
+
+	return utils.WithHospitalID(ctx, hospital.ID), nil
 }
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+)
+
+// CircuitBreaker stops calls to an upstream that keeps failing.
+// After FailureThreshold consecutive failures the circuit opens and calls fail fast.
+// Once the cooldown has passed a single trial call is let through: its success
+// closes the circuit, its failure opens it for another cooldown.
+type CircuitBreaker struct {
+	cfg config.CircuitBreakerConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker creates a new closed CircuitBreaker
+func NewCircuitBreaker(cfg config.CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		cfg:   cfg,
+		now:   time.Now,
+		state: models.CircuitClosed,
+	}
+}
+
+// Allow reports whether a call may be made now
+func (b *CircuitBreaker) Allow() bool {
+	if b.cfg.FailureThreshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case models.CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.Cooldown {
+			return false
+		}
+		b.state = models.CircuitHalfOpen
+		b.probing = true
+		return true
+	case models.CircuitHalfOpen:
+		// Only one trial call at a time
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// RecordSuccess records a successful call and closes the circuit
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = models.CircuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// RecordFailure records a failed call, opening the circuit if the threshold is reached
+// or the failed call was a trial call
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.cfg.FailureThreshold <= 0 {
+		return
+	}
+
+	if b.state == models.CircuitHalfOpen || b.failures >= b.cfg.FailureThreshold {
+		b.state = models.CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Release records a call that ended without an outcome, such as one its caller cancelled.
+// The circuit's state is unchanged, but a trial call's slot is given back so the next
+// call can be the trial instead.
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// State returns the current state of the circuit and the number of consecutive failures
+func (b *CircuitBreaker) State() (string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state
+	if state == models.CircuitOpen && b.now().Sub(b.openedAt) >= b.cfg.Cooldown {
+		// The next call will be a trial call
+		state = models.CircuitHalfOpen
+	}
+	return state, b.failures
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// HospitalAPIRegistry holds the named HospitalAPIService adapters of every
+// upstream hospital and routes patient lookups to one or all of them
+type HospitalAPIRegistry struct {
+	names    []string
+	adapters map[string]HospitalAPIService
+}
+
+// NewHospitalAPIRegistry creates an empty HospitalAPIRegistry
+func NewHospitalAPIRegistry() *HospitalAPIRegistry {
+	return &HospitalAPIRegistry{
+		adapters: make(map[string]HospitalAPIService),
+	}
+}
+
+// NewHospitalAPIRegistryFromConfig creates a HospitalAPIRegistry with one adapter per configured hospital
+func NewHospitalAPIRegistryFromConfig(cfg config.HospitalAPIConfig) (*HospitalAPIRegistry, error) {
+	registry := NewHospitalAPIRegistry()
+
+	for _, endpoint := range cfg.Hospitals {
+		if cfg.Mode == "mock" {
+			registry.Register(endpoint.Name, NewMockHospitalAAPIService())
+			continue
+		}
+
+		if endpoint.BaseURL == "" {
+			return nil, fmt.Errorf("hospital API %q has no base URL", endpoint.Name)
+		}
+		registry.Register(endpoint.Name, NewHTTPHospitalAPIService(endpoint))
+	}
+
+	return registry, nil
+}
+
+// Register adds an adapter under the given name, replacing any adapter already registered with it
+func (r *HospitalAPIRegistry) Register(name string, adapter HospitalAPIService) {
+	if _, exists := r.adapters[name]; !exists {
+		r.names = append(r.names, name)
+	}
+	r.adapters[name] = adapter
+}
+
+// Get returns the adapter registered under the given name
+func (r *HospitalAPIRegistry) Get(name string) (HospitalAPIService, bool) {
+	adapter, ok := r.adapters[name]
+	return adapter, ok
+}
+
+// Names returns the registered hospital names in registration order
+func (r *HospitalAPIRegistry) Names() []string {
+	names := make([]string, len(r.names))
+	copy(names, r.names)
+	return names
+}
+
+// Health returns the health of every registered hospital that tracks it, in registration order
+func (r *HospitalAPIRegistry) Health() []models.HospitalAPIHealth {
+	health := []models.HospitalAPIHealth{}
+	for _, name := range r.names {
+		if reporter, ok := r.adapters[name].(interface {
+			Health() models.HospitalAPIHealth
+		}); ok {
+			health = append(health, reporter.Health())
+		}
+	}
+	return health
+}
+
+// SearchPatient looks up a patient in the named hospital
+func (r *HospitalAPIRegistry) SearchPatient(ctx context.Context, hospital, id string) (*models.PatientSearchResponse, error) {
+	adapter, ok := r.Get(hospital)
+	if !ok {
+		return nil, apperrors.NewInvalidInputError(fmt.Sprintf("unknown hospital %q", hospital))
+	}
+
+	response, err := adapter.SearchPatient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	response.SourceHospital = hospital
+	return response, nil
+}
+
+// SearchAll looks up a patient in every registered hospital concurrently and merges the results.
+// The first hospital (in registration order) that knows the patient provides the record; fields it
+// leaves empty are filled in from the other hospitals' records. The record keeps the providing
+// hospital's HN, since it is cached and refreshed under that hospital.
+func (r *HospitalAPIRegistry) SearchAll(ctx context.Context, id string) (*models.PatientSearchResponse, error) {
+	if len(r.names) == 0 {
+		return nil, apperrors.NewNotFoundError("patient not found")
+	}
+
+	responses := make([]*models.PatientSearchResponse, len(r.names))
+	errs := make([]error, len(r.names))
+
+	var wg sync.WaitGroup
+	for i, name := range r.names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			responses[i], errs[i] = r.SearchPatient(ctx, name, id)
+		}(i, name)
+	}
+	wg.Wait()
+
+	var merged *models.PatientSearchResponse
+	var upstreamErr error
+	for i := range r.names {
+		if errs[i] != nil {
+			// A hospital that doesn't know the patient is not a failure
+			if !errors.Is(errs[i], apperrors.ErrNotFound) && upstreamErr == nil {
+				upstreamErr = errs[i]
+			}
+			continue
+		}
+
+		if merged == nil {
+			merged = responses[i]
+			continue
+		}
+		mergePatientSearchResponse(merged, responses[i])
+	}
+
+	if merged != nil {
+		return merged, nil
+	}
+	if upstreamErr != nil {
+		return nil, upstreamErr
+	}
+	return nil, apperrors.NewNotFoundError("patient not found")
+}
+
+// mergePatientSearchResponse fills the empty fields of dst from src. The HN and source hospital
+// identify the record within one hospital and are never taken from another.
+func mergePatientSearchResponse(dst, src *models.PatientSearchResponse) {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+
+	fill(&dst.FirstNameTH, src.FirstNameTH)
+	fill(&dst.MiddleNameTH, src.MiddleNameTH)
+	fill(&dst.LastNameTH, src.LastNameTH)
+	fill(&dst.FirstNameEN, src.FirstNameEN)
+	fill(&dst.MiddleNameEN, src.MiddleNameEN)
+	fill(&dst.LastNameEN, src.LastNameEN)
+	fill(&dst.NationalID, src.NationalID)
+	fill(&dst.PassportID, src.PassportID)
+	fill(&dst.PhoneNumber, src.PhoneNumber)
+	fill(&dst.Email, src.Email)
+	fill(&dst.Gender, src.Gender)
+
+	if dst.DateOfBirth.IsZero() {
+		dst.DateOfBirth = src.DateOfBirth
+	}
+}
@@ -1,67 +1,174 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/DingDong039/hms/internal/config"
 	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
 	apperrors "github.com/DingDong039/hms/pkg/errors"
 )
 
-// HospitalAPIService defines the interface for external hospital API operations
+// HospitalAPIService defines the interface for external hospital API operations.
+// Calls stop when the context is cancelled or its deadline passes.
 type HospitalAPIService interface {
-	SearchPatient(id string) (*models.PatientSearchResponse, error)
+	SearchPatient(ctx context.Context, id string) (*models.PatientSearchResponse, error)
 }
 
-// HospitalAAPIService implements HospitalAPIService for Hospital A
-type HospitalAAPIService struct {
-	config *config.Config
-	client *http.Client
+// HTTPHospitalAPIService implements HospitalAPIService for a hospital exposing
+// the standard GET /patient/search/{id} endpoint. Failed calls are retried with
+// jittered exponential backoff, and a circuit breaker fails fast while the
+// hospital is down.
+type HTTPHospitalAPIService struct {
+	endpoint config.HospitalEndpointConfig
+	client   *http.Client
+	breaker  *CircuitBreaker
 }
 
-// NewHospitalAAPIService creates a new HospitalAAPIService
-func NewHospitalAAPIService(config *config.Config) *HospitalAAPIService {
-	return &HospitalAAPIService{
-		config: config,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+// NewHTTPHospitalAPIService creates a new HTTPHospitalAPIService for the given hospital
+func NewHTTPHospitalAPIService(endpoint config.HospitalEndpointConfig) *HTTPHospitalAPIService {
+	return &HTTPHospitalAPIService{
+		endpoint: endpoint,
+		client:   &http.Client{},
+		breaker:  NewCircuitBreaker(endpoint.Breaker),
 	}
 }
 
-// SearchPatient searches for a patient in Hospital A's API
-func (s *HospitalAAPIService) SearchPatient(id string) (*models.PatientSearchResponse, error) {
+// SearchPatient searches for a patient in the hospital's API.
+// Each attempt gets the hospital's timeout or whatever is left of the context's deadline,
+// whichever is shorter, and no retry is started once the context is done.
+func (s *HTTPHospitalAPIService) SearchPatient(ctx context.Context, id string) (*models.PatientSearchResponse, error) {
+	if !s.breaker.Allow() {
+		return nil, apperrors.NewUnavailableError(fmt.Sprintf("%s API is unavailable", s.endpoint.Name))
+	}
+
 	// Build the URL
-	url := fmt.Sprintf("%s/patient/search/%s", s.config.HospitalAPI.HospitalABaseURL, id)
+	searchURL := fmt.Sprintf("%s/patient/search/%s", s.endpoint.BaseURL, url.PathEscape(id))
+
+	var patient *models.PatientSearchResponse
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		patient, retryable, err = s.search(ctx, searchURL)
+		if !retryable || attempt >= s.endpoint.Retry.MaxRetries || !sleepContext(ctx, s.backoff(attempt)) {
+			break
+		}
+	}
+
+	switch {
+	case err == nil || errors.Is(err, apperrors.ErrNotFound):
+		// An unknown patient is a healthy answer
+		s.breaker.RecordSuccess()
+	case errors.Is(ctx.Err(), context.Canceled):
+		// The caller went away; that says nothing about the hospital
+		s.breaker.Release()
+	default:
+		s.breaker.RecordFailure()
+	}
+
+	return patient, err
+}
+
+// Health returns the state of the hospital's circuit breaker
+func (s *HTTPHospitalAPIService) Health() models.HospitalAPIHealth {
+	state, failures := s.breaker.State()
+	return models.HospitalAPIHealth{
+		Name:                s.endpoint.Name,
+		Circuit:             state,
+		ConsecutiveFailures: failures,
+	}
+}
+
+// search makes a single call to the search endpoint and reports whether a failure is worth retrying
+func (s *HTTPHospitalAPIService) search(ctx context.Context, searchURL string) (*models.PatientSearchResponse, bool, error) {
+	if s.endpoint.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.endpoint.Timeout)
+		defer cancel()
+	}
 
 	// Create the request
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, searchURL, nil)
 	if err != nil {
-		return nil, apperrors.NewInternalServerError(err)
+		return nil, false, apperrors.NewInternalServerError(err)
+	}
+	s.authorize(req)
+	if requestID := utils.RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set(utils.RequestIDHeader, requestID)
 	}
 
 	// Send the request
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, apperrors.NewExternalAPIError(err)
+		return nil, true, apperrors.NewExternalAPIError(err)
 	}
 	defer resp.Body.Close()
 
 	// Check the response status
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, apperrors.NewNotFoundError("patient not found")
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, apperrors.NewExternalAPIError(fmt.Errorf("hospital API returned status %d", resp.StatusCode))
+		retryable := resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retryable, apperrors.NewExternalAPIError(fmt.Errorf("%s API returned status %d", s.endpoint.Name, resp.StatusCode))
 	}
 
 	// Parse the response
 	var patient models.PatientSearchResponse
 	if err := json.NewDecoder(resp.Body).Decode(&patient); err != nil {
-		return nil, apperrors.NewInternalServerError(err)
+		return nil, false, apperrors.NewExternalAPIError(err)
 	}
 
-	return &patient, nil
+	return &patient, false, nil
+}
+
+// backoff returns how long to wait before the retry following the given attempt:
+// a random delay up to BaseDelay * 2^attempt, capped at MaxDelay ("full jitter")
+func (s *HTTPHospitalAPIService) backoff(attempt int) time.Duration {
+	retry := s.endpoint.Retry
+	delay := retry.BaseDelay << attempt
+	if retry.MaxDelay > 0 && (delay > retry.MaxDelay || delay < retry.BaseDelay) {
+		delay = retry.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// sleepContext waits for the given duration and reports whether the context
+// is still live afterwards. It gives up at once if the context would expire first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return false
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// authorize adds the hospital's configured credentials to the request
+func (s *HTTPHospitalAPIService) authorize(req *http.Request) {
+	switch s.endpoint.AuthType {
+	case "api_key":
+		req.Header.Set("X-API-Key", s.endpoint.AuthToken)
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+s.endpoint.AuthToken)
+	}
 }
 
 // MockHospitalAAPIService implements a mock version of HospitalAPIService for testing
@@ -73,7 +180,11 @@ func NewMockHospitalAAPIService() *MockHospitalAAPIService {
 }
 
 // SearchPatient returns mock patient data
-func (s *MockHospitalAAPIService) SearchPatient(id string) (*models.PatientSearchResponse, error) {
+func (s *MockHospitalAAPIService) SearchPatient(ctx context.Context, id string) (*models.PatientSearchResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, apperrors.NewExternalAPIError(err)
+	}
+
 	// For testing purposes, return mock data based on the ID
 	if id == "1234567890123" || id == "AB1234567" {
 		dob, _ := time.Parse("2006-01-02", "1990-01-01")
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oidcKeysMinRefresh limits how often an identity provider's signing keys are re-fetched
+// for ID tokens naming an unknown key
+const oidcKeysMinRefresh = time.Minute
+
+// oidcMaxResponseSize caps the size of responses read from an identity provider
+const oidcMaxResponseSize = 1 << 20
+
+// oidcClockSkew is tolerated between the identity provider's clock and ours
+const oidcClockSkew = time.Minute
+
+// oidcSigningMethods are the algorithms ID tokens may be signed with. Symmetric
+// algorithms are excluded, since they would make the client secret a signing key.
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// OIDCProvider signs staff in through one hospital's OpenID Connect identity provider
+// with the authorization code flow and PKCE. The provider's discovery document is
+// fetched on first use, and its signing keys are re-fetched when an ID token names a
+// key that isn't known yet.
+type OIDCProvider struct {
+	config config.OIDCProviderConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// oidcDiscovery holds the parts of an OpenID Provider's discovery document HMS uses
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcTokenResponse holds the parts of a token endpoint response HMS uses
+type oidcTokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+// NewOIDCProvider creates a new OIDCProvider for the given hospital's identity provider
+func NewOIDCProvider(cfg config.OIDCProviderConfig) *OIDCProvider {
+	return &OIDCProvider{
+		config: cfg,
+		client: &http.Client{},
+	}
+}
+
+// NewOIDCProviders creates an OIDCProvider for each configured hospital, keyed by hospital code
+func NewOIDCProviders(cfg config.OIDCConfig) map[string]*OIDCProvider {
+	providers := make(map[string]*OIDCProvider, len(cfg.Providers))
+	for _, provider := range cfg.Providers {
+		providers[provider.HospitalCode] = NewOIDCProvider(provider)
+	}
+	return providers
+}
+
+// AuthCodeURL returns the URL of the identity provider's sign-in page for a new sign-in
+// with the given state, nonce and PKCE verifier
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", apperrors.NewExternalAPIError(fmt.Errorf("invalid authorization endpoint: %w", err))
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+// Exchange redeems an authorization code with its PKCE verifier, verifies the ID token
+// that comes back against the nonce of the sign-in, and returns the identity it asserts
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*models.OIDCIdentity, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if p.config.ClientSecret == "" {
+		// Public clients identify themselves in the body and rely on PKCE alone
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, apperrors.NewExternalAPIError(err)
+	}
+	defer resp.Body.Close()
+
+	// A rejected code, verifier or client is the caller's problem, not an outage
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return nil, apperrors.NewUnauthorizedError("identity provider refused the authorization code")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, apperrors.NewExternalAPIError(fmt.Errorf("token endpoint returned status %d", resp.StatusCode))
+	}
+
+	var tokens oidcTokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseSize)).Decode(&tokens); err != nil {
+		return nil, apperrors.NewExternalAPIError(err)
+	}
+	if tokens.IDToken == "" {
+		return nil, apperrors.NewExternalAPIError(errors.New("token endpoint returned no ID token"))
+	}
+
+	return p.verifyIDToken(ctx, discovery, tokens.IDToken, nonce)
+}
+
+// RoleFor returns the HMS role granted to members of the given groups: the role of the
+// first mapping that matches one of them, or the default role
+func (p *OIDCProvider) RoleFor(groups []string) string {
+	for _, mapping := range p.config.RoleMappings {
+		for _, group := range groups {
+			if group == mapping.Group {
+				return mapping.Role
+			}
+		}
+	}
+	return p.config.DefaultRole
+}
+
+// verifyIDToken checks an ID token's signature, issuer, audience, expiry and nonce and
+// reads the identity it asserts
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, discovery *oidcDiscovery, idToken, nonce string) (*models.OIDCIdentity, error) {
+	invalid := apperrors.NewUnauthorizedError("invalid ID token")
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return p.verificationKey(ctx, discovery, token)
+		},
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+	)
+	if err != nil {
+		return nil, invalid
+	}
+
+	// The nonce ties the token to the sign-in this browser started, so it can't be replayed
+	tokenNonce, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, invalid
+	}
+	// A token issued to several audiences must name us as the party it was issued for
+	if azp, ok := claims["azp"].(string); ok && azp != p.config.ClientID {
+		return nil, invalid
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, invalid
+	}
+
+	identity := &models.OIDCIdentity{
+		Issuer:  discovery.Issuer,
+		Subject: subject,
+		Groups:  stringListClaim(claims[p.config.GroupsClaim]),
+	}
+	identity.Username, _ = claims[p.config.UsernameClaim].(string)
+	identity.FullName, _ = claims["name"].(string)
+
+	return identity, nil
+}
+
+// verificationKey returns the provider's public key an ID token's kid header names.
+// Keys are re-fetched once for an unknown kid, so tokens signed with a newly rotated
+// key are accepted.
+func (p *OIDCProvider) verificationKey(ctx context.Context, discovery *oidcDiscovery, token *jwt.Token) (crypto.PublicKey, error) {
+	id, _ := token.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(id); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < oidcKeysMinRefresh {
+		return nil, fmt.Errorf("unknown signing key %q", id)
+	}
+
+	var jwks utils.JWKS
+	if err := p.getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of types we don't support are skipped rather than failing every sign-in
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(id); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", id)
+}
+
+// lookupKey finds a cached key by ID. A token without a kid can only be verified by a
+// provider publishing a single key. The caller must hold p.mu.
+func (p *OIDCProvider) lookupKey(id string) (crypto.PublicKey, bool) {
+	if id == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[id]
+	return key, ok
+}
+
+// getDiscovery returns the provider's discovery document, fetching it on first use
+func (p *OIDCProvider) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := p.getJSON(ctx, p.config.IssuerURL+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+
+	// The document must be about the issuer we trust, or ID tokens could name any issuer
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.config.IssuerURL {
+		return nil, apperrors.NewExternalAPIError(fmt.Errorf("discovery document names issuer %q, expected %q", discovery.Issuer, p.config.IssuerURL))
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, apperrors.NewExternalAPIError(errors.New("discovery document is missing endpoints"))
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// getJSON fetches a JSON document from the provider
+func (p *OIDCProvider) getJSON(ctx context.Context, documentURL string, v interface{}) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, documentURL, nil)
+	if err != nil {
+		return apperrors.NewInternalServerError(err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return apperrors.NewExternalAPIError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return apperrors.NewExternalAPIError(fmt.Errorf("%s returned status %d", documentURL, resp.StatusCode))
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseSize)).Decode(v); err != nil {
+		return apperrors.NewExternalAPIError(err)
+	}
+	return nil
+}
+
+// withTimeout bounds a call to the provider by its configured timeout
+func (p *OIDCProvider) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.config.Timeout > 0 {
+		return context.WithTimeout(ctx, p.config.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// stringListClaim reads a claim holding a list of strings, or a single string
+func stringListClaim(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		list := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/DingDong039/hms/internal/config"
+)
+
+// maxPasswordBytes is the longest password bcrypt can hash
+const maxPasswordBytes = 72
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// commonPasswords holds the bundled common and breached passwords, lowercase
+var commonPasswords = parseCommonPasswords(commonPasswordsFile)
+
+// ValidatePassword checks a new password against the password policy. It returns a
+// ValidationError describing the first rule the password breaks.
+func ValidatePassword(policy config.PasswordPolicyConfig, username, password string) error {
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		return NewValidationError(fmt.Sprintf("password must be at least %d characters long", policy.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		return NewValidationError(fmt.Sprintf("password must be at most %d bytes long", maxPasswordBytes))
+	}
+
+	if classes := characterClasses(password); classes < policy.MinCharacterClasses {
+		return NewValidationError(fmt.Sprintf(
+			"password must contain at least %d of lowercase letters, uppercase letters, digits and symbols",
+			policy.MinCharacterClasses,
+		))
+	}
+
+	lower := strings.ToLower(password)
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		return NewValidationError("password must not contain the username")
+	}
+
+	// A common password with digits or symbols appended, such as Password123!, is still common
+	stem := strings.TrimRightFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+	if commonPasswords[lower] || (stem != "" && commonPasswords[stem]) {
+		return NewValidationError("password is too common")
+	}
+
+	return nil
+}
+
+// characterClasses counts the classes of lowercase letters, uppercase letters, digits
+// and symbols a password uses
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLetter(r):
+			// Letters of scripts without case, such as Thai, count as lowercase
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+// parseCommonPasswords parses the bundled password list, skipping blank and comment lines
+func parseCommonPasswords(file string) map[string]bool {
+	passwords := make(map[string]bool)
+	for _, line := range strings.Split(file, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = true
+	}
+	return passwords
+}
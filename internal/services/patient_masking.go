@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// maskPatient masks a patient's PII in place unless the caller may see it unmasked
+func maskPatient(ctx context.Context, patient *models.Patient) {
+	if patient == nil || utils.PIIAccessFromContext(ctx).Unmasked {
+		return
+	}
+	patient.NationalID = utils.MaskNationalID(patient.NationalID)
+	patient.PassportID = utils.MaskPassportID(patient.PassportID)
+	patient.PhoneNumber = utils.MaskPhoneNumber(patient.PhoneNumber)
+	patient.Email = utils.MaskEmail(patient.Email)
+}
+
+// keepMaskedPII restores the stored PII wherever an edit sent back the masked form of it,
+// so a caller who only ever sees masked values can save a record it read without
+// overwriting the real values with their masks
+func keepMaskedPII(patient *models.Patient, stored *models.Patient) {
+	keep := func(value *string, storedValue string, mask func(string) string) {
+		if storedValue != "" && *value == mask(storedValue) {
+			*value = storedValue
+		}
+	}
+	keep(&patient.NationalID, stored.NationalID, utils.MaskNationalID)
+	keep(&patient.PassportID, stored.PassportID, utils.MaskPassportID)
+	keep(&patient.PhoneNumber, stored.PhoneNumber, utils.MaskPhoneNumber)
+	keep(&patient.Email, stored.Email, utils.MaskEmail)
+}
+
+// rejectMaskedNationalID refuses a national ID left in masked form, which request validation
+// lets through so that keepMaskedPII can restore it; one that wasn't restored is not the
+// record's own ID
+func rejectMaskedNationalID(patient *models.Patient) error {
+	if utils.IsMaskedNationalID(patient.NationalID) {
+		return apperrors.NewInvalidInputError("national_id is masked and doesn't match the stored ID")
+	}
+	return nil
+}
+
+// maskPatientSearchResponse masks a search response's PII in place unless the caller may see it unmasked
+func maskPatientSearchResponse(ctx context.Context, response *models.PatientSearchResponse) {
+	if response == nil || utils.PIIAccessFromContext(ctx).Unmasked {
+		return
+	}
+	response.NationalID = utils.MaskNationalID(response.NationalID)
+	response.PassportID = utils.MaskPassportID(response.PassportID)
+	response.PhoneNumber = utils.MaskPhoneNumber(response.PhoneNumber)
+	response.Email = utils.MaskEmail(response.Email)
+}
+
+// piiAccessDetails returns the audit details recording that a patient's PII was
+// served unmasked, and why, merged into the given details. Masked access adds nothing.
+func piiAccessDetails(ctx context.Context, details map[string]string) map[string]string {
+	access := utils.PIIAccessFromContext(ctx)
+	if !access.Unmasked {
+		return details
+	}
+
+	merged := map[string]string{"pii": "unmasked"}
+	for key, value := range details {
+		merged[key] = value
+	}
+	if access.BreakGlassReason != "" {
+		merged["break_glass_reason"] = access.BreakGlassReason
+	}
+	return merged
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/repositories"
+	"github.com/DingDong039/hms/internal/utils"
+)
+
+// defaultDuplicateQueryLimit is used when a duplicate listing doesn't set a limit
+const defaultDuplicateQueryLimit = 50
+
+// PatientMergeService defines the interface for finding and merging duplicate patients.
+// PII in the records returned is masked unless the context grants unmasked access.
+type PatientMergeService interface {
+	DetectDuplicates(ctx context.Context) (int, error)
+	ListDuplicates(ctx context.Context, req models.PatientDuplicateQueryRequest) ([]*models.PatientDuplicateCandidate, error)
+	DismissDuplicate(ctx context.Context, id int) error
+	MergePatients(ctx context.Context, req models.PatientMergeRequest) (*models.PatientMergeResponse, error)
+}
+
+// PatientMergeServiceImpl implements PatientMergeService
+type PatientMergeServiceImpl struct {
+	mergeRepo    repositories.PatientMergeRepository
+	auditService AuditService
+}
+
+// NewPatientMergeService creates a new PatientMergeServiceImpl
+func NewPatientMergeService(mergeRepo repositories.PatientMergeRepository, auditService AuditService) *PatientMergeServiceImpl {
+	return &PatientMergeServiceImpl{
+		mergeRepo:    mergeRepo,
+		auditService: auditService,
+	}
+}
+
+// DetectDuplicates records new duplicate candidates among the caller's hospital's patients
+// and returns how many were found
+func (s *PatientMergeServiceImpl) DetectDuplicates(ctx context.Context) (int, error) {
+	return s.mergeRepo.DetectCandidates(ctx)
+}
+
+// ListDuplicates returns the caller's hospital's duplicate candidates, open ones by default
+func (s *PatientMergeServiceImpl) ListDuplicates(ctx context.Context, req models.PatientDuplicateQueryRequest) ([]*models.PatientDuplicateCandidate, error) {
+	if req.Status == "" {
+		req.Status = models.DuplicateStatusOpen
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultDuplicateQueryLimit
+	}
+
+	candidates, err := s.mergeRepo.FindCandidates(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		for _, patient := range []*models.Patient{candidate.PatientA, candidate.PatientB} {
+			if patient == nil {
+				continue
+			}
+			if err := s.recordMergeEvent(ctx, models.AuditActionPatientRead, patient.ID, nil); err != nil {
+				return nil, err
+			}
+			maskPatient(ctx, patient)
+		}
+	}
+
+	return candidates, nil
+}
+
+// DismissDuplicate marks a duplicate candidate as not a duplicate, so detection doesn't raise it again
+func (s *PatientMergeServiceImpl) DismissDuplicate(ctx context.Context, id int) error {
+	return s.mergeRepo.DismissCandidate(ctx, id)
+}
+
+// MergePatients merges a duplicate patient into a survivor. The duplicate's record is kept
+// in a link, and its ID and HN resolve to the survivor from then on.
+func (s *PatientMergeServiceImpl) MergePatients(ctx context.Context, req models.PatientMergeRequest) (*models.PatientMergeResponse, error) {
+	link := &models.PatientLink{Reason: req.Reason}
+	if staffID, ok := utils.StaffIDFromContext(ctx); ok {
+		link.MergedBy = &staffID
+	}
+
+	survivor, err := s.mergeRepo.Merge(ctx, req.SurvivorID, req.DuplicateID, link)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]string{
+		"survivor_id":       strconv.Itoa(link.SurvivorID),
+		"merged_patient_id": strconv.Itoa(link.MergedPatientID),
+		"merged_patient_hn": link.MergedPatientHN,
+	}
+	if err := s.recordMergeEvent(ctx, models.AuditActionPatientMerge, link.SurvivorID, details); err != nil {
+		return nil, err
+	}
+	if err := s.recordMergeEvent(ctx, models.AuditActionPatientMerge, link.MergedPatientID, details); err != nil {
+		return nil, err
+	}
+	maskPatient(ctx, survivor)
+	maskPatient(ctx, link.Snapshot)
+
+	return &models.PatientMergeResponse{
+		Survivor: survivor,
+		Link:     link,
+	}, nil
+}
+
+// recordMergeEvent appends an event about a local patient to the audit trail,
+// noting whether the patient's PII was served unmasked
+func (s *PatientMergeServiceImpl) recordMergeEvent(ctx context.Context, action string, patientID int, details map[string]string) error {
+	return s.auditService.Record(ctx, &models.AuditEvent{
+		PatientID: &patientID,
+		Action:    action,
+		Source:    models.AuditSourceLocal,
+		Details:   piiAccessDetails(ctx, details),
+	})
+}
@@ -2,32 +2,50 @@ package services
 
 import (
 	"context"
+	"errors"
 	"strings"
 
 	"github.com/DingDong039/hms/internal/models"
 	"github.com/DingDong039/hms/internal/repositories"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
 )
 
-// PatientService defines the interface for patient operations
+// PatientService defines the interface for patient operations.
+// PII in the records returned is masked unless the context grants unmasked access.
 type PatientService interface {
 	SearchPatient(ctx context.Context, req models.PatientSearchRequest) (*models.PatientSearchResponse, error)
+	QueryPatients(ctx context.Context, req models.PatientQueryRequest) ([]*models.Patient, int, error)
+	FuzzySearchPatients(ctx context.Context, req models.PatientFuzzySearchRequest) ([]*models.PatientMatch, error)
+	CreatePatient(ctx context.Context, req models.PatientCreateRequest) (*models.Patient, error)
+	GetPatient(ctx context.Context, id int) (*models.Patient, error)
+	UpdatePatient(ctx context.Context, id int, req models.PatientUpdateRequest) (*models.Patient, error)
+	PatchPatient(ctx context.Context, id int, req models.PatientPatchRequest) (*models.Patient, error)
+	DeletePatient(ctx context.Context, id int) error
 }
 
 // PatientServiceImpl implements PatientService
 type PatientServiceImpl struct {
-	patientRepo        repositories.PatientRepository
-	hospitalAPIService HospitalAPIService
+	patientRepo  repositories.PatientRepository
+	hospitalAPIs *HospitalAPIRegistry
+	syncService  *PatientSyncService
+	auditService AuditService
 }
 
 // NewPatientService creates a new PatientServiceImpl
-func NewPatientService(patientRepo repositories.PatientRepository, hospitalAPIService HospitalAPIService) *PatientServiceImpl {
+func NewPatientService(patientRepo repositories.PatientRepository, hospitalAPIs *HospitalAPIRegistry, syncService *PatientSyncService, auditService AuditService) *PatientServiceImpl {
 	return &PatientServiceImpl{
-		patientRepo:        patientRepo,
-		hospitalAPIService: hospitalAPIService,
+		patientRepo:  patientRepo,
+		hospitalAPIs: hospitalAPIs,
+		syncService:  syncService,
+		auditService: auditService,
 	}
 }
 
-// SearchPatient searches for a patient by ID (national ID or passport ID)
+// SearchPatient searches for a patient by ID (national ID or passport ID).
+// Patients are looked up locally first. Patients found upstream are cached, and a
+// stale cached record is refreshed from its source hospital before it is served;
+// if the refresh fails the cached record is served instead.
 func (s *PatientServiceImpl) SearchPatient(ctx context.Context, req models.PatientSearchRequest) (*models.PatientSearchResponse, error) {
 	// Determine if the ID is a national ID or passport ID
 	// Thai national ID is 13 digits
@@ -48,52 +66,321 @@ func (s *PatientServiceImpl) SearchPatient(ctx context.Context, req models.Patie
 
 	// If patient is found in local database, return the data
 	if err == nil && patient != nil {
-		return &models.PatientSearchResponse{
-			FirstNameTH:  patient.FirstNameTH,
-			MiddleNameTH: patient.MiddleNameTH,
-			LastNameTH:   patient.LastNameTH,
-			FirstNameEN:  patient.FirstNameEN,
-			MiddleNameEN: patient.MiddleNameEN,
-			LastNameEN:   patient.LastNameEN,
-			DateOfBirth:  patient.DateOfBirth,
-			PatientHN:    patient.PatientHN,
-			NationalID:   patient.NationalID,
-			PassportID:   patient.PassportID,
-			PhoneNumber:  patient.PhoneNumber,
-			Email:        patient.Email,
-			Gender:       patient.Gender,
-		}, nil
-	}
-
-	// If patient is not found in local database, search in Hospital A API
-	response, err := s.hospitalAPIService.SearchPatient(id)
+		source := models.AuditSourceLocal
+		if s.syncService.IsStale(patient) {
+			if refreshed, err := s.syncService.Refresh(ctx, patient); err == nil {
+				patient = refreshed
+				source = patient.SourceHospital
+			}
+		}
+
+		if err := s.recordAccess(ctx, models.AuditActionPatientRead, patient.ID, source); err != nil {
+			return nil, err
+		}
+
+		response := toPatientSearchResponse(patient)
+		maskPatientSearchResponse(ctx, response)
+		return response, nil
+	}
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		return nil, err
+	}
+
+	// If patient is not found in local database, search the requested upstream
+	// hospital, or every upstream hospital when none is named
+	var response *models.PatientSearchResponse
+	if req.Hospital != "" {
+		response, err = s.hospitalAPIs.SearchPatient(ctx, req.Hospital, id)
+	} else {
+		response, err = s.hospitalAPIs.SearchAll(ctx, id)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// Store the patient data in local database for future use
-	newPatient := &models.Patient{
-		NationalID:   response.NationalID,
-		PassportID:   response.PassportID,
-		FirstNameTH:  response.FirstNameTH,
-		MiddleNameTH: response.MiddleNameTH,
-		LastNameTH:   response.LastNameTH,
-		FirstNameEN:  response.FirstNameEN,
-		MiddleNameEN: response.MiddleNameEN,
-		LastNameEN:   response.LastNameEN,
-		DateOfBirth:  response.DateOfBirth,
-		PatientHN:    response.PatientHN,
-		PhoneNumber:  response.PhoneNumber,
-		Email:        response.Email,
-		Gender:       response.Gender,
-	}
-
-	// Save patient to database (ignore errors as this is just caching)
-	_ = s.patientRepo.Create(ctx, newPatient)
+	cached, err := s.syncService.Cache(ctx, response)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.recordAccess(ctx, models.AuditActionPatientRead, cached.ID, response.SourceHospital); err != nil {
+		return nil, err
+	}
 
+	maskPatientSearchResponse(ctx, response)
 	return response, nil
 }
 
+// QueryPatients searches the caller's hospital's patients by any combination of
+// name, date of birth, phone number, email and HN. It returns one page of
+// matches and the total number of matches.
+func (s *PatientServiceImpl) QueryPatients(ctx context.Context, req models.PatientQueryRequest) ([]*models.Patient, int, error) {
+	req.FirstName = strings.TrimSpace(req.FirstName)
+	req.LastName = strings.TrimSpace(req.LastName)
+	req.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+	req.Email = strings.TrimSpace(req.Email)
+	req.PatientHN = strings.TrimSpace(req.PatientHN)
+	if req.Limit == 0 {
+		req.Limit = models.DefaultPatientQueryLimit
+	}
+
+	patients, total, err := s.patientRepo.Search(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, patient := range patients {
+		if err := s.recordAccess(ctx, models.AuditActionPatientRead, patient.ID, models.AuditSourceLocal); err != nil {
+			return nil, 0, err
+		}
+		maskPatient(ctx, patient)
+	}
+
+	return patients, total, nil
+}
+
+// FuzzySearchPatients finds the caller's hospital's patients whose Thai or English
+// names approximately match the request, ranked by match score
+func (s *PatientServiceImpl) FuzzySearchPatients(ctx context.Context, req models.PatientFuzzySearchRequest) ([]*models.PatientMatch, error) {
+	req.FirstName = utils.NormalizeName(req.FirstName)
+	req.LastName = utils.NormalizeName(req.LastName)
+	if req.FirstName == "" && req.LastName == "" {
+		return nil, apperrors.NewInvalidInputError("first_name or last_name is required")
+	}
+	if req.MinScore == 0 {
+		req.MinScore = models.DefaultPatientFuzzyMinScore
+	}
+	if req.Limit == 0 {
+		req.Limit = models.DefaultPatientFuzzyLimit
+	}
+
+	matches, err := s.patientRepo.FuzzySearch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, match := range matches {
+		if err := s.recordAccess(ctx, models.AuditActionPatientRead, match.Patient.ID, models.AuditSourceLocal); err != nil {
+			return nil, err
+		}
+		maskPatient(ctx, match.Patient)
+	}
+
+	return matches, nil
+}
+
+// CreatePatient registers a new patient in the caller's hospital
+func (s *PatientServiceImpl) CreatePatient(ctx context.Context, req models.PatientCreateRequest) (*models.Patient, error) {
+	patient := &models.Patient{}
+	applyPatientFields(patient, req)
+
+	if err := rejectMaskedNationalID(patient); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkUniqueIDs(ctx, patient); err != nil {
+		return nil, err
+	}
+
+	if err := s.patientRepo.Create(ctx, patient); err != nil {
+		return nil, err
+	}
+
+	if err := s.recordAccess(ctx, models.AuditActionPatientCreate, patient.ID, models.AuditSourceLocal); err != nil {
+		return nil, err
+	}
+
+	maskPatient(ctx, patient)
+	return patient, nil
+}
+
+// GetPatient returns a patient of the caller's hospital by ID.
+// The ID of a merged-away patient returns the patient it was merged into.
+func (s *PatientServiceImpl) GetPatient(ctx context.Context, id int) (*models.Patient, error) {
+	patient, err := s.patientRepo.FindByID(ctx, id)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		patient, err = s.patientRepo.FindByMergedID(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.recordAccess(ctx, models.AuditActionPatientRead, patient.ID, models.AuditSourceLocal); err != nil {
+		return nil, err
+	}
+
+	maskPatient(ctx, patient)
+	return patient, nil
+}
+
+// UpdatePatient replaces every field of a patient record. PII sent back in its masked
+// form keeps its stored value.
+func (s *PatientServiceImpl) UpdatePatient(ctx context.Context, id int, req models.PatientUpdateRequest) (*models.Patient, error) {
+	patient, err := s.patientRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := *patient
+	applyPatientFields(patient, req.PatientCreateRequest)
+	keepMaskedPII(patient, &stored)
+	patient.UpdatedAt = req.UpdatedAt
+
+	return s.savePatient(ctx, patient)
+}
+
+// PatchPatient changes the fields set in the request and leaves the others unchanged.
+// PII sent back in its masked form keeps its stored value.
+func (s *PatientServiceImpl) PatchPatient(ctx context.Context, id int, req models.PatientPatchRequest) (*models.Patient, error) {
+	patient, err := s.patientRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := *patient
+	patchString := func(dst *string, src *string) {
+		if src != nil {
+			*dst = strings.TrimSpace(*src)
+		}
+	}
+	patchString(&patient.NationalID, req.NationalID)
+	patchString(&patient.PassportID, req.PassportID)
+	patchString(&patient.FirstNameTH, req.FirstNameTH)
+	patchString(&patient.MiddleNameTH, req.MiddleNameTH)
+	patchString(&patient.LastNameTH, req.LastNameTH)
+	patchString(&patient.FirstNameEN, req.FirstNameEN)
+	patchString(&patient.MiddleNameEN, req.MiddleNameEN)
+	patchString(&patient.LastNameEN, req.LastNameEN)
+	patchString(&patient.PatientHN, req.PatientHN)
+	patchString(&patient.PhoneNumber, req.PhoneNumber)
+	patchString(&patient.Email, req.Email)
+	patchString(&patient.Gender, req.Gender)
+	if req.DateOfBirth != nil {
+		patient.DateOfBirth = *req.DateOfBirth
+	}
+	keepMaskedPII(patient, &stored)
+	patient.UpdatedAt = req.UpdatedAt
+
+	if patient.NationalID == "" && patient.PassportID == "" {
+		return nil, apperrors.NewInvalidInputError("either national_id or passport_id is required")
+	}
+
+	return s.savePatient(ctx, patient)
+}
+
+// DeletePatient deletes a patient record of the caller's hospital
+func (s *PatientServiceImpl) DeletePatient(ctx context.Context, id int) error {
+	if err := s.patientRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	return s.recordAccess(ctx, models.AuditActionPatientDelete, id, models.AuditSourceLocal)
+}
+
+// savePatient writes an edited patient record, guarded by its updated_at version
+func (s *PatientServiceImpl) savePatient(ctx context.Context, patient *models.Patient) (*models.Patient, error) {
+	if err := rejectMaskedNationalID(patient); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkUniqueIDs(ctx, patient); err != nil {
+		return nil, err
+	}
+
+	if err := s.patientRepo.Update(ctx, patient); err != nil {
+		return nil, err
+	}
+
+	if err := s.recordAccess(ctx, models.AuditActionPatientUpdate, patient.ID, models.AuditSourceLocal); err != nil {
+		return nil, err
+	}
+
+	maskPatient(ctx, patient)
+	return patient, nil
+}
+
+// checkUniqueIDs rejects a national ID or passport ID already used by another patient
+func (s *PatientServiceImpl) checkUniqueIDs(ctx context.Context, patient *models.Patient) error {
+	if patient.NationalID != "" {
+		existing, err := s.patientRepo.FindByNationalID(ctx, patient.NationalID)
+		if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+			return err
+		}
+		if existing != nil && existing.ID != patient.ID {
+			return apperrors.NewDuplicateResourceError("a patient with this national ID already exists")
+		}
+	}
+
+	if patient.PassportID != "" {
+		existing, err := s.patientRepo.FindByPassportID(ctx, patient.PassportID)
+		if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+			return err
+		}
+		if existing != nil && existing.ID != patient.ID {
+			return apperrors.NewDuplicateResourceError("a patient with this passport ID already exists")
+		}
+	}
+
+	return nil
+}
+
+// toPatientSearchResponse converts a patient record into a search response
+func toPatientSearchResponse(patient *models.Patient) *models.PatientSearchResponse {
+	return &models.PatientSearchResponse{
+		FirstNameTH:    patient.FirstNameTH,
+		MiddleNameTH:   patient.MiddleNameTH,
+		LastNameTH:     patient.LastNameTH,
+		FirstNameEN:    patient.FirstNameEN,
+		MiddleNameEN:   patient.MiddleNameEN,
+		LastNameEN:     patient.LastNameEN,
+		DateOfBirth:    patient.DateOfBirth,
+		PatientHN:      patient.PatientHN,
+		NationalID:     patient.NationalID,
+		PassportID:     patient.PassportID,
+		PhoneNumber:    patient.PhoneNumber,
+		Email:          patient.Email,
+		Gender:         patient.Gender,
+		SourceHospital: patient.SourceHospital,
+	}
+}
+
+// applyPatientFields copies the fields of a create request onto a patient
+func applyPatientFields(patient *models.Patient, req models.PatientCreateRequest) {
+	patient.NationalID = strings.TrimSpace(req.NationalID)
+	patient.PassportID = strings.TrimSpace(req.PassportID)
+	patient.FirstNameTH = strings.TrimSpace(req.FirstNameTH)
+	patient.MiddleNameTH = strings.TrimSpace(req.MiddleNameTH)
+	patient.LastNameTH = strings.TrimSpace(req.LastNameTH)
+	patient.FirstNameEN = strings.TrimSpace(req.FirstNameEN)
+	patient.MiddleNameEN = strings.TrimSpace(req.MiddleNameEN)
+	patient.LastNameEN = strings.TrimSpace(req.LastNameEN)
+	patient.DateOfBirth = req.DateOfBirth
+	patient.PatientHN = strings.TrimSpace(req.PatientHN)
+	patient.PhoneNumber = strings.TrimSpace(req.PhoneNumber)
+	patient.Email = strings.TrimSpace(req.Email)
+	patient.Gender = req.Gender
+}
+
+// recordAccess appends a patient access to the audit trail.
+// A patient ID of zero is recorded as unknown. Every action but a delete returns
+// the record, so its event also notes whether the record's PII was served unmasked.
+// Audit failures are returned so that no record is served without a trace.
+func (s *PatientServiceImpl) recordAccess(ctx context.Context, action string, patientID int, source string) error {
+	event := &models.AuditEvent{
+		Action: action,
+		Source: source,
+	}
+	if action != models.AuditActionPatientDelete {
+		event.Details = piiAccessDetails(ctx, nil)
+	}
+	if patientID != 0 {
+		event.PatientID = &patientID
+	}
+
+	return s.auditService.Record(ctx, event)
+}
+
 // isNumeric checks if a string contains only digits
 func isNumeric(s string) bool {
 	for _, char := range s {
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/repositories"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// PatientSyncService keeps patients cached from upstream hospitals fresh.
+// A cached record is stale once it is older than its source hospital's cache TTL;
+// stale records are refreshed when read, and optionally in the background by Run.
+type PatientSyncService struct {
+	patientRepo  repositories.PatientRepository
+	hospitalRepo repositories.HospitalRepository
+	hospitalAPIs *HospitalAPIRegistry
+	cacheTTLs    map[string]time.Duration
+	batchSize    int
+	logger       *slog.Logger
+	now          func() time.Time
+}
+
+// NewPatientSyncService creates a new PatientSyncService
+func NewPatientSyncService(
+	patientRepo repositories.PatientRepository,
+	hospitalRepo repositories.HospitalRepository,
+	hospitalAPIs *HospitalAPIRegistry,
+	cfg *config.Config,
+	logger *slog.Logger,
+) *PatientSyncService {
+	cacheTTLs := make(map[string]time.Duration)
+	for _, endpoint := range cfg.HospitalAPI.Hospitals {
+		cacheTTLs[endpoint.Name] = endpoint.CacheTTL
+	}
+
+	return &PatientSyncService{
+		patientRepo:  patientRepo,
+		hospitalRepo: hospitalRepo,
+		hospitalAPIs: hospitalAPIs,
+		cacheTTLs:    cacheTTLs,
+		batchSize:    cfg.PatientSync.BatchSize,
+		logger:       logger,
+		now:          time.Now,
+	}
+}
+
+// IsStale reports whether a cached patient is due for a refresh.
+// Records entered locally, or cached from a hospital without a TTL, never go stale.
+func (s *PatientSyncService) IsStale(patient *models.Patient) bool {
+	ttl := s.cacheTTLs[patient.SourceHospital]
+	if patient.SourceHospital == "" || ttl <= 0 {
+		return false
+	}
+
+	return patient.LastSyncedAt == nil || s.now().Sub(*patient.LastSyncedAt) > ttl
+}
+
+// Cache stores a patient fetched from an upstream hospital in the caller's hospital,
+// updating the record already cached from that hospital if there is one
+func (s *PatientSyncService) Cache(ctx context.Context, response *models.PatientSearchResponse) (*models.Patient, error) {
+	syncedAt := s.now()
+	patient := &models.Patient{
+		NationalID:     response.NationalID,
+		PassportID:     response.PassportID,
+		FirstNameTH:    response.FirstNameTH,
+		MiddleNameTH:   response.MiddleNameTH,
+		LastNameTH:     response.LastNameTH,
+		FirstNameEN:    response.FirstNameEN,
+		MiddleNameEN:   response.MiddleNameEN,
+		LastNameEN:     response.LastNameEN,
+		DateOfBirth:    response.DateOfBirth,
+		PatientHN:      response.PatientHN,
+		PhoneNumber:    response.PhoneNumber,
+		Email:          response.Email,
+		Gender:         response.Gender,
+		SourceHospital: response.SourceHospital,
+		LastSyncedAt:   &syncedAt,
+	}
+
+	if err := s.patientRepo.Upsert(ctx, patient); err != nil {
+		return nil, err
+	}
+
+	return patient, nil
+}
+
+// Refresh re-fetches a cached patient from its source hospital and updates the cache.
+// If the hospital no longer knows the patient, the record is kept as is and marked as
+// synced so it isn't re-fetched until its TTL passes again; a not found error is returned.
+func (s *PatientSyncService) Refresh(ctx context.Context, patient *models.Patient) (*models.Patient, error) {
+	id := patient.NationalID
+	if id == "" {
+		id = patient.PassportID
+	}
+
+	response, err := s.hospitalAPIs.SearchPatient(ctx, patient.SourceHospital, id)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			if markErr := s.patientRepo.MarkSynced(ctx, patient.ID, s.now()); markErr != nil {
+				return nil, markErr
+			}
+		}
+		return nil, err
+	}
+
+	return s.Cache(ctx, response)
+}
+
+// SyncStale refreshes up to one batch of stale records per hospital and upstream source.
+// It returns the number of records refreshed; failures are collected and don't stop the run.
+func (s *PatientSyncService) SyncStale(ctx context.Context) (int, error) {
+	hospitals, err := s.hospitalRepo.FindAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	refreshed := 0
+	var errs []error
+	for _, hospital := range hospitals {
+		hospitalCtx := utils.WithHospitalID(ctx, hospital.ID)
+
+		for _, source := range s.hospitalAPIs.Names() {
+			ttl := s.cacheTTLs[source]
+			if ttl <= 0 {
+				continue
+			}
+
+			stale, err := s.patientRepo.FindStale(hospitalCtx, source, s.now().Add(-ttl), s.batchSize)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			for _, patient := range stale {
+				if ctx.Err() != nil {
+					return refreshed, errors.Join(append(errs, ctx.Err())...)
+				}
+
+				if _, err := s.Refresh(hospitalCtx, patient); err != nil {
+					if !errors.Is(err, apperrors.ErrNotFound) {
+						errs = append(errs, fmt.Errorf("refresh patient %d from %s: %w", patient.ID, source, err))
+					}
+					continue
+				}
+				refreshed++
+			}
+		}
+	}
+
+	return refreshed, errors.Join(errs...)
+}
+
+// Run calls SyncStale every interval until the context is cancelled
+func (s *PatientSyncService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refreshed, err := s.SyncStale(ctx)
+			if err != nil {
+				s.logger.ErrorContext(ctx, "patient sync failed", slog.Any("error", err))
+			}
+			if refreshed > 0 {
+				s.logger.InfoContext(ctx, "patient sync refreshed records", slog.Int("refreshed", refreshed))
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/repositories"
+)
+
+// RateLimiter defines the interface for token bucket rate limiting of callers
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, rule config.RateLimitRule) (models.RateLimitDecision, error)
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// NewRateLimiter creates the rate limiter backend the configuration selects
+func NewRateLimiter(cfg config.RateLimitConfig, rateLimitRepo repositories.RateLimitRepository) RateLimiter {
+	if cfg.Backend == "postgres" {
+		return NewStoreRateLimiter(rateLimitRepo)
+	}
+	return NewMemoryRateLimiter()
+}
+
+// MemoryRateLimiter implements RateLimiter with buckets held in memory, so each instance
+// limits callers on its own
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*models.TokenBucket
+	now     func() time.Time
+}
+
+// NewMemoryRateLimiter creates a new MemoryRateLimiter
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets: make(map[string]*models.TokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the caller's bucket, creating it full on first use
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string, rule config.RateLimitRule) (models.RateLimitDecision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = models.NewTokenBucket(rule.Burst, now)
+		l.buckets[key] = bucket
+	}
+
+	return bucket.Take(now, rule.Rate(), rule.Burst), nil
+}
+
+// Prune drops the buckets not used since before
+func (l *MemoryRateLimiter) Prune(ctx context.Context, before time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, bucket := range l.buckets {
+		if bucket.UpdatedAt.Before(before) {
+			delete(l.buckets, key)
+		}
+	}
+	return nil
+}
+
+// StoreRateLimiter implements RateLimiter with buckets in the database, so every instance
+// of a deployment shares them
+type StoreRateLimiter struct {
+	rateLimitRepo repositories.RateLimitRepository
+}
+
+// NewStoreRateLimiter creates a new StoreRateLimiter
+func NewStoreRateLimiter(rateLimitRepo repositories.RateLimitRepository) *StoreRateLimiter {
+	return &StoreRateLimiter{
+		rateLimitRepo: rateLimitRepo,
+	}
+}
+
+// Allow takes a token from the caller's bucket, creating it full on first use
+func (l *StoreRateLimiter) Allow(ctx context.Context, key string, rule config.RateLimitRule) (models.RateLimitDecision, error) {
+	return l.rateLimitRepo.Take(ctx, key, rule.Rate(), rule.Burst)
+}
+
+// Prune deletes the buckets not used since before
+func (l *StoreRateLimiter) Prune(ctx context.Context, before time.Time) error {
+	return l.rateLimitRepo.DeleteIdle(ctx, before)
+}
+
+// RunRateLimitPruning prunes buckets idle for longer than idleTimeout, every idleTimeout,
+// until the context is cancelled
+func RunRateLimitPruning(ctx context.Context, limiter RateLimiter, idleTimeout time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(idleTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := limiter.Prune(ctx, time.Now().Add(-idleTimeout)); err != nil {
+				logger.ErrorContext(ctx, "rate limit pruning failed", slog.Any("error", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,510 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/repositories"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+)
+
+// StaffService defines the interface for managing the staff of the caller's hospital
+type StaffService interface {
+	CreateStaff(ctx context.Context, req models.StaffCreateRequest) (*models.Staff, error)
+	InviteStaff(ctx context.Context, req models.StaffInviteRequest) (*models.StaffInviteResponse, error)
+	AcceptInvite(ctx context.Context, req models.StaffInviteAcceptRequest) (*models.Staff, error)
+	ListStaff(ctx context.Context, req models.StaffQueryRequest) ([]*models.Staff, int, error)
+	GetStaff(ctx context.Context, id int) (*models.Staff, error)
+	UpdateStaff(ctx context.Context, id int, req models.StaffPatchRequest) (*models.Staff, error)
+	DeactivateStaff(ctx context.Context, id int) (*models.Staff, error)
+	ReactivateStaff(ctx context.Context, id int) (*models.Staff, error)
+	UnlockStaff(ctx context.Context, id int) error
+	ResetPassword(ctx context.Context, id int) (*models.PasswordResetTokenResponse, error)
+	ResetMFA(ctx context.Context, id int) error
+}
+
+// StaffServiceImpl implements StaffService
+type StaffServiceImpl struct {
+	staffRepo         repositories.StaffRepository
+	roleRepo          repositories.RoleRepository
+	refreshTokenRepo  repositories.RefreshTokenRepository
+	passwordResetRepo repositories.PasswordResetTokenRepository
+	mfaRepo           repositories.MFARepository
+	inviteRepo        repositories.StaffInviteRepository
+	auditService      AuditService
+	config            *config.Config
+}
+
+// NewStaffService creates a new StaffServiceImpl
+func NewStaffService(
+	staffRepo repositories.StaffRepository,
+	roleRepo repositories.RoleRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	passwordResetRepo repositories.PasswordResetTokenRepository,
+	mfaRepo repositories.MFARepository,
+	inviteRepo repositories.StaffInviteRepository,
+	auditService AuditService,
+	config *config.Config,
+) *StaffServiceImpl {
+	return &StaffServiceImpl{
+		staffRepo:         staffRepo,
+		roleRepo:          roleRepo,
+		refreshTokenRepo:  refreshTokenRepo,
+		passwordResetRepo: passwordResetRepo,
+		mfaRepo:           mfaRepo,
+		inviteRepo:        inviteRepo,
+		auditService:      auditService,
+		config:            config,
+	}
+}
+
+// CreateStaff creates a staff member in the caller's hospital with an initial password,
+// which they must change on first login
+func (s *StaffServiceImpl) CreateStaff(ctx context.Context, req models.StaffCreateRequest) (*models.Staff, error) {
+	if err := ValidatePassword(s.config.Password, req.Username, req.Password); err != nil {
+		return nil, err
+	}
+
+	role, err := s.resolveRole(ctx, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	staff := &models.Staff{
+		Username:           req.Username,
+		Password:           hashedPassword,
+		FullName:           strings.TrimSpace(req.FullName),
+		Department:         strings.TrimSpace(req.Department),
+		RoleID:             role.ID,
+		Role:               role.Name,
+		MustChangePassword: true,
+	}
+	if err := s.staffRepo.Create(ctx, staff); err != nil {
+		return nil, err
+	}
+
+	err = s.auditService.Record(ctx, &models.AuditEvent{
+		Action:  models.AuditActionStaffCreate,
+		Source:  models.AuditSourceLocal,
+		Details: map[string]string{"staff_id": strconv.Itoa(staff.ID), "role": staff.Role},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't return the password
+	staff.Password = ""
+	return staff, nil
+}
+
+// InviteStaff issues a one-time token a new staff member of the caller's hospital can
+// create their account with, choosing their own password. Only the token's hash is
+// stored, so the token is returned once, to be handed to the new staff member out of band.
+func (s *StaffServiceImpl) InviteStaff(ctx context.Context, req models.StaffInviteRequest) (*models.StaffInviteResponse, error) {
+	role, err := s.resolveRole(ctx, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkUsernameAvailable(ctx, req.Username); err != nil {
+		return nil, err
+	}
+
+	value, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	invite := &models.StaffInvite{
+		Username:  req.Username,
+		RoleID:    role.ID,
+		Role:      role.Name,
+		TokenHash: utils.HashToken(value),
+		ExpiresAt: time.Now().Add(s.config.Staff.InviteTTL),
+	}
+	if staffID, ok := utils.StaffIDFromContext(ctx); ok {
+		invite.CreatedBy = &staffID
+	}
+
+	if err := s.inviteRepo.Create(ctx, invite); err != nil {
+		return nil, err
+	}
+
+	err = s.auditService.Record(ctx, &models.AuditEvent{
+		Action: models.AuditActionStaffInvite,
+		Source: models.AuditSourceLocal,
+		Details: map[string]string{
+			"invite_id": strconv.Itoa(invite.ID),
+			"username":  invite.Username,
+			"role":      invite.Role,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.StaffInviteResponse{
+		Token:     value,
+		Username:  invite.Username,
+		Role:      invite.Role,
+		ExpiresAt: invite.ExpiresAt,
+	}, nil
+}
+
+// AcceptInvite creates the account an invite was issued for, with a password the new
+// staff member chose. The creation is audited as done by the new staff member.
+func (s *StaffServiceImpl) AcceptInvite(ctx context.Context, req models.StaffInviteAcceptRequest) (*models.Staff, error) {
+	invalid := apperrors.NewUnauthorizedError("invalid or expired invite")
+
+	invite, err := s.inviteRepo.FindByHash(ctx, utils.HashToken(req.Token))
+	if err != nil {
+		return nil, invalid
+	}
+	if invite.AcceptedAt != nil || time.Now().After(invite.ExpiresAt) {
+		return nil, invalid
+	}
+
+	ctx = utils.WithHospitalID(ctx, invite.HospitalID)
+
+	// Check the password and username before using up the invite, so a rejected
+	// password can be retried
+	if err := ValidatePassword(s.config.Password, invite.Username, req.Password); err != nil {
+		return nil, err
+	}
+	if err := s.checkUsernameAvailable(ctx, invite.Username); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	if err := s.inviteRepo.MarkAccepted(ctx, invite.ID); err != nil {
+		return nil, err
+	}
+
+	staff := &models.Staff{
+		Username: invite.Username,
+		Password: hashedPassword,
+		RoleID:   invite.RoleID,
+		Role:     invite.Role,
+	}
+	if err := s.staffRepo.Create(ctx, staff); err != nil {
+		return nil, err
+	}
+
+	err = s.auditService.Record(utils.WithStaffID(ctx, staff.ID), &models.AuditEvent{
+		Action: models.AuditActionStaffCreate,
+		Source: models.AuditSourceLocal,
+		Details: map[string]string{
+			"staff_id":  strconv.Itoa(staff.ID),
+			"role":      staff.Role,
+			"invite_id": strconv.Itoa(invite.ID),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't return the password
+	staff.Password = ""
+	return staff, nil
+}
+
+// ListStaff returns one page of the caller's hospital's staff matching the query and
+// the total number of matches
+func (s *StaffServiceImpl) ListStaff(ctx context.Context, req models.StaffQueryRequest) ([]*models.Staff, int, error) {
+	req.Username = strings.TrimSpace(req.Username)
+	req.FullName = strings.TrimSpace(req.FullName)
+	req.Department = strings.TrimSpace(req.Department)
+	if req.Limit == 0 {
+		req.Limit = models.DefaultStaffQueryLimit
+	}
+
+	staffList, total, err := s.staffRepo.Search(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Don't return the passwords
+	for _, staff := range staffList {
+		staff.Password = ""
+	}
+	return staffList, total, nil
+}
+
+// GetStaff returns a staff member of the caller's hospital
+func (s *StaffServiceImpl) GetStaff(ctx context.Context, id int) (*models.Staff, error) {
+	staff, err := s.staffRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't return the password
+	staff.Password = ""
+	return staff, nil
+}
+
+// UpdateStaff changes the fields set in the request and leaves the others unchanged.
+// Changing is_active deactivates or reactivates the staff member as DeactivateStaff and
+// ReactivateStaff do.
+func (s *StaffServiceImpl) UpdateStaff(ctx context.Context, id int, req models.StaffPatchRequest) (*models.Staff, error) {
+	staff, err := s.staffRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *staff
+	var changed []string
+	if req.FullName != nil && strings.TrimSpace(*req.FullName) != staff.FullName {
+		updated.FullName = strings.TrimSpace(*req.FullName)
+		changed = append(changed, "full_name")
+	}
+	if req.Department != nil && strings.TrimSpace(*req.Department) != staff.Department {
+		updated.Department = strings.TrimSpace(*req.Department)
+		changed = append(changed, "department")
+	}
+	if req.Role != nil && *req.Role != staff.Role {
+		role, err := s.roleRepo.FindByName(ctx, *req.Role)
+		if err != nil {
+			return nil, err
+		}
+		updated.RoleID = role.ID
+		updated.Role = role.Name
+		changed = append(changed, "role")
+	}
+	if req.IsActive != nil {
+		setActive(&updated, *req.IsActive)
+	}
+
+	return s.saveStaff(ctx, staff, &updated, changed)
+}
+
+// DeactivateStaff stops a staff member from logging in and revokes all of their
+// sessions. The account is kept, so the audit trail still refers to it.
+func (s *StaffServiceImpl) DeactivateStaff(ctx context.Context, id int) (*models.Staff, error) {
+	return s.changeActive(ctx, id, false)
+}
+
+// ReactivateStaff lets a deactivated staff member log in again
+func (s *StaffServiceImpl) ReactivateStaff(ctx context.Context, id int) (*models.Staff, error) {
+	return s.changeActive(ctx, id, true)
+}
+
+// UnlockStaff unlocks a staff member's account and clears their failed login count
+func (s *StaffServiceImpl) UnlockStaff(ctx context.Context, id int) error {
+	if err := s.staffRepo.ResetFailedLogins(ctx, id); err != nil {
+		return err
+	}
+
+	return s.auditService.Record(ctx, &models.AuditEvent{
+		Action:  models.AuditActionStaffUnlock,
+		Source:  models.AuditSourceLocal,
+		Details: map[string]string{"staff_id": strconv.Itoa(id)},
+	})
+}
+
+// ResetPassword issues a one-time token the staff member can set a new password with,
+// replacing any unused token issued before. Only the token's hash is stored, so the
+// token is returned once, to be handed to the staff member out of band.
+func (s *StaffServiceImpl) ResetPassword(ctx context.Context, id int) (*models.PasswordResetTokenResponse, error) {
+	// Make sure the staff member belongs to the caller's hospital
+	staff, err := s.staffRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, apperrors.NewInternalServerError(err)
+	}
+
+	token := &models.PasswordResetToken{
+		StaffID:   staff.ID,
+		TokenHash: utils.HashToken(value),
+		ExpiresAt: time.Now().Add(s.config.Password.ResetTokenTTL),
+	}
+	if staffID, ok := utils.StaffIDFromContext(ctx); ok {
+		token.CreatedBy = &staffID
+	}
+
+	if err := s.passwordResetRepo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	err = s.auditService.Record(ctx, &models.AuditEvent{
+		Action:  models.AuditActionStaffPasswordReset,
+		Source:  models.AuditSourceLocal,
+		Details: map[string]string{"staff_id": strconv.Itoa(staff.ID)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PasswordResetTokenResponse{
+		Token:     value,
+		ExpiresAt: token.ExpiresAt,
+	}, nil
+}
+
+// ResetMFA removes a staff member's two-factor enrollment and recovery codes, so they
+// can log in with their password alone and enroll again. Their sessions are left alone.
+func (s *StaffServiceImpl) ResetMFA(ctx context.Context, id int) error {
+	if err := s.mfaRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	return s.auditService.Record(ctx, &models.AuditEvent{
+		Action:  models.AuditActionStaffMFAReset,
+		Source:  models.AuditSourceLocal,
+		Details: map[string]string{"staff_id": strconv.Itoa(id)},
+	})
+}
+
+// changeActive deactivates or reactivates a staff member, failing with a conflict error
+// if they already are
+func (s *StaffServiceImpl) changeActive(ctx context.Context, id int, active bool) (*models.Staff, error) {
+	staff, err := s.staffRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if staff.IsActive == active {
+		if active {
+			return nil, apperrors.NewConflictError("staff member is already active")
+		}
+		return nil, apperrors.NewConflictError("staff member is already deactivated")
+	}
+
+	updated := *staff
+	setActive(&updated, active)
+	return s.saveStaff(ctx, staff, &updated, nil)
+}
+
+// saveStaff writes the changes from before to after, listed in changed apart from the
+// active flag, and audits them. Sessions are revoked when the staff member is deactivated
+// or their role changes, so the change takes effect at once.
+func (s *StaffServiceImpl) saveStaff(ctx context.Context, before, after *models.Staff, changed []string) (*models.Staff, error) {
+	activeChanged := before.IsActive != after.IsActive
+	if len(changed) == 0 && !activeChanged {
+		after.Password = ""
+		return after, nil
+	}
+
+	if err := s.checkStaffChange(ctx, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := s.staffRepo.Update(ctx, after); err != nil {
+		return nil, err
+	}
+
+	if !after.IsActive || after.Role != before.Role {
+		if err := s.refreshTokenRepo.RevokeAllForStaff(ctx, after.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	staffID := strconv.Itoa(after.ID)
+	if len(changed) > 0 {
+		details := map[string]string{"staff_id": staffID, "fields": strings.Join(changed, ",")}
+		if after.Role != before.Role {
+			details["role"] = after.Role
+		}
+		err := s.auditService.Record(ctx, &models.AuditEvent{
+			Action:  models.AuditActionStaffUpdate,
+			Source:  models.AuditSourceLocal,
+			Details: details,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if activeChanged {
+		action := models.AuditActionStaffDeactivate
+		if after.IsActive {
+			action = models.AuditActionStaffReactivate
+		}
+		err := s.auditService.Record(ctx, &models.AuditEvent{
+			Action:  action,
+			Source:  models.AuditSourceLocal,
+			Details: map[string]string{"staff_id": staffID},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Don't return the password
+	after.Password = ""
+	return after, nil
+}
+
+// checkStaffChange refuses changes that would lock the hospital out of staff management:
+// callers can't deactivate themselves or change their own role, and the last active
+// admin can't be deactivated or given another role
+func (s *StaffServiceImpl) checkStaffChange(ctx context.Context, before, after *models.Staff) error {
+	if callerID, ok := utils.StaffIDFromContext(ctx); ok && callerID == before.ID {
+		if !after.IsActive || after.Role != before.Role {
+			return apperrors.NewForbiddenError("you can't deactivate yourself or change your own role")
+		}
+	}
+
+	demoted := !after.IsActive || after.Role != models.RoleAdmin
+	if before.IsActive && before.Role == models.RoleAdmin && demoted {
+		admins, err := s.staffRepo.CountByRole(ctx, models.RoleAdmin)
+		if err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return apperrors.NewConflictError("the hospital must keep at least one active admin")
+		}
+	}
+
+	return nil
+}
+
+// setActive sets a staff member's active flag and when they were deactivated
+func setActive(staff *models.Staff, active bool) {
+	if staff.IsActive == active {
+		return
+	}
+	staff.IsActive = active
+	staff.DeactivatedAt = nil
+	if !active {
+		now := time.Now()
+		staff.DeactivatedAt = &now
+	}
+}
+
+// resolveRole finds a role by name, falling back to the default role
+func (s *StaffServiceImpl) resolveRole(ctx context.Context, name string) (*models.Role, error) {
+	if name == "" {
+		name = models.DefaultRole
+	}
+	return s.roleRepo.FindByName(ctx, name)
+}
+
+// checkUsernameAvailable fails with a duplicate resource error if the caller's hospital
+// already has a staff member with the username
+func (s *StaffServiceImpl) checkUsernameAvailable(ctx context.Context, username string) error {
+	_, err := s.staffRepo.FindByUsername(ctx, username)
+	if err == nil {
+		return apperrors.NewDuplicateResourceError("staff member already exists")
+	}
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil
+	}
+	return err
+}
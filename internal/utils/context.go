@@ -0,0 +1,100 @@
+package utils
+
+import "context"
+
+// contextKey is an unexported type for context keys defined in this package
+type contextKey string
+
+const (
+	hospitalIDKey  contextKey = "hospitalID"
+	staffIDKey     contextKey = "staffID"
+	apiClientIDKey contextKey = "apiClientID"
+	clientIPKey    contextKey = "clientIP"
+	requestIDKey   contextKey = "requestID"
+	piiAccessKey   contextKey = "piiAccess"
+)
+
+// PIIAccess describes how the caller may see patient PII
+type PIIAccess struct {
+	Unmasked         bool   // PII is served in full instead of partially masked
+	BreakGlassReason string // Why the caller broke the glass to see unmasked PII, empty when their role allows it
+}
+
+// WithHospitalID returns a copy of ctx scoped to the given hospital
+func WithHospitalID(ctx context.Context, hospitalID int) context.Context {
+	return context.WithValue(ctx, hospitalIDKey, hospitalID)
+}
+
+// HospitalIDFromContext returns the hospital the context is scoped to
+func HospitalIDFromContext(ctx context.Context) (int, bool) {
+	hospitalID, ok := ctx.Value(hospitalIDKey).(int)
+	if !ok || hospitalID <= 0 {
+		return 0, false
+	}
+	return hospitalID, true
+}
+
+// WithStaffID returns a copy of ctx carrying the authenticated staff member's ID
+func WithStaffID(ctx context.Context, staffID int) context.Context {
+	return context.WithValue(ctx, staffIDKey, staffID)
+}
+
+// StaffIDFromContext returns the authenticated staff member's ID
+func StaffIDFromContext(ctx context.Context) (int, bool) {
+	staffID, ok := ctx.Value(staffIDKey).(int)
+	if !ok || staffID <= 0 {
+		return 0, false
+	}
+	return staffID, true
+}
+
+// WithAPIClientID returns a copy of ctx carrying the authenticated API client's ID
+func WithAPIClientID(ctx context.Context, clientID int) context.Context {
+	return context.WithValue(ctx, apiClientIDKey, clientID)
+}
+
+// APIClientIDFromContext returns the authenticated API client's ID
+func APIClientIDFromContext(ctx context.Context) (int, bool) {
+	clientID, ok := ctx.Value(apiClientIDKey).(int)
+	if !ok || clientID <= 0 {
+		return 0, false
+	}
+	return clientID, true
+}
+
+// WithClientIP returns a copy of ctx carrying the caller's IP address
+func WithClientIP(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, clientIPKey, clientIP)
+}
+
+// ClientIPFromContext returns the caller's IP address, or an empty string
+func ClientIPFromContext(ctx context.Context) string {
+	clientIP, _ := ctx.Value(clientIPKey).(string)
+	return clientIP
+}
+
+// RequestIDHeader carries the ID correlating a request across HMS and the hospital APIs it calls
+const RequestIDHeader = "X-Request-ID"
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithPIIAccess returns a copy of ctx carrying how the caller may see patient PII
+func WithPIIAccess(ctx context.Context, access PIIAccess) context.Context {
+	return context.WithValue(ctx, piiAccessKey, access)
+}
+
+// PIIAccessFromContext returns how the caller may see patient PII.
+// Without an explicit grant PII is masked.
+func PIIAccessFromContext(ctx context.Context) PIIAccess {
+	access, _ := ctx.Value(piiAccessKey).(PIIAccess)
+	return access
+}
@@ -4,39 +4,90 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/DingDong039/hms/internal/config"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenPurposeMFA marks a token that only proves the password step of a two-step
+// login and can't be used as an access token
+const TokenPurposeMFA = "mfa"
+
+// Token audiences. Access and MFA tokens are signed with the same published keys, so
+// their aud claim is what tells them apart to any verifier.
+const (
+	TokenAudienceAccess = "hms-api"
+	TokenAudienceMFA    = "hms-mfa"
+)
+
 // JWTClaims represents the claims in a JWT token
 type JWTClaims struct {
-	UserID int `json:"user_id"`
+	UserID      int      `json:"user_id"`
+	HospitalID  int      `json:"hospital_id"`
+	Role        string   `json:"role,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+
+	// PasswordChangeRequired limits the token to changing the password
+	PasswordChangeRequired bool `json:"pwd_change,omitempty"`
+	// MFAEnrollmentRequired limits the token to enrolling in two-factor authentication
+	MFAEnrollmentRequired bool `json:"mfa_enroll,omitempty"`
+	// Purpose is set for tokens that aren't access tokens
+	Purpose string `json:"purpose,omitempty"`
+	// APIClientID is set for API clients authenticating with a key; it is never signed into a token
+	APIClientID int `json:"-"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a new JWT token for a user
-func GenerateToken(userID int, cfg config.JWTConfig) (string, int64, error) {
+// HasPermission reports whether the claims grant the given permission
+func (c *JWTClaims) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateToken signs a new JWT token for the given claims, valid for ttl.
+// The registered claims (ID, expiry, issuer, subject, ...) are filled in here.
+func GenerateToken(claims *JWTClaims, ttl time.Duration, keys *JWTKeySet) (string, int64, error) {
+	return signToken(claims, TokenAudienceAccess, ttl, keys)
+}
+
+// GenerateMFAToken signs a short-lived token proving a staff member passed the password
+// step of a two-step login, to be exchanged for an access token with a second factor
+func GenerateMFAToken(staffID, hospitalID int, ttl time.Duration, keys *JWTKeySet) (string, int64, error) {
+	claims := &JWTClaims{
+		UserID:     staffID,
+		HospitalID: hospitalID,
+		Purpose:    TokenPurposeMFA,
+	}
+	return signToken(claims, TokenAudienceMFA, ttl, keys)
+}
+
+// signToken signs a JWT token for the given claims and audience with the current key, valid for ttl
+func signToken(claims *JWTClaims, audience string, ttl time.Duration, keys *JWTKeySet) (string, int64, error) {
 	// Set expiration time
-	expirationTime := time.Now().Add(time.Duration(cfg.ExpireTime) * time.Hour)
+	expirationTime := time.Now().Add(ttl)
 	expiresAt := expirationTime.Unix()
 
-	// Create claims
-	claims := &JWTClaims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "hms-api",
-			Subject:   fmt.Sprintf("%d", userID),
-		},
+	// Generate a unique token ID so the token can be revoked
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", 0, err
 	}
 
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Set registered claims
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		ExpiresAt: jwt.NewNumericDate(expirationTime),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		NotBefore: jwt.NewNumericDate(time.Now()),
+		Issuer:    "hms-api",
+		Subject:   fmt.Sprintf("%d", claims.UserID),
+		Audience:  jwt.ClaimStrings{audience},
+	}
 
-	// Sign token with secret key
-	tokenString, err := token.SignedString([]byte(cfg.Secret))
+	// Sign token with the current key
+	tokenString, err := keys.sign(claims)
 	if err != nil {
 		return "", 0, err
 	}
@@ -44,16 +95,37 @@ func GenerateToken(userID int, cfg config.JWTConfig) (string, int64, error) {
 	return tokenString, expiresAt, nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
-func ValidateToken(tokenString string, cfg config.JWTConfig) (*JWTClaims, error) {
-	// Parse token
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(cfg.Secret), nil
-	})
+// ValidateToken validates an access token and returns the claims
+func ValidateToken(tokenString string, keys *JWTKeySet) (*JWTClaims, error) {
+	claims, err := parseToken(tokenString, TokenAudienceAccess, keys)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, fmt.Errorf("not an access token")
+	}
+	return claims, nil
+}
+
+// ValidateMFAToken validates a token issued by GenerateMFAToken and returns the claims
+func ValidateMFAToken(tokenString string, keys *JWTKeySet) (*JWTClaims, error) {
+	claims, err := parseToken(tokenString, TokenAudienceMFA, keys)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != TokenPurposeMFA {
+		return nil, fmt.Errorf("not an MFA token")
+	}
+	return claims, nil
+}
+
+// parseToken verifies a JWT token's signature, with the key its kid header names, its
+// expiry and its audience and returns the claims
+func parseToken(tokenString, audience string, keys *JWTKeySet) (*JWTClaims, error) {
+	// Parse token, accepting only the asymmetric algorithms keys are issued for
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, keys.verificationKey,
+		jwt.WithValidMethods([]string{JWTAlgorithmRS256, JWTAlgorithmEdDSA}),
+		jwt.WithAudience(audience))
 
 	if err != nil {
 		return nil, err
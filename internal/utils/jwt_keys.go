@@ -0,0 +1,372 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWT signing algorithms
+const (
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+// rsaKeyBits is the size of generated RSA signing keys
+const rsaKeyBits = 2048
+
+// JWTKeyFile is the JSON layout of a JWT signing key file. Keys are PEM-encoded PKCS #8
+// RSA or Ed25519 private keys. The current key signs new tokens; every listed key
+// verifies tokens and is published in the JWKS, so a key can be published before it
+// becomes current and kept after it is retired until the tokens it signed expire.
+type JWTKeyFile struct {
+	CurrentKeyID string            `json:"current_key_id"`
+	Keys         map[string]string `json:"keys"`
+}
+
+// JWTKeySet holds the keys tokens are signed and verified with
+type JWTKeySet struct {
+	currentKeyID string
+	keys         map[string]*jwtKey
+}
+
+// jwtKey is a private key and the algorithm it signs with
+type jwtKey struct {
+	method jwt.SigningMethod
+	signer crypto.Signer
+}
+
+// JWK is the public half of a signing key as published in a JSON Web Key Set (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"` // Ed25519 and EC keys
+	X   string `json:"x,omitempty"`   // Ed25519 and EC keys
+	Y   string `json:"y,omitempty"`   // EC keys
+	N   string `json:"n,omitempty"`   // RSA keys
+	E   string `json:"e,omitempty"`   // RSA keys
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicKey decodes the public key of an RSA, EC or Ed25519 JWK
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, errors.New("invalid EC point")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// LoadJWTKeySet reads a JWT signing key file and creates a JWTKeySet from it
+func LoadJWTKeySet(path string) (*JWTKeySet, error) {
+	file, err := ReadJWTKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewJWTKeySet(file)
+}
+
+// NewJWTKeySet creates a JWTKeySet from the contents of a key file
+func NewJWTKeySet(file *JWTKeyFile) (*JWTKeySet, error) {
+	if _, ok := file.Keys[file.CurrentKeyID]; !ok {
+		return nil, fmt.Errorf("current JWT key %q is not in the key file", file.CurrentKeyID)
+	}
+
+	set := &JWTKeySet{
+		currentKeyID: file.CurrentKeyID,
+		keys:         make(map[string]*jwtKey, len(file.Keys)),
+	}
+	for id, encoded := range file.Keys {
+		if id == "" {
+			return nil, errors.New("JWT key with an empty ID")
+		}
+		key, err := parseJWTKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT key %q: %w", id, err)
+		}
+		set.keys[id] = key
+	}
+
+	return set, nil
+}
+
+// CurrentKeyID returns the ID of the key new tokens are signed with
+func (s *JWTKeySet) CurrentKeyID() string {
+	return s.currentKeyID
+}
+
+// JWKS returns the public halves of every key, ordered by key ID
+func (s *JWTKeySet) JWKS() *JWKS {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := &JWKS{Keys: make([]JWK, 0, len(ids))}
+	for _, id := range ids {
+		key := s.keys[id]
+		jwk := JWK{Use: "sig", Alg: key.method.Alg(), Kid: id}
+		switch public := key.signer.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+// sign signs a token with the current key and names the key in its kid header
+func (s *JWTKeySet) sign(claims jwt.Claims) (string, error) {
+	key := s.keys[s.currentKeyID]
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = s.currentKeyID
+	return token.SignedString(key.signer)
+}
+
+// verificationKey returns the public key a token's kid header names, checking that the
+// token is signed with that key's algorithm
+func (s *JWTKeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", id)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.signer.Public(), nil
+}
+
+// AddJWTKey generates a new signing key with the given algorithm, adds it to the key
+// file at path and returns its ID. The file is created if it doesn't exist. With
+// makeCurrent the new key signs tokens from the next start; otherwise it is only
+// published, so partners can fetch it before it is made current with SetCurrentJWTKey.
+func AddJWTKey(path, algorithm string, makeCurrent bool) (string, error) {
+	file, err := ReadJWTKeyFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		file = &JWTKeyFile{Keys: map[string]string{}}
+	} else if err != nil {
+		return "", err
+	}
+
+	key, err := generateJWTKey(algorithm)
+	if err != nil {
+		return "", err
+	}
+	// IDs sort by creation time; the random suffix keeps keys added in the same second apart
+	suffix := make([]byte, 4)
+	if _, err := io.ReadFull(rand.Reader, suffix); err != nil {
+		return "", err
+	}
+	id := time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+	if _, exists := file.Keys[id]; exists {
+		return "", fmt.Errorf("JWT key %q already exists", id)
+	}
+	file.Keys[id] = key
+	if makeCurrent || file.CurrentKeyID == "" {
+		file.CurrentKeyID = id
+	}
+
+	if err := writeJWTKeyFile(path, file); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// SetCurrentJWTKey makes a key already in the key file at path the current key
+func SetCurrentJWTKey(path, id string) error {
+	file, err := ReadJWTKeyFile(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := file.Keys[id]; !ok {
+		return fmt.Errorf("JWT key %q is not in the key file", id)
+	}
+	file.CurrentKeyID = id
+	return writeJWTKeyFile(path, file)
+}
+
+// RemoveJWTKey removes a retired key from the key file at path. Tokens signed with it
+// stop verifying, so it should only be removed once they have expired.
+func RemoveJWTKey(path, id string) error {
+	file, err := ReadJWTKeyFile(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := file.Keys[id]; !ok {
+		return fmt.Errorf("JWT key %q is not in the key file", id)
+	}
+	if id == file.CurrentKeyID {
+		return fmt.Errorf("JWT key %q is the current key", id)
+	}
+	delete(file.Keys, id)
+	return writeJWTKeyFile(path, file)
+}
+
+// ReadJWTKeyFile reads and parses a JWT signing key file
+func ReadJWTKeyFile(path string) (*JWTKeyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key file: %w", err)
+	}
+
+	file := &JWTKeyFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("invalid JWT key file: %w", err)
+	}
+	if file.Keys == nil {
+		file.Keys = map[string]string{}
+	}
+	return file, nil
+}
+
+// JWTKeyAlgorithm returns the algorithm a PEM-encoded key from a key file signs with
+func JWTKeyAlgorithm(encoded string) (string, error) {
+	key, err := parseJWTKey(encoded)
+	if err != nil {
+		return "", err
+	}
+	return key.method.Alg(), nil
+}
+
+// writeJWTKeyFile writes a key file, readable only by its owner
+func writeJWTKeyFile(path string, file *JWTKeyFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a failed write can't lose the existing keys
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// generateJWTKey returns a new PEM-encoded private key for the given algorithm
+func generateJWTKey(algorithm string) (string, error) {
+	var key crypto.Signer
+	var err error
+	switch algorithm {
+	case JWTAlgorithmRS256:
+		key, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case JWTAlgorithmEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// parseJWTKey parses a PEM-encoded PKCS #8 private key and picks its signing algorithm
+func parseJWTKey(encoded string) (*jwtKey, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(encoded)))
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("expected a PEM-encoded PKCS #8 private key")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key must be at least 2048 bits, got %d", key.N.BitLen())
+		}
+		return &jwtKey{method: jwt.SigningMethodRS256, signer: key}, nil
+	case ed25519.PrivateKey:
+		return &jwtKey{method: jwt.SigningMethodEdDSA, signer: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue replaces personal data in log output
+const RedactedValue = "[REDACTED]"
+
+// piiLogKeys are attribute keys whose values are always personal data
+var piiLogKeys = map[string]bool{
+	"national_id":  true,
+	"passport_id":  true,
+	"phone_number": true,
+	"phone":        true,
+	"email":        true,
+}
+
+// piiLogPatterns match personal data embedded in free text, such as error messages and paths
+var piiLogPatterns = []*regexp.Regexp{
+	// Email addresses
+	regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	// Thai national IDs, with or without dashes
+	regexp.MustCompile(`\b\d-?\d{4}-?\d{5}-?\d{2}-?\d\b`),
+	// Phone numbers in local (0XX) or international (+CC) form
+	regexp.MustCompile(`(?:\+\d{1,3}[ -]?|\b0)\d{1,2}[ -]?\d{3,4}[ -]?\d{3,4}\b`),
+	// Passport numbers
+	regexp.MustCompile(`\b[A-Z]{1,2}\d{6,9}\b`),
+	// Any other long run of digits
+	regexp.MustCompile(`\b\d{9,}\b`),
+}
+
+// NewLogger creates the application logger writing JSON or text records to w. National IDs,
+// passport IDs, phone numbers and emails are redacted from every record, and records logged
+// with a request context carry the request's ID.
+func NewLogger(w io.Writer, level slog.Level, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if format == "text" {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+
+	return slog.New(NewRedactingHandler(requestIDHandler{handler}))
+}
+
+// RedactingHandler is a slog handler that removes personal data from records before
+// passing them on. Attributes named after PII fields are replaced outright; the message
+// and every other string value are scrubbed of anything shaped like PII. Values of other
+// kinds that may hold text, such as errors and structs, are logged as scrubbed strings.
+type RedactingHandler struct {
+	next slog.Handler
+}
+
+// NewRedactingHandler wraps a handler so that PII never reaches it
+func NewRedactingHandler(next slog.Handler) *RedactingHandler {
+	return &RedactingHandler{next: next}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle redacts a record and passes it to the wrapped handler
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, RedactPII(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs returns a handler whose records carry the given attributes, redacted
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+	return &RedactingHandler{next: h.next.WithAttrs(redacted)}
+}
+
+// WithGroup returns a handler that nests the attributes that follow under a group
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name)}
+}
+
+// RedactPII replaces anything shaped like personal data in a string
+func RedactPII(s string) string {
+	for _, pattern := range piiLogPatterns {
+		s = pattern.ReplaceAllString(s, RedactedValue)
+	}
+	return s
+}
+
+// redactAttr redacts an attribute's value, recursing into groups
+func redactAttr(attr slog.Attr) slog.Attr {
+	if piiLogKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, RedactedValue)
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, RedactPII(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, member := range group {
+			redacted[i] = redactAttr(member)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, RedactPII(err.Error()))
+		}
+		return slog.String(attr.Key, RedactPII(fmt.Sprintf("%+v", value.Any())))
+	default:
+		return slog.Attr{Key: attr.Key, Value: value}
+	}
+}
+
+// requestIDHandler is a slog handler adding the ID of the request being served to records
+type requestIDHandler struct {
+	slog.Handler
+}
+
+// Handle adds the request ID from ctx, if any, and passes the record on
+func (h requestIDHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a handler whose records carry the given attributes
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler that nests the attributes that follow under a group
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,65 @@
+package utils
+
+import "strings"
+
+// maskChar replaces the hidden characters of a masked value
+const maskChar = "x"
+
+// MaskNationalID partially masks a Thai national ID in its printed form,
+// e.g. 1234567890121 becomes 1-2345-xxxxx-12-1. Other values keep only their last three characters.
+func MaskNationalID(nationalID string) string {
+	if len(nationalID) != 13 || !isDigits(nationalID) {
+		return maskAllButLast(nationalID, 3)
+	}
+	return nationalID[0:1] + "-" + nationalID[1:5] + "-" + strings.Repeat(maskChar, 5) + "-" + nationalID[10:12] + "-" + nationalID[12:]
+}
+
+// IsMaskedNationalID reports whether s is a Thai national ID in the masked form MaskNationalID prints
+func IsMaskedNationalID(s string) bool {
+	return len(s) == 17 &&
+		isDigits(s[0:1]) && s[1] == '-' &&
+		isDigits(s[2:6]) && s[6:13] == "-"+strings.Repeat(maskChar, 5)+"-" &&
+		isDigits(s[13:15]) && s[15] == '-' &&
+		isDigits(s[16:17])
+}
+
+// MaskPassportID masks all but the last three characters of a passport ID
+func MaskPassportID(passportID string) string {
+	return maskAllButLast(passportID, 3)
+}
+
+// MaskPhoneNumber masks all but the last four digits of a phone number
+func MaskPhoneNumber(phoneNumber string) string {
+	return maskAllButLast(phoneNumber, 4)
+}
+
+// MaskEmail masks the local part of an email address except its first character,
+// e.g. somchai@example.com becomes sxxxxxx@example.com
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return maskAllButLast(email, 0)
+	}
+	local := []rune(email[:at])
+	return string(local[0]) + strings.Repeat(maskChar, len(local)-1) + email[at:]
+}
+
+// maskAllButLast masks every character of s except the last n.
+// Values too short to keep n characters hidden are masked entirely.
+func maskAllButLast(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n*2 {
+		return strings.Repeat(maskChar, len(runes))
+	}
+	return strings.Repeat(maskChar, len(runes)-n) + string(runes[len(runes)-n:])
+}
+
+// isDigits reports whether s consists of ASCII digits only
+func isDigits(s string) bool {
+	for _, char := range s {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
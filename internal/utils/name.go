@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeName prepares a name for fuzzy matching: it lowercases the name,
+// strips Thai tone and diacritic marks (U+0E47-U+0E4E) and collapses whitespace.
+// It mirrors the hms_normalize_name SQL function used by the trigram indexes.
+func NormalizeName(name string) string {
+	var b strings.Builder
+	b.Grow(len(name))
+
+	space := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= '\u0E47' && r <= '\u0E4E':
+			continue
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
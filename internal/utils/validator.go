@@ -1,10 +1,21 @@
 package utils
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 )
 
+func init() {
+	// Register the custom validation tags used in request bindings
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		_ = v.RegisterValidation("thai_national_id", validateThaiNationalID)
+		_ = v.RegisterValidation("past_date", validatePastDate)
+	}
+}
+
 // ValidationError represents a validation error
 type ValidationError struct {
 	Field   string `json:"field"`
@@ -14,41 +25,101 @@ type ValidationError struct {
 // ValidateRequest validates a request struct and returns validation errors
 func ValidateRequest(c *gin.Context, req interface{}) []ValidationError {
 	if err := c.ShouldBindJSON(req); err != nil {
-		var validationErrors []ValidationError
-
-		if verrs, ok := err.(validator.ValidationErrors); ok {
-			for _, verr := range verrs {
-				validationError := ValidationError{
-					Field:   verr.Field(),
-					Message: getValidationErrorMessage(verr),
-				}
-				validationErrors = append(validationErrors, validationError)
-			}
-		} else {
-			validationErrors = append(validationErrors, ValidationError{
-				Field:   "request",
-				Message: "Invalid request format",
-			})
-		}
+		return toValidationErrors(err)
+	}
 
-		return validationErrors
+	return nil
+}
+
+// ValidateQuery binds and validates query string parameters into a request struct
+// and returns validation errors
+func ValidateQuery(c *gin.Context, req interface{}) []ValidationError {
+	if err := c.ShouldBindQuery(req); err != nil {
+		return toValidationErrors(err)
 	}
 
 	return nil
 }
 
+// toValidationErrors converts a binding error into validation errors
+func toValidationErrors(err error) []ValidationError {
+	var validationErrors []ValidationError
+
+	if verrs, ok := err.(validator.ValidationErrors); ok {
+		for _, verr := range verrs {
+			validationError := ValidationError{
+				Field:   verr.Field(),
+				Message: getValidationErrorMessage(verr),
+			}
+			validationErrors = append(validationErrors, validationError)
+		}
+	} else {
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "request",
+			Message: "Invalid request format",
+		})
+	}
+
+	return validationErrors
+}
+
 // getValidationErrorMessage returns a human-readable error message for a validation error
 func getValidationErrorMessage(verr validator.FieldError) string {
 	switch verr.Tag() {
 	case "required":
 		return "This field is required"
+	case "required_without":
+		return "This field is required when " + verr.Param() + " is not set"
 	case "email":
 		return "Invalid email format"
 	case "min":
 		return "Value is too short"
 	case "max":
 		return "Value is too long"
+	case "oneof":
+		return "Value must be one of: " + verr.Param()
+	case "thai_national_id":
+		return "Invalid Thai national ID"
+	case "past_date":
+		return "Date must be in the past"
 	default:
 		return "Invalid value"
 	}
 }
+
+// IsValidThaiNationalID reports whether id is a 13-digit Thai national ID with a valid check digit
+func IsValidThaiNationalID(id string) bool {
+	if len(id) != 13 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 13; i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return false
+		}
+		if i < 12 {
+			sum += int(id[i]-'0') * (13 - i)
+		}
+	}
+
+	checkDigit := (11 - sum%11) % 10
+	return int(id[12]-'0') == checkDigit
+}
+
+// validateThaiNationalID implements the thai_national_id validation tag. The masked form
+// is accepted too, so that a record read with masked PII can be sent back; the patient
+// service restores it to the stored ID or rejects it.
+func validateThaiNationalID(fl validator.FieldLevel) bool {
+	id := fl.Field().String()
+	return IsValidThaiNationalID(id) || IsMaskedNationalID(id)
+}
+
+// validatePastDate implements the past_date validation tag
+func validatePastDate(fl validator.FieldLevel) bool {
+	date, ok := fl.Field().Interface().(time.Time)
+	if !ok {
+		return false
+	}
+	return !date.IsZero() && date.Before(time.Now())
+}
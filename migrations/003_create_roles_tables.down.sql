@@ -0,0 +1,6 @@
+-- Down migration: unlink staff from roles and drop role tables
+DROP INDEX IF EXISTS idx_staff_role_id;
+ALTER TABLE staff DROP COLUMN IF EXISTS role_id;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,61 @@
+-- Up migration: create roles, permissions and role_permissions tables and link staff to a role
+CREATE TABLE IF NOT EXISTS roles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    description VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(name)
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description VARCHAR(255),
+    UNIQUE(name)
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+-- Seed roles
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access, including staff management'),
+    ('doctor', 'Reads and updates patient records'),
+    ('nurse', 'Reads patient records'),
+    ('registrar', 'Registers and updates patient records'),
+    ('auditor', 'Reads the audit trail')
+ON CONFLICT (name) DO NOTHING;
+
+-- Seed permissions
+INSERT INTO permissions (name, description) VALUES
+    ('patient:read', 'Search and view patient records'),
+    ('patient:write', 'Create and update patient records'),
+    ('patient:delete', 'Delete patient records'),
+    ('staff:read', 'View staff accounts'),
+    ('staff:manage', 'Create, update and deactivate staff accounts'),
+    ('audit:read', 'Query the audit trail')
+ON CONFLICT (name) DO NOTHING;
+
+-- Grant permissions to roles
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON (
+    (r.name = 'admin') OR
+    (r.name = 'doctor' AND p.name IN ('patient:read', 'patient:write')) OR
+    (r.name = 'nurse' AND p.name IN ('patient:read')) OR
+    (r.name = 'registrar' AND p.name IN ('patient:read', 'patient:write')) OR
+    (r.name = 'auditor' AND p.name IN ('audit:read', 'staff:read'))
+)
+ON CONFLICT DO NOTHING;
+
+-- Link staff to a role; existing staff become registrars
+ALTER TABLE staff ADD COLUMN IF NOT EXISTS role_id INTEGER REFERENCES roles(id);
+UPDATE staff SET role_id = (SELECT id FROM roles WHERE name = 'registrar') WHERE role_id IS NULL;
+ALTER TABLE staff ALTER COLUMN role_id SET NOT NULL;
+
+-- Create index for faster lookups
+CREATE INDEX IF NOT EXISTS idx_staff_role_id ON staff(role_id);
@@ -0,0 +1,8 @@
+-- Down migration: unscope staff and patients and drop hospitals table
+DROP INDEX IF EXISTS idx_patients_hospital_id;
+DROP INDEX IF EXISTS idx_staff_hospital_id;
+ALTER TABLE patients DROP COLUMN IF EXISTS hospital_id;
+ALTER TABLE staff DROP CONSTRAINT IF EXISTS staff_hospital_id_username_key;
+ALTER TABLE staff DROP COLUMN IF EXISTS hospital_id;
+ALTER TABLE staff ADD CONSTRAINT staff_username_key UNIQUE (username);
+DROP TABLE IF EXISTS hospitals;
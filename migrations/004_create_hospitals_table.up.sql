@@ -0,0 +1,29 @@
+-- Up migration: create hospitals table and scope staff and patients to a hospital
+CREATE TABLE IF NOT EXISTS hospitals (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(code)
+);
+
+-- Seed the hospital that existing data belongs to
+INSERT INTO hospitals (code, name) VALUES ('hospital-a', 'Hospital A')
+ON CONFLICT (code) DO NOTHING;
+
+-- Scope staff to a hospital; usernames are unique per hospital
+ALTER TABLE staff ADD COLUMN IF NOT EXISTS hospital_id INTEGER REFERENCES hospitals(id);
+UPDATE staff SET hospital_id = (SELECT id FROM hospitals WHERE code = 'hospital-a') WHERE hospital_id IS NULL;
+ALTER TABLE staff ALTER COLUMN hospital_id SET NOT NULL;
+ALTER TABLE staff DROP CONSTRAINT IF EXISTS staff_username_key;
+ALTER TABLE staff ADD CONSTRAINT staff_hospital_id_username_key UNIQUE (hospital_id, username);
+
+-- Scope patients to a hospital
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS hospital_id INTEGER REFERENCES hospitals(id);
+UPDATE patients SET hospital_id = (SELECT id FROM hospitals WHERE code = 'hospital-a') WHERE hospital_id IS NULL;
+ALTER TABLE patients ALTER COLUMN hospital_id SET NOT NULL;
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_staff_hospital_id ON staff(hospital_id);
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_id ON patients(hospital_id);
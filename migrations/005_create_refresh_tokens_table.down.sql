@@ -0,0 +1,5 @@
+-- Down migration: drop refresh_tokens indexes and table
+DROP INDEX IF EXISTS idx_refresh_tokens_access_jti;
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+DROP INDEX IF EXISTS idx_refresh_tokens_staff_id;
+DROP TABLE IF EXISTS refresh_tokens;
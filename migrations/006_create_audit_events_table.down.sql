@@ -0,0 +1,8 @@
+-- Down migration: drop audit_events triggers, indexes and table
+DROP TRIGGER IF EXISTS trg_audit_events_no_truncate ON audit_events;
+DROP TRIGGER IF EXISTS trg_audit_events_immutable ON audit_events;
+DROP FUNCTION IF EXISTS audit_events_immutable();
+DROP INDEX IF EXISTS idx_audit_events_patient_id;
+DROP INDEX IF EXISTS idx_audit_events_staff_id;
+DROP INDEX IF EXISTS idx_audit_events_hospital_occurred_at;
+DROP TABLE IF EXISTS audit_events;
@@ -0,0 +1,35 @@
+-- Up migration: create append-only audit_events table
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    hospital_id INTEGER NOT NULL REFERENCES hospitals(id),
+    staff_id INTEGER REFERENCES staff(id) ON DELETE SET NULL,
+    patient_id INTEGER,
+    action VARCHAR(50) NOT NULL,
+    source VARCHAR(100) NOT NULL,
+    client_ip VARCHAR(45),
+    request_id VARCHAR(100),
+    details JSONB,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Audit events can be appended but never changed or removed
+CREATE OR REPLACE FUNCTION audit_events_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_audit_events_immutable ON audit_events;
+CREATE TRIGGER trg_audit_events_immutable
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_immutable();
+
+DROP TRIGGER IF EXISTS trg_audit_events_no_truncate ON audit_events;
+CREATE TRIGGER trg_audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_immutable();
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_audit_events_hospital_occurred_at ON audit_events(hospital_id, occurred_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_staff_id ON audit_events(staff_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_patient_id ON audit_events(patient_id);
@@ -0,0 +1,5 @@
+-- Down migration: drop patient search indexes
+DROP INDEX IF EXISTS idx_patients_hospital_created_at;
+DROP INDEX IF EXISTS idx_patients_hospital_email;
+DROP INDEX IF EXISTS idx_patients_hospital_phone_number;
+DROP INDEX IF EXISTS idx_patients_hospital_date_of_birth;
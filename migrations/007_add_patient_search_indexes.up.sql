@@ -0,0 +1,5 @@
+-- Up migration: index the columns used by multi-criteria patient search
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_date_of_birth ON patients(hospital_id, date_of_birth);
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_phone_number ON patients(hospital_id, phone_number);
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_email ON patients(hospital_id, LOWER(email));
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_created_at ON patients(hospital_id, created_at DESC);
@@ -0,0 +1,6 @@
+-- Down migration: drop patient name trigram indexes and normalization function
+DROP INDEX IF EXISTS idx_patients_last_name_en_trgm;
+DROP INDEX IF EXISTS idx_patients_first_name_en_trgm;
+DROP INDEX IF EXISTS idx_patients_last_name_th_trgm;
+DROP INDEX IF EXISTS idx_patients_first_name_th_trgm;
+DROP FUNCTION IF EXISTS hms_normalize_name(TEXT);
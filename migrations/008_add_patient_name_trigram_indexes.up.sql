@@ -0,0 +1,17 @@
+-- Up migration: enable trigram matching on normalized patient names
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- hms_normalize_name lowercases a name, strips Thai tone and diacritic marks
+-- (U+0E47-U+0E4E) and collapses whitespace, so names typed with or without tone
+-- marks compare equal. It must stay in sync with utils.NormalizeName.
+CREATE OR REPLACE FUNCTION hms_normalize_name(name TEXT) RETURNS TEXT AS $$
+    SELECT btrim(regexp_replace(
+        translate(lower(COALESCE(name, '')), U&'\0E47\0E48\0E49\0E4A\0E4B\0E4C\0E4D\0E4E', ''),
+        '\s+', ' ', 'g'
+    ));
+$$ LANGUAGE SQL IMMUTABLE PARALLEL SAFE;
+
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_th_trgm ON patients USING GIN (hms_normalize_name(first_name_th) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_th_trgm ON patients USING GIN (hms_normalize_name(last_name_th) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_first_name_en_trgm ON patients USING GIN (hms_normalize_name(first_name_en) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_patients_last_name_en_trgm ON patients USING GIN (hms_normalize_name(last_name_en) gin_trgm_ops);
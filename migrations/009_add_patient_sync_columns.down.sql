@@ -0,0 +1,5 @@
+-- Down migration: drop patient sync columns and indexes
+DROP INDEX IF EXISTS idx_patients_source_last_synced_at;
+DROP INDEX IF EXISTS idx_patients_source_hn;
+ALTER TABLE patients DROP COLUMN IF EXISTS last_synced_at;
+ALTER TABLE patients DROP COLUMN IF EXISTS source_hospital;
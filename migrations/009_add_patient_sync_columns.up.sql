@@ -0,0 +1,11 @@
+-- Up migration: track which upstream hospital a cached patient came from and when it was last synced
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS source_hospital VARCHAR(50);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS last_synced_at TIMESTAMP WITH TIME ZONE;
+
+-- A cached record is identified by its HN at the upstream hospital, so re-caching updates it in place
+CREATE UNIQUE INDEX IF NOT EXISTS idx_patients_source_hn ON patients(hospital_id, source_hospital, patient_hn)
+    WHERE source_hospital IS NOT NULL;
+
+-- Finds the cached records due for a refresh
+CREATE INDEX IF NOT EXISTS idx_patients_source_last_synced_at ON patients(hospital_id, source_hospital, last_synced_at)
+    WHERE source_hospital IS NOT NULL;
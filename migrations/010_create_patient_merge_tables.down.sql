@@ -0,0 +1,4 @@
+-- Down migration: drop patient merge tables and the merge permission
+DELETE FROM permissions WHERE name = 'patient:merge';
+DROP TABLE IF EXISTS patient_links;
+DROP TABLE IF EXISTS patient_duplicate_candidates;
@@ -0,0 +1,47 @@
+-- Up migration: create patient duplicate candidate and merge link tables
+CREATE TABLE IF NOT EXISTS patient_duplicate_candidates (
+    id SERIAL PRIMARY KEY,
+    hospital_id INTEGER NOT NULL REFERENCES hospitals(id),
+    patient_id_a INTEGER NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    patient_id_b INTEGER NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    reason VARCHAR(20) NOT NULL,
+    score REAL NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    detected_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_candidate_order CHECK (patient_id_a < patient_id_b),
+    CONSTRAINT chk_candidate_reason CHECK (reason IN ('national_id', 'passport_id', 'name_dob')),
+    CONSTRAINT chk_candidate_status CHECK (status IN ('open', 'dismissed')),
+    UNIQUE(patient_id_a, patient_id_b)
+);
+
+-- A merged-away patient is deleted; its link keeps a snapshot of the record and
+-- lets its ID and HN resolve to the surviving patient
+CREATE TABLE IF NOT EXISTS patient_links (
+    id SERIAL PRIMARY KEY,
+    hospital_id INTEGER NOT NULL REFERENCES hospitals(id),
+    survivor_id INTEGER NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    merged_patient_id INTEGER NOT NULL,
+    merged_patient_hn VARCHAR(50) NOT NULL,
+    snapshot JSONB NOT NULL,
+    merged_by INTEGER REFERENCES staff(id) ON DELETE SET NULL,
+    reason VARCHAR(255),
+    merged_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(merged_patient_id)
+);
+
+-- Seed the merge permission
+INSERT INTO permissions (name, description) VALUES
+    ('patient:merge', 'Review duplicate patients and merge patient records')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'patient:merge'
+WHERE r.name IN ('admin', 'registrar')
+ON CONFLICT DO NOTHING;
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_patient_duplicate_candidates_hospital_status ON patient_duplicate_candidates(hospital_id, status);
+CREATE INDEX IF NOT EXISTS idx_patient_links_survivor_id ON patient_links(survivor_id);
+CREATE INDEX IF NOT EXISTS idx_patient_links_hospital_hn ON patient_links(hospital_id, merged_patient_hn);
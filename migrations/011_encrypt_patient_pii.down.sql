@@ -0,0 +1,16 @@
+-- Down migration: drop the blind indexes and restore the plaintext indexes.
+-- Encrypted values are left as they are; the columns keep the TEXT type so they still fit.
+DROP INDEX IF EXISTS idx_patients_hospital_email_bidx;
+DROP INDEX IF EXISTS idx_patients_hospital_phone_number_bidx;
+DROP INDEX IF EXISTS idx_patients_hospital_passport_id_bidx;
+DROP INDEX IF EXISTS idx_patients_hospital_national_id_bidx;
+
+ALTER TABLE patients DROP COLUMN IF EXISTS email_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS phone_number_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS passport_id_bidx;
+ALTER TABLE patients DROP COLUMN IF EXISTS national_id_bidx;
+
+CREATE INDEX IF NOT EXISTS idx_patients_national_id ON patients(national_id);
+CREATE INDEX IF NOT EXISTS idx_patients_passport_id ON patients(passport_id);
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_phone_number ON patients(hospital_id, phone_number);
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_email ON patients(hospital_id, LOWER(email));
@@ -0,0 +1,22 @@
+-- Up migration: make room for encrypted patient PII and add blind indexes to look it up by.
+-- Existing plaintext values are encrypted and indexed by running cmd/rotatekeys.
+ALTER TABLE patients ALTER COLUMN national_id TYPE TEXT;
+ALTER TABLE patients ALTER COLUMN passport_id TYPE TEXT;
+ALTER TABLE patients ALTER COLUMN phone_number TYPE TEXT;
+ALTER TABLE patients ALTER COLUMN email TYPE TEXT;
+
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS national_id_bidx CHAR(64);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS passport_id_bidx CHAR(64);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS phone_number_bidx CHAR(64);
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS email_bidx CHAR(64);
+
+-- Indexes on ciphertext are useless; lookups go through the blind indexes instead
+DROP INDEX IF EXISTS idx_patients_national_id;
+DROP INDEX IF EXISTS idx_patients_passport_id;
+DROP INDEX IF EXISTS idx_patients_hospital_phone_number;
+DROP INDEX IF EXISTS idx_patients_hospital_email;
+
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_national_id_bidx ON patients(hospital_id, national_id_bidx);
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_passport_id_bidx ON patients(hospital_id, passport_id_bidx);
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_phone_number_bidx ON patients(hospital_id, phone_number_bidx);
+CREATE INDEX IF NOT EXISTS idx_patients_hospital_email_bidx ON patients(hospital_id, email_bidx);
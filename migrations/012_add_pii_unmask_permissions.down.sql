@@ -0,0 +1,2 @@
+-- Down migration: remove the PII unmask permissions
+DELETE FROM permissions WHERE name IN ('patient:unmask', 'patient:break_glass');
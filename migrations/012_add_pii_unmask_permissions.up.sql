@@ -0,0 +1,14 @@
+-- Up migration: seed the permissions that let staff see patient PII unmasked
+INSERT INTO permissions (name, description) VALUES
+    ('patient:unmask', 'See patient national ID, passport ID, phone and email unmasked'),
+    ('patient:break_glass', 'See patient PII unmasked after stating a reason, which is audited')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON (
+    (r.name IN ('admin', 'doctor') AND p.name = 'patient:unmask') OR
+    (r.name IN ('nurse', 'registrar') AND p.name = 'patient:break_glass')
+)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,17 @@
+-- Up migration: track failed logins per staff member and per client IP
+ALTER TABLE staff
+    ADD COLUMN IF NOT EXISTS failed_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+
+-- Every login attempt, so failures from one IP address can be counted across accounts
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    hospital_id INTEGER REFERENCES hospitals(id) ON DELETE CASCADE,
+    username VARCHAR(50) NOT NULL,
+    client_ip VARCHAR(45) NOT NULL,
+    succeeded BOOLEAN NOT NULL,
+    attempted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_login_attempts_client_ip_attempted_at ON login_attempts(client_ip, attempted_at);
@@ -0,0 +1,7 @@
+-- Down migration: remove password history and reset tokens
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS password_history;
+
+ALTER TABLE staff
+    DROP COLUMN IF EXISTS password_changed_at,
+    DROP COLUMN IF EXISTS must_change_password;
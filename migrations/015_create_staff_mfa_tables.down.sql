@@ -0,0 +1,3 @@
+-- Down migration: drop TOTP two-factor authentication tables
+DROP TABLE IF EXISTS staff_recovery_codes;
+DROP TABLE IF EXISTS staff_mfa;
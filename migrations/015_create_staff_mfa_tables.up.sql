@@ -0,0 +1,19 @@
+-- Up migration: create TOTP two-factor authentication tables
+-- The secret is encrypted like patient PII; a row is pending until enabled_at is set
+CREATE TABLE IF NOT EXISTS staff_mfa (
+    staff_id INTEGER PRIMARY KEY REFERENCES staff(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    enabled_at TIMESTAMP WITH TIME ZONE,
+    last_used_counter BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One-time recovery codes, stored hashed
+CREATE TABLE IF NOT EXISTS staff_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    staff_id INTEGER NOT NULL REFERENCES staff(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(staff_id, code_hash)
+);
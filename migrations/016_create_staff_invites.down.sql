@@ -0,0 +1,2 @@
+-- Down migration: drop staff invites
+DROP TABLE IF EXISTS staff_invites;
@@ -0,0 +1,16 @@
+-- Up migration: create staff invites, redeemed by new staff to set their own password
+CREATE TABLE IF NOT EXISTS staff_invites (
+    id SERIAL PRIMARY KEY,
+    hospital_id INTEGER NOT NULL REFERENCES hospitals(id) ON DELETE CASCADE,
+    username VARCHAR(50) NOT NULL,
+    role_id INTEGER NOT NULL REFERENCES roles(id),
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    created_by INTEGER REFERENCES staff(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(token_hash)
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_staff_invites_hospital_username ON staff_invites(hospital_id, username);
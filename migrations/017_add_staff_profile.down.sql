@@ -0,0 +1,8 @@
+-- Down migration: remove staff profile fields and deactivation
+DROP INDEX IF EXISTS idx_staff_hospital_department;
+
+ALTER TABLE staff
+    DROP COLUMN IF EXISTS deactivated_at,
+    DROP COLUMN IF EXISTS is_active,
+    DROP COLUMN IF EXISTS department,
+    DROP COLUMN IF EXISTS full_name;
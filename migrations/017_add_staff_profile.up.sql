@@ -0,0 +1,9 @@
+-- Up migration: add staff profile fields and deactivation
+ALTER TABLE staff
+    ADD COLUMN IF NOT EXISTS full_name VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS department VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP WITH TIME ZONE;
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_staff_hospital_department ON staff(hospital_id, department);
@@ -0,0 +1,3 @@
+-- Down migration: drop the OpenID Connect single sign-on tables
+DROP TABLE IF EXISTS staff_identities;
+DROP TABLE IF EXISTS oidc_login_states;
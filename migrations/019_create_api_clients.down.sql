@@ -0,0 +1,4 @@
+-- Down migration: drop API clients
+DELETE FROM permissions WHERE name = 'api_client:manage';
+ALTER TABLE audit_events DROP COLUMN IF EXISTS api_client_id;
+DROP TABLE IF EXISTS api_clients;
@@ -0,0 +1,34 @@
+-- Up migration: create API clients, machine-to-machine callers authenticating with a key
+CREATE TABLE IF NOT EXISTS api_clients (
+    id SERIAL PRIMARY KEY,
+    hospital_id INTEGER NOT NULL REFERENCES hospitals(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_by INTEGER REFERENCES staff(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(key_hash)
+);
+
+-- Audit events record the API client that acted, as they do the staff member
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS api_client_id INTEGER REFERENCES api_clients(id);
+
+-- Only admins manage API clients
+INSERT INTO permissions (name, description) VALUES
+    ('api_client:manage', 'Issue and revoke API keys for integration clients')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'api_client:manage'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_api_clients_hospital_id ON api_clients(hospital_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_api_client_id ON audit_events(api_client_id);
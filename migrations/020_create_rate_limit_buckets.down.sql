@@ -0,0 +1,2 @@
+-- Down migration: drop rate limit buckets
+DROP TABLE IF EXISTS rate_limit_buckets;
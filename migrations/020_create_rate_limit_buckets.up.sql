@@ -0,0 +1,10 @@
+-- Up migration: create rate limit buckets, shared by every instance when RATE_LIMIT_BACKEND=postgres
+-- Losing buckets in a crash only resets limits, so the table skips the write-ahead log
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+    key VARCHAR(200) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
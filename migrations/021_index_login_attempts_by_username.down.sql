@@ -0,0 +1,2 @@
+-- Down migration: drop the login attempts username index
+DROP INDEX IF EXISTS idx_login_attempts_username_attempted_at;
@@ -0,0 +1,3 @@
+-- Up migration: index login attempts by username, so failed logins for unknown usernames
+-- can be counted and slowed down like those for real accounts
+CREATE INDEX IF NOT EXISTS idx_login_attempts_username_attempted_at ON login_attempts(username, attempted_at);
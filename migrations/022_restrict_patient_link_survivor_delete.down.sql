@@ -0,0 +1,5 @@
+-- Down migration: delete merge links along with their survivor again
+ALTER TABLE patient_links
+    DROP CONSTRAINT IF EXISTS patient_links_survivor_id_fkey,
+    ADD CONSTRAINT patient_links_survivor_id_fkey
+        FOREIGN KEY (survivor_id) REFERENCES patients(id) ON DELETE CASCADE;
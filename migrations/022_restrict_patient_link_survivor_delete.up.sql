@@ -0,0 +1,6 @@
+-- Up migration: refuse to delete a patient other patients were merged into, instead of
+-- deleting their links along with it and losing the merge history
+ALTER TABLE patient_links
+    DROP CONSTRAINT IF EXISTS patient_links_survivor_id_fkey,
+    ADD CONSTRAINT patient_links_survivor_id_fkey
+        FOREIGN KEY (survivor_id) REFERENCES patients(id) ON DELETE RESTRICT;
@@ -0,0 +1,5 @@
+-- Down migration: clear the staff of their audit events on delete again
+ALTER TABLE audit_events
+    DROP CONSTRAINT IF EXISTS audit_events_staff_id_fkey,
+    ADD CONSTRAINT audit_events_staff_id_fkey
+        FOREIGN KEY (staff_id) REFERENCES staff(id) ON DELETE SET NULL;
@@ -0,0 +1,7 @@
+-- Up migration: refuse to delete a staff member with audit events. Setting their
+-- staff_id to NULL is an update of audit_events, which the append-only trigger rejects,
+-- so audited staff can only be deactivated.
+ALTER TABLE audit_events
+    DROP CONSTRAINT IF EXISTS audit_events_staff_id_fkey,
+    ADD CONSTRAINT audit_events_staff_id_fkey
+        FOREIGN KEY (staff_id) REFERENCES staff(id) ON DELETE RESTRICT;
@@ -14,7 +14,11 @@ var (
 	ErrForbidden         = errors.New("forbidden")
 	ErrInternalServer    = errors.New("internal server error")
 	ErrDuplicateResource = errors.New("resource already exists")
+	ErrConflict          = errors.New("resource was modified concurrently")
 	ErrExternalAPI       = errors.New("external API error")
+	ErrUnavailable       = errors.New("service unavailable")
+	ErrLocked            = errors.New("resource is locked")
+	ErrTooManyRequests   = errors.New("too many requests")
 )
 
 // AppError represents an application error with HTTP status code
@@ -97,6 +101,15 @@ func NewDuplicateResourceError(message string) *AppError {
 	}
 }
 
+// NewConflictError creates a new conflict error for a write based on a stale version of a resource
+func NewConflictError(message string) *AppError {
+	return &AppError{
+		Err:        ErrConflict,
+		StatusCode: http.StatusConflict,
+		Message:    message,
+	}
+}
+
 // NewExternalAPIError creates a new external API error
 func NewExternalAPIError(err error) *AppError {
 	return &AppError{
@@ -105,3 +118,30 @@ func NewExternalAPIError(err error) *AppError {
 		Message:    fmt.Sprintf("external API error: %v", err),
 	}
 }
+
+// NewUnavailableError creates a new error for a dependency that is known to be down
+func NewUnavailableError(message string) *AppError {
+	return &AppError{
+		Err:        ErrUnavailable,
+		StatusCode: http.StatusServiceUnavailable,
+		Message:    message,
+	}
+}
+
+// NewLockedError creates a new error for a resource that is temporarily locked
+func NewLockedError(message string) *AppError {
+	return &AppError{
+		Err:        ErrLocked,
+		StatusCode: http.StatusLocked,
+		Message:    message,
+	}
+}
+
+// NewTooManyRequestsError creates a new error for a caller that has exceeded a limit
+func NewTooManyRequestsError(message string) *AppError {
+	return &AppError{
+		Err:        ErrTooManyRequests,
+		StatusCode: http.StatusTooManyRequests,
+		Message:    message,
+	}
+}
@@ -0,0 +1,173 @@
+package encryption_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DingDong039/hms/internal/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newKeyFile creates a local key file with one key in a temporary directory
+func newKeyFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "keys", "encryption.json")
+	_, err := encryption.AddLocalKey(path)
+	require.NoError(t, err)
+	return path
+}
+
+// newEncryptor creates a FieldEncryptor from a local key file
+func newEncryptor(t *testing.T, path string) (*encryption.FieldEncryptor, *encryption.LocalKeyProvider) {
+	provider, err := encryption.LoadLocalKeyProvider(path)
+	require.NoError(t, err)
+	return encryption.NewFieldEncryptor(provider), provider
+}
+
+func TestFieldEncryptor_EncryptDecrypt(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	encryptor, _ := newEncryptor(t, newKeyFile(t))
+
+	// Execute
+	encrypted, err := encryptor.Encrypt(ctx, "national_id", "1234567890121")
+	require.NoError(t, err)
+	decrypted, err := encryptor.Decrypt(ctx, "national_id", encrypted)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "1234567890121", decrypted)
+	assert.True(t, strings.HasPrefix(encrypted, "enc:v1:"))
+	assert.NotContains(t, encrypted, "1234567890121")
+}
+
+func TestFieldEncryptor_EncryptIsRandomized(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	encryptor, _ := newEncryptor(t, newKeyFile(t))
+
+	// Execute
+	first, err := encryptor.Encrypt(ctx, "email", "somchai@example.com")
+	require.NoError(t, err)
+	second, err := encryptor.Encrypt(ctx, "email", "somchai@example.com")
+	require.NoError(t, err)
+
+	// Assert
+	assert.NotEqual(t, first, second)
+}
+
+func TestFieldEncryptor_EmptyAndPlaintextValues(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	encryptor, _ := newEncryptor(t, newKeyFile(t))
+
+	// Execute
+	encrypted, encryptErr := encryptor.Encrypt(ctx, "passport_id", "")
+	legacy, decryptErr := encryptor.Decrypt(ctx, "phone_number", "0812345678")
+
+	// Assert
+	assert.NoError(t, encryptErr)
+	assert.Empty(t, encrypted)
+	assert.NoError(t, decryptErr)
+	assert.Equal(t, "0812345678", legacy)
+	assert.True(t, encryptor.NeedsReencryption("0812345678"))
+	assert.False(t, encryptor.NeedsReencryption(""))
+}
+
+func TestFieldEncryptor_DecryptRejectsOtherField(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	encryptor, _ := newEncryptor(t, newKeyFile(t))
+	encrypted, err := encryptor.Encrypt(ctx, "national_id", "1234567890121")
+	require.NoError(t, err)
+
+	// Execute
+	_, err = encryptor.Decrypt(ctx, "passport_id", encrypted)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestFieldEncryptor_DecryptRejectsOtherKeys(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	encryptor, _ := newEncryptor(t, newKeyFile(t))
+	other, _ := newEncryptor(t, newKeyFile(t))
+	encrypted, err := encryptor.Encrypt(ctx, "email", "somchai@example.com")
+	require.NoError(t, err)
+
+	// Execute
+	_, err = other.Decrypt(ctx, "email", encrypted)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestFieldEncryptor_KeyRotation(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	path := newKeyFile(t)
+	oldEncryptor, oldProvider := newEncryptor(t, path)
+	encrypted, err := oldEncryptor.Encrypt(ctx, "national_id", "1234567890121")
+	require.NoError(t, err)
+
+	// Execute
+	newKeyID, err := encryption.AddLocalKey(path)
+	require.NoError(t, err)
+	encryptor, provider := newEncryptor(t, path)
+	decrypted, decryptErr := encryptor.Decrypt(ctx, "national_id", encrypted)
+	reencrypted, encryptErr := encryptor.Encrypt(ctx, "national_id", decrypted)
+
+	// Assert
+	assert.NotEqual(t, oldProvider.CurrentKeyID(), newKeyID)
+	assert.Equal(t, newKeyID, provider.CurrentKeyID())
+	assert.NoError(t, decryptErr)
+	assert.Equal(t, "1234567890121", decrypted)
+	assert.True(t, encryptor.NeedsReencryption(encrypted))
+	assert.NoError(t, encryptErr)
+	assert.False(t, encryptor.NeedsReencryption(reencrypted))
+	assert.Equal(t, oldProvider.BlindIndexKey(), provider.BlindIndexKey())
+}
+
+func TestFieldEncryptor_BlindIndex(t *testing.T) {
+	// Setup
+	encryptor, _ := newEncryptor(t, newKeyFile(t))
+
+	// Execute
+	index := encryptor.BlindIndex("national_id", "1234567890121")
+
+	// Assert
+	assert.Len(t, index, 64)
+	assert.Equal(t, index, encryptor.BlindIndex("national_id", "1234567890121"))
+	assert.NotEqual(t, index, encryptor.BlindIndex("passport_id", "1234567890121"))
+	assert.NotEqual(t, index, encryptor.BlindIndex("national_id", "1234567890122"))
+	assert.Empty(t, encryptor.BlindIndex("national_id", ""))
+}
+
+func TestAddLocalKey_CreatesPrivateKeyFile(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "encryption.json")
+
+	// Execute
+	keyID, err := encryption.AddLocalKey(path)
+
+	// Assert
+	require.NoError(t, err)
+	info, statErr := os.Stat(path)
+	require.NoError(t, statErr)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	provider, loadErr := encryption.LoadLocalKeyProvider(path)
+	assert.NoError(t, loadErr)
+	assert.Equal(t, keyID, provider.CurrentKeyID())
+}
+
+func TestLoadLocalKeyProvider_MissingFile(t *testing.T) {
+	// Execute
+	_, err := encryption.LoadLocalKeyProvider(filepath.Join(t.TempDir(), "missing.json"))
+
+	// Assert
+	assert.Error(t, err)
+}
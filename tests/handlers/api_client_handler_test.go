@@ -0,0 +1,262 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/handlers"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAPIClientService is a mock implementation of the APIClientService interface
+type MockAPIClientService struct {
+	mock.Mock
+}
+
+func (m *MockAPIClientService) CreateClient(ctx context.Context, req models.APIClientCreateRequest) (*models.APIClientCreateResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIClientCreateResponse), args.Error(1)
+}
+
+func (m *MockAPIClientService) ListClients(ctx context.Context) ([]*models.APIClient, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.APIClient), args.Error(1)
+}
+
+func (m *MockAPIClientService) GetClient(ctx context.Context, id int) (*models.APIClient, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIClient), args.Error(1)
+}
+
+func (m *MockAPIClientService) RevokeClient(ctx context.Context, id int) (*models.APIClient, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIClient), args.Error(1)
+}
+
+func setupAPIClientRouter(permissions ...string) (*gin.Engine, *MockAPIClientService, *MockAuthService) {
+	gin.SetMode(gin.TestMode)
+	mockAPIClientService := new(MockAPIClientService)
+	mockAuthService := new(MockAuthService)
+	apiClientHandler := handlers.NewAPIClientHandler(mockAPIClientService, mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	apiClientHandler.RegisterRoutes(v1)
+
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, HospitalID: 1, Role: models.RoleAdmin, Permissions: permissions}, nil)
+
+	return router, mockAPIClientService, mockAuthService
+}
+
+func TestCreateAPIClient_Success(t *testing.T) {
+	// Setup
+	router, mockAPIClientService, _ := setupAPIClientRouter(models.PermissionAPIClientManage)
+	reqBody := models.APIClientCreateRequest{Name: "Lab analyzer", Scopes: []string{models.PermissionPatientRead}}
+	created := &models.APIClientCreateResponse{
+		Key:    "hms_secret",
+		Client: &models.APIClient{ID: 3, Name: "Lab analyzer", KeyPrefix: "hms_secr", Scopes: reqBody.Scopes, ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	mockAPIClientService.On("CreateClient", mock.Anything, reqBody).Return(created, nil)
+
+	// Execute
+	jsonValue, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("POST", "/api/v1/api-clients", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"key":"hms_secret"`)
+	assert.NotContains(t, w.Body.String(), "key_hash")
+	mockAPIClientService.AssertExpectations(t)
+}
+
+func TestCreateAPIClient_RejectsStaffOnlyScopes(t *testing.T) {
+	// Setup
+	router, mockAPIClientService, _ := setupAPIClientRouter(models.PermissionAPIClientManage)
+	reqBody := models.APIClientCreateRequest{Name: "Kiosk", Scopes: []string{models.PermissionStaffManage}}
+	mockAPIClientService.On("CreateClient", mock.Anything, reqBody).Return(nil, apperrors.NewInvalidInputError(`scope "staff:manage" can't be granted to API clients`))
+
+	// Execute
+	jsonValue, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("POST", "/api/v1/api-clients", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "can't be granted to API clients")
+	mockAPIClientService.AssertExpectations(t)
+}
+
+func TestCreateAPIClient_Forbidden(t *testing.T) {
+	// Setup
+	router, mockAPIClientService, _ := setupAPIClientRouter(models.PermissionStaffManage)
+
+	// Execute
+	jsonValue, _ := json.Marshal(models.APIClientCreateRequest{Name: "Kiosk", Scopes: []string{models.PermissionPatientRead}})
+	req, _ := http.NewRequest("POST", "/api/v1/api-clients", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockAPIClientService.AssertNotCalled(t, "CreateClient", mock.Anything, mock.Anything)
+}
+
+func TestRevokeAPIClient_AlreadyRevoked(t *testing.T) {
+	// Setup
+	router, mockAPIClientService, _ := setupAPIClientRouter(models.PermissionAPIClientManage)
+	mockAPIClientService.On("RevokeClient", mock.Anything, 3).Return(nil, apperrors.NewConflictError("API client is already revoked"))
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/api-clients/3/revoke", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockAPIClientService.AssertExpectations(t)
+}
+
+func TestAPIClients_RefuseAPIKeys(t *testing.T) {
+	// Setup: an API key can't be used to manage API keys, whatever its scopes
+	router, mockAPIClientService, mockAuthService := setupAPIClientRouter()
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/api-clients", nil)
+	req.Header.Set("X-API-Key", "hms_key")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockAuthService.AssertNotCalled(t, "AuthenticateAPIKey", mock.Anything, mock.Anything)
+	mockAPIClientService.AssertNotCalled(t, "ListClients", mock.Anything)
+}
+
+// setupPatientRouterForAPIKey returns a patient router where "hms_key" authenticates
+// API client 5 with the given scopes
+func setupPatientRouterForAPIKey(scopes ...string) (*gin.Engine, *MockPatientService, *MockAuthServiceForPatient) {
+	gin.SetMode(gin.TestMode)
+	mockPatientService := new(MockPatientService)
+	mockAuthService := new(MockAuthServiceForPatient)
+	patientHandler := handlers.NewPatientHandler(mockPatientService, mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	patientHandler.RegisterRoutes(v1)
+
+	mockAuthService.On("AuthenticateAPIKey", mock.Anything, "hms_key").Return(&utils.JWTClaims{HospitalID: 1, Permissions: scopes, APIClientID: 5}, nil)
+	mockAuthService.On("AuthenticateAPIKey", mock.Anything, mock.Anything).Return(nil, apperrors.NewUnauthorizedError("invalid or expired API key"))
+
+	return router, mockPatientService, mockAuthService
+}
+
+func TestAPIKey_ActsAsClientWithinItsScopes(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouterForAPIKey(models.PermissionPatientRead)
+	reqBody := models.PatientSearchRequest{ID: "1234567890123"}
+	mockPatientService.On("SearchPatient", mock.MatchedBy(func(ctx context.Context) bool {
+		clientID, isClient := utils.APIClientIDFromContext(ctx)
+		_, isStaff := utils.StaffIDFromContext(ctx)
+		hospitalID, _ := utils.HospitalIDFromContext(ctx)
+		return isClient && clientID == 5 && !isStaff && hospitalID == 1
+	}), reqBody).Return(&models.PatientSearchResponse{PatientHN: "HN12345"}, nil)
+
+	// Execute
+	jsonValue, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("POST", "/api/v1/patients/search", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "hms_key")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestAPIKey_OutsideItsScopes(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouterForAPIKey(models.PermissionAuditRead)
+
+	// Execute
+	jsonValue, _ := json.Marshal(models.PatientSearchRequest{ID: "1234567890123"})
+	req, _ := http.NewRequest("POST", "/api/v1/patients/search", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "hms_key")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockPatientService.AssertNotCalled(t, "SearchPatient", mock.Anything, mock.Anything)
+}
+
+func TestAPIKey_Invalid(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouterForAPIKey(models.PermissionPatientRead)
+
+	// Execute
+	jsonValue, _ := json.Marshal(models.PatientSearchRequest{ID: "1234567890123"})
+	req, _ := http.NewRequest("POST", "/api/v1/patients/search", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "hms_revoked")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockPatientService.AssertNotCalled(t, "SearchPatient", mock.Anything, mock.Anything)
+}
+
+func TestAPIKey_RefusedForStaffAccountRoutes(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuthService := new(MockAuthService)
+	authHandler := handlers.NewAuthHandler(mockAuthService)
+	router := gin.Default()
+	authHandler.RegisterRoutes(router.Group("/api/v1"))
+
+	// Execute
+	jsonValue, _ := json.Marshal(models.MFACodeRequest{Code: "123456"})
+	req, _ := http.NewRequest("POST", "/api/v1/auth/mfa/disable", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "hms_key")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockAuthService.AssertNotCalled(t, "DisableMFA", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,127 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DingDong039/hms/internal/handlers"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAuditService is a mock implementation of the AuditService interface
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) Record(ctx context.Context, event *models.AuditEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockAuditService) Query(ctx context.Context, req models.AuditQueryRequest) ([]*models.AuditEvent, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.AuditEvent), args.Error(1)
+}
+
+func TestQueryAuditEvents_Success(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuditService := new(MockAuditService)
+	mockAuthService := new(MockAuthService)
+	auditHandler := handlers.NewAuditHandler(mockAuditService, mockAuthService)
+
+	// Create a test router
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	auditHandler.RegisterRoutes(v1)
+
+	// Stub token validation for an auditor
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, Role: models.RoleAuditor, Permissions: []string{models.PermissionAuditRead}}, nil)
+
+	// Mock service response
+	staffID, patientID := 2, 10
+	mockEvents := []*models.AuditEvent{
+		{ID: 1, StaffID: &staffID, PatientID: &patientID, Action: models.AuditActionPatientRead, Source: models.AuditSourceLocal},
+	}
+	mockAuditService.On("Query", mock.Anything, models.AuditQueryRequest{PatientID: 10, Limit: 20}).Return(mockEvents, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/audit?patient_id=10&limit=20", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.APIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Success)
+
+	// Verify mock
+	mockAuditService.AssertExpectations(t)
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestQueryAuditEvents_Forbidden(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuditService := new(MockAuditService)
+	mockAuthService := new(MockAuthService)
+	auditHandler := handlers.NewAuditHandler(mockAuditService, mockAuthService)
+
+	// Create a test router
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	auditHandler.RegisterRoutes(v1)
+
+	// Stub token validation for a nurse without audit:read
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, Role: models.RoleNurse, Permissions: []string{models.PermissionPatientRead}}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/audit", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockAuditService.AssertNotCalled(t, "Query", mock.Anything, mock.Anything)
+}
+
+func TestQueryAuditEvents_ValidationError(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuditService := new(MockAuditService)
+	mockAuthService := new(MockAuthService)
+	auditHandler := handlers.NewAuditHandler(mockAuditService, mockAuthService)
+
+	// Create a test router
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	auditHandler.RegisterRoutes(v1)
+
+	// Stub token validation for an auditor
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, Role: models.RoleAuditor, Permissions: []string{models.PermissionAuditRead}}, nil)
+
+	// Create request with a limit above the maximum
+	req, _ := http.NewRequest("GET", "/api/v1/audit?limit=10000", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockAuditService.AssertNotCalled(t, "Query", mock.Anything, mock.Anything)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/DingDong039/hms/internal/models"
 	"github.com/DingDong039/hms/internal/services"
 	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -22,15 +23,41 @@ type MockAuthService struct {
 	mock.Mock
 }
 
-func (m *MockAuthService) CreateStaff(ctx context.Context, req models.StaffCreateRequest) (*models.Staff, error) {
+func (m *MockAuthService) Login(ctx context.Context, req models.StaffLoginRequest) (*models.StaffLoginResponse, error) {
 	args := m.Called(ctx, req)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*models.Staff), args.Error(1)
+	return args.Get(0).(*models.StaffLoginResponse), args.Error(1)
 }
 
-func (m *MockAuthService) Login(ctx context.Context, req models.StaffLoginRequest) (*models.StaffLoginResponse, error) {
+func (m *MockAuthService) Refresh(ctx context.Context, req models.RefreshTokenRequest) (*models.StaffLoginResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StaffLoginResponse), args.Error(1)
+}
+
+func (m *MockAuthService) Logout(ctx context.Context, claims *utils.JWTClaims, req models.LogoutRequest) error {
+	args := m.Called(ctx, claims, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ChangePassword(ctx context.Context, claims *utils.JWTClaims, req models.PasswordChangeRequest) (*models.StaffLoginResponse, error) {
+	args := m.Called(ctx, claims, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StaffLoginResponse), args.Error(1)
+}
+
+func (m *MockAuthService) ResetPassword(ctx context.Context, req models.PasswordResetRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) VerifyMFA(ctx context.Context, req models.MFAVerifyRequest) (*models.StaffLoginResponse, error) {
 	args := m.Called(ctx, req)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -38,15 +65,88 @@ func (m *MockAuthService) Login(ctx context.Context, req models.StaffLoginReques
 	return args.Get(0).(*models.StaffLoginResponse), args.Error(1)
 }
 
-func (m *MockAuthService) ValidateToken(token string) (*utils.JWTClaims, error) {
-	args := m.Called(token)
+func (m *MockAuthService) EnrollMFA(ctx context.Context, claims *utils.JWTClaims) (*models.MFAEnrollResponse, error) {
+	args := m.Called(ctx, claims)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.MFAEnrollResponse), args.Error(1)
+}
+
+func (m *MockAuthService) ConfirmMFA(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) (*models.MFAConfirmResponse, error) {
+	args := m.Called(ctx, claims, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.MFAConfirmResponse), args.Error(1)
+}
+
+func (m *MockAuthService) RegenerateRecoveryCodes(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) (*models.MFARecoveryCodesResponse, error) {
+	args := m.Called(ctx, claims, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.MFARecoveryCodesResponse), args.Error(1)
+}
+
+func (m *MockAuthService) DisableMFA(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) error {
+	args := m.Called(ctx, claims, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) StartOIDCLogin(ctx context.Context, req models.OIDCLoginRequest) (string, error) {
+	args := m.Called(ctx, req)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockAuthService) CompleteOIDCLogin(ctx context.Context, req models.OIDCCallbackRequest) (*models.StaffLoginResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StaffLoginResponse), args.Error(1)
+}
+
+func (m *MockAuthService) ValidateToken(ctx context.Context, token string) (*utils.JWTClaims, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*utils.JWTClaims), args.Error(1)
+}
+
+func (m *MockAuthService) AuthenticateAPIKey(ctx context.Context, key string) (*utils.JWTClaims, error) {
+	args := m.Called(ctx, key)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*utils.JWTClaims), args.Error(1)
 }
 
-func TestCreateStaff_Success(t *testing.T) {
+func TestCreateStaff_NotPublic(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuthService := new(MockAuthService)
+	authHandler := handlers.NewAuthHandler(mockAuthService)
+
+	// Create a test router
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	authHandler.RegisterRoutes(v1)
+
+	jsonValue, _ := json.Marshal(models.StaffCreateRequest{Username: "testuser", Password: "Tangerine-Kite-42"})
+
+	// Execute: staff can no longer register themselves
+	req, _ := http.NewRequest("POST", "/api/v1/auth/staff/create", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestLogin_Success(t *testing.T) {
 	// Setup
 	gin.SetMode(gin.TestMode)
 	mockAuthService := new(MockAuthService)
@@ -58,27 +158,27 @@ func TestCreateStaff_Success(t *testing.T) {
 	authHandler.RegisterRoutes(v1)
 
 	// Mock request data
-	reqBody := models.StaffCreateRequest{
+	reqBody := models.StaffLoginRequest{
 		Username: "testuser",
 		Password: "password123",
 	}
 	jsonValue, _ := json.Marshal(reqBody)
 
 	// Mock service response
-	mockStaff := &models.Staff{
-		ID:       1,
-		Username: "testuser",
+	mockResponse := &models.StaffLoginResponse{
+		Token:     "jwt-token",
+		ExpiresAt: 1628432000,
 	}
-	mockAuthService.On("CreateStaff", mock.Anything, reqBody).Return(mockStaff, nil)
+	mockAuthService.On("Login", mock.Anything, reqBody).Return(mockResponse, nil)
 
 	// Create request
-	req, _ := http.NewRequest("POST", "/api/v1/auth/staff/create", bytes.NewBuffer(jsonValue))
+	req, _ := http.NewRequest("POST", "/api/v1/auth/staff/login", bytes.NewBuffer(jsonValue))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
 	// Assert
-	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, http.StatusOK, w.Code)
 
 	var response models.APIResponse
 	err := json.Unmarshal(w.Body.Bytes(), &response)
@@ -89,7 +189,7 @@ func TestCreateStaff_Success(t *testing.T) {
 	mockAuthService.AssertExpectations(t)
 }
 
-func TestCreateStaff_ValidationError(t *testing.T) {
+func TestLogin_InvalidCredentials(t *testing.T) {
 	// Setup
 	gin.SetMode(gin.TestMode)
 	mockAuthService := new(MockAuthService)
@@ -100,14 +200,18 @@ func TestCreateStaff_ValidationError(t *testing.T) {
 	v1 := router.Group("/api/v1")
 	authHandler.RegisterRoutes(v1)
 
-	// Invalid request (missing required fields)
-	reqBody := models.StaffCreateRequest{
+	// Mock request data
+	reqBody := models.StaffLoginRequest{
 		Username: "testuser",
+		Password: "wrongpassword",
 	}
 	jsonValue, _ := json.Marshal(reqBody)
 
+	// Mock service error
+	mockAuthService.On("Login", mock.Anything, reqBody).Return(nil, services.NewValidationError("invalid credentials"))
+
 	// Create request
-	req, _ := http.NewRequest("POST", "/api/v1/auth/staff/create", bytes.NewBuffer(jsonValue))
+	req, _ := http.NewRequest("POST", "/api/v1/auth/staff/login", bytes.NewBuffer(jsonValue))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -119,9 +223,51 @@ func TestCreateStaff_ValidationError(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.False(t, response.Success)
+
+	// Verify mock
+	mockAuthService.AssertExpectations(t)
 }
 
-func TestLogin_Success(t *testing.T) {
+func TestLogin_LockoutErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"locked account", apperrors.NewLockedError("account is temporarily locked after too many failed logins"), http.StatusUnauthorized},
+		{"too many failures from IP", apperrors.NewTooManyRequestsError("too many failed login attempts, try again later"), http.StatusTooManyRequests},
+		{"wrong password", apperrors.NewUnauthorizedError("invalid credentials"), http.StatusUnauthorized},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			gin.SetMode(gin.TestMode)
+			mockAuthService := new(MockAuthService)
+			authHandler := handlers.NewAuthHandler(mockAuthService)
+
+			router := gin.Default()
+			v1 := router.Group("/api/v1")
+			authHandler.RegisterRoutes(v1)
+
+			reqBody := models.StaffLoginRequest{Username: "testuser", Password: "password123"}
+			jsonValue, _ := json.Marshal(reqBody)
+			mockAuthService.On("Login", mock.Anything, reqBody).Return(nil, tc.err)
+
+			// Execute
+			req, _ := http.NewRequest("POST", "/api/v1/auth/staff/login", bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tc.status, w.Code)
+			mockAuthService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRefresh_Success(t *testing.T) {
 	// Setup
 	gin.SetMode(gin.TestMode)
 	mockAuthService := new(MockAuthService)
@@ -133,21 +279,22 @@ func TestLogin_Success(t *testing.T) {
 	authHandler.RegisterRoutes(v1)
 
 	// Mock request data
-	reqBody := models.StaffLoginRequest{
-		Username: "testuser",
-		Password: "password123",
+	reqBody := models.RefreshTokenRequest{
+		RefreshToken: "refresh-token",
 	}
 	jsonValue, _ := json.Marshal(reqBody)
 
 	// Mock service response
 	mockResponse := &models.StaffLoginResponse{
-		Token:     "jwt-token",
-		ExpiresAt: 1628432000,
+		Token:            "new-jwt-token",
+		ExpiresAt:        1628432000,
+		RefreshToken:     "new-refresh-token",
+		RefreshExpiresAt: 1629036800,
 	}
-	mockAuthService.On("Login", mock.Anything, reqBody).Return(mockResponse, nil)
+	mockAuthService.On("Refresh", mock.Anything, reqBody).Return(mockResponse, nil)
 
 	// Create request
-	req, _ := http.NewRequest("POST", "/api/v1/auth/staff/login", bytes.NewBuffer(jsonValue))
+	req, _ := http.NewRequest("POST", "/api/v1/auth/refresh", bytes.NewBuffer(jsonValue))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -164,7 +311,7 @@ func TestLogin_Success(t *testing.T) {
 	mockAuthService.AssertExpectations(t)
 }
 
-func TestLogin_InvalidCredentials(t *testing.T) {
+func TestRefresh_InvalidToken(t *testing.T) {
 	// Setup
 	gin.SetMode(gin.TestMode)
 	mockAuthService := new(MockAuthService)
@@ -176,23 +323,22 @@ func TestLogin_InvalidCredentials(t *testing.T) {
 	authHandler.RegisterRoutes(v1)
 
 	// Mock request data
-	reqBody := models.StaffLoginRequest{
-		Username: "testuser",
-		Password: "wrongpassword",
+	reqBody := models.RefreshTokenRequest{
+		RefreshToken: "reused-refresh-token",
 	}
 	jsonValue, _ := json.Marshal(reqBody)
 
 	// Mock service error
-	mockAuthService.On("Login", mock.Anything, reqBody).Return(nil, services.NewValidationError("invalid credentials"))
+	mockAuthService.On("Refresh", mock.Anything, reqBody).Return(nil, apperrors.NewUnauthorizedError("invalid refresh token"))
 
 	// Create request
-	req, _ := http.NewRequest("POST", "/api/v1/auth/staff/login", bytes.NewBuffer(jsonValue))
+	req, _ := http.NewRequest("POST", "/api/v1/auth/refresh", bytes.NewBuffer(jsonValue))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
 	// Assert
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
 
 	var response models.APIResponse
 	err := json.Unmarshal(w.Body.Bytes(), &response)
@@ -202,3 +348,395 @@ func TestLogin_InvalidCredentials(t *testing.T) {
 	// Verify mock
 	mockAuthService.AssertExpectations(t)
 }
+
+func TestLogout_Success(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuthService := new(MockAuthService)
+	authHandler := handlers.NewAuthHandler(mockAuthService)
+
+	// Create a test router
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	authHandler.RegisterRoutes(v1)
+
+	// Stub token validation for auth middleware
+	claims := &utils.JWTClaims{UserID: 1}
+	claims.ID = "access-jti"
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(claims, nil)
+	mockAuthService.On("Logout", mock.Anything, claims, models.LogoutRequest{}).Return(nil)
+
+	// Create request without a body
+	req, _ := http.NewRequest("POST", "/api/v1/auth/logout", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Verify mock
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestLogout_RevokedToken(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuthService := new(MockAuthService)
+	authHandler := handlers.NewAuthHandler(mockAuthService)
+
+	// Create a test router
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	authHandler.RegisterRoutes(v1)
+
+	// Stub token validation rejecting a revoked token
+	mockAuthService.On("ValidateToken", mock.Anything, "revoked-token").Return(nil, apperrors.NewUnauthorizedError("token has been revoked"))
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/api/v1/auth/logout", nil)
+	req.Header.Set("Authorization", "Bearer revoked-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockAuthService.AssertNotCalled(t, "Logout", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestChangePassword_AllowedWhenChangeRequired(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuthService := new(MockAuthService)
+	authHandler := handlers.NewAuthHandler(mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	authHandler.RegisterRoutes(v1)
+
+	claims := &utils.JWTClaims{UserID: 1, HospitalID: 1, PasswordChangeRequired: true}
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(claims, nil)
+
+	reqBody := models.PasswordChangeRequest{CurrentPassword: "initial-password", NewPassword: "Tangerine-Kite-42"}
+	mockAuthService.On("ChangePassword", mock.Anything, claims, reqBody).Return(&models.StaffLoginResponse{Token: "new-token"}, nil)
+	jsonValue, _ := json.Marshal(reqBody)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/auth/staff/password", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestChangePassword_PolicyViolation(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuthService := new(MockAuthService)
+	authHandler := handlers.NewAuthHandler(mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	authHandler.RegisterRoutes(v1)
+
+	claims := &utils.JWTClaims{UserID: 1, HospitalID: 1}
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(claims, nil)
+
+	reqBody := models.PasswordChangeRequest{CurrentPassword: "initial-password", NewPassword: "password1234"}
+	mockAuthService.On("ChangePassword", mock.Anything, claims, reqBody).Return(nil, services.NewValidationError("password is too common"))
+	jsonValue, _ := json.Marshal(reqBody)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/auth/staff/password", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "password is too common")
+}
+
+func TestPasswordChangeRequired_BlocksOtherRoutes(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuditService := new(MockAuditService)
+	mockAuthService := new(MockAuthService)
+	auditHandler := handlers.NewAuditHandler(mockAuditService, mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	auditHandler.RegisterRoutes(v1)
+
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{
+		UserID: 1, Role: models.RoleAuditor, Permissions: []string{models.PermissionAuditRead}, PasswordChangeRequired: true,
+	}, nil)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/audit", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockAuditService.AssertNotCalled(t, "Query", mock.Anything, mock.Anything)
+}
+
+func TestResetPassword_InvalidToken(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuthService := new(MockAuthService)
+	authHandler := handlers.NewAuthHandler(mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	authHandler.RegisterRoutes(v1)
+
+	reqBody := models.PasswordResetRequest{Token: "used-token", NewPassword: "Tangerine-Kite-42"}
+	mockAuthService.On("ResetPassword", mock.Anything, reqBody).Return(apperrors.NewUnauthorizedError("invalid or expired password reset token"))
+	jsonValue, _ := json.Marshal(reqBody)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/auth/staff/password/reset", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestVerifyMFA_Success(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuthService := new(MockAuthService)
+	authHandler := handlers.NewAuthHandler(mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	authHandler.RegisterRoutes(v1)
+
+	reqBody := models.MFAVerifyRequest{MFAToken: "mfa-token", Code: "123456"}
+	mockAuthService.On("VerifyMFA", mock.Anything, reqBody).Return(&models.StaffLoginResponse{Token: "access-token", RefreshToken: "refresh-token"}, nil)
+	jsonValue, _ := json.Marshal(reqBody)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/auth/staff/login/mfa", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "access-token")
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestVerifyMFA_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"invalid code", apperrors.NewUnauthorizedError("invalid code"), http.StatusUnauthorized},
+		{"account locked", apperrors.NewLockedError("account is temporarily locked after too many failed logins"), http.StatusLocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			gin.SetMode(gin.TestMode)
+			mockAuthService := new(MockAuthService)
+			authHandler := handlers.NewAuthHandler(mockAuthService)
+
+			router := gin.Default()
+			v1 := router.Group("/api/v1")
+			authHandler.RegisterRoutes(v1)
+
+			reqBody := models.MFAVerifyRequest{MFAToken: "mfa-token", Code: "123456"}
+			mockAuthService.On("VerifyMFA", mock.Anything, reqBody).Return(nil, tt.err)
+			jsonValue, _ := json.Marshal(reqBody)
+
+			// Execute
+			req, _ := http.NewRequest("POST", "/api/v1/auth/staff/login/mfa", bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestEnrollMFA_AllowedWhenEnrollmentRequired(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuthService := new(MockAuthService)
+	authHandler := handlers.NewAuthHandler(mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	authHandler.RegisterRoutes(v1)
+
+	claims := &utils.JWTClaims{UserID: 1, HospitalID: 1, Role: models.RoleAdmin, MFAEnrollmentRequired: true}
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(claims, nil)
+	mockAuthService.On("EnrollMFA", mock.Anything, claims).Return(&models.MFAEnrollResponse{
+		Secret:          "JBSWY3DPEHPK3PXP",
+		ProvisioningURI: "otpauth://totp/HMS:admin?secret=JBSWY3DPEHPK3PXP",
+	}, nil)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/auth/mfa/enroll", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "otpauth://")
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestMFAEnrollmentRequired_BlocksOtherRoutes(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuditService := new(MockAuditService)
+	mockAuthService := new(MockAuthService)
+	auditHandler := handlers.NewAuditHandler(mockAuditService, mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	auditHandler.RegisterRoutes(v1)
+
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{
+		UserID: 1, Role: models.RoleAdmin, Permissions: []string{models.PermissionAuditRead}, MFAEnrollmentRequired: true,
+	}, nil)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/audit", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "two-factor enrollment required")
+	mockAuditService.AssertNotCalled(t, "Query", mock.Anything, mock.Anything)
+}
+
+func TestEnrollMFA_BlockedWhenPasswordChangeRequired(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuthService := new(MockAuthService)
+	authHandler := handlers.NewAuthHandler(mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	authHandler.RegisterRoutes(v1)
+
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{
+		UserID: 1, Role: models.RoleAdmin, PasswordChangeRequired: true, MFAEnrollmentRequired: true,
+	}, nil)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/auth/mfa/enroll", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert: the password is changed first
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockAuthService.AssertNotCalled(t, "EnrollMFA", mock.Anything, mock.Anything)
+}
+
+func TestStartOIDCLogin_RedirectsToIdentityProvider(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockAuthService := new(MockAuthService)
+	authHandler := handlers.NewAuthHandler(mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	authHandler.RegisterRoutes(v1)
+
+	authURL := "https://idp.hospital-a.example/authorize?client_id=hms&state=abc"
+	mockAuthService.On("StartOIDCLogin", mock.Anything, models.OIDCLoginRequest{HospitalCode: "hospital-a"}).Return(authURL, nil)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/auth/oidc/login?hospital_code=hospital-a", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, authURL, w.Header().Get("Location"))
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestCompleteOIDCLogin(t *testing.T) {
+	testCases := []struct {
+		name         string
+		query        string
+		mockResponse *models.StaffLoginResponse
+		mockError    error
+		expectedCode int
+	}{
+		{
+			name:         "issues tokens",
+			query:        "state=abc&code=xyz",
+			mockResponse: &models.StaffLoginResponse{Token: "jwt-token", RefreshToken: "refresh-token"},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "refused by identity provider",
+			query:        "state=abc&error=access_denied",
+			mockError:    apperrors.NewUnauthorizedError("sign-in was refused by the identity provider"),
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "groups grant no role",
+			query:        "state=abc&code=xyz",
+			mockError:    apperrors.NewForbiddenError("your identity provider groups don't grant access"),
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "missing code",
+			query:        "state=abc",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			gin.SetMode(gin.TestMode)
+			mockAuthService := new(MockAuthService)
+			authHandler := handlers.NewAuthHandler(mockAuthService)
+
+			router := gin.Default()
+			v1 := router.Group("/api/v1")
+			authHandler.RegisterRoutes(v1)
+
+			if tc.mockResponse != nil || tc.mockError != nil {
+				mockAuthService.On("CompleteOIDCLogin", mock.Anything, mock.AnythingOfType("models.OIDCCallbackRequest")).Return(tc.mockResponse, tc.mockError)
+			}
+
+			// Execute
+			req, _ := http.NewRequest("GET", "/api/v1/auth/oidc/callback?"+tc.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tc.expectedCode, w.Code)
+			mockAuthService.AssertExpectations(t)
+		})
+	}
+}
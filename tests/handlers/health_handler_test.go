@@ -0,0 +1,83 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/handlers"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// healthResponse is the body of a health check response
+type healthResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Status       string                     `json:"status"`
+		HospitalAPIs []models.HospitalAPIHealth `json:"hospital_apis"`
+	} `json:"data"`
+}
+
+func TestHealth_ReportsOpenCircuit(t *testing.T) {
+	// Setup: a hospital API that is down
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	registry := services.NewHospitalAPIRegistry()
+	hospitalA := services.NewHTTPHospitalAPIService(config.HospitalEndpointConfig{
+		Name:    "hospital-a",
+		BaseURL: upstream.URL,
+		Timeout: time.Second,
+		Breaker: config.CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Minute},
+	})
+	registry.Register("hospital-a", hospitalA)
+	_, _ = hospitalA.SearchPatient(context.Background(), "1234567890123")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	handlers.NewHealthHandler(registry).RegisterRoutes(router.Group("/api/v1"))
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/health", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response healthResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "degraded", response.Data.Status)
+	assert.Equal(t, []models.HospitalAPIHealth{{Name: "hospital-a", Circuit: models.CircuitOpen, ConsecutiveFailures: 1}}, response.Data.HospitalAPIs)
+}
+
+func TestHealth_OK(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	handlers.NewHealthHandler(services.NewHospitalAPIRegistry()).RegisterRoutes(router.Group("/api/v1"))
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/api/v1/health", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response healthResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", response.Data.Status)
+	assert.Empty(t, response.Data.HospitalAPIs)
+}
@@ -0,0 +1,43 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/DingDong039/hms/internal/handlers"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetJWKS(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "jwt.json")
+	keyID, err := utils.AddJWTKey(path, utils.JWTAlgorithmEdDSA, true)
+	require.NoError(t, err)
+	keys, err := utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handlers.NewJWKSHandler(keys).RegisterRoutes(&router.RouterGroup)
+
+	// Execute: no Authorization header
+	req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert: a bare key set, not wrapped in the API response envelope
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Cache-Control"), "max-age")
+
+	var jwks utils.JWKS
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jwks))
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, keyID, jwks.Keys[0].Kid)
+	assert.Equal(t, "EdDSA", jwks.Keys[0].Alg)
+}
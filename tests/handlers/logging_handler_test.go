@@ -0,0 +1,81 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DingDong039/hms/internal/middleware"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupLoggedRouter returns a router using the server's logging middlewares, logging to output
+func setupLoggedRouter(output *bytes.Buffer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := utils.NewLogger(output, slog.LevelInfo, "json")
+
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Recovery(logger))
+	router.Use(middleware.Logger(logger))
+	router.GET("/patients/search/:id", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(http.StatusNotFound, "Patient not found"))
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("unexpected patient somchai@example.com")
+	})
+	return router
+}
+
+func TestLogger_LogsRequestWithoutPII(t *testing.T) {
+	// Setup
+	var output bytes.Buffer
+	router := setupLoggedRouter(&output)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/patients/search/1234567890121?email=somchai@example.com", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert: one structured record per request
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(output.Bytes(), &record))
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "request", record["msg"])
+	assert.Equal(t, float64(http.StatusNotFound), record["status"])
+	assert.Equal(t, "GET", record["method"])
+	assert.Equal(t, "req-42", record["request_id"])
+	assert.NotContains(t, output.String(), "1234567890121")
+	assert.NotContains(t, output.String(), "somchai@example.com")
+}
+
+func TestRecovery_LogsPanicAndResponds500(t *testing.T) {
+	// Setup
+	var output bytes.Buffer
+	router := setupLoggedRouter(&output)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	req.Header.Set("X-Request-ID", "req-43")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	var response models.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "internal server error", response.Error.Message)
+	assert.Equal(t, "req-43", response.Error.RequestID)
+
+	assert.Contains(t, output.String(), "panic recovered")
+	assert.Contains(t, output.String(), `"request_id":"req-43"`)
+	assert.NotContains(t, output.String(), "somchai@example.com")
+}
@@ -0,0 +1,415 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/handlers"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// setupPatientRouter creates a router with the patient routes and a caller holding the given permissions
+func setupPatientRouter(permissions ...string) (*gin.Engine, *MockPatientService, *MockAuthServiceForPatient) {
+	gin.SetMode(gin.TestMode)
+	mockPatientService := new(MockPatientService)
+	mockAuthService := new(MockAuthServiceForPatient)
+	patientHandler := handlers.NewPatientHandler(mockPatientService, mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	patientHandler.RegisterRoutes(v1)
+
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, HospitalID: 1, Permissions: permissions}, nil)
+
+	return router, mockPatientService, mockAuthService
+}
+
+// newPatientRequest creates an authenticated JSON request
+func newPatientRequest(method, path string, body interface{}) *http.Request {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	return req
+}
+
+func TestQueryPatients_Success(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead)
+
+	// Mock service response
+	expectedQuery := models.PatientQueryRequest{
+		LastName:    "Jaidee",
+		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		Sort:        "last_name_en",
+		Order:       "asc",
+		Limit:       10,
+		Offset:      20,
+	}
+	mockPatients := []*models.Patient{{ID: 10, LastNameEN: "Jaidee"}}
+	mockPatientService.On("QueryPatients", mock.Anything, expectedQuery).Return(mockPatients, 21, nil)
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("GET", "/api/v1/patients?last_name=Jaidee&date_of_birth=1990-01-01&sort=last_name_en&order=asc&limit=10&offset=20", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.APIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Success)
+	assert.Equal(t, &models.Pagination{Total: 21, Limit: 10, Offset: 20}, response.Meta)
+
+	// Verify mock
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestQueryPatients_DefaultLimit(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead)
+
+	// Mock service response
+	expectedQuery := models.PatientQueryRequest{PatientHN: "HN12345", Limit: models.DefaultPatientQueryLimit}
+	mockPatientService.On("QueryPatients", mock.Anything, expectedQuery).Return([]*models.Patient{}, 0, nil)
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("GET", "/api/v1/patients?patient_hn=HN12345", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.APIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DefaultPatientQueryLimit, response.Meta.Limit)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestQueryPatients_InvalidSort(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead)
+
+	// Create request sorting by a column that isn't whitelisted
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("GET", "/api/v1/patients?sort=national_id", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockPatientService.AssertNotCalled(t, "QueryPatients", mock.Anything, mock.Anything)
+}
+
+func TestFuzzySearchPatients_Success(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead)
+
+	// Mock request data with a misspelled name
+	reqBody := models.PatientFuzzySearchRequest{FirstName: "Somchay", LastName: "Jaidee"}
+
+	// Mock service response
+	mockMatches := []*models.PatientMatch{
+		{Patient: &models.Patient{ID: 10, FirstNameEN: "Somchai", LastNameEN: "Jaidee"}, Score: 0.78},
+	}
+	mockPatientService.On("FuzzySearchPatients", mock.Anything, reqBody).Return(mockMatches, nil)
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("POST", "/api/v1/patients/search/fuzzy", reqBody))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Success bool                   `json:"success"`
+		Data    []*models.PatientMatch `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Success)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, 0.78, response.Data[0].Score)
+
+	// Verify mock
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestFuzzySearchPatients_ValidationError(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead)
+
+	// Invalid request (no name and a score above 1)
+	reqBody := map[string]interface{}{"min_score": 1.5}
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("POST", "/api/v1/patients/search/fuzzy", reqBody))
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockPatientService.AssertNotCalled(t, "FuzzySearchPatients", mock.Anything, mock.Anything)
+}
+
+func TestCreatePatient_Success(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientWrite)
+
+	// Mock request data (1101700230708 has a valid check digit)
+	reqBody := models.PatientCreateRequest{
+		NationalID:  "1101700230708",
+		FirstNameEN: "Somchai",
+		LastNameEN:  "Jaidee",
+		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		PatientHN:   "HN12345",
+		Email:       "somchai@example.com",
+		Gender:      "M",
+	}
+
+	// Mock service response
+	mockPatient := &models.Patient{ID: 10, NationalID: reqBody.NationalID, PatientHN: reqBody.PatientHN}
+	mockPatientService.On("CreatePatient", mock.Anything, reqBody).Return(mockPatient, nil)
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("POST", "/api/v1/patients", reqBody))
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.APIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Success)
+
+	// Verify mock
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestCreatePatient_ValidationError(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientWrite)
+
+	// Invalid request (bad national ID check digit, unknown gender, malformed email)
+	reqBody := models.PatientCreateRequest{
+		NationalID:  "1101700230709",
+		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		PatientHN:   "HN12345",
+		Email:       "not-an-email",
+		Gender:      "X",
+	}
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("POST", "/api/v1/patients", reqBody))
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockPatientService.AssertNotCalled(t, "CreatePatient", mock.Anything, mock.Anything)
+}
+
+func TestCreatePatient_Duplicate(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientWrite)
+
+	// Mock request data
+	reqBody := models.PatientCreateRequest{
+		PassportID:  "AB1234567",
+		DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		PatientHN:   "HN12345",
+		Gender:      "F",
+	}
+
+	// Mock service error
+	mockPatientService.On("CreatePatient", mock.Anything, reqBody).Return(nil, apperrors.NewDuplicateResourceError("a patient with this passport ID already exists"))
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("POST", "/api/v1/patients", reqBody))
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestGetPatient_NotFound(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead)
+
+	// Mock service error
+	mockPatientService.On("GetPatient", mock.Anything, 99).Return(nil, apperrors.NewNotFoundError("patient not found"))
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("GET", "/api/v1/patients/99", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestGetPatient_InvalidID(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead)
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("GET", "/api/v1/patients/abc", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockPatientService.AssertNotCalled(t, "GetPatient", mock.Anything, mock.Anything)
+}
+
+func TestUpdatePatient_Conflict(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientWrite)
+
+	// Mock request data carrying a stale version
+	reqBody := models.PatientUpdateRequest{
+		PatientCreateRequest: models.PatientCreateRequest{
+			NationalID:  "1101700230708",
+			DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+			PatientHN:   "HN12345",
+			Gender:      "M",
+		},
+		UpdatedAt: time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	// Mock service error
+	mockPatientService.On("UpdatePatient", mock.Anything, 10, reqBody).Return(nil, apperrors.NewConflictError("patient was modified by another request"))
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("PUT", "/api/v1/patients/10", reqBody))
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestPatchPatient_MissingVersion(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientWrite)
+
+	// Invalid request (no updated_at)
+	reqBody := map[string]string{"phone_number": "0899999999"}
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("PATCH", "/api/v1/patients/10", reqBody))
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockPatientService.AssertNotCalled(t, "PatchPatient", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdatePatient_MaskedNationalIDRoundTrip(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientWrite)
+
+	// Mock request data sending back the record as read, with its national ID masked
+	reqBody := models.PatientUpdateRequest{
+		PatientCreateRequest: models.PatientCreateRequest{
+			NationalID:  "1-1017-xxxxx-70-8",
+			FirstNameEN: "Somchai",
+			DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+			PatientHN:   "HN12345",
+			Gender:      "M",
+		},
+		UpdatedAt: time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	// Mock service response
+	mockPatient := &models.Patient{ID: 10, NationalID: reqBody.NationalID, PatientHN: reqBody.PatientHN}
+	mockPatientService.On("UpdatePatient", mock.Anything, 10, reqBody).Return(mockPatient, nil)
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("PUT", "/api/v1/patients/10", reqBody))
+
+	// Assert: the masked ID passes validation and reaches the service, which restores it
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestPatchPatient_MaskedNationalIDRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name         string
+		nationalID   string
+		expectedCode int
+	}{
+		{name: "Masked Form", nationalID: "1-1017-xxxxx-70-8", expectedCode: http.StatusOK},
+		{name: "Partly Masked", nationalID: "1-1017-xxx02-70-8", expectedCode: http.StatusBadRequest},
+		{name: "Masked Without Dashes", nationalID: "11017xxxxx708", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientWrite)
+
+			// Mock request data
+			nationalID := tc.nationalID
+			reqBody := models.PatientPatchRequest{
+				NationalID: &nationalID,
+				UpdatedAt:  time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC),
+			}
+
+			// Mock service response
+			mockPatient := &models.Patient{ID: 10, NationalID: nationalID, PatientHN: "HN12345"}
+			mockPatientService.On("PatchPatient", mock.Anything, 10, reqBody).Return(mockPatient, nil)
+
+			// Create request
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newPatientRequest("PATCH", "/api/v1/patients/10", reqBody))
+
+			// Assert
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedCode != http.StatusOK {
+				mockPatientService.AssertNotCalled(t, "PatchPatient", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestDeletePatient_Success(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientDelete)
+
+	// Mock service response
+	mockPatientService.On("DeletePatient", mock.Anything, 10).Return(nil)
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("DELETE", "/api/v1/patients/10", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestDeletePatient_Forbidden(t *testing.T) {
+	// Setup: a registrar can write but not delete
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead, models.PermissionPatientWrite)
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("DELETE", "/api/v1/patients/10", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockPatientService.AssertNotCalled(t, "DeletePatient", mock.Anything, mock.Anything)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/DingDong039/hms/internal/handlers"
 	"github.com/DingDong039/hms/internal/models"
 	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -31,20 +32,99 @@ func (m *MockPatientService) SearchPatient(ctx context.Context, req models.Patie
 	return args.Get(0).(*models.PatientSearchResponse), args.Error(1)
 }
 
+func (m *MockPatientService) QueryPatients(ctx context.Context, req models.PatientQueryRequest) ([]*models.Patient, int, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]*models.Patient), args.Int(1), args.Error(2)
+}
+
+func (m *MockPatientService) FuzzySearchPatients(ctx context.Context, req models.PatientFuzzySearchRequest) ([]*models.PatientMatch, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.PatientMatch), args.Error(1)
+}
+
+func (m *MockPatientService) CreatePatient(ctx context.Context, req models.PatientCreateRequest) (*models.Patient, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) GetPatient(ctx context.Context, id int) (*models.Patient, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) UpdatePatient(ctx context.Context, id int, req models.PatientUpdateRequest) (*models.Patient, error) {
+	args := m.Called(ctx, id, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) PatchPatient(ctx context.Context, id int, req models.PatientPatchRequest) (*models.Patient, error) {
+	args := m.Called(ctx, id, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) DeletePatient(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 // MockAuthServiceForPatient is a mock implementation of the AuthService interface used in patient handler tests
 type MockAuthServiceForPatient struct {
 	mock.Mock
 }
 
-func (m *MockAuthServiceForPatient) CreateStaff(ctx context.Context, req models.StaffCreateRequest) (*models.Staff, error) {
+func (m *MockAuthServiceForPatient) Login(ctx context.Context, req models.StaffLoginRequest) (*models.StaffLoginResponse, error) {
 	args := m.Called(ctx, req)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*models.Staff), args.Error(1)
+	return args.Get(0).(*models.StaffLoginResponse), args.Error(1)
 }
 
-func (m *MockAuthServiceForPatient) Login(ctx context.Context, req models.StaffLoginRequest) (*models.StaffLoginResponse, error) {
+func (m *MockAuthServiceForPatient) Refresh(ctx context.Context, req models.RefreshTokenRequest) (*models.StaffLoginResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StaffLoginResponse), args.Error(1)
+}
+
+func (m *MockAuthServiceForPatient) Logout(ctx context.Context, claims *utils.JWTClaims, req models.LogoutRequest) error {
+	args := m.Called(ctx, claims, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthServiceForPatient) ChangePassword(ctx context.Context, claims *utils.JWTClaims, req models.PasswordChangeRequest) (*models.StaffLoginResponse, error) {
+	args := m.Called(ctx, claims, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StaffLoginResponse), args.Error(1)
+}
+
+func (m *MockAuthServiceForPatient) ResetPassword(ctx context.Context, req models.PasswordResetRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthServiceForPatient) VerifyMFA(ctx context.Context, req models.MFAVerifyRequest) (*models.StaffLoginResponse, error) {
 	args := m.Called(ctx, req)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -52,8 +132,58 @@ func (m *MockAuthServiceForPatient) Login(ctx context.Context, req models.StaffL
 	return args.Get(0).(*models.StaffLoginResponse), args.Error(1)
 }
 
-func (m *MockAuthServiceForPatient) ValidateToken(token string) (*utils.JWTClaims, error) {
-	args := m.Called(token)
+func (m *MockAuthServiceForPatient) EnrollMFA(ctx context.Context, claims *utils.JWTClaims) (*models.MFAEnrollResponse, error) {
+	args := m.Called(ctx, claims)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.MFAEnrollResponse), args.Error(1)
+}
+
+func (m *MockAuthServiceForPatient) ConfirmMFA(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) (*models.MFAConfirmResponse, error) {
+	args := m.Called(ctx, claims, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.MFAConfirmResponse), args.Error(1)
+}
+
+func (m *MockAuthServiceForPatient) RegenerateRecoveryCodes(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) (*models.MFARecoveryCodesResponse, error) {
+	args := m.Called(ctx, claims, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.MFARecoveryCodesResponse), args.Error(1)
+}
+
+func (m *MockAuthServiceForPatient) DisableMFA(ctx context.Context, claims *utils.JWTClaims, req models.MFACodeRequest) error {
+	args := m.Called(ctx, claims, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthServiceForPatient) StartOIDCLogin(ctx context.Context, req models.OIDCLoginRequest) (string, error) {
+	args := m.Called(ctx, req)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockAuthServiceForPatient) CompleteOIDCLogin(ctx context.Context, req models.OIDCCallbackRequest) (*models.StaffLoginResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StaffLoginResponse), args.Error(1)
+}
+
+func (m *MockAuthServiceForPatient) ValidateToken(ctx context.Context, token string) (*utils.JWTClaims, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*utils.JWTClaims), args.Error(1)
+}
+
+func (m *MockAuthServiceForPatient) AuthenticateAPIKey(ctx context.Context, key string) (*utils.JWTClaims, error) {
+	args := m.Called(ctx, key)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -74,7 +204,7 @@ func TestSearchPatient_Success(t *testing.T) {
 	patientHandler.RegisterRoutes(v1)
 
 	// Stub token validation for auth middleware
-	mockAuthService.On("ValidateToken", "valid-token").Return(&utils.JWTClaims{UserID: 1}, nil)
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, Permissions: []string{models.PermissionPatientRead}}, nil)
 
 	// Mock request data
 	reqBody := models.PatientSearchRequest{
@@ -131,7 +261,7 @@ func TestSearchPatient_NotFound(t *testing.T) {
 	patientHandler.RegisterRoutes(v1)
 
 	// Stub token validation for auth middleware
-	mockAuthService.On("ValidateToken", "valid-token").Return(&utils.JWTClaims{UserID: 1}, nil)
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, Permissions: []string{models.PermissionPatientRead}}, nil)
 
 	// Mock request data
 	reqBody := models.PatientSearchRequest{
@@ -140,7 +270,7 @@ func TestSearchPatient_NotFound(t *testing.T) {
 	jsonValue, _ := json.Marshal(reqBody)
 
 	// Mock service error
-	mockPatientService.On("SearchPatient", mock.Anything, reqBody).Return(nil, errors.New("patient not found"))
+	mockPatientService.On("SearchPatient", mock.Anything, reqBody).Return(nil, apperrors.NewNotFoundError("patient not found"))
 
 	// Create request
 	req, _ := http.NewRequest("POST", "/api/v1/patients/search", bytes.NewBuffer(jsonValue))
@@ -162,6 +292,59 @@ func TestSearchPatient_NotFound(t *testing.T) {
 	mockAuthService.AssertExpectations(t)
 }
 
+func TestSearchPatient_UpstreamErrors(t *testing.T) {
+	testCases := []struct {
+		name         string
+		serviceErr   error
+		expectedCode int
+	}{
+		{
+			name:         "Hospital Unavailable",
+			serviceErr:   apperrors.NewUnavailableError("hospital-a API is unavailable"),
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "Hospital Failed",
+			serviceErr:   apperrors.NewExternalAPIError(errors.New("hospital-a API returned status 500")),
+			expectedCode: http.StatusBadGateway,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			gin.SetMode(gin.TestMode)
+			mockPatientService := new(MockPatientService)
+			mockAuthService := new(MockAuthServiceForPatient)
+			patientHandler := handlers.NewPatientHandler(mockPatientService, mockAuthService)
+
+			router := gin.Default()
+			patientHandler.RegisterRoutes(router.Group("/api/v1"))
+
+			mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, Permissions: []string{models.PermissionPatientRead}}, nil)
+			reqBody := models.PatientSearchRequest{ID: "1234567890123"}
+			mockPatientService.On("SearchPatient", mock.Anything, reqBody).Return(nil, tc.serviceErr)
+
+			// Execute
+			jsonValue, _ := json.Marshal(reqBody)
+			req, _ := http.NewRequest("POST", "/api/v1/patients/search", bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer valid-token")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert: an upstream outage isn't reported as an unknown patient
+			var response models.APIResponse
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedCode, response.Error.Code)
+			assert.Equal(t, http.StatusText(tc.expectedCode), response.Error.Message)
+			mockPatientService.AssertExpectations(t)
+		})
+	}
+}
+
 func TestSearchPatient_ValidationError(t *testing.T) {
 	// Setup
 	gin.SetMode(gin.TestMode)
@@ -176,7 +359,7 @@ func TestSearchPatient_ValidationError(t *testing.T) {
 	patientHandler.RegisterRoutes(v1)
 
 	// Stub token validation for auth middleware
-	mockAuthService.On("ValidateToken", "valid-token").Return(&utils.JWTClaims{UserID: 1}, nil)
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, Permissions: []string{models.PermissionPatientRead}}, nil)
 
 	// Invalid request (empty ID)
 	reqBody := models.PatientSearchRequest{
@@ -202,3 +385,45 @@ func TestSearchPatient_ValidationError(t *testing.T) {
 	// Verify mock
 	mockAuthService.AssertExpectations(t)
 }
+
+func TestSearchPatient_Forbidden(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	mockPatientService := new(MockPatientService)
+	mockAuthService := new(MockAuthServiceForPatient)
+	patientHandler := handlers.NewPatientHandler(mockPatientService, mockAuthService)
+
+	// Create a test router
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+
+	patientHandler.RegisterRoutes(v1)
+
+	// Stub token validation for a caller without patient:read
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, Role: models.RoleAuditor, Permissions: []string{models.PermissionAuditRead}}, nil)
+
+	// Mock request data
+	reqBody := models.PatientSearchRequest{
+		ID: "1234567890123",
+	}
+	jsonValue, _ := json.Marshal(reqBody)
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/api/v1/patients/search", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token") // Mock token
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var response models.APIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.False(t, response.Success)
+
+	// Verify the service was never reached
+	mockPatientService.AssertNotCalled(t, "SearchPatient", mock.Anything, mock.Anything)
+	mockAuthService.AssertExpectations(t)
+}
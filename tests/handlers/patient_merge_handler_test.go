@@ -0,0 +1,159 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DingDong039/hms/internal/handlers"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPatientMergeService is a mock implementation of the PatientMergeService interface
+type MockPatientMergeService struct {
+	mock.Mock
+}
+
+func (m *MockPatientMergeService) DetectDuplicates(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPatientMergeService) ListDuplicates(ctx context.Context, req models.PatientDuplicateQueryRequest) ([]*models.PatientDuplicateCandidate, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.PatientDuplicateCandidate), args.Error(1)
+}
+
+func (m *MockPatientMergeService) DismissDuplicate(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPatientMergeService) MergePatients(ctx context.Context, req models.PatientMergeRequest) (*models.PatientMergeResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PatientMergeResponse), args.Error(1)
+}
+
+// setupMergeRouter creates a router with the patient and merge routes and a caller holding the given permissions
+func setupMergeRouter(permissions ...string) (*gin.Engine, *MockPatientMergeService) {
+	gin.SetMode(gin.TestMode)
+	mockMergeService := new(MockPatientMergeService)
+	mockAuthService := new(MockAuthServiceForPatient)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	handlers.NewPatientHandler(new(MockPatientService), mockAuthService).RegisterRoutes(v1)
+	handlers.NewPatientMergeHandler(mockMergeService, mockAuthService).RegisterRoutes(v1)
+
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, HospitalID: 1, Permissions: permissions}, nil)
+
+	return router, mockMergeService
+}
+
+func TestMergePatients_Success(t *testing.T) {
+	// Setup
+	router, mockMergeService := setupMergeRouter(models.PermissionPatientRead, models.PermissionPatientMerge)
+
+	// Mock request data
+	reqBody := models.PatientMergeRequest{SurvivorID: 10, DuplicateID: 11, Reason: "same person registered twice"}
+
+	// Mock service response
+	mockResult := &models.PatientMergeResponse{
+		Survivor: &models.Patient{ID: 10, PatientHN: "HN12345"},
+		Link:     &models.PatientLink{ID: 1, SurvivorID: 10, MergedPatientID: 11, MergedPatientHN: "HN99999"},
+	}
+	mockMergeService.On("MergePatients", mock.Anything, reqBody).Return(mockResult, nil)
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("POST", "/api/v1/patients/merge", reqBody))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.APIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Success)
+
+	// Verify mock
+	mockMergeService.AssertExpectations(t)
+}
+
+func TestMergePatients_SamePatient(t *testing.T) {
+	// Setup
+	router, mockMergeService := setupMergeRouter(models.PermissionPatientMerge)
+
+	// Invalid request (a patient can't be merged into itself)
+	reqBody := models.PatientMergeRequest{SurvivorID: 10, DuplicateID: 10}
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("POST", "/api/v1/patients/merge", reqBody))
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockMergeService.AssertNotCalled(t, "MergePatients", mock.Anything, mock.Anything)
+}
+
+func TestMergePatients_NotFound(t *testing.T) {
+	// Setup
+	router, mockMergeService := setupMergeRouter(models.PermissionPatientMerge)
+
+	// Mock service error
+	reqBody := models.PatientMergeRequest{SurvivorID: 10, DuplicateID: 99}
+	mockMergeService.On("MergePatients", mock.Anything, reqBody).Return(nil, apperrors.NewNotFoundError("duplicate patient not found"))
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("POST", "/api/v1/patients/merge", reqBody))
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockMergeService.AssertExpectations(t)
+}
+
+func TestMergePatients_Forbidden(t *testing.T) {
+	// Setup: a doctor can edit patients but not merge them
+	router, mockMergeService := setupMergeRouter(models.PermissionPatientRead, models.PermissionPatientWrite)
+
+	// Create request
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("POST", "/api/v1/patients/merge", models.PatientMergeRequest{SurvivorID: 10, DuplicateID: 11}))
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockMergeService.AssertNotCalled(t, "MergePatients", mock.Anything, mock.Anything)
+}
+
+func TestListDuplicates_Success(t *testing.T) {
+	// Setup
+	router, mockMergeService := setupMergeRouter(models.PermissionPatientRead, models.PermissionPatientMerge)
+
+	// Mock service response
+	mockCandidates := []*models.PatientDuplicateCandidate{
+		{ID: 1, PatientA: &models.Patient{ID: 10}, PatientB: &models.Patient{ID: 11}, Reason: models.DuplicateReasonNationalID, Score: 1, Status: models.DuplicateStatusOpen},
+	}
+	mockMergeService.On("ListDuplicates", mock.Anything, models.PatientDuplicateQueryRequest{Limit: 10}).Return(mockCandidates, nil)
+
+	// Create request (must not be taken for GET /patients/:id)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("GET", "/api/v1/patients/duplicates?limit=10", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockMergeService.AssertExpectations(t)
+}
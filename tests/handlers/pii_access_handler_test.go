@@ -0,0 +1,112 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DingDong039/hms/internal/middleware"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// withPIIAccess matches a context granting the given PII access
+func withPIIAccess(access utils.PIIAccess) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		return utils.PIIAccessFromContext(ctx) == access
+	})
+}
+
+func TestGetPatient_MaskedByDefault(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead)
+	mockPatientService.On("GetPatient", withPIIAccess(utils.PIIAccess{}), 10).Return(&models.Patient{ID: 10}, nil)
+
+	// Execute
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("GET", "/api/v1/patients/10", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestGetPatient_UnmaskPermission(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead, models.PermissionPatientUnmask)
+	mockPatientService.On("GetPatient", withPIIAccess(utils.PIIAccess{Unmasked: true}), 10).Return(&models.Patient{ID: 10}, nil)
+
+	// Execute
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("GET", "/api/v1/patients/10", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestGetPatient_BreakGlassHeader(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead, models.PermissionPatientBreakGlass)
+	access := utils.PIIAccess{Unmasked: true, BreakGlassReason: "emergency admission, patient unconscious"}
+	mockPatientService.On("GetPatient", withPIIAccess(access), 10).Return(&models.Patient{ID: 10}, nil)
+
+	// Execute
+	req := newPatientRequest("GET", "/api/v1/patients/10", nil)
+	req.Header.Set(middleware.BreakGlassReasonHeader, access.BreakGlassReason)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestQueryPatients_BreakGlassQueryParameter(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead, models.PermissionPatientBreakGlass)
+	access := utils.PIIAccess{Unmasked: true, BreakGlassReason: "verifying identity by phone"}
+	expectedQuery := models.PatientQueryRequest{PatientHN: "HN12345", Limit: models.DefaultPatientQueryLimit}
+	mockPatientService.On("QueryPatients", withPIIAccess(access), expectedQuery).Return([]*models.Patient{}, 0, nil)
+
+	// Execute
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newPatientRequest("GET", "/api/v1/patients?patient_hn=HN12345&break_glass_reason=verifying+identity+by+phone", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestGetPatient_BreakGlassWithoutPermission(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead)
+
+	// Execute
+	req := newPatientRequest("GET", "/api/v1/patients/10", nil)
+	req.Header.Set(middleware.BreakGlassReasonHeader, "emergency admission, patient unconscious")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockPatientService.AssertNotCalled(t, "GetPatient", mock.Anything, mock.Anything)
+}
+
+func TestGetPatient_BreakGlassReasonTooShort(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupPatientRouter(models.PermissionPatientRead, models.PermissionPatientBreakGlass)
+
+	// Execute
+	req := newPatientRequest("GET", "/api/v1/patients/10", nil)
+	req.Header.Set(middleware.BreakGlassReasonHeader, "because")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockPatientService.AssertNotCalled(t, "GetPatient", mock.Anything, mock.Anything)
+}
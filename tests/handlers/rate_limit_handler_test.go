@@ -0,0 +1,152 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/handlers"
+	"github.com/DingDong039/hms/internal/middleware"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// failingRateLimiter is a rate limiter whose backend is down
+type failingRateLimiter struct{}
+
+func (failingRateLimiter) Allow(ctx context.Context, key string, rule config.RateLimitRule) (models.RateLimitDecision, error) {
+	return models.RateLimitDecision{}, errors.New("connection refused")
+}
+
+func (failingRateLimiter) Prune(ctx context.Context, before time.Time) error {
+	return nil
+}
+
+// setupRateLimitedPatientRouter returns a patient router allowing two searches per caller
+// and stubbing the tokens of staff 1 and staff 2
+func setupRateLimitedPatientRouter(limiter services.RateLimiter) (*gin.Engine, *MockPatientService, *MockAuthServiceForPatient) {
+	gin.SetMode(gin.TestMode)
+	mockPatientService := new(MockPatientService)
+	mockAuthService := new(MockAuthServiceForPatient)
+	patientHandler := handlers.NewPatientHandler(mockPatientService, mockAuthService)
+
+	limits := config.RateLimitConfig{
+		Default: config.RateLimitRule{PerMinute: 600, Burst: 100},
+		Groups:  map[string]config.RateLimitRule{"patients": {PerMinute: 1, Burst: 2}},
+	}
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	v1.Use(middleware.RateLimit(limiter, mockAuthService, limits, slog.New(slog.DiscardHandler)))
+	patientHandler.RegisterRoutes(v1)
+
+	permissions := []string{models.PermissionPatientRead}
+	mockAuthService.On("ValidateToken", mock.Anything, "staff-1-token").Return(&utils.JWTClaims{UserID: 1, HospitalID: 1, Permissions: permissions}, nil)
+	mockAuthService.On("ValidateToken", mock.Anything, "staff-2-token").Return(&utils.JWTClaims{UserID: 2, HospitalID: 1, Permissions: permissions}, nil)
+	mockAuthService.On("ValidateToken", mock.Anything, mock.Anything).Return(nil, apperrors.NewUnauthorizedError("invalid token"))
+	mockPatientService.On("SearchPatient", mock.Anything, mock.Anything).Return(&models.PatientSearchResponse{PatientHN: "HN12345"}, nil)
+
+	return router, mockPatientService, mockAuthService
+}
+
+// searchPatient sends a patient search with the given bearer token, or none
+func searchPatient(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	jsonValue, _ := json.Marshal(models.PatientSearchRequest{ID: "1234567890123"})
+	req, _ := http.NewRequest("POST", "/api/v1/patients/search", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.RemoteAddr = "10.0.0.1:5000"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit_PerStaffMember(t *testing.T) {
+	// Setup
+	router, mockPatientService, mockAuthService := setupRateLimitedPatientRouter(services.NewMemoryRateLimiter())
+
+	// Execute
+	first := searchPatient(router, "staff-1-token")
+	second := searchPatient(router, "staff-1-token")
+	third := searchPatient(router, "staff-1-token")
+	colleague := searchPatient(router, "staff-2-token")
+
+	// Assert: staff behind the same address have limits of their own
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, http.StatusTooManyRequests, third.Code)
+	assert.Equal(t, "60", third.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, colleague.Code)
+	mockPatientService.AssertNumberOfCalls(t, "SearchPatient", 3)
+
+	// The token is validated once per request, not again by the auth middleware
+	mockAuthService.AssertNumberOfCalls(t, "ValidateToken", 4)
+}
+
+func TestRateLimit_InvalidCredentialsCountedByIP(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupRateLimitedPatientRouter(services.NewMemoryRateLimiter())
+
+	// Execute: made-up tokens don't each get a fresh allowance
+	first := searchPatient(router, "guess-1")
+	second := searchPatient(router, "")
+	third := searchPatient(router, "guess-2")
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, first.Code)
+	assert.Equal(t, http.StatusUnauthorized, second.Code)
+	assert.Equal(t, http.StatusTooManyRequests, third.Code)
+	assert.NotEmpty(t, third.Header().Get("Retry-After"))
+	mockPatientService.AssertNotCalled(t, "SearchPatient", mock.Anything, mock.Anything)
+}
+
+func TestRateLimit_PerAPIClient(t *testing.T) {
+	// Setup
+	router, _, mockAuthService := setupRateLimitedPatientRouter(services.NewMemoryRateLimiter())
+	mockAuthService.On("AuthenticateAPIKey", mock.Anything, "hms_key").Return(&utils.JWTClaims{HospitalID: 1, Permissions: []string{models.PermissionPatientRead}, APIClientID: 5}, nil)
+
+	// Execute
+	var codes []int
+	for i := 0; i < 3; i++ {
+		jsonValue, _ := json.Marshal(models.PatientSearchRequest{ID: "1234567890123"})
+		req, _ := http.NewRequest("POST", "/api/v1/patients/search", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", "hms_key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+
+	// Assert
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+	mockAuthService.AssertNumberOfCalls(t, "AuthenticateAPIKey", 3)
+}
+
+func TestRateLimit_BackendFailureLetsRequestsThrough(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupRateLimitedPatientRouter(failingRateLimiter{})
+
+	// Execute
+	var codes []int
+	for i := 0; i < 3; i++ {
+		codes = append(codes, searchPatient(router, "staff-1-token").Code)
+	}
+
+	// Assert
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusOK}, codes)
+	mockPatientService.AssertNumberOfCalls(t, "SearchPatient", 3)
+}
@@ -0,0 +1,117 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DingDong039/hms/internal/handlers"
+	"github.com/DingDong039/hms/internal/middleware"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// setupRequestIDRouter returns a patient router identifying requests the way the server does
+func setupRequestIDRouter() (*gin.Engine, *MockPatientService, *MockAuthServiceForPatient) {
+	gin.SetMode(gin.TestMode)
+	mockPatientService := new(MockPatientService)
+	mockAuthService := new(MockAuthServiceForPatient)
+	patientHandler := handlers.NewPatientHandler(mockPatientService, mockAuthService)
+
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.Use(middleware.RequestContext())
+	patientHandler.RegisterRoutes(router.Group("/api/v1"))
+
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, HospitalID: 1, Permissions: []string{models.PermissionPatientRead}}, nil)
+	mockAuthService.On("ValidateToken", mock.Anything, mock.Anything).Return(nil, apperrors.NewUnauthorizedError("invalid token"))
+
+	return router, mockPatientService, mockAuthService
+}
+
+// searchPatientWithRequestID sends a patient search carrying the given X-Request-ID, or none
+func searchPatientWithRequestID(router *gin.Engine, token, requestID string) *httptest.ResponseRecorder {
+	jsonValue, _ := json.Marshal(models.PatientSearchRequest{ID: "1234567890123"})
+	req, _ := http.NewRequest("POST", "/api/v1/patients/search", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestID_GeneratedWhenMissing(t *testing.T) {
+	// Setup
+	router, _, _ := setupRequestIDRouter()
+
+	// Execute
+	first := searchPatientWithRequestID(router, "bad-token", "")
+	second := searchPatientWithRequestID(router, "bad-token", "")
+
+	// Assert
+	requestID := first.Header().Get("X-Request-ID")
+	assert.NotEmpty(t, requestID)
+	assert.NotEqual(t, requestID, second.Header().Get("X-Request-ID"))
+
+	var response models.APIResponse
+	err := json.Unmarshal(first.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, first.Code)
+	assert.Equal(t, requestID, response.Error.RequestID)
+}
+
+func TestRequestID_EchoesCallerID(t *testing.T) {
+	// Setup
+	router, mockPatientService, _ := setupRequestIDRouter()
+	mockPatientService.On("SearchPatient", mock.MatchedBy(func(ctx context.Context) bool {
+		return utils.RequestIDFromContext(ctx) == "client-42.retry:1"
+	}), mock.Anything).Return(nil, apperrors.NewNotFoundError("Patient not found"))
+
+	// Execute
+	w := searchPatientWithRequestID(router, "valid-token", "client-42.retry:1")
+
+	// Assert: the ID reaches the service and comes back in the error
+	var response models.APIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "client-42.retry:1", w.Header().Get("X-Request-ID"))
+	assert.Equal(t, "client-42.retry:1", response.Error.RequestID)
+	mockPatientService.AssertExpectations(t)
+}
+
+func TestRequestID_ReplacesMalformedID(t *testing.T) {
+	testCases := []struct {
+		name      string
+		requestID string
+	}{
+		{name: "Forged Log Line", requestID: "abc\" | 200 | forged"},
+		{name: "Too Long", requestID: strings.Repeat("a", 101)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			router, _, _ := setupRequestIDRouter()
+
+			// Execute
+			w := searchPatientWithRequestID(router, "bad-token", tc.requestID)
+
+			// Assert
+			requestID := w.Header().Get("X-Request-ID")
+			assert.NotEmpty(t, requestID)
+			assert.NotEqual(t, tc.requestID, requestID)
+		})
+	}
+}
@@ -0,0 +1,546 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/handlers"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockStaffService is a mock implementation of the StaffService interface
+type MockStaffService struct {
+	mock.Mock
+}
+
+func (m *MockStaffService) CreateStaff(ctx context.Context, req models.StaffCreateRequest) (*models.Staff, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Staff), args.Error(1)
+}
+
+func (m *MockStaffService) InviteStaff(ctx context.Context, req models.StaffInviteRequest) (*models.StaffInviteResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StaffInviteResponse), args.Error(1)
+}
+
+func (m *MockStaffService) AcceptInvite(ctx context.Context, req models.StaffInviteAcceptRequest) (*models.Staff, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Staff), args.Error(1)
+}
+
+func (m *MockStaffService) ListStaff(ctx context.Context, req models.StaffQueryRequest) ([]*models.Staff, int, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*models.Staff), args.Int(1), args.Error(2)
+}
+
+func (m *MockStaffService) GetStaff(ctx context.Context, id int) (*models.Staff, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Staff), args.Error(1)
+}
+
+func (m *MockStaffService) UpdateStaff(ctx context.Context, id int, req models.StaffPatchRequest) (*models.Staff, error) {
+	args := m.Called(ctx, id, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Staff), args.Error(1)
+}
+
+func (m *MockStaffService) DeactivateStaff(ctx context.Context, id int) (*models.Staff, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Staff), args.Error(1)
+}
+
+func (m *MockStaffService) ReactivateStaff(ctx context.Context, id int) (*models.Staff, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Staff), args.Error(1)
+}
+
+func (m *MockStaffService) UnlockStaff(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockStaffService) ResetPassword(ctx context.Context, id int) (*models.PasswordResetTokenResponse, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PasswordResetTokenResponse), args.Error(1)
+}
+
+func (m *MockStaffService) ResetMFA(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func setupStaffRouter(permissions ...string) (*gin.Engine, *MockStaffService, *MockAuthService) {
+	gin.SetMode(gin.TestMode)
+	mockStaffService := new(MockStaffService)
+	mockAuthService := new(MockAuthService)
+	staffHandler := handlers.NewStaffHandler(mockStaffService, mockAuthService)
+
+	router := gin.Default()
+	v1 := router.Group("/api/v1")
+	staffHandler.RegisterRoutes(v1)
+
+	mockAuthService.On("ValidateToken", mock.Anything, "valid-token").Return(&utils.JWTClaims{UserID: 1, HospitalID: 1, Role: models.RoleAdmin, Permissions: permissions}, nil)
+
+	return router, mockStaffService, mockAuthService
+}
+
+func TestUnlockStaff_Success(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+	mockStaffService.On("UnlockStaff", mock.Anything, 7).Return(nil)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/7/unlock", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStaffService.AssertExpectations(t)
+}
+
+func TestUnlockStaff_NotFound(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+	mockStaffService.On("UnlockStaff", mock.Anything, 99).Return(apperrors.NewNotFoundError("staff member not found"))
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/99/unlock", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUnlockStaff_Forbidden(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffRead)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/7/unlock", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockStaffService.AssertNotCalled(t, "UnlockStaff", mock.Anything, mock.Anything)
+}
+
+func TestResetStaffPassword_Success(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+	mockStaffService.On("ResetPassword", mock.Anything, 7).Return(&models.PasswordResetTokenResponse{Token: "reset-token", ExpiresAt: time.Now().Add(time.Hour)}, nil)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/7/password-reset", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "reset-token")
+	mockStaffService.AssertExpectations(t)
+}
+
+func TestResetStaffMFA_Success(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+	mockStaffService.On("ResetMFA", mock.Anything, 7).Return(nil)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/7/mfa-reset", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStaffService.AssertExpectations(t)
+}
+
+func TestResetStaffMFA_NotEnrolled(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+	mockStaffService.On("ResetMFA", mock.Anything, 7).Return(apperrors.NewNotFoundError("two-factor authentication is not set up"))
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/7/mfa-reset", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateStaff_Success(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+
+	reqBody := models.StaffCreateRequest{Username: "testuser", Password: "Tangerine-Kite-42"}
+	mockStaffService.On("CreateStaff", mock.Anything, reqBody).Return(&models.Staff{ID: 1, Username: "testuser"}, nil)
+	jsonValue, _ := json.Marshal(reqBody)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.APIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Success)
+	mockStaffService.AssertExpectations(t)
+}
+
+func TestCreateStaff_ValidationError(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+
+	// Invalid request (missing required fields)
+	jsonValue, _ := json.Marshal(models.StaffCreateRequest{Username: "testuser"})
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockStaffService.AssertNotCalled(t, "CreateStaff", mock.Anything, mock.Anything)
+}
+
+func TestCreateStaff_WeakPassword(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+
+	reqBody := models.StaffCreateRequest{Username: "testuser", Password: "password123"}
+	mockStaffService.On("CreateStaff", mock.Anything, reqBody).Return(nil, services.NewValidationError("password is too common"))
+	jsonValue, _ := json.Marshal(reqBody)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateStaff_RequiresAuthentication(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+	jsonValue, _ := json.Marshal(models.StaffCreateRequest{Username: "testuser", Password: "Tangerine-Kite-42"})
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockStaffService.AssertNotCalled(t, "CreateStaff", mock.Anything, mock.Anything)
+}
+
+func TestInviteStaff_Success(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+
+	reqBody := models.StaffInviteRequest{Username: "newnurse", Role: models.RoleNurse}
+	mockStaffService.On("InviteStaff", mock.Anything, reqBody).Return(&models.StaffInviteResponse{
+		Token: "invite-token", Username: "newnurse", Role: models.RoleNurse, ExpiresAt: time.Now().Add(72 * time.Hour),
+	}, nil)
+	jsonValue, _ := json.Marshal(reqBody)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/invites", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "invite-token")
+	mockStaffService.AssertExpectations(t)
+}
+
+func TestInviteStaff_Forbidden(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffRead)
+	jsonValue, _ := json.Marshal(models.StaffInviteRequest{Username: "newnurse"})
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/invites", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockStaffService.AssertNotCalled(t, "InviteStaff", mock.Anything, mock.Anything)
+}
+
+func TestAcceptInvite_Public(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter()
+
+	reqBody := models.StaffInviteAcceptRequest{Token: "invite-token", Password: "Tangerine-Kite-42"}
+	mockStaffService.On("AcceptInvite", mock.Anything, reqBody).Return(&models.Staff{ID: 9, Username: "newnurse", Role: models.RoleNurse}, nil)
+	jsonValue, _ := json.Marshal(reqBody)
+
+	// Execute: no Authorization header
+	req, _ := http.NewRequest("POST", "/api/v1/staff/invites/accept", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockStaffService.AssertExpectations(t)
+}
+
+func TestAcceptInvite_InvalidToken(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter()
+
+	reqBody := models.StaffInviteAcceptRequest{Token: "used-token", Password: "Tangerine-Kite-42"}
+	mockStaffService.On("AcceptInvite", mock.Anything, reqBody).Return(nil, apperrors.NewUnauthorizedError("invalid or expired invite"))
+	jsonValue, _ := json.Marshal(reqBody)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/invites/accept", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestListStaff_Success(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffRead)
+
+	active := true
+	expectedQuery := models.StaffQueryRequest{Department: "ER", Role: models.RoleNurse, IsActive: &active, Limit: 10, Offset: 10}
+	mockStaffService.On("ListStaff", mock.Anything, expectedQuery).Return([]*models.Staff{{ID: 7, Username: "nurse1", IsActive: true}}, 11, nil)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/staff?department=ER&role=nurse&is_active=true&limit=10&offset=10", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.APIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Pagination{Total: 11, Limit: 10, Offset: 10}, response.Meta)
+	mockStaffService.AssertExpectations(t)
+}
+
+func TestListStaff_DefaultLimit(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffRead)
+	expectedQuery := models.StaffQueryRequest{Limit: models.DefaultStaffQueryLimit}
+	mockStaffService.On("ListStaff", mock.Anything, expectedQuery).Return([]*models.Staff{}, 0, nil)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/staff", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStaffService.AssertExpectations(t)
+}
+
+func TestListStaff_InvalidRole(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffRead)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/staff?role=janitor", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockStaffService.AssertNotCalled(t, "ListStaff", mock.Anything, mock.Anything)
+}
+
+func TestListStaff_Forbidden(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionPatientRead)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/staff", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockStaffService.AssertNotCalled(t, "ListStaff", mock.Anything, mock.Anything)
+}
+
+func TestGetStaff_Success(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffRead)
+	mockStaffService.On("GetStaff", mock.Anything, 7).Return(&models.Staff{ID: 7, Username: "nurse1", FullName: "Somsri Jaidee"}, nil)
+
+	// Execute
+	req, _ := http.NewRequest("GET", "/api/v1/staff/7", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Somsri Jaidee")
+	mockStaffService.AssertExpectations(t)
+}
+
+func TestUpdateStaff_Success(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+
+	department := "ICU"
+	role := models.RoleDoctor
+	reqBody := models.StaffPatchRequest{Department: &department, Role: &role}
+	mockStaffService.On("UpdateStaff", mock.Anything, 7, reqBody).Return(&models.Staff{ID: 7, Department: department, Role: role}, nil)
+	jsonValue, _ := json.Marshal(reqBody)
+
+	// Execute
+	req, _ := http.NewRequest("PATCH", "/api/v1/staff/7", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStaffService.AssertExpectations(t)
+}
+
+func TestUpdateStaff_Forbidden(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffRead)
+	jsonValue, _ := json.Marshal(map[string]string{"department": "ICU"})
+
+	// Execute
+	req, _ := http.NewRequest("PATCH", "/api/v1/staff/7", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockStaffService.AssertNotCalled(t, "UpdateStaff", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeactivateStaff_Success(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+	mockStaffService.On("DeactivateStaff", mock.Anything, 7).Return(&models.Staff{ID: 7, IsActive: false}, nil)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/7/deactivate", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStaffService.AssertExpectations(t)
+}
+
+func TestDeactivateStaff_LastAdmin(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+	mockStaffService.On("DeactivateStaff", mock.Anything, 2).Return(nil, apperrors.NewConflictError("the hospital must keep at least one active admin"))
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/2/deactivate", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestReactivateStaff_Success(t *testing.T) {
+	// Setup
+	router, mockStaffService, _ := setupStaffRouter(models.PermissionStaffManage)
+	mockStaffService.On("ReactivateStaff", mock.Anything, 7).Return(&models.Staff{ID: 7, IsActive: true}, nil)
+
+	// Execute
+	req, _ := http.NewRequest("POST", "/api/v1/staff/7/reactivate", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStaffService.AssertExpectations(t)
+}
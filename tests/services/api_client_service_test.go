@@ -0,0 +1,178 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPIClientService() (*services.APIClientServiceImpl, *MockAPIClientRepository, *MockAuditService) {
+	apiClientRepo := new(MockAPIClientRepository)
+	auditService := new(MockAuditService)
+	cfg := &config.Config{APIClients: config.APIClientConfig{MaxKeyTTL: 30 * 24 * time.Hour}}
+	return services.NewAPIClientService(apiClientRepo, auditService, cfg), apiClientRepo, auditService
+}
+
+func TestAPIClientService_CreateClient(t *testing.T) {
+	// Setup
+	service, apiClientRepo, auditService := newAPIClientService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 7)
+
+	var stored *models.APIClient
+	apiClientRepo.On("Create", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*models.APIClient)
+		stored.ID = 3
+	}).Return(nil)
+	auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionAPIClientCreate && event.Details["api_client_id"] == "3" &&
+			event.Details["scopes"] == "patient:read patient:write"
+	})).Return(nil)
+
+	// Execute
+	created, err := service.CreateClient(ctx, models.APIClientCreateRequest{
+		Name:   " Lab analyzer ",
+		Scopes: []string{models.PermissionPatientRead, models.PermissionPatientWrite, models.PermissionPatientRead},
+	})
+
+	// Assert: only the hash of the key is stored, and the key lasts as long as allowed
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Key, models.APIClientKeyPrefix))
+	assert.Equal(t, utils.HashToken(created.Key), stored.KeyHash)
+	assert.True(t, strings.HasPrefix(created.Key, stored.KeyPrefix))
+	assert.Less(t, len(stored.KeyPrefix), len(created.Key)/2)
+	assert.Equal(t, "Lab analyzer", stored.Name)
+	assert.Equal(t, []string{models.PermissionPatientRead, models.PermissionPatientWrite}, stored.Scopes)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), stored.ExpiresAt, time.Minute)
+	require.NotNil(t, stored.CreatedBy)
+	assert.Equal(t, 7, *stored.CreatedBy)
+	apiClientRepo.AssertExpectations(t)
+	auditService.AssertExpectations(t)
+}
+
+func TestAPIClientService_CreateClient_ExpiryBeyondMaximum(t *testing.T) {
+	// Setup
+	service, apiClientRepo, _ := newAPIClientService()
+	ctx := utils.WithHospitalID(context.Background(), 1)
+	expiresAt := time.Now().Add(365 * 24 * time.Hour)
+
+	// Execute
+	_, err := service.CreateClient(ctx, models.APIClientCreateRequest{
+		Name:      "Kiosk",
+		Scopes:    []string{models.PermissionPatientRead},
+		ExpiresAt: &expiresAt,
+	})
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrInvalidInput)
+	apiClientRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestAPIClientService_CreateClient_RejectsStaffOnlyScopes(t *testing.T) {
+	// Setup
+	service, apiClientRepo, _ := newAPIClientService()
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	// Execute
+	_, err := service.CreateClient(ctx, models.APIClientCreateRequest{
+		Name:   "Kiosk",
+		Scopes: []string{models.PermissionPatientRead, models.PermissionStaffManage},
+	})
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrInvalidInput)
+	apiClientRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestAPIClientService_RevokeClient(t *testing.T) {
+	// Setup
+	service, apiClientRepo, auditService := newAPIClientService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 7)
+	client := &models.APIClient{ID: 3, HospitalID: 1, Name: "Kiosk"}
+
+	apiClientRepo.On("FindByID", ctx, 3).Return(client, nil)
+	apiClientRepo.On("Revoke", ctx, client).Return(nil)
+	auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionAPIClientRevoke && event.Details["api_client_id"] == "3"
+	})).Return(nil)
+
+	// Execute
+	revoked, err := service.RevokeClient(ctx, 3)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, client, revoked)
+	apiClientRepo.AssertExpectations(t)
+	auditService.AssertExpectations(t)
+}
+
+func TestAuthService_AuthenticateAPIKey(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := context.Background()
+	client := &models.APIClient{
+		ID:         3,
+		HospitalID: 1,
+		Scopes:     []string{models.PermissionPatientRead},
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	mocks.apiClientRepo.On("FindByHash", ctx, utils.HashToken("hms_valid")).Return(client, nil)
+	mocks.apiClientRepo.On("TouchLastUsed", utils.WithHospitalID(ctx, 1), 3).Return(nil)
+
+	// Execute
+	claims, err := service.AuthenticateAPIKey(ctx, "hms_valid")
+
+	// Assert: the client is its own principal, with only its scopes and no staff identity
+	require.NoError(t, err)
+	assert.Equal(t, 3, claims.APIClientID)
+	assert.Equal(t, 1, claims.HospitalID)
+	assert.Zero(t, claims.UserID)
+	assert.Empty(t, claims.Role)
+	assert.True(t, claims.HasPermission(models.PermissionPatientRead))
+	assert.False(t, claims.HasPermission(models.PermissionPatientWrite))
+	mocks.apiClientRepo.AssertExpectations(t)
+}
+
+func TestAuthService_AuthenticateAPIKey_Refused(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name   string
+		key    string
+		client *models.APIClient
+	}{
+		{"revoked", "hms_revoked", &models.APIClient{ID: 3, HospitalID: 1, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}},
+		{"expired", "hms_expired", &models.APIClient{ID: 3, HospitalID: 1, ExpiresAt: time.Now().Add(-time.Minute)}},
+		{"unknown", "hms_unknown", nil},
+		{"not an API key", "eyJhbGciOiJFZERTQSJ9", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+			if tt.client != nil {
+				mocks.apiClientRepo.On("FindByHash", mock.Anything, utils.HashToken(tt.key)).Return(tt.client, nil)
+			} else {
+				mocks.apiClientRepo.On("FindByHash", mock.Anything, mock.Anything).Return(nil, apperrors.NewNotFoundError("API client not found"))
+			}
+
+			// Execute
+			claims, err := service.AuthenticateAPIKey(context.Background(), tt.key)
+
+			// Assert
+			assert.Nil(t, claims)
+			assert.ErrorIs(t, err, apperrors.ErrUnauthorized)
+			mocks.apiClientRepo.AssertNotCalled(t, "TouchLastUsed", mock.Anything, mock.Anything)
+		})
+	}
+}
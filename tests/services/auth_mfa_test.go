@@ -0,0 +1,269 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// mfaSecret is the TOTP secret of the staff member in these tests
+const mfaSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// newEnabledMFA returns an enabled enrollment with mfaSecret
+func newEnabledMFA() *models.StaffMFA {
+	enabledAt := time.Now().Add(-time.Hour)
+	return &models.StaffMFA{StaffID: 7, Secret: mfaSecret, EnabledAt: &enabledAt}
+}
+
+// currentCode returns the current TOTP code of mfaSecret and its time step
+func currentCode(t *testing.T) (string, int64) {
+	t.Helper()
+
+	code, err := utils.TOTPCode(mfaSecret, time.Now())
+	require.NoError(t, err)
+	counter, ok := utils.ValidateTOTPCode(mfaSecret, code, time.Now())
+	require.True(t, ok)
+	return code, counter
+}
+
+func TestAuthService_Login_MFAChallenge(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := context.Background()
+	staff := newLoginStaff(t)
+	staff.MFAEnabled = true
+
+	mocks.hospitalRepo.On("FindByCode", ctx, "H001").Return(&models.Hospital{ID: 1, Code: "H001"}, nil)
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "nurse1").Return(staff, nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	// Execute
+	response, err := service.Login(ctx, models.StaffLoginRequest{Username: "nurse1", Password: "correct-password"})
+
+	// Assert: no session is started until the second step
+	require.NoError(t, err)
+	assert.True(t, response.MFARequired)
+	assert.NotEmpty(t, response.MFAToken)
+	assert.Empty(t, response.Token)
+	assert.Empty(t, response.RefreshToken)
+	mocks.refreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+
+	// The MFA token can't be used as an access token
+	_, err = service.ValidateToken(ctx, response.MFAToken)
+	assert.Error(t, err)
+}
+
+func TestAuthService_Login_MFAEnrollmentRequired(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := context.Background()
+	staff := newLoginStaff(t)
+	staff.Role = models.RoleAdmin
+
+	mocks.hospitalRepo.On("FindByCode", ctx, "H001").Return(&models.Hospital{ID: 1, Code: "H001"}, nil)
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "nurse1").Return(staff, nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	mocks.roleRepo.On("FindPermissionsByRoleID", mock.Anything, 2).Return([]string{models.PermissionStaffManage}, nil)
+	mocks.refreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	// Execute
+	response, err := service.Login(ctx, models.StaffLoginRequest{Username: "nurse1", Password: "correct-password"})
+
+	// Assert: the token is limited to enrolling
+	require.NoError(t, err)
+	assert.True(t, response.MFAEnrollmentRequired)
+
+	mocks.refreshTokenRepo.On("IsAccessTokenRevoked", mock.Anything, mock.Anything).Return(false, nil)
+	claims, err := service.ValidateToken(ctx, response.Token)
+	require.NoError(t, err)
+	assert.True(t, claims.MFAEnrollmentRequired)
+}
+
+func TestAuthService_VerifyMFA(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	staff := newLoginStaff(t)
+	staff.MFAEnabled = true
+	code, counter := currentCode(t)
+
+	challenge, _, err := utils.GenerateMFAToken(7, 1, time.Minute, mocks.jwtKeys)
+	require.NoError(t, err)
+
+	mocks.staffRepo.On("FindByID", mock.Anything, 7).Return(staff, nil)
+	mocks.mfaRepo.On("FindByStaffID", mock.Anything, 7).Return(newEnabledMFA(), nil)
+	mocks.mfaRepo.On("UseCounter", mock.Anything, 7, counter).Return(nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.MatchedBy(func(attempt *models.LoginAttempt) bool {
+		return attempt.Succeeded && attempt.Username == "nurse1"
+	})).Return(nil)
+	mocks.roleRepo.On("FindPermissionsByRoleID", mock.Anything, 2).Return([]string{models.PermissionPatientRead}, nil)
+	mocks.refreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	// Execute
+	response, err := service.VerifyMFA(context.Background(), models.MFAVerifyRequest{MFAToken: challenge, Code: code})
+
+	// Assert
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.Token)
+	assert.NotEmpty(t, response.RefreshToken)
+	mocks.mfaRepo.AssertExpectations(t)
+	mocks.refreshTokenRepo.AssertExpectations(t)
+}
+
+func TestAuthService_VerifyMFA_ReplayedCode(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{MaxFailedAttempts: 5, LockoutDuration: 15 * time.Minute})
+	staff := newLoginStaff(t)
+	staff.MFAEnabled = true
+	code, counter := currentCode(t)
+	mfa := newEnabledMFA()
+	mfa.LastUsedCounter = counter
+
+	challenge, _, err := utils.GenerateMFAToken(7, 1, time.Minute, mocks.jwtKeys)
+	require.NoError(t, err)
+
+	mocks.staffRepo.On("FindByID", mock.Anything, 7).Return(staff, nil)
+	mocks.mfaRepo.On("FindByStaffID", mock.Anything, 7).Return(mfa, nil)
+	mocks.staffRepo.On("RecordFailedLogin", mock.Anything, staff, 5, 15*time.Minute).Return(nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.MatchedBy(func(attempt *models.LoginAttempt) bool {
+		return !attempt.Succeeded
+	})).Return(nil)
+
+	// Execute
+	_, err = service.VerifyMFA(context.Background(), models.MFAVerifyRequest{MFAToken: challenge, Code: code})
+
+	// Assert: a used code counts as a failed login
+	assert.ErrorIs(t, err, apperrors.ErrUnauthorized)
+	mocks.mfaRepo.AssertNotCalled(t, "UseCounter", mock.Anything, mock.Anything, mock.Anything)
+	mocks.staffRepo.AssertExpectations(t)
+	mocks.refreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_VerifyMFA_RecoveryCode(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	staff := newLoginStaff(t)
+	staff.MFAEnabled = true
+
+	challenge, _, err := utils.GenerateMFAToken(7, 1, time.Minute, mocks.jwtKeys)
+	require.NoError(t, err)
+
+	mocks.staffRepo.On("FindByID", mock.Anything, 7).Return(staff, nil)
+	mocks.mfaRepo.On("FindByStaffID", mock.Anything, 7).Return(newEnabledMFA(), nil)
+	mocks.mfaRepo.On("UseRecoveryCode", mock.Anything, 7, utils.HashToken("abcdefghijklmnop")).Return(nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	mocks.roleRepo.On("FindPermissionsByRoleID", mock.Anything, 2).Return([]string{models.PermissionPatientRead}, nil)
+	mocks.refreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	// Execute: recovery codes can be entered in any case, with or without separators
+	response, err := service.VerifyMFA(context.Background(), models.MFAVerifyRequest{MFAToken: challenge, Code: "ABCD-EFGH-ijkl-mnop"})
+
+	// Assert
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.Token)
+	mocks.mfaRepo.AssertExpectations(t)
+}
+
+func TestAuthService_VerifyMFA_InvalidToken(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+
+	// An access token is not an MFA token
+	accessToken, _, err := utils.GenerateToken(&utils.JWTClaims{UserID: 7, HospitalID: 1}, time.Hour, mocks.jwtKeys)
+	require.NoError(t, err)
+
+	// Execute
+	_, err = service.VerifyMFA(context.Background(), models.MFAVerifyRequest{MFAToken: accessToken, Code: "123456"})
+
+	// Assert
+	assert.Error(t, err)
+	mocks.staffRepo.AssertNotCalled(t, "FindByID", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_ConfirmMFA(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := utils.WithHospitalID(context.Background(), 1)
+	staff := newLoginStaff(t)
+	staff.Role = models.RoleAdmin
+	code, counter := currentCode(t)
+
+	var storedHashes []string
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(staff, nil)
+	mocks.mfaRepo.On("FindByStaffID", ctx, 7).Return(&models.StaffMFA{StaffID: 7, Secret: mfaSecret}, nil)
+	mocks.mfaRepo.On("Enable", ctx, 7, counter, mock.AnythingOfType("[]string")).Run(func(args mock.Arguments) {
+		storedHashes = args.Get(3).([]string)
+	}).Return(nil)
+	mocks.refreshTokenRepo.On("RevokeAllForStaff", ctx, 7).Return(nil)
+	mocks.roleRepo.On("FindPermissionsByRoleID", ctx, 2).Return([]string{models.PermissionStaffManage}, nil)
+	mocks.refreshTokenRepo.On("Create", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	// Execute
+	response, err := service.ConfirmMFA(ctx, &utils.JWTClaims{UserID: 7, Role: models.RoleAdmin, MFAEnrollmentRequired: true}, models.MFACodeRequest{Code: code})
+
+	// Assert: only hashes of the returned codes are stored, and the new session is unrestricted
+	require.NoError(t, err)
+	require.Len(t, response.RecoveryCodes, 4)
+	for i, recoveryCode := range response.RecoveryCodes {
+		assert.Equal(t, utils.HashToken(strings.ReplaceAll(recoveryCode, "-", "")), storedHashes[i])
+	}
+	assert.False(t, response.Session.MFAEnrollmentRequired)
+	mocks.mfaRepo.AssertExpectations(t)
+	mocks.refreshTokenRepo.AssertExpectations(t)
+}
+
+func TestAuthService_ConfirmMFA_WrongCode(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(newLoginStaff(t), nil)
+	mocks.mfaRepo.On("FindByStaffID", ctx, 7).Return(&models.StaffMFA{StaffID: 7, Secret: mfaSecret}, nil)
+
+	// Execute
+	_, err := service.ConfirmMFA(ctx, &utils.JWTClaims{UserID: 7}, models.MFACodeRequest{Code: "not-a-code"})
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrInvalidInput)
+	mocks.mfaRepo.AssertNotCalled(t, "Enable", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_DisableMFA_RequiredForRole(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := utils.WithHospitalID(context.Background(), 1)
+	code, _ := currentCode(t)
+
+	// Execute
+	err := service.DisableMFA(ctx, &utils.JWTClaims{UserID: 7, Role: models.RoleAdmin}, models.MFACodeRequest{Code: code})
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrForbidden)
+	mocks.mfaRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_DisableMFA(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := utils.WithHospitalID(context.Background(), 1)
+	code, counter := currentCode(t)
+
+	mocks.mfaRepo.On("FindByStaffID", ctx, 7).Return(newEnabledMFA(), nil)
+	mocks.mfaRepo.On("UseCounter", ctx, 7, counter).Return(nil)
+	mocks.mfaRepo.On("Delete", ctx, 7).Return(nil)
+
+	// Execute
+	err := service.DisableMFA(ctx, &utils.JWTClaims{UserID: 7, Role: models.RoleNurse}, models.MFACodeRequest{Code: code})
+
+	// Assert
+	assert.NoError(t, err)
+	mocks.mfaRepo.AssertExpectations(t)
+}
@@ -0,0 +1,425 @@
+package services_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	stubClientID     = "hms"
+	stubClientSecret = "s3cret"
+	stubRedirectURL  = "https://hms.example.com/oidc/callback"
+)
+
+// stubUser is an account at the stub identity provider
+type stubUser struct {
+	Subject  string
+	Username string
+	Name     string
+	Groups   []string
+}
+
+// stubAuthorization is an authorization code the stub identity provider has issued
+type stubAuthorization struct {
+	user          stubUser
+	nonce         string
+	codeChallenge string
+}
+
+// stubIdP is a local OpenID Connect identity provider implementing discovery, JWKS and
+// the authorization code grant with PKCE
+type stubIdP struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	audience string // Audience of issued ID tokens; the client ID unless a test overrides it
+
+	mu    sync.Mutex
+	codes map[string]stubAuthorization
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &stubIdP{key: key, audience: stubClientID, codes: map[string]stubAuthorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(utils.JWKS{Keys: []utils.JWK{{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "RS256",
+			Kid: "stub-key",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// signIn plays the user's browser at the sign-in page HMS redirected to, and returns the
+// callback HMS receives once the user has signed in
+func (idp *stubIdP) signIn(t *testing.T, authURL string, user stubUser) models.OIDCCallbackRequest {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	query := parsed.Query()
+	require.Equal(t, idp.server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	require.Equal(t, stubClientID, query.Get("client_id"))
+	require.Equal(t, stubRedirectURL, query.Get("redirect_uri"))
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+
+	code, err := utils.GenerateRandomToken(16)
+	require.NoError(t, err)
+
+	idp.mu.Lock()
+	idp.codes[code] = stubAuthorization{user: user, nonce: query.Get("nonce"), codeChallenge: query.Get("code_challenge")}
+	idp.mu.Unlock()
+
+	return models.OIDCCallbackRequest{State: query.Get("state"), Code: code}
+}
+
+// token implements the token endpoint: it authenticates the client, checks the code and
+// its PKCE verifier, and issues an ID token
+func (idp *stubIdP) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != stubClientID || clientSecret != stubClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	idp.mu.Lock()
+	authorization, found := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !found || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != stubRedirectURL ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != authorization.codeChallenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                idp.server.URL,
+		"aud":                idp.audience,
+		"sub":                authorization.user.Subject,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              authorization.nonce,
+		"preferred_username": authorization.user.Username,
+		"name":               authorization.user.Name,
+		"groups":             authorization.user.Groups,
+	})
+	idToken.Header["kid"] = "stub-key"
+	signed, err := idToken.SignedString(idp.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": signed})
+}
+
+// newOIDCAuthService returns an AuthService signing in staff of hospital H001 through the stub identity provider
+func newOIDCAuthService(t *testing.T, idp *stubIdP, configure func(*config.OIDCProviderConfig)) (*services.AuthServiceImpl, *authServiceMocks) {
+	t.Helper()
+
+	provider := config.OIDCProviderConfig{
+		HospitalCode:  "H001",
+		IssuerURL:     idp.server.URL,
+		ClientID:      stubClientID,
+		ClientSecret:  stubClientSecret,
+		RedirectURL:   stubRedirectURL,
+		Scopes:        []string{"openid", "profile", "groups"},
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		RoleMappings: []config.OIDCRoleMapping{
+			{Group: "hms-doctors", Role: models.RoleDoctor},
+			{Group: "hms-nurses", Role: models.RoleNurse},
+		},
+		AutoProvision: true,
+		Timeout:       5 * time.Second,
+	}
+	if configure != nil {
+		configure(&provider)
+	}
+
+	cfg := newAuthConfig()
+	cfg.OIDC.Providers = []config.OIDCProviderConfig{provider}
+	return newAuthServiceWithConfig(t, cfg, services.NewOIDCProviders(cfg.OIDC))
+}
+
+// startOIDCSignIn starts a sign-in, has the user sign in at the stub identity provider,
+// and returns the callback along with the pending sign-in the callback redeems
+func startOIDCSignIn(t *testing.T, service *services.AuthServiceImpl, mocks *authServiceMocks, idp *stubIdP, user stubUser) (models.OIDCCallbackRequest, *models.OIDCLoginState) {
+	t.Helper()
+
+	var pending *models.OIDCLoginState
+	mocks.hospitalRepo.On("FindByCode", mock.Anything, "H001").Return(&models.Hospital{ID: 1, Code: "H001"}, nil)
+	mocks.hospitalRepo.On("FindByID", mock.Anything, 1).Return(&models.Hospital{ID: 1, Code: "H001"}, nil)
+	mocks.oidcStateRepo.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		pending = args.Get(1).(*models.OIDCLoginState)
+		pending.HospitalID = 1
+	}).Return(nil)
+
+	authURL, err := service.StartOIDCLogin(context.Background(), models.OIDCLoginRequest{})
+	require.NoError(t, err)
+	callback := idp.signIn(t, authURL, user)
+
+	require.NotNil(t, pending)
+	assert.Equal(t, utils.HashToken(callback.State), pending.StateHash)
+	mocks.oidcStateRepo.On("Consume", mock.Anything, pending.StateHash).Return(pending, nil).Once()
+	return callback, pending
+}
+
+// expectSession sets up the mocks that record a successful sign-in and start a session
+func expectSession(mocks *authServiceMocks, roleID int) {
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.MatchedBy(func(attempt *models.LoginAttempt) bool {
+		return attempt.Succeeded
+	})).Return(nil)
+	mocks.roleRepo.On("FindPermissionsByRoleID", mock.Anything, roleID).Return([]string{models.PermissionPatientRead}, nil)
+	mocks.refreshTokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+}
+
+var stubDoctor = stubUser{Subject: "user-123", Username: "dr.somchai", Name: "Somchai Jaidee", Groups: []string{"staff", "hms-doctors"}}
+
+func TestAuthService_OIDCLogin_ProvisionsStaffOnFirstSignIn(t *testing.T) {
+	// Setup
+	idp := newStubIdP(t)
+	service, mocks := newOIDCAuthService(t, idp, nil)
+	callback, _ := startOIDCSignIn(t, service, mocks, idp, stubDoctor)
+
+	mocks.roleRepo.On("FindByName", mock.Anything, models.RoleDoctor).Return(&models.Role{ID: 3, Name: models.RoleDoctor}, nil)
+	mocks.identityRepo.On("FindStaffID", mock.Anything, idp.server.URL, "user-123").Return(0, apperrors.NewNotFoundError("identity is not linked to a staff member"))
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "dr.somchai").Return(nil, apperrors.NewNotFoundError("staff member not found"))
+	mocks.staffRepo.On("Create", mock.Anything, mock.MatchedBy(func(staff *models.Staff) bool {
+		return staff.Username == "dr.somchai" && staff.FullName == "Somchai Jaidee" && staff.RoleID == 3 && staff.Password != "" && !staff.MustChangePassword
+	})).Run(func(args mock.Arguments) {
+		staff := args.Get(1).(*models.Staff)
+		staff.ID = 42
+		staff.HospitalID = 1
+		staff.IsActive = true
+	}).Return(nil)
+	mocks.auditService.On("Record", mock.Anything, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionStaffCreate && event.Details["staff_id"] == "42" && event.Details["identity_provider"] == idp.server.URL
+	})).Return(nil)
+	mocks.identityRepo.On("Create", mock.Anything, mock.MatchedBy(func(identity *models.StaffIdentity) bool {
+		return identity.StaffID == 42 && identity.Issuer == idp.server.URL && identity.Subject == "user-123"
+	})).Return(nil)
+	expectSession(mocks, 3)
+
+	// Execute
+	response, err := service.CompleteOIDCLogin(context.Background(), callback)
+
+	// Assert: the normal HMS token is issued for the new staff member
+	require.NoError(t, err)
+	claims, err := utils.ValidateToken(response.Token, mocks.jwtKeys)
+	require.NoError(t, err)
+	assert.Equal(t, 42, claims.UserID)
+	assert.Equal(t, 1, claims.HospitalID)
+	assert.Equal(t, models.RoleDoctor, claims.Role)
+	assert.NotEmpty(t, response.RefreshToken)
+	mocks.staffRepo.AssertExpectations(t)
+	mocks.identityRepo.AssertExpectations(t)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestAuthService_OIDCLogin_SyncsRoleOfLinkedStaff(t *testing.T) {
+	// Setup: a nurse who has since joined the doctors group
+	idp := newStubIdP(t)
+	service, mocks := newOIDCAuthService(t, idp, nil)
+	callback, _ := startOIDCSignIn(t, service, mocks, idp, stubDoctor)
+	staff := &models.Staff{ID: 7, HospitalID: 1, Username: "dr.somchai", RoleID: 2, Role: models.RoleNurse, IsActive: true}
+
+	mocks.roleRepo.On("FindByName", mock.Anything, models.RoleDoctor).Return(&models.Role{ID: 3, Name: models.RoleDoctor}, nil)
+	mocks.identityRepo.On("FindStaffID", mock.Anything, idp.server.URL, "user-123").Return(7, nil)
+	mocks.staffRepo.On("FindByID", mock.Anything, 7).Return(staff, nil)
+	mocks.staffRepo.On("Update", mock.Anything, mock.MatchedBy(func(updated *models.Staff) bool {
+		return updated.ID == 7 && updated.RoleID == 3
+	})).Return(nil)
+	mocks.refreshTokenRepo.On("RevokeAllForStaff", mock.Anything, 7).Return(nil)
+	mocks.auditService.On("Record", mock.Anything, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionStaffUpdate && event.Details["role"] == models.RoleDoctor
+	})).Return(nil)
+	expectSession(mocks, 3)
+
+	// Execute
+	response, err := service.CompleteOIDCLogin(context.Background(), callback)
+
+	// Assert: sessions carrying the old role are revoked and the new one has the new role
+	require.NoError(t, err)
+	claims, err := utils.ValidateToken(response.Token, mocks.jwtKeys)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleDoctor, claims.Role)
+	mocks.refreshTokenRepo.AssertCalled(t, "RevokeAllForStaff", mock.Anything, 7)
+	mocks.staffRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_OIDCLogin_RejectsTamperedSignIn(t *testing.T) {
+	testCases := []struct {
+		name   string
+		tamper func(idp *stubIdP, pending *models.OIDCLoginState)
+	}{
+		{
+			name: "wrong PKCE verifier",
+			tamper: func(idp *stubIdP, pending *models.OIDCLoginState) {
+				pending.CodeVerifier = "not-the-verifier-the-challenge-was-made-from"
+			},
+		},
+		{
+			name: "nonce of another sign-in",
+			tamper: func(idp *stubIdP, pending *models.OIDCLoginState) {
+				pending.Nonce = "another-nonce"
+			},
+		},
+		{
+			name: "ID token for another client",
+			tamper: func(idp *stubIdP, pending *models.OIDCLoginState) {
+				idp.audience = "another-client"
+			},
+		},
+		{
+			name: "expired sign-in",
+			tamper: func(idp *stubIdP, pending *models.OIDCLoginState) {
+				pending.ExpiresAt = time.Now().Add(-time.Second)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			idp := newStubIdP(t)
+			service, mocks := newOIDCAuthService(t, idp, nil)
+			callback, pending := startOIDCSignIn(t, service, mocks, idp, stubDoctor)
+			tc.tamper(idp, pending)
+
+			// Execute
+			_, err := service.CompleteOIDCLogin(context.Background(), callback)
+
+			// Assert
+			assert.True(t, errors.Is(err, apperrors.ErrUnauthorized), "got %v", err)
+			mocks.identityRepo.AssertNotCalled(t, "FindStaffID", mock.Anything, mock.Anything, mock.Anything)
+			mocks.refreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestAuthService_OIDCLogin_StateCanOnlyBeUsedOnce(t *testing.T) {
+	// Setup: the pending sign-in was already redeemed
+	idp := newStubIdP(t)
+	service, mocks := newOIDCAuthService(t, idp, nil)
+	mocks.oidcStateRepo.On("Consume", mock.Anything, utils.HashToken("used-state")).Return(nil, apperrors.NewNotFoundError("sign-in not found"))
+
+	// Execute
+	_, err := service.CompleteOIDCLogin(context.Background(), models.OIDCCallbackRequest{State: "used-state", Code: "code"})
+
+	// Assert
+	assert.True(t, errors.Is(err, apperrors.ErrUnauthorized))
+}
+
+func TestAuthService_OIDCLogin_UnmappedGroupsAreRefused(t *testing.T) {
+	// Setup
+	idp := newStubIdP(t)
+	service, mocks := newOIDCAuthService(t, idp, nil)
+	callback, _ := startOIDCSignIn(t, service, mocks, idp, stubUser{Subject: "user-9", Username: "visitor", Groups: []string{"staff"}})
+
+	// Execute
+	_, err := service.CompleteOIDCLogin(context.Background(), callback)
+
+	// Assert
+	assert.True(t, errors.Is(err, apperrors.ErrForbidden))
+	mocks.staffRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_OIDCLogin_DoesNotTakeOverLocalAccount(t *testing.T) {
+	// Setup: a local account already uses the username and linking is off
+	idp := newStubIdP(t)
+	service, mocks := newOIDCAuthService(t, idp, nil)
+	callback, _ := startOIDCSignIn(t, service, mocks, idp, stubDoctor)
+
+	mocks.roleRepo.On("FindByName", mock.Anything, models.RoleDoctor).Return(&models.Role{ID: 3, Name: models.RoleDoctor}, nil)
+	mocks.identityRepo.On("FindStaffID", mock.Anything, idp.server.URL, "user-123").Return(0, apperrors.NewNotFoundError("identity is not linked to a staff member"))
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "dr.somchai").Return(&models.Staff{ID: 7, Username: "dr.somchai", IsActive: true}, nil)
+
+	// Execute
+	_, err := service.CompleteOIDCLogin(context.Background(), callback)
+
+	// Assert
+	assert.True(t, errors.Is(err, apperrors.ErrConflict))
+	mocks.identityRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_OIDCLogin_MFAStillApplies(t *testing.T) {
+	// Setup: a linked staff member who uses two-factor authentication
+	idp := newStubIdP(t)
+	service, mocks := newOIDCAuthService(t, idp, nil)
+	callback, _ := startOIDCSignIn(t, service, mocks, idp, stubDoctor)
+	staff := &models.Staff{ID: 7, HospitalID: 1, Username: "dr.somchai", RoleID: 3, Role: models.RoleDoctor, IsActive: true, MFAEnabled: true}
+
+	mocks.roleRepo.On("FindByName", mock.Anything, models.RoleDoctor).Return(&models.Role{ID: 3, Name: models.RoleDoctor}, nil)
+	mocks.identityRepo.On("FindStaffID", mock.Anything, idp.server.URL, "user-123").Return(7, nil)
+	mocks.staffRepo.On("FindByID", mock.Anything, 7).Return(staff, nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	// Execute
+	response, err := service.CompleteOIDCLogin(context.Background(), callback)
+
+	// Assert: only an MFA token is issued
+	require.NoError(t, err)
+	assert.True(t, response.MFARequired)
+	assert.NotEmpty(t, response.MFAToken)
+	assert.Empty(t, response.Token)
+	mocks.refreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_StartOIDCLogin_UnconfiguredHospital(t *testing.T) {
+	// Setup
+	idp := newStubIdP(t)
+	service, _ := newOIDCAuthService(t, idp, nil)
+
+	// Execute
+	_, err := service.StartOIDCLogin(context.Background(), models.OIDCLoginRequest{HospitalCode: "H002"})
+
+	// Assert
+	assert.True(t, errors.Is(err, apperrors.ErrNotFound))
+}
@@ -0,0 +1,419 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// authServiceMocks holds the repositories behind an AuthService under test
+type authServiceMocks struct {
+	staffRepo        *MockStaffRepository
+	roleRepo         *MockRoleRepository
+	hospitalRepo     *MockHospitalRepository
+	refreshTokenRepo *MockRefreshTokenRepository
+	loginAttemptRepo *MockLoginAttemptRepository
+	resetTokenRepo   *MockPasswordResetTokenRepository
+	mfaRepo          *MockMFARepository
+	identityRepo     *MockStaffIdentityRepository
+	oidcStateRepo    *MockOIDCLoginStateRepository
+	apiClientRepo    *MockAPIClientRepository
+	auditService     *MockAuditService
+	jwtKeys          *utils.JWTKeySet
+}
+
+// newTestJWTKeys returns a key set with a single new EdDSA signing key
+func newTestJWTKeys(t *testing.T) *utils.JWTKeySet {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwt.json")
+	_, err := utils.AddJWTKey(path, utils.JWTAlgorithmEdDSA, true)
+	require.NoError(t, err)
+	keys, err := utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+	return keys
+}
+
+func newAuthService(t *testing.T, login config.LoginProtectionConfig) (*services.AuthServiceImpl, *authServiceMocks) {
+	t.Helper()
+
+	cfg := newAuthConfig()
+	cfg.Login = login
+	return newAuthServiceWithConfig(t, cfg, nil)
+}
+
+// newAuthConfig returns the configuration auth services under test start from
+func newAuthConfig() *config.Config {
+	return &config.Config{
+		JWT:      config.JWTConfig{AccessTokenTTL: time.Hour, RefreshTokenTTL: 24 * time.Hour},
+		Tenancy:  config.TenancyConfig{DefaultHospitalCode: "H001"},
+		Password: config.PasswordPolicyConfig{MinLength: 12, MinCharacterClasses: 3, HistorySize: 2, ResetTokenTTL: time.Hour},
+		MFA:      config.MFAConfig{Issuer: "HMS", RequiredRoles: []string{models.RoleAdmin}, ChallengeTTL: 5 * time.Minute, RecoveryCodeCount: 4},
+		OIDC:     config.OIDCConfig{StateTTL: 10 * time.Minute},
+	}
+}
+
+func newAuthServiceWithConfig(t *testing.T, cfg *config.Config, oidcProviders map[string]*services.OIDCProvider) (*services.AuthServiceImpl, *authServiceMocks) {
+	t.Helper()
+
+	mocks := &authServiceMocks{
+		staffRepo:        new(MockStaffRepository),
+		roleRepo:         new(MockRoleRepository),
+		hospitalRepo:     new(MockHospitalRepository),
+		refreshTokenRepo: new(MockRefreshTokenRepository),
+		loginAttemptRepo: new(MockLoginAttemptRepository),
+		resetTokenRepo:   new(MockPasswordResetTokenRepository),
+		mfaRepo:          new(MockMFARepository),
+		identityRepo:     new(MockStaffIdentityRepository),
+		oidcStateRepo:    new(MockOIDCLoginStateRepository),
+		apiClientRepo:    new(MockAPIClientRepository),
+		auditService:     new(MockAuditService),
+		jwtKeys:          newTestJWTKeys(t),
+	}
+
+	service := services.NewAuthService(mocks.staffRepo, mocks.roleRepo, mocks.hospitalRepo, mocks.refreshTokenRepo, mocks.loginAttemptRepo,
+		mocks.resetTokenRepo, mocks.mfaRepo, mocks.identityRepo, mocks.oidcStateRepo, mocks.apiClientRepo, oidcProviders, mocks.auditService, mocks.jwtKeys, cfg)
+	return service, mocks
+}
+
+// newLoginStaff returns a staff member whose password is "correct-password"
+func newLoginStaff(t *testing.T) *models.Staff {
+	t.Helper()
+
+	hash, err := utils.HashPassword("correct-password")
+	require.NoError(t, err)
+	return &models.Staff{ID: 7, HospitalID: 1, Username: "nurse1", Password: hash, RoleID: 2, Role: models.RoleNurse, IsActive: true}
+}
+
+func TestAuthService_Login_WrongPasswordLocksAccount(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{MaxFailedAttempts: 5, LockoutDuration: 15 * time.Minute})
+	ctx := utils.WithClientIP(context.Background(), "10.0.0.1")
+	staff := newLoginStaff(t)
+	lockedUntil := time.Now().Add(15 * time.Minute)
+
+	mocks.hospitalRepo.On("FindByCode", ctx, "H001").Return(&models.Hospital{ID: 1, Code: "H001"}, nil)
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "nurse1").Return(staff, nil)
+	mocks.staffRepo.On("RecordFailedLogin", mock.Anything, staff, 5, 15*time.Minute).Run(func(args mock.Arguments) {
+		failed := args.Get(1).(*models.Staff)
+		failed.FailedAttempts = 5
+		failed.LockedUntil = &lockedUntil
+	}).Return(nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.MatchedBy(func(attempt *models.LoginAttempt) bool {
+		return !attempt.Succeeded && attempt.ClientIP == "10.0.0.1" && attempt.Username == "nurse1" &&
+			attempt.HospitalID != nil && *attempt.HospitalID == 1
+	})).Return(nil)
+
+	// Execute
+	_, err := service.Login(ctx, models.StaffLoginRequest{Username: "nurse1", Password: "wrong-password"})
+
+	// Assert
+	assert.True(t, errors.Is(err, apperrors.ErrUnauthorized))
+	mocks.staffRepo.AssertExpectations(t)
+	mocks.loginAttemptRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_LockedAccountIsRefused(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{MaxFailedAttempts: 5, LockoutDuration: 15 * time.Minute, FailureDelay: 20 * time.Millisecond, MaxFailureDelay: time.Second})
+	ctx := context.Background()
+	staff := newLoginStaff(t)
+	lockedUntil := time.Now().Add(10 * time.Minute)
+	staff.FailedAttempts = 5
+	staff.LockedUntil = &lockedUntil
+	hospitalID := 1
+
+	mocks.hospitalRepo.On("FindByCode", ctx, "H001").Return(&models.Hospital{ID: 1, Code: "H001"}, nil)
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "nurse1").Return(staff, nil)
+	mocks.loginAttemptRepo.On("CountFailuresByUsername", mock.Anything, &hospitalID, "nurse1", mock.AnythingOfType("time.Time")).Return(2, nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.MatchedBy(func(attempt *models.LoginAttempt) bool {
+		return !attempt.Succeeded && attempt.Username == "nurse1"
+	})).Return(nil)
+
+	// Execute: even the correct password is refused
+	start := time.Now()
+	_, err := service.Login(ctx, models.StaffLoginRequest{Username: "nurse1", Password: "correct-password"})
+
+	// Assert: answered like an unknown username, so the lockout doesn't reveal the account
+	assert.True(t, errors.Is(err, apperrors.ErrUnauthorized))
+	assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+	mocks.loginAttemptRepo.AssertExpectations(t)
+	mocks.staffRepo.AssertNotCalled(t, "RecordFailedLogin", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mocks.refreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Login_TooManyFailuresFromIP(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{IPMaxFailedAttempts: 20, IPWindow: 15 * time.Minute})
+	ctx := utils.WithClientIP(context.Background(), "10.0.0.1")
+
+	mocks.loginAttemptRepo.On("CountFailuresByIP", ctx, "10.0.0.1", mock.AnythingOfType("time.Time")).Return(20, nil)
+
+	// Execute
+	_, err := service.Login(ctx, models.StaffLoginRequest{Username: "nurse1", Password: "correct-password"})
+
+	// Assert
+	assert.True(t, errors.Is(err, apperrors.ErrTooManyRequests))
+	mocks.hospitalRepo.AssertNotCalled(t, "FindByCode", mock.Anything, mock.Anything)
+	mocks.loginAttemptRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_UnknownUserIsDelayed(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{LockoutDuration: 15 * time.Minute, FailureDelay: 20 * time.Millisecond, MaxFailureDelay: time.Second})
+	ctx := context.Background()
+	hospitalID := 1
+
+	mocks.hospitalRepo.On("FindByCode", ctx, "H001").Return(&models.Hospital{ID: 1, Code: "H001"}, nil)
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "nobody").Return(nil, apperrors.NewNotFoundError("staff member not found"))
+	mocks.loginAttemptRepo.On("CountFailuresByUsername", mock.Anything, &hospitalID, "nobody", mock.AnythingOfType("time.Time")).Return(2, nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.MatchedBy(func(attempt *models.LoginAttempt) bool {
+		return !attempt.Succeeded && attempt.Username == "nobody"
+	})).Return(nil)
+
+	// Execute
+	start := time.Now()
+	_, err := service.Login(ctx, models.StaffLoginRequest{Username: "nobody", Password: "whatever"})
+
+	// Assert: the caller can't tell an unknown username from a wrong password; the third
+	// failure in a row waits as long as it would for a real account
+	assert.True(t, errors.Is(err, apperrors.ErrUnauthorized))
+	assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+	mocks.loginAttemptRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_UnknownHospitalIsRefused(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := context.Background()
+
+	mocks.hospitalRepo.On("FindByCode", ctx, "H999").Return(nil, apperrors.NewNotFoundError("hospital not found"))
+	mocks.loginAttemptRepo.On("CountFailuresByUsername", mock.Anything, (*int)(nil), "nurse1", mock.AnythingOfType("time.Time")).Return(0, nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	// Execute
+	_, err := service.Login(ctx, models.StaffLoginRequest{HospitalCode: "H999", Username: "nurse1", Password: "whatever"})
+
+	// Assert
+	assert.True(t, errors.Is(err, apperrors.ErrUnauthorized))
+	mocks.loginAttemptRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_LookupFailureIsNotACredentialFailure(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{FailureDelay: time.Second})
+	ctx := context.Background()
+
+	mocks.hospitalRepo.On("FindByCode", ctx, "H001").Return(&models.Hospital{ID: 1, Code: "H001"}, nil)
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "nurse1").Return(nil, apperrors.NewInternalServerError(errors.New("connection refused")))
+
+	// Execute
+	_, err := service.Login(ctx, models.StaffLoginRequest{Username: "nurse1", Password: "correct-password"})
+
+	// Assert: the error is returned as is, without recording a failed login
+	assert.True(t, errors.Is(err, apperrors.ErrInternalServer))
+	mocks.loginAttemptRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Login_DeactivatedStaffIsRefused(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := context.Background()
+	staff := newLoginStaff(t)
+	staff.IsActive = false
+
+	mocks.hospitalRepo.On("FindByCode", ctx, "H001").Return(&models.Hospital{ID: 1, Code: "H001"}, nil)
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "nurse1").Return(staff, nil)
+	mocks.loginAttemptRepo.On("CountFailuresByUsername", mock.Anything, mock.Anything, "nurse1", mock.AnythingOfType("time.Time")).Return(0, nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.MatchedBy(func(attempt *models.LoginAttempt) bool {
+		return !attempt.Succeeded
+	})).Return(nil)
+
+	// Execute: with the right password
+	_, err := service.Login(ctx, models.StaffLoginRequest{Username: "nurse1", Password: "correct-password"})
+
+	// Assert: refused like an unknown username
+	assert.True(t, errors.Is(err, apperrors.ErrUnauthorized))
+	mocks.refreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Login_SuccessResetsFailures(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{MaxFailedAttempts: 5, LockoutDuration: 15 * time.Minute})
+	ctx := context.Background()
+	staff := newLoginStaff(t)
+	staff.FailedAttempts = 2
+
+	mocks.hospitalRepo.On("FindByCode", ctx, "H001").Return(&models.Hospital{ID: 1, Code: "H001"}, nil)
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "nurse1").Return(staff, nil)
+	mocks.staffRepo.On("ResetFailedLogins", mock.Anything, 7).Return(nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.MatchedBy(func(attempt *models.LoginAttempt) bool {
+		return attempt.Succeeded
+	})).Return(nil)
+	mocks.roleRepo.On("FindPermissionsByRoleID", mock.Anything, 2).Return([]string{models.PermissionPatientRead}, nil)
+	mocks.refreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	// Execute
+	response, err := service.Login(ctx, models.StaffLoginRequest{Username: "nurse1", Password: "correct-password"})
+
+	// Assert
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.Token)
+	mocks.staffRepo.AssertExpectations(t)
+	mocks.loginAttemptRepo.AssertExpectations(t)
+	mocks.refreshTokenRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_MustChangePassword(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := context.Background()
+	staff := newLoginStaff(t)
+	staff.MustChangePassword = true
+
+	mocks.hospitalRepo.On("FindByCode", ctx, "H001").Return(&models.Hospital{ID: 1, Code: "H001"}, nil)
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "nurse1").Return(staff, nil)
+	mocks.loginAttemptRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	mocks.roleRepo.On("FindPermissionsByRoleID", mock.Anything, 2).Return([]string{models.PermissionPatientRead}, nil)
+	mocks.refreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	// Execute
+	response, err := service.Login(ctx, models.StaffLoginRequest{Username: "nurse1", Password: "correct-password"})
+
+	// Assert: the token is limited to changing the password
+	require.NoError(t, err)
+	assert.True(t, response.PasswordChangeRequired)
+
+	mocks.refreshTokenRepo.On("IsAccessTokenRevoked", mock.Anything, mock.Anything).Return(false, nil)
+	claims, err := service.ValidateToken(ctx, response.Token)
+	require.NoError(t, err)
+	assert.True(t, claims.PasswordChangeRequired)
+}
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := utils.WithHospitalID(context.Background(), 1)
+	staff := newLoginStaff(t)
+	staff.MustChangePassword = true
+	claims := &utils.JWTClaims{UserID: 7, HospitalID: 1, PasswordChangeRequired: true}
+
+	oldHash, err := utils.HashPassword("Previous-Pass-1")
+	require.NoError(t, err)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(staff, nil)
+	mocks.staffRepo.On("FindPasswordHistory", ctx, 7, 2).Return([]string{oldHash}, nil)
+	mocks.staffRepo.On("UpdatePassword", ctx, 7, mock.MatchedBy(func(hash string) bool {
+		return utils.CheckPasswordHash("Tangerine-Kite-42", hash)
+	}), false, 2).Return(nil)
+	mocks.refreshTokenRepo.On("RevokeAllForStaff", ctx, 7).Return(nil)
+	mocks.roleRepo.On("FindPermissionsByRoleID", ctx, 2).Return([]string{models.PermissionPatientRead}, nil)
+	mocks.refreshTokenRepo.On("Create", ctx, mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	// Execute
+	response, err := service.ChangePassword(ctx, claims, models.PasswordChangeRequest{
+		CurrentPassword: "correct-password",
+		NewPassword:     "Tangerine-Kite-42",
+	})
+
+	// Assert: the new session is no longer limited to changing the password
+	require.NoError(t, err)
+	assert.False(t, response.PasswordChangeRequired)
+	mocks.staffRepo.AssertExpectations(t)
+	mocks.refreshTokenRepo.AssertExpectations(t)
+}
+
+func TestAuthService_ChangePassword_RejectsReuse(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := utils.WithHospitalID(context.Background(), 1)
+	staff := newLoginStaff(t)
+
+	oldHash, err := utils.HashPassword("Previous-Pass-1")
+	require.NoError(t, err)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(staff, nil)
+	mocks.staffRepo.On("FindPasswordHistory", ctx, 7, 2).Return([]string{oldHash}, nil)
+
+	// Execute
+	_, err = service.ChangePassword(ctx, &utils.JWTClaims{UserID: 7}, models.PasswordChangeRequest{
+		CurrentPassword: "correct-password",
+		NewPassword:     "Previous-Pass-1",
+	})
+
+	// Assert
+	assert.IsType(t, &services.ValidationError{}, err)
+	mocks.staffRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_ChangePassword_WrongCurrentPassword(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(newLoginStaff(t), nil)
+
+	// Execute
+	_, err := service.ChangePassword(ctx, &utils.JWTClaims{UserID: 7}, models.PasswordChangeRequest{
+		CurrentPassword: "wrong-password",
+		NewPassword:     "Tangerine-Kite-42",
+	})
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrInvalidInput)
+}
+
+func TestAuthService_ResetPassword(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+	staff := newLoginStaff(t)
+	lockedUntil := time.Now().Add(time.Minute)
+	staff.FailedAttempts = 5
+	staff.LockedUntil = &lockedUntil
+
+	mocks.resetTokenRepo.On("FindByHash", mock.Anything, utils.HashToken("reset-token")).Return(&models.PasswordResetToken{
+		ID: 3, StaffID: 7, HospitalID: 1, ExpiresAt: time.Now().Add(time.Hour),
+	}, nil)
+	mocks.staffRepo.On("FindByID", mock.Anything, 7).Return(staff, nil)
+	mocks.staffRepo.On("FindPasswordHistory", mock.Anything, 7, 2).Return([]string{}, nil)
+	mocks.resetTokenRepo.On("MarkUsed", mock.Anything, 3).Return(nil)
+	mocks.staffRepo.On("UpdatePassword", mock.Anything, 7, mock.AnythingOfType("string"), false, 2).Return(nil)
+	mocks.refreshTokenRepo.On("RevokeAllForStaff", mock.Anything, 7).Return(nil)
+	mocks.staffRepo.On("ResetFailedLogins", mock.Anything, 7).Return(nil)
+
+	// Execute
+	err := service.ResetPassword(context.Background(), models.PasswordResetRequest{Token: "reset-token", NewPassword: "Tangerine-Kite-42"})
+
+	// Assert
+	assert.NoError(t, err)
+	mocks.staffRepo.AssertExpectations(t)
+	mocks.resetTokenRepo.AssertExpectations(t)
+	mocks.refreshTokenRepo.AssertExpectations(t)
+}
+
+func TestAuthService_ResetPassword_ExpiredToken(t *testing.T) {
+	// Setup
+	service, mocks := newAuthService(t, config.LoginProtectionConfig{})
+
+	mocks.resetTokenRepo.On("FindByHash", mock.Anything, utils.HashToken("reset-token")).Return(&models.PasswordResetToken{
+		ID: 3, StaffID: 7, HospitalID: 1, ExpiresAt: time.Now().Add(-time.Minute),
+	}, nil)
+
+	// Execute
+	err := service.ResetPassword(context.Background(), models.PasswordResetRequest{Token: "reset-token", NewPassword: "Tangerine-Kite-42"})
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrUnauthorized)
+	mocks.resetTokenRepo.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,147 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// newHospitalServer starts a fake hospital API that knows a single patient
+func newHospitalServer(t *testing.T, patient *models.PatientSearchResponse) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if patient == nil || r.URL.Path != "/patient/search/"+patient.NationalID {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(patient)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newRegistry builds a live registry pointing at the given fake hospitals
+func newRegistry(t *testing.T, servers map[string]*httptest.Server, names ...string) *services.HospitalAPIRegistry {
+	cfg := config.HospitalAPIConfig{Mode: "live"}
+	for _, name := range names {
+		cfg.Hospitals = append(cfg.Hospitals, config.HospitalEndpointConfig{
+			Name:    name,
+			BaseURL: servers[name].URL,
+			Timeout: time.Second,
+		})
+	}
+
+	registry, err := services.NewHospitalAPIRegistryFromConfig(cfg)
+	assert.NoError(t, err)
+	return registry
+}
+
+func TestHospitalAPIRegistry_SearchPatient_NamedHospital(t *testing.T) {
+	// Setup
+	servers := map[string]*httptest.Server{
+		"hospital-a": newHospitalServer(t, nil),
+		"hospital-b": newHospitalServer(t, &models.PatientSearchResponse{NationalID: "1234567890123", PatientHN: "HN-B"}),
+	}
+	registry := newRegistry(t, servers, "hospital-a", "hospital-b")
+
+	// Execute
+	patient, err := registry.SearchPatient(context.Background(), "hospital-b", "1234567890123")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "HN-B", patient.PatientHN)
+	assert.Equal(t, "hospital-b", patient.SourceHospital)
+}
+
+func TestHospitalAPIRegistry_SearchPatient_UnknownHospital(t *testing.T) {
+	// Setup
+	registry := services.NewHospitalAPIRegistry()
+
+	// Execute
+	patient, err := registry.SearchPatient(context.Background(), "hospital-z", "1234567890123")
+
+	// Assert
+	assert.Nil(t, patient)
+	assert.True(t, errors.Is(err, apperrors.ErrInvalidInput))
+}
+
+func TestHospitalAPIRegistry_SearchAll_MergesResults(t *testing.T) {
+	// Setup
+	servers := map[string]*httptest.Server{
+		"hospital-a": newHospitalServer(t, &models.PatientSearchResponse{NationalID: "1234567890123", PatientHN: "HN-A"}),
+		"hospital-b": newHospitalServer(t, &models.PatientSearchResponse{NationalID: "1234567890123", PatientHN: "HN-B", PhoneNumber: "0812345678"}),
+	}
+	registry := newRegistry(t, servers, "hospital-a", "hospital-b")
+
+	// Execute
+	patient, err := registry.SearchAll(context.Background(), "1234567890123")
+
+	// Assert: hospital-a provides the record, hospital-b fills in the missing phone number
+	assert.NoError(t, err)
+	assert.Equal(t, "HN-A", patient.PatientHN)
+	assert.Equal(t, "0812345678", patient.PhoneNumber)
+	assert.Equal(t, "hospital-a", patient.SourceHospital)
+}
+
+func TestHospitalAPIRegistry_SearchAll_KeepsProvidingHospitalHN(t *testing.T) {
+	// Setup: hospital-a knows the patient but sends no HN
+	servers := map[string]*httptest.Server{
+		"hospital-a": newHospitalServer(t, &models.PatientSearchResponse{NationalID: "1234567890123", FirstNameEN: "Somchai"}),
+		"hospital-b": newHospitalServer(t, &models.PatientSearchResponse{NationalID: "1234567890123", PatientHN: "HN-B", LastNameEN: "Jaidee"}),
+	}
+	registry := newRegistry(t, servers, "hospital-a", "hospital-b")
+
+	// Execute
+	patient, err := registry.SearchAll(context.Background(), "1234567890123")
+
+	// Assert: hospital-b's HN is not cached as if it were hospital-a's
+	assert.NoError(t, err)
+	assert.Equal(t, "hospital-a", patient.SourceHospital)
+	assert.Empty(t, patient.PatientHN)
+	assert.Equal(t, "Jaidee", patient.LastNameEN)
+}
+
+func TestHospitalAPIRegistry_SearchAll_NotFound(t *testing.T) {
+	// Setup
+	servers := map[string]*httptest.Server{
+		"hospital-a": newHospitalServer(t, nil),
+		"hospital-b": newHospitalServer(t, nil),
+	}
+	registry := newRegistry(t, servers, "hospital-a", "hospital-b")
+
+	// Execute
+	patient, err := registry.SearchAll(context.Background(), "1234567890123")
+
+	// Assert
+	assert.Nil(t, patient)
+	assert.True(t, errors.Is(err, apperrors.ErrNotFound))
+}
+
+func TestHospitalAPIRegistry_SearchAll_UpstreamFailure(t *testing.T) {
+	// Setup: one hospital doesn't know the patient, the other is down
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+	servers := map[string]*httptest.Server{
+		"hospital-a": newHospitalServer(t, nil),
+		"hospital-b": failing,
+	}
+	registry := newRegistry(t, servers, "hospital-a", "hospital-b")
+
+	// Execute
+	patient, err := registry.SearchAll(context.Background(), "1234567890123")
+
+	// Assert
+	assert.Nil(t, patient)
+	assert.True(t, errors.Is(err, apperrors.ErrExternalAPI))
+}
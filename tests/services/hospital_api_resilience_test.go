@@ -0,0 +1,216 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFlakyHospitalServer starts a fake hospital API that answers with the given statuses in turn,
+// then serves the patient; it counts the calls it receives
+func newFlakyHospitalServer(t *testing.T, calls *int32, statuses ...int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(atomic.AddInt32(calls, 1))
+		if call <= len(statuses) {
+			w.WriteHeader(statuses[call-1])
+			return
+		}
+		_ = json.NewEncoder(w).Encode(models.PatientSearchResponse{NationalID: "1234567890123", PatientHN: "HN12345"})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newResilientService creates an HTTP hospital adapter with fast retries and the given breaker threshold
+func newResilientService(server *httptest.Server, maxRetries, failureThreshold int, cooldown time.Duration) *services.HTTPHospitalAPIService {
+	return services.NewHTTPHospitalAPIService(config.HospitalEndpointConfig{
+		Name:    "hospital-a",
+		BaseURL: server.URL,
+		Timeout: time.Second,
+		Retry: config.RetryConfig{
+			MaxRetries: maxRetries,
+			BaseDelay:  time.Millisecond,
+			MaxDelay:   5 * time.Millisecond,
+		},
+		Breaker: config.CircuitBreakerConfig{
+			FailureThreshold: failureThreshold,
+			Cooldown:         cooldown,
+		},
+	})
+}
+
+func TestHTTPHospitalAPIService_RetriesServerErrors(t *testing.T) {
+	// Setup
+	var calls int32
+	server := newFlakyHospitalServer(t, &calls, http.StatusServiceUnavailable, http.StatusBadGateway)
+	service := newResilientService(server, 2, 0, 0)
+
+	// Execute
+	patient, err := service.SearchPatient(context.Background(), "1234567890123")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "HN12345", patient.PatientHN)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestHTTPHospitalAPIService_GivesUpAfterMaxRetries(t *testing.T) {
+	// Setup
+	var calls int32
+	server := newFlakyHospitalServer(t, &calls, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	service := newResilientService(server, 1, 0, 0)
+
+	// Execute
+	patient, err := service.SearchPatient(context.Background(), "1234567890123")
+
+	// Assert
+	assert.Nil(t, patient)
+	assert.True(t, errors.Is(err, apperrors.ErrExternalAPI))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestHTTPHospitalAPIService_DoesNotRetryNotFound(t *testing.T) {
+	// Setup
+	var calls int32
+	server := newFlakyHospitalServer(t, &calls, http.StatusNotFound)
+	service := newResilientService(server, 2, 1, time.Minute)
+
+	// Execute
+	patient, err := service.SearchPatient(context.Background(), "1234567890123")
+
+	// Assert
+	assert.Nil(t, patient)
+	assert.True(t, errors.Is(err, apperrors.ErrNotFound))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, models.CircuitClosed, service.Health().Circuit)
+}
+
+func TestHTTPHospitalAPIService_CircuitBreaker(t *testing.T) {
+	// Setup: two failing calls open the circuit
+	var calls int32
+	server := newFlakyHospitalServer(t, &calls, http.StatusInternalServerError, http.StatusInternalServerError)
+	service := newResilientService(server, 0, 2, 50*time.Millisecond)
+
+	_, _ = service.SearchPatient(context.Background(), "1234567890123")
+	_, _ = service.SearchPatient(context.Background(), "1234567890123")
+	assert.Equal(t, models.CircuitOpen, service.Health().Circuit)
+	assert.Equal(t, 2, service.Health().ConsecutiveFailures)
+
+	// Execute: an open circuit fails fast without calling the hospital
+	patient, err := service.SearchPatient(context.Background(), "1234567890123")
+
+	// Assert
+	assert.Nil(t, patient)
+	assert.True(t, errors.Is(err, apperrors.ErrUnavailable))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// Execute: after the cooldown a successful trial call closes the circuit
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, models.CircuitHalfOpen, service.Health().Circuit)
+	patient, err = service.SearchPatient(context.Background(), "1234567890123")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "HN12345", patient.PatientHN)
+	assert.Equal(t, models.CircuitClosed, service.Health().Circuit)
+	assert.Equal(t, 0, service.Health().ConsecutiveFailures)
+}
+
+func TestHTTPHospitalAPIService_StopsAtContextDeadline(t *testing.T) {
+	// Setup: a hospital API slower than the request budget
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	service := newResilientService(server, 3, 0, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Execute
+	start := time.Now()
+	patient, err := service.SearchPatient(ctx, "1234567890123")
+
+	// Assert: the call gives up with the request and doesn't retry past the deadline
+	assert.Nil(t, patient)
+	assert.True(t, errors.Is(err, apperrors.ErrExternalAPI))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHTTPHospitalAPIService_CancelledCallerDoesNotTripBreaker(t *testing.T) {
+	t.Run("Closed", func(t *testing.T) {
+		// Setup
+		var calls int32
+		server := newFlakyHospitalServer(t, &calls)
+		service := newResilientService(server, 0, 1, time.Minute)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Execute
+		_, err := service.SearchPatient(ctx, "1234567890123")
+
+		// Assert
+		assert.Error(t, err)
+		assert.Equal(t, models.CircuitClosed, service.Health().Circuit)
+	})
+
+	t.Run("Half Open", func(t *testing.T) {
+		// Setup: one failure opens the circuit, then the cooldown passes
+		var calls int32
+		server := newFlakyHospitalServer(t, &calls, http.StatusServiceUnavailable)
+		service := newResilientService(server, 0, 1, 20*time.Millisecond)
+		_, _ = service.SearchPatient(context.Background(), "1234567890123")
+		time.Sleep(30 * time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Execute: the trial call is cancelled, then another call comes in
+		_, cancelledErr := service.SearchPatient(ctx, "1234567890123")
+		patient, err := service.SearchPatient(context.Background(), "1234567890123")
+
+		// Assert: the cancelled trial gave its slot back
+		assert.Error(t, cancelledErr)
+		require.NoError(t, err)
+		assert.Equal(t, "HN12345", patient.PatientHN)
+		assert.Equal(t, models.CircuitClosed, service.Health().Circuit)
+	})
+}
+
+func TestHTTPHospitalAPIService_ForwardsRequestID(t *testing.T) {
+	// Setup
+	var requestID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = r.Header.Get("X-Request-ID")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+	service := newResilientService(server, 0, 0, 0)
+
+	// Execute
+	_, _ = service.SearchPatient(utils.WithRequestID(context.Background(), "req-123"), "1234567890123")
+
+	// Assert
+	assert.Equal(t, "req-123", requestID)
+}
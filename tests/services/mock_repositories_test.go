@@ -0,0 +1,464 @@
+package services_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPatientRepository is a mock implementation of the PatientRepository interface
+type MockPatientRepository struct {
+	mock.Mock
+}
+
+func (m *MockPatientRepository) Create(ctx context.Context, patient *models.Patient) error {
+	args := m.Called(ctx, patient)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepository) FindByID(ctx context.Context, id int) (*models.Patient, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Patient), args.Error(1)
+}
+
+func (m *MockPatientRepository) FindByNationalID(ctx context.Context, nationalID string) (*models.Patient, error) {
+	args := m.Called(ctx, nationalID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Patient), args.Error(1)
+}
+
+func (m *MockPatientRepository) FindByPassportID(ctx context.Context, passportID string) (*models.Patient, error) {
+	args := m.Called(ctx, passportID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Patient), args.Error(1)
+}
+
+func (m *MockPatientRepository) FindByMergedID(ctx context.Context, mergedID int) (*models.Patient, error) {
+	args := m.Called(ctx, mergedID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Patient), args.Error(1)
+}
+
+func (m *MockPatientRepository) Search(ctx context.Context, query models.PatientQueryRequest) ([]*models.Patient, int, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]*models.Patient), args.Int(1), args.Error(2)
+}
+
+func (m *MockPatientRepository) FuzzySearch(ctx context.Context, query models.PatientFuzzySearchRequest) ([]*models.PatientMatch, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.PatientMatch), args.Error(1)
+}
+
+func (m *MockPatientRepository) FindStale(ctx context.Context, sourceHospital string, syncedBefore time.Time, limit int) ([]*models.Patient, error) {
+	args := m.Called(ctx, sourceHospital, syncedBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Patient), args.Error(1)
+}
+
+func (m *MockPatientRepository) Upsert(ctx context.Context, patient *models.Patient) error {
+	args := m.Called(ctx, patient)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepository) MarkSynced(ctx context.Context, id int, syncedAt time.Time) error {
+	args := m.Called(ctx, id, syncedAt)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepository) Update(ctx context.Context, patient *models.Patient) error {
+	args := m.Called(ctx, patient)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepository) Delete(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepository) Reencrypt(ctx context.Context, batchSize int) (int, error) {
+	args := m.Called(ctx, batchSize)
+	return args.Int(0), args.Error(1)
+}
+
+// MockHospitalRepository is a mock implementation of the HospitalRepository interface
+type MockHospitalRepository struct {
+	mock.Mock
+}
+
+func (m *MockHospitalRepository) FindByCode(ctx context.Context, code string) (*models.Hospital, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Hospital), args.Error(1)
+}
+
+func (m *MockHospitalRepository) FindByID(ctx context.Context, id int) (*models.Hospital, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Hospital), args.Error(1)
+}
+
+func (m *MockHospitalRepository) FindAll(ctx context.Context) ([]*models.Hospital, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Hospital), args.Error(1)
+}
+
+// MockStaffRepository is a mock implementation of the StaffRepository interface
+type MockStaffRepository struct {
+	mock.Mock
+}
+
+func (m *MockStaffRepository) Create(ctx context.Context, staff *models.Staff) error {
+	args := m.Called(ctx, staff)
+	return args.Error(0)
+}
+
+func (m *MockStaffRepository) FindByUsername(ctx context.Context, username string) (*models.Staff, error) {
+	args := m.Called(ctx, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Staff), args.Error(1)
+}
+
+func (m *MockStaffRepository) FindByID(ctx context.Context, id int) (*models.Staff, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Staff), args.Error(1)
+}
+
+func (m *MockStaffRepository) Search(ctx context.Context, query models.StaffQueryRequest) ([]*models.Staff, int, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*models.Staff), args.Int(1), args.Error(2)
+}
+
+func (m *MockStaffRepository) Update(ctx context.Context, staff *models.Staff) error {
+	args := m.Called(ctx, staff)
+	return args.Error(0)
+}
+
+func (m *MockStaffRepository) Delete(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockStaffRepository) RecordFailedLogin(ctx context.Context, staff *models.Staff, maxAttempts int, lockout time.Duration) error {
+	args := m.Called(ctx, staff, maxAttempts, lockout)
+	return args.Error(0)
+}
+
+func (m *MockStaffRepository) ResetFailedLogins(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockStaffRepository) UpdatePassword(ctx context.Context, id int, passwordHash string, mustChange bool, historySize int) error {
+	args := m.Called(ctx, id, passwordHash, mustChange, historySize)
+	return args.Error(0)
+}
+
+func (m *MockStaffRepository) FindPasswordHistory(ctx context.Context, id int, limit int) ([]string, error) {
+	args := m.Called(ctx, id, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockStaffRepository) CountByRole(ctx context.Context, role string) (int, error) {
+	args := m.Called(ctx, role)
+	return args.Int(0), args.Error(1)
+}
+
+// MockRoleRepository is a mock implementation of the RoleRepository interface
+type MockRoleRepository struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepository) FindByName(ctx context.Context, name string) (*models.Role, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) FindByID(ctx context.Context, id int) (*models.Role, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) FindPermissionsByRoleID(ctx context.Context, roleID int) ([]string, error) {
+	args := m.Called(ctx, roleID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// MockRefreshTokenRepository is a mock implementation of the RefreshTokenRepository interface
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) Rotate(ctx context.Context, oldID int, next *models.RefreshToken) error {
+	args := m.Called(ctx, oldID, next)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeByAccessJTI(ctx context.Context, accessJTI string) error {
+	args := m.Called(ctx, accessJTI)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeAllForStaff(ctx context.Context, staffID int) error {
+	args := m.Called(ctx, staffID)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) IsAccessTokenRevoked(ctx context.Context, accessJTI string) (bool, error) {
+	args := m.Called(ctx, accessJTI)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockLoginAttemptRepository is a mock implementation of the LoginAttemptRepository interface
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptRepository) Create(ctx context.Context, attempt *models.LoginAttempt) error {
+	args := m.Called(ctx, attempt)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) CountFailuresByIP(ctx context.Context, clientIP string, since time.Time) (int, error) {
+	args := m.Called(ctx, clientIP, since)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) CountFailuresByUsername(ctx context.Context, hospitalID *int, username string, since time.Time) (int, error) {
+	args := m.Called(ctx, hospitalID, username, since)
+	return args.Int(0), args.Error(1)
+}
+
+// MockPasswordResetTokenRepository is a mock implementation of the PasswordResetTokenRepository interface
+type MockPasswordResetTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockPasswordResetTokenRepository) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockPasswordResetTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PasswordResetToken), args.Error(1)
+}
+
+func (m *MockPasswordResetTokenRepository) MarkUsed(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MockMFARepository is a mock implementation of the MFARepository interface
+type MockMFARepository struct {
+	mock.Mock
+}
+
+func (m *MockMFARepository) FindByStaffID(ctx context.Context, staffID int) (*models.StaffMFA, error) {
+	args := m.Called(ctx, staffID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StaffMFA), args.Error(1)
+}
+
+func (m *MockMFARepository) CreatePending(ctx context.Context, staffID int, secret string) error {
+	args := m.Called(ctx, staffID, secret)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) Enable(ctx context.Context, staffID int, counter int64, recoveryCodeHashes []string) error {
+	args := m.Called(ctx, staffID, counter, recoveryCodeHashes)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) UseCounter(ctx context.Context, staffID int, counter int64) error {
+	args := m.Called(ctx, staffID, counter)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) UseRecoveryCode(ctx context.Context, staffID int, codeHash string) error {
+	args := m.Called(ctx, staffID, codeHash)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) ReplaceRecoveryCodes(ctx context.Context, staffID int, codeHashes []string) error {
+	args := m.Called(ctx, staffID, codeHashes)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) Delete(ctx context.Context, staffID int) error {
+	args := m.Called(ctx, staffID)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) Reencrypt(ctx context.Context, batchSize int) (int, error) {
+	args := m.Called(ctx, batchSize)
+	return args.Int(0), args.Error(1)
+}
+
+// MockStaffInviteRepository is a mock implementation of the StaffInviteRepository interface
+type MockStaffInviteRepository struct {
+	mock.Mock
+}
+
+func (m *MockStaffInviteRepository) Create(ctx context.Context, invite *models.StaffInvite) error {
+	args := m.Called(ctx, invite)
+	return args.Error(0)
+}
+
+func (m *MockStaffInviteRepository) FindByHash(ctx context.Context, tokenHash string) (*models.StaffInvite, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StaffInvite), args.Error(1)
+}
+
+func (m *MockStaffInviteRepository) MarkAccepted(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MockStaffIdentityRepository is a mock implementation of the StaffIdentityRepository interface
+type MockStaffIdentityRepository struct {
+	mock.Mock
+}
+
+func (m *MockStaffIdentityRepository) Create(ctx context.Context, identity *models.StaffIdentity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+func (m *MockStaffIdentityRepository) FindStaffID(ctx context.Context, issuer, subject string) (int, error) {
+	args := m.Called(ctx, issuer, subject)
+	return args.Int(0), args.Error(1)
+}
+
+// MockOIDCLoginStateRepository is a mock implementation of the OIDCLoginStateRepository interface
+type MockOIDCLoginStateRepository struct {
+	mock.Mock
+}
+
+func (m *MockOIDCLoginStateRepository) Create(ctx context.Context, state *models.OIDCLoginState) error {
+	args := m.Called(ctx, state)
+	return args.Error(0)
+}
+
+func (m *MockOIDCLoginStateRepository) Consume(ctx context.Context, stateHash string) (*models.OIDCLoginState, error) {
+	args := m.Called(ctx, stateHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OIDCLoginState), args.Error(1)
+}
+
+// MockAPIClientRepository is a mock implementation of the APIClientRepository interface
+type MockAPIClientRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIClientRepository) Create(ctx context.Context, client *models.APIClient) error {
+	args := m.Called(ctx, client)
+	return args.Error(0)
+}
+
+func (m *MockAPIClientRepository) FindByID(ctx context.Context, id int) (*models.APIClient, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIClient), args.Error(1)
+}
+
+func (m *MockAPIClientRepository) FindByHash(ctx context.Context, keyHash string) (*models.APIClient, error) {
+	args := m.Called(ctx, keyHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIClient), args.Error(1)
+}
+
+func (m *MockAPIClientRepository) List(ctx context.Context) ([]*models.APIClient, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.APIClient), args.Error(1)
+}
+
+func (m *MockAPIClientRepository) Revoke(ctx context.Context, client *models.APIClient) error {
+	args := m.Called(ctx, client)
+	return args.Error(0)
+}
+
+func (m *MockAPIClientRepository) TouchLastUsed(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
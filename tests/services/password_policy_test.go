@@ -0,0 +1,42 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePassword(t *testing.T) {
+	policy := config.PasswordPolicyConfig{MinLength: 12, MinCharacterClasses: 3}
+
+	cases := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"strong", "Tangerine-Kite-42", true},
+		{"too short", "Ab1!xyz", false},
+		{"too few character classes", "tangerinekitelamp", false},
+		{"contains username", "Nurse1-Tangerine!", false},
+		{"common password", "Password1234", false},
+		{"common password with suffix", "Welcome2025!!", false},
+		{"too long for bcrypt", "Aa1!" + string(make([]byte, 72)), false},
+		{"caseless letters count as lowercase", "สวัสดีครับ-2025", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Execute
+			err := services.ValidatePassword(policy, "nurse1", tc.password)
+
+			// Assert
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, &services.ValidationError{}, err)
+			}
+		})
+	}
+}
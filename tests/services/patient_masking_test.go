@@ -0,0 +1,213 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newUnmaskedPatient returns a patient record as stored, with its PII in full
+func newUnmaskedPatient() *models.Patient {
+	return &models.Patient{
+		ID:          10,
+		NationalID:  "1234567890121",
+		PassportID:  "AB1234567",
+		PhoneNumber: "0812345678",
+		Email:       "somchai@example.com",
+		PatientHN:   "HN12345",
+	}
+}
+
+func TestPatientService_GetPatient_MasksPII(t *testing.T) {
+	// Setup
+	patientRepo := new(MockPatientRepository)
+	auditService := new(MockAuditService)
+	service := services.NewPatientService(patientRepo, nil, nil, auditService)
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	patientRepo.On("FindByID", ctx, 10).Return(newUnmaskedPatient(), nil)
+	auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionPatientRead && event.Details == nil
+	})).Return(nil)
+
+	// Execute
+	patient, err := service.GetPatient(ctx, 10)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "1-2345-xxxxx-12-1", patient.NationalID)
+	assert.Equal(t, "xxxxxx567", patient.PassportID)
+	assert.Equal(t, "xxxxxx5678", patient.PhoneNumber)
+	assert.Equal(t, "sxxxxxx@example.com", patient.Email)
+	assert.Equal(t, "HN12345", patient.PatientHN)
+	auditService.AssertExpectations(t)
+}
+
+func TestPatientService_GetPatient_UnmaskedByRole(t *testing.T) {
+	// Setup
+	patientRepo := new(MockPatientRepository)
+	auditService := new(MockAuditService)
+	service := services.NewPatientService(patientRepo, nil, nil, auditService)
+	ctx := utils.WithPIIAccess(utils.WithHospitalID(context.Background(), 1), utils.PIIAccess{Unmasked: true})
+
+	patientRepo.On("FindByID", ctx, 10).Return(newUnmaskedPatient(), nil)
+	auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		_, brokeGlass := event.Details["break_glass_reason"]
+		return event.Details["pii"] == "unmasked" && !brokeGlass
+	})).Return(nil)
+
+	// Execute
+	patient, err := service.GetPatient(ctx, 10)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, newUnmaskedPatient(), patient)
+	auditService.AssertExpectations(t)
+}
+
+func TestPatientService_QueryPatients_BreakGlassIsAudited(t *testing.T) {
+	// Setup
+	patientRepo := new(MockPatientRepository)
+	auditService := new(MockAuditService)
+	service := services.NewPatientService(patientRepo, nil, nil, auditService)
+	access := utils.PIIAccess{Unmasked: true, BreakGlassReason: "emergency admission, patient unconscious"}
+	ctx := utils.WithPIIAccess(utils.WithHospitalID(context.Background(), 1), access)
+
+	query := models.PatientQueryRequest{PatientHN: "HN12345", Limit: models.DefaultPatientQueryLimit}
+	patientRepo.On("Search", ctx, query).Return([]*models.Patient{newUnmaskedPatient()}, 1, nil)
+	auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Details["pii"] == "unmasked" && event.Details["break_glass_reason"] == access.BreakGlassReason
+	})).Return(nil)
+
+	// Execute
+	patients, total, err := service.QueryPatients(ctx, models.PatientQueryRequest{PatientHN: "HN12345"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "1234567890121", patients[0].NationalID)
+	auditService.AssertExpectations(t)
+}
+
+func TestPatientMergeService_ListDuplicates_MasksPII(t *testing.T) {
+	// Setup
+	mergeRepo := new(MockPatientMergeRepository)
+	auditService := new(MockAuditService)
+	service := services.NewPatientMergeService(mergeRepo, auditService)
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	patientB := newUnmaskedPatient()
+	patientB.ID = 11
+	candidate := &models.PatientDuplicateCandidate{ID: 1, PatientA: newUnmaskedPatient(), PatientB: patientB}
+	mergeRepo.On("FindCandidates", ctx, mock.Anything).Return([]*models.PatientDuplicateCandidate{candidate}, nil)
+	auditService.On("Record", ctx, mock.Anything).Return(nil).Twice()
+
+	// Execute
+	candidates, err := service.ListDuplicates(ctx, models.PatientDuplicateQueryRequest{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "1-2345-xxxxx-12-1", candidates[0].PatientA.NationalID)
+	assert.Equal(t, "sxxxxxx@example.com", candidates[0].PatientB.Email)
+	auditService.AssertExpectations(t)
+}
+
+func TestPatientService_UpdatePatient_MaskedRoundTripKeepsPII(t *testing.T) {
+	// Setup
+	patientRepo := new(MockPatientRepository)
+	auditService := new(MockAuditService)
+	service := services.NewPatientService(patientRepo, nil, nil, auditService)
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	patientRepo.On("FindByID", ctx, 10).Return(newUnmaskedPatient(), nil).Once()
+	auditService.On("Record", ctx, mock.Anything).Return(nil)
+
+	// A caller without patient:unmask reads the record...
+	read, err := service.GetPatient(ctx, 10)
+	assert.NoError(t, err)
+
+	// ...and saves it back with only the English name changed
+	patientRepo.On("FindByID", ctx, 10).Return(newUnmaskedPatient(), nil).Once()
+	patientRepo.On("FindByNationalID", ctx, "1234567890121").Return(newUnmaskedPatient(), nil)
+	patientRepo.On("FindByPassportID", ctx, "AB1234567").Return(newUnmaskedPatient(), nil)
+	patientRepo.On("Update", ctx, mock.MatchedBy(func(patient *models.Patient) bool {
+		return patient.NationalID == "1234567890121" &&
+			patient.PassportID == "AB1234567" &&
+			patient.PhoneNumber == "0812345678" &&
+			patient.Email == "somchai@example.com" &&
+			patient.FirstNameEN == "Somchai"
+	})).Return(nil)
+
+	// Execute
+	_, err = service.UpdatePatient(ctx, 10, models.PatientUpdateRequest{
+		PatientCreateRequest: models.PatientCreateRequest{
+			NationalID:  read.NationalID,
+			PassportID:  read.PassportID,
+			FirstNameEN: "Somchai",
+			PatientHN:   read.PatientHN,
+			PhoneNumber: read.PhoneNumber,
+			Email:       read.Email,
+		},
+		UpdatedAt: read.UpdatedAt,
+	})
+
+	// Assert: the masks were not written over the stored PII
+	assert.NoError(t, err)
+	assert.Equal(t, "1-2345-xxxxx-12-1", read.NationalID)
+	patientRepo.AssertExpectations(t)
+}
+
+func TestPatientService_PatchPatient_MaskedValuesKeepPII(t *testing.T) {
+	// Setup
+	patientRepo := new(MockPatientRepository)
+	auditService := new(MockAuditService)
+	service := services.NewPatientService(patientRepo, nil, nil, auditService)
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	patientRepo.On("FindByID", ctx, 10).Return(newUnmaskedPatient(), nil)
+	patientRepo.On("FindByNationalID", ctx, "1234567890121").Return(newUnmaskedPatient(), nil)
+	patientRepo.On("FindByPassportID", ctx, "AB1234567").Return(newUnmaskedPatient(), nil)
+	patientRepo.On("Update", ctx, mock.MatchedBy(func(patient *models.Patient) bool {
+		return patient.NationalID == "1234567890121" &&
+			patient.PassportID == "AB1234567" &&
+			patient.Email == "new@example.com"
+	})).Return(nil)
+	auditService.On("Record", ctx, mock.Anything).Return(nil)
+
+	// Execute
+	maskedNationalID := "1-2345-xxxxx-12-1"
+	maskedPassportID := "xxxxxx567"
+	email := "new@example.com"
+	_, err := service.PatchPatient(ctx, 10, models.PatientPatchRequest{
+		NationalID: &maskedNationalID,
+		PassportID: &maskedPassportID,
+		Email:      &email,
+	})
+
+	// Assert: a changed value is still saved
+	assert.NoError(t, err)
+	patientRepo.AssertExpectations(t)
+}
+
+func TestPatientService_PatchPatient_RejectsOtherMaskedNationalID(t *testing.T) {
+	// Setup
+	patientRepo := new(MockPatientRepository)
+	service := services.NewPatientService(patientRepo, nil, nil, new(MockAuditService))
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	patientRepo.On("FindByID", ctx, 10).Return(newUnmaskedPatient(), nil)
+
+	// Execute
+	masked := "1-9999-xxxxx-12-1"
+	_, err := service.PatchPatient(ctx, 10, models.PatientPatchRequest{NationalID: &masked})
+
+	// Assert: the mask is never stored as an ID
+	assert.ErrorIs(t, err, apperrors.ErrInvalidInput)
+	patientRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,121 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPatientMergeRepository is a mock implementation of the PatientMergeRepository interface
+type MockPatientMergeRepository struct {
+	mock.Mock
+}
+
+func (m *MockPatientMergeRepository) DetectCandidates(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPatientMergeRepository) FindCandidates(ctx context.Context, query models.PatientDuplicateQueryRequest) ([]*models.PatientDuplicateCandidate, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.PatientDuplicateCandidate), args.Error(1)
+}
+
+func (m *MockPatientMergeRepository) DismissCandidate(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPatientMergeRepository) Merge(ctx context.Context, survivorID, duplicateID int, link *models.PatientLink) (*models.Patient, error) {
+	args := m.Called(ctx, survivorID, duplicateID, link)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Patient), args.Error(1)
+}
+
+func (m *MockPatientMergeRepository) ReencryptLinks(ctx context.Context, batchSize int) (int, error) {
+	args := m.Called(ctx, batchSize)
+	return args.Int(0), args.Error(1)
+}
+
+// MockAuditService is a mock implementation of the AuditService interface
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) Record(ctx context.Context, event *models.AuditEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockAuditService) Query(ctx context.Context, req models.AuditQueryRequest) ([]*models.AuditEvent, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.AuditEvent), args.Error(1)
+}
+
+func TestPatientMergeService_MergePatients(t *testing.T) {
+	// Setup
+	mergeRepo := new(MockPatientMergeRepository)
+	auditService := new(MockAuditService)
+	service := services.NewPatientMergeService(mergeRepo, auditService)
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 7)
+
+	// Mock repository: the merge fills in the link
+	mergeRepo.On("Merge", ctx, 10, 11, mock.MatchedBy(func(link *models.PatientLink) bool {
+		return link.MergedBy != nil && *link.MergedBy == 7 && link.Reason == "registered twice"
+	})).Run(func(args mock.Arguments) {
+		link := args.Get(3).(*models.PatientLink)
+		link.SurvivorID = 10
+		link.MergedPatientID = 11
+		link.MergedPatientHN = "HN99999"
+	}).Return(&models.Patient{ID: 10, PatientHN: "HN12345"}, nil)
+
+	// Both patients get a merge event
+	for _, patientID := range []int{10, 11} {
+		id := patientID
+		auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+			return event.Action == models.AuditActionPatientMerge && *event.PatientID == id &&
+				event.Details["merged_patient_hn"] == "HN99999"
+		})).Return(nil).Once()
+	}
+
+	// Execute
+	result, err := service.MergePatients(ctx, models.PatientMergeRequest{SurvivorID: 10, DuplicateID: 11, Reason: "registered twice"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 10, result.Survivor.ID)
+	assert.Equal(t, "HN99999", result.Link.MergedPatientHN)
+	mergeRepo.AssertExpectations(t)
+	auditService.AssertExpectations(t)
+}
+
+func TestPatientMergeService_ListDuplicates_DefaultsToOpen(t *testing.T) {
+	// Setup
+	mergeRepo := new(MockPatientMergeRepository)
+	auditService := new(MockAuditService)
+	service := services.NewPatientMergeService(mergeRepo, auditService)
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	mergeRepo.On("FindCandidates", ctx, models.PatientDuplicateQueryRequest{Status: models.DuplicateStatusOpen, Limit: 50}).Return([]*models.PatientDuplicateCandidate{}, nil)
+
+	// Execute
+	candidates, err := service.ListDuplicates(ctx, models.PatientDuplicateQueryRequest{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, candidates)
+	mergeRepo.AssertExpectations(t)
+}
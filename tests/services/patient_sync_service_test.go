@@ -0,0 +1,111 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newSyncService creates a PatientSyncService backed by a fake hospital-a with a one hour cache TTL
+func newSyncService(t *testing.T, upstream *models.PatientSearchResponse) (*services.PatientSyncService, *MockPatientRepository, *MockHospitalRepository) {
+	patientRepo := new(MockPatientRepository)
+	hospitalRepo := new(MockHospitalRepository)
+
+	servers := map[string]*httptest.Server{"hospital-a": newHospitalServer(t, upstream)}
+	registry := newRegistry(t, servers, "hospital-a")
+
+	cfg := &config.Config{
+		HospitalAPI: config.HospitalAPIConfig{
+			Hospitals: []config.HospitalEndpointConfig{{Name: "hospital-a", CacheTTL: time.Hour}},
+		},
+		PatientSync: config.PatientSyncConfig{BatchSize: 10},
+	}
+
+	return services.NewPatientSyncService(patientRepo, hospitalRepo, registry, cfg, slog.New(slog.DiscardHandler)), patientRepo, hospitalRepo
+}
+
+func TestPatientSyncService_IsStale(t *testing.T) {
+	// Setup
+	service, _, _ := newSyncService(t, nil)
+	recent := time.Now().Add(-time.Minute)
+	old := time.Now().Add(-2 * time.Hour)
+
+	// Assert
+	assert.False(t, service.IsStale(&models.Patient{}), "local records never go stale")
+	assert.False(t, service.IsStale(&models.Patient{SourceHospital: "hospital-a", LastSyncedAt: &recent}))
+	assert.True(t, service.IsStale(&models.Patient{SourceHospital: "hospital-a", LastSyncedAt: &old}))
+	assert.True(t, service.IsStale(&models.Patient{SourceHospital: "hospital-a"}))
+	assert.False(t, service.IsStale(&models.Patient{SourceHospital: "hospital-z", LastSyncedAt: &old}), "hospitals without a TTL never go stale")
+}
+
+func TestPatientSyncService_Refresh_UpsertsUpstreamRecord(t *testing.T) {
+	// Setup
+	upstream := &models.PatientSearchResponse{NationalID: "1234567890123", PatientHN: "HN12345", PhoneNumber: "0899999999"}
+	service, patientRepo, _ := newSyncService(t, upstream)
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	patientRepo.On("Upsert", ctx, mock.MatchedBy(func(p *models.Patient) bool {
+		return p.SourceHospital == "hospital-a" && p.PatientHN == "HN12345" && p.PhoneNumber == "0899999999" && p.LastSyncedAt != nil
+	})).Return(nil)
+
+	// Execute
+	stale := &models.Patient{ID: 10, NationalID: "1234567890123", PatientHN: "HN12345", PhoneNumber: "0811111111", SourceHospital: "hospital-a"}
+	refreshed, err := service.Refresh(ctx, stale)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "0899999999", refreshed.PhoneNumber)
+	patientRepo.AssertExpectations(t)
+}
+
+func TestPatientSyncService_Refresh_MarksSyncedWhenUpstreamForgetsPatient(t *testing.T) {
+	// Setup
+	service, patientRepo, _ := newSyncService(t, nil)
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	patientRepo.On("MarkSynced", ctx, 10, mock.AnythingOfType("time.Time")).Return(nil)
+
+	// Execute
+	stale := &models.Patient{ID: 10, NationalID: "1234567890123", SourceHospital: "hospital-a"}
+	refreshed, err := service.Refresh(ctx, stale)
+
+	// Assert
+	assert.Nil(t, refreshed)
+	assert.True(t, errors.Is(err, apperrors.ErrNotFound))
+	patientRepo.AssertExpectations(t)
+	patientRepo.AssertNotCalled(t, "Upsert", mock.Anything, mock.Anything)
+}
+
+func TestPatientSyncService_SyncStale(t *testing.T) {
+	// Setup
+	upstream := &models.PatientSearchResponse{NationalID: "1234567890123", PatientHN: "HN12345"}
+	service, patientRepo, hospitalRepo := newSyncService(t, upstream)
+	ctx := context.Background()
+	hospitalCtx := utils.WithHospitalID(ctx, 1)
+
+	hospitalRepo.On("FindAll", ctx).Return([]*models.Hospital{{ID: 1, Code: "hospital-a"}}, nil)
+	patientRepo.On("FindStale", hospitalCtx, "hospital-a", mock.AnythingOfType("time.Time"), 10).Return([]*models.Patient{
+		{ID: 10, NationalID: "1234567890123", PatientHN: "HN12345", SourceHospital: "hospital-a"},
+	}, nil)
+	patientRepo.On("Upsert", hospitalCtx, mock.AnythingOfType("*models.Patient")).Return(nil)
+
+	// Execute
+	refreshed, err := service.SyncStale(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, refreshed)
+	hospitalRepo.AssertExpectations(t)
+	patientRepo.AssertExpectations(t)
+}
@@ -0,0 +1,76 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket_Take(t *testing.T) {
+	// Setup: 3 requests at once, then one a second
+	start := time.Date(2025, 8, 10, 12, 0, 0, 0, time.UTC)
+	bucket := models.NewTokenBucket(3, start)
+
+	// Execute & Assert: the burst is allowed, then the caller waits for a refill
+	for i := 2; i >= 0; i-- {
+		decision := bucket.Take(start, 1, 3)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, i, decision.Remaining)
+	}
+
+	decision := bucket.Take(start.Add(250*time.Millisecond), 1, 3)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 750*time.Millisecond, decision.RetryAfter)
+
+	decision = bucket.Take(start.Add(time.Second), 1, 3)
+	assert.True(t, decision.Allowed)
+
+	// A long pause refills the bucket only up to the burst
+	decision = bucket.Take(start.Add(time.Hour), 1, 3)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 2, decision.Remaining)
+}
+
+func TestMemoryRateLimiter_Allow(t *testing.T) {
+	// Setup
+	limiter := services.NewMemoryRateLimiter()
+	rule := config.RateLimitRule{PerMinute: 1, Burst: 2}
+	ctx := context.Background()
+
+	// Execute
+	var allowed []bool
+	for i := 0; i < 3; i++ {
+		decision, err := limiter.Allow(ctx, "patients|staff:7", rule)
+		require.NoError(t, err)
+		allowed = append(allowed, decision.Allowed)
+	}
+	other, err := limiter.Allow(ctx, "patients|staff:8", rule)
+	require.NoError(t, err)
+
+	// Assert: each caller has a bucket of their own
+	assert.Equal(t, []bool{true, true, false}, allowed)
+	assert.True(t, other.Allowed)
+}
+
+func TestMemoryRateLimiter_Prune(t *testing.T) {
+	// Setup
+	limiter := services.NewMemoryRateLimiter()
+	rule := config.RateLimitRule{PerMinute: 1, Burst: 1}
+	ctx := context.Background()
+	_, err := limiter.Allow(ctx, "auth|ip:10.0.0.1", rule)
+	require.NoError(t, err)
+
+	// Execute: buckets idle since before now are dropped, so the caller starts over
+	require.NoError(t, limiter.Prune(ctx, time.Now().Add(time.Second)))
+	decision, err := limiter.Allow(ctx, "auth|ip:10.0.0.1", rule)
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
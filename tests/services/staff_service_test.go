@@ -0,0 +1,487 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/config"
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/services"
+	"github.com/DingDong039/hms/internal/utils"
+	apperrors "github.com/DingDong039/hms/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// staffServiceMocks holds the dependencies of a StaffService under test
+type staffServiceMocks struct {
+	staffRepo        *MockStaffRepository
+	roleRepo         *MockRoleRepository
+	refreshTokenRepo *MockRefreshTokenRepository
+	resetTokenRepo   *MockPasswordResetTokenRepository
+	mfaRepo          *MockMFARepository
+	inviteRepo       *MockStaffInviteRepository
+	auditService     *MockAuditService
+}
+
+func newStaffService() (*services.StaffServiceImpl, *staffServiceMocks) {
+	mocks := &staffServiceMocks{
+		staffRepo:        new(MockStaffRepository),
+		roleRepo:         new(MockRoleRepository),
+		refreshTokenRepo: new(MockRefreshTokenRepository),
+		resetTokenRepo:   new(MockPasswordResetTokenRepository),
+		mfaRepo:          new(MockMFARepository),
+		inviteRepo:       new(MockStaffInviteRepository),
+		auditService:     new(MockAuditService),
+	}
+	cfg := &config.Config{
+		Password: config.PasswordPolicyConfig{MinLength: 12, MinCharacterClasses: 3, ResetTokenTTL: time.Hour},
+		Staff:    config.StaffConfig{InviteTTL: 72 * time.Hour},
+	}
+
+	service := services.NewStaffService(mocks.staffRepo, mocks.roleRepo, mocks.refreshTokenRepo, mocks.resetTokenRepo, mocks.mfaRepo, mocks.inviteRepo, mocks.auditService, cfg)
+	return service, mocks
+}
+
+func TestStaffService_UnlockStaff(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 1)
+
+	mocks.staffRepo.On("ResetFailedLogins", ctx, 7).Return(nil)
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionStaffUnlock && event.Details["staff_id"] == "7"
+	})).Return(nil)
+
+	// Execute
+	err := service.UnlockStaff(ctx, 7)
+
+	// Assert
+	assert.NoError(t, err)
+	mocks.staffRepo.AssertExpectations(t)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestStaffService_UnlockStaff_NotFound(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	mocks.staffRepo.On("ResetFailedLogins", ctx, 99).Return(apperrors.NewNotFoundError("staff member not found"))
+
+	// Execute
+	err := service.UnlockStaff(ctx, 99)
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	mocks.auditService.AssertNotCalled(t, "Record", mock.Anything, mock.Anything)
+}
+
+func TestStaffService_ResetPassword(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 1)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(&models.Staff{ID: 7, HospitalID: 1, Username: "nurse1"}, nil)
+	var stored *models.PasswordResetToken
+	mocks.resetTokenRepo.On("Create", ctx, mock.AnythingOfType("*models.PasswordResetToken")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*models.PasswordResetToken)
+	}).Return(nil)
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionStaffPasswordReset && event.Details["staff_id"] == "7"
+	})).Return(nil)
+
+	// Execute
+	response, err := service.ResetPassword(ctx, 7)
+
+	// Assert: only the hash of the returned token is stored
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.Token)
+	assert.Equal(t, utils.HashToken(response.Token), stored.TokenHash)
+	assert.Equal(t, 7, stored.StaffID)
+	assert.Equal(t, 1, *stored.CreatedBy)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), response.ExpiresAt, time.Minute)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestStaffService_ResetPassword_OtherHospital(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithHospitalID(context.Background(), 2)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(nil, apperrors.NewNotFoundError("staff member not found"))
+
+	// Execute
+	_, err := service.ResetPassword(ctx, 7)
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	mocks.resetTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestStaffService_ResetMFA(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 1)
+
+	mocks.mfaRepo.On("Delete", ctx, 7).Return(nil)
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionStaffMFAReset && event.Details["staff_id"] == "7"
+	})).Return(nil)
+
+	// Execute
+	err := service.ResetMFA(ctx, 7)
+
+	// Assert
+	assert.NoError(t, err)
+	mocks.mfaRepo.AssertExpectations(t)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestStaffService_ResetMFA_NotEnrolled(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	mocks.mfaRepo.On("Delete", ctx, 7).Return(apperrors.NewNotFoundError("two-factor authentication is not set up"))
+
+	// Execute
+	err := service.ResetMFA(ctx, 7)
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	mocks.auditService.AssertNotCalled(t, "Record", mock.Anything, mock.Anything)
+}
+
+func TestStaffService_CreateStaff(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 1)
+
+	mocks.roleRepo.On("FindByName", ctx, models.RoleNurse).Return(&models.Role{ID: 3, Name: models.RoleNurse}, nil)
+	mocks.staffRepo.On("Create", ctx, mock.MatchedBy(func(staff *models.Staff) bool {
+		return staff.Username == "nurse2" && staff.RoleID == 3 && staff.MustChangePassword &&
+			utils.CheckPasswordHash("Tangerine-Kite-42", staff.Password)
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Staff).ID = 12
+	}).Return(nil)
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionStaffCreate && event.Details["staff_id"] == "12"
+	})).Return(nil)
+
+	// Execute
+	staff, err := service.CreateStaff(ctx, models.StaffCreateRequest{Username: "nurse2", Password: "Tangerine-Kite-42", Role: models.RoleNurse})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 12, staff.ID)
+	assert.Empty(t, staff.Password)
+	mocks.staffRepo.AssertExpectations(t)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestStaffService_CreateStaff_WeakPassword(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	// Execute
+	_, err := service.CreateStaff(ctx, models.StaffCreateRequest{Username: "nurse1", Password: "password123"})
+
+	// Assert
+	assert.IsType(t, &services.ValidationError{}, err)
+	mocks.staffRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestStaffService_InviteStaff(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 1)
+
+	var stored *models.StaffInvite
+	mocks.roleRepo.On("FindByName", ctx, models.DefaultRole).Return(&models.Role{ID: 4, Name: models.DefaultRole}, nil)
+	mocks.staffRepo.On("FindByUsername", ctx, "newclerk").Return(nil, apperrors.NewNotFoundError("staff member not found"))
+	mocks.inviteRepo.On("Create", ctx, mock.AnythingOfType("*models.StaffInvite")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*models.StaffInvite)
+		stored.ID = 5
+	}).Return(nil)
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionStaffInvite && event.Details["username"] == "newclerk"
+	})).Return(nil)
+
+	// Execute
+	response, err := service.InviteStaff(ctx, models.StaffInviteRequest{Username: "newclerk"})
+
+	// Assert: only the hash of the returned token is stored
+	require.NoError(t, err)
+	assert.Equal(t, models.DefaultRole, response.Role)
+	assert.Equal(t, utils.HashToken(response.Token), stored.TokenHash)
+	assert.Equal(t, 1, *stored.CreatedBy)
+	assert.WithinDuration(t, time.Now().Add(72*time.Hour), response.ExpiresAt, time.Minute)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestStaffService_InviteStaff_UsernameTaken(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	mocks.roleRepo.On("FindByName", ctx, models.RoleNurse).Return(&models.Role{ID: 3, Name: models.RoleNurse}, nil)
+	mocks.staffRepo.On("FindByUsername", ctx, "nurse1").Return(&models.Staff{ID: 7, Username: "nurse1"}, nil)
+
+	// Execute
+	_, err := service.InviteStaff(ctx, models.StaffInviteRequest{Username: "nurse1", Role: models.RoleNurse})
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrDuplicateResource)
+	mocks.inviteRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestStaffService_AcceptInvite(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+
+	mocks.inviteRepo.On("FindByHash", mock.Anything, utils.HashToken("invite-token")).Return(&models.StaffInvite{
+		ID: 5, HospitalID: 2, Username: "newnurse", RoleID: 3, Role: models.RoleNurse, ExpiresAt: time.Now().Add(time.Hour),
+	}, nil)
+	mocks.staffRepo.On("FindByUsername", mock.Anything, "newnurse").Return(nil, apperrors.NewNotFoundError("staff member not found"))
+	mocks.inviteRepo.On("MarkAccepted", mock.Anything, 5).Return(nil)
+	mocks.staffRepo.On("Create", mock.MatchedBy(func(ctx context.Context) bool {
+		hospitalID, ok := utils.HospitalIDFromContext(ctx)
+		return ok && hospitalID == 2
+	}), mock.MatchedBy(func(staff *models.Staff) bool {
+		return staff.Username == "newnurse" && staff.RoleID == 3 && !staff.MustChangePassword
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Staff).ID = 13
+	}).Return(nil)
+	mocks.auditService.On("Record", mock.MatchedBy(func(ctx context.Context) bool {
+		staffID, ok := utils.StaffIDFromContext(ctx)
+		return ok && staffID == 13
+	}), mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionStaffCreate && event.Details["invite_id"] == "5"
+	})).Return(nil)
+
+	// Execute
+	staff, err := service.AcceptInvite(context.Background(), models.StaffInviteAcceptRequest{Token: "invite-token", Password: "Tangerine-Kite-42"})
+
+	// Assert: the new staff member chose the password, so they don't have to change it
+	require.NoError(t, err)
+	assert.Equal(t, 13, staff.ID)
+	mocks.inviteRepo.AssertExpectations(t)
+	mocks.staffRepo.AssertExpectations(t)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestStaffService_AcceptInvite_WeakPasswordKeepsInvite(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+
+	mocks.inviteRepo.On("FindByHash", mock.Anything, utils.HashToken("invite-token")).Return(&models.StaffInvite{
+		ID: 5, HospitalID: 2, Username: "newnurse", RoleID: 3, Role: models.RoleNurse, ExpiresAt: time.Now().Add(time.Hour),
+	}, nil)
+
+	// Execute
+	_, err := service.AcceptInvite(context.Background(), models.StaffInviteAcceptRequest{Token: "invite-token", Password: "password123"})
+
+	// Assert
+	assert.IsType(t, &services.ValidationError{}, err)
+	mocks.inviteRepo.AssertNotCalled(t, "MarkAccepted", mock.Anything, mock.Anything)
+}
+
+func TestStaffService_AcceptInvite_Expired(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+
+	mocks.inviteRepo.On("FindByHash", mock.Anything, utils.HashToken("invite-token")).Return(&models.StaffInvite{
+		ID: 5, HospitalID: 2, Username: "newnurse", ExpiresAt: time.Now().Add(-time.Minute),
+	}, nil)
+
+	// Execute
+	_, err := service.AcceptInvite(context.Background(), models.StaffInviteAcceptRequest{Token: "invite-token", Password: "Tangerine-Kite-42"})
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrUnauthorized)
+	mocks.inviteRepo.AssertNotCalled(t, "MarkAccepted", mock.Anything, mock.Anything)
+	mocks.staffRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestStaffService_ListStaff(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	expectedQuery := models.StaffQueryRequest{FullName: "Som", Limit: models.DefaultStaffQueryLimit}
+	mocks.staffRepo.On("Search", ctx, expectedQuery).Return([]*models.Staff{{ID: 7, Username: "nurse1", Password: "hash"}}, 1, nil)
+
+	// Execute
+	staffList, total, err := service.ListStaff(ctx, models.StaffQueryRequest{FullName: "  Som "})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Empty(t, staffList[0].Password)
+	mocks.staffRepo.AssertExpectations(t)
+}
+
+func TestStaffService_UpdateStaff(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 1)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(&models.Staff{ID: 7, Username: "nurse1", RoleID: 3, Role: models.RoleNurse, IsActive: true}, nil)
+	mocks.roleRepo.On("FindByName", ctx, models.RoleDoctor).Return(&models.Role{ID: 2, Name: models.RoleDoctor}, nil)
+	mocks.staffRepo.On("Update", ctx, mock.MatchedBy(func(staff *models.Staff) bool {
+		return staff.Department == "ICU" && staff.RoleID == 2 && staff.IsActive
+	})).Return(nil)
+	mocks.refreshTokenRepo.On("RevokeAllForStaff", ctx, 7).Return(nil)
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionStaffUpdate && event.Details["fields"] == "department,role" &&
+			event.Details["role"] == models.RoleDoctor
+	})).Return(nil)
+
+	// Execute
+	department := " ICU "
+	role := models.RoleDoctor
+	staff, err := service.UpdateStaff(ctx, 7, models.StaffPatchRequest{Department: &department, Role: &role})
+
+	// Assert: a role change ends the staff member's sessions so it takes effect at once
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleDoctor, staff.Role)
+	mocks.staffRepo.AssertExpectations(t)
+	mocks.refreshTokenRepo.AssertExpectations(t)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestStaffService_UpdateStaff_ProfileKeepsSessions(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 1)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(&models.Staff{ID: 7, Role: models.RoleNurse, IsActive: true}, nil)
+	mocks.staffRepo.On("Update", ctx, mock.AnythingOfType("*models.Staff")).Return(nil)
+	mocks.auditService.On("Record", ctx, mock.AnythingOfType("*models.AuditEvent")).Return(nil)
+
+	// Execute
+	fullName := "Somsri Jaidee"
+	_, err := service.UpdateStaff(ctx, 7, models.StaffPatchRequest{FullName: &fullName})
+
+	// Assert
+	require.NoError(t, err)
+	mocks.refreshTokenRepo.AssertNotCalled(t, "RevokeAllForStaff", mock.Anything, mock.Anything)
+}
+
+func TestStaffService_UpdateStaff_NoChanges(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(&models.Staff{ID: 7, Department: "ER", IsActive: true}, nil)
+
+	// Execute
+	department := "ER"
+	active := true
+	_, err := service.UpdateStaff(ctx, 7, models.StaffPatchRequest{Department: &department, IsActive: &active})
+
+	// Assert
+	require.NoError(t, err)
+	mocks.staffRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	mocks.auditService.AssertNotCalled(t, "Record", mock.Anything, mock.Anything)
+}
+
+func TestStaffService_DeactivateStaff(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 1)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(&models.Staff{ID: 7, Role: models.RoleNurse, IsActive: true}, nil)
+	mocks.staffRepo.On("Update", ctx, mock.MatchedBy(func(staff *models.Staff) bool {
+		return !staff.IsActive && staff.DeactivatedAt != nil
+	})).Return(nil)
+	mocks.refreshTokenRepo.On("RevokeAllForStaff", ctx, 7).Return(nil)
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionStaffDeactivate && event.Details["staff_id"] == "7"
+	})).Return(nil)
+
+	// Execute
+	staff, err := service.DeactivateStaff(ctx, 7)
+
+	// Assert
+	require.NoError(t, err)
+	assert.False(t, staff.IsActive)
+	mocks.staffRepo.AssertExpectations(t)
+	mocks.refreshTokenRepo.AssertExpectations(t)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestStaffService_DeactivateStaff_Self(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 7)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(&models.Staff{ID: 7, Role: models.RoleAdmin, IsActive: true}, nil)
+
+	// Execute
+	_, err := service.DeactivateStaff(ctx, 7)
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrForbidden)
+	mocks.staffRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestStaffService_UpdateStaff_LastAdmin(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 1)
+
+	mocks.staffRepo.On("FindByID", ctx, 2).Return(&models.Staff{ID: 2, RoleID: 1, Role: models.RoleAdmin, IsActive: true}, nil)
+	mocks.roleRepo.On("FindByName", ctx, models.RoleAuditor).Return(&models.Role{ID: 5, Name: models.RoleAuditor}, nil)
+	mocks.staffRepo.On("CountByRole", ctx, models.RoleAdmin).Return(1, nil)
+
+	// Execute
+	role := models.RoleAuditor
+	_, err := service.UpdateStaff(ctx, 2, models.StaffPatchRequest{Role: &role})
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrConflict)
+	mocks.staffRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestStaffService_ReactivateStaff(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithStaffID(utils.WithHospitalID(context.Background(), 1), 1)
+	deactivatedAt := time.Now().Add(-time.Hour)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(&models.Staff{ID: 7, Role: models.RoleNurse, DeactivatedAt: &deactivatedAt}, nil)
+	mocks.staffRepo.On("Update", ctx, mock.MatchedBy(func(staff *models.Staff) bool {
+		return staff.IsActive && staff.DeactivatedAt == nil
+	})).Return(nil)
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *models.AuditEvent) bool {
+		return event.Action == models.AuditActionStaffReactivate
+	})).Return(nil)
+
+	// Execute
+	_, err := service.ReactivateStaff(ctx, 7)
+
+	// Assert
+	require.NoError(t, err)
+	mocks.refreshTokenRepo.AssertNotCalled(t, "RevokeAllForStaff", mock.Anything, mock.Anything)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestStaffService_ReactivateStaff_AlreadyActive(t *testing.T) {
+	// Setup
+	service, mocks := newStaffService()
+	ctx := utils.WithHospitalID(context.Background(), 1)
+
+	mocks.staffRepo.On("FindByID", ctx, 7).Return(&models.Staff{ID: 7, IsActive: true}, nil)
+
+	// Execute
+	_, err := service.ReactivateStaff(ctx, 7)
+
+	// Assert
+	assert.ErrorIs(t, err, apperrors.ErrConflict)
+	mocks.staffRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,280 @@
+package utils_test
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newKeyFile returns the path of a new key file holding one key with the given algorithm
+func newKeyFile(t *testing.T, algorithm string) (string, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwt.json")
+	id, err := utils.AddJWTKey(path, algorithm, true)
+	require.NoError(t, err)
+	return path, id
+}
+
+func TestGenerateToken_SignsWithCurrentKey(t *testing.T) {
+	for _, algorithm := range []string{utils.JWTAlgorithmRS256, utils.JWTAlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			// Setup
+			path, id := newKeyFile(t, algorithm)
+			keys, err := utils.LoadJWTKeySet(path)
+			require.NoError(t, err)
+
+			// Execute
+			token, _, err := utils.GenerateToken(&utils.JWTClaims{UserID: 7, HospitalID: 1}, time.Minute, keys)
+			require.NoError(t, err)
+			claims, err := utils.ValidateToken(token, keys)
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, 7, claims.UserID)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &utils.JWTClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, algorithm, parsed.Method.Alg())
+			assert.Equal(t, id, parsed.Header["kid"])
+		})
+	}
+}
+
+func TestValidateToken_AcceptsRetiredKeyUntilRemoved(t *testing.T) {
+	// Setup: sign with the first key, then rotate to a second one
+	path, oldID := newKeyFile(t, utils.JWTAlgorithmEdDSA)
+	oldKeys, err := utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+	token, _, err := utils.GenerateToken(&utils.JWTClaims{UserID: 7}, time.Minute, oldKeys)
+	require.NoError(t, err)
+
+	newID, err := utils.AddJWTKey(path, utils.JWTAlgorithmEdDSA, true)
+	require.NoError(t, err)
+	keys, err := utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+
+	// Execute / Assert: the retired key still verifies
+	assert.Equal(t, newID, keys.CurrentKeyID())
+	_, err = utils.ValidateToken(token, keys)
+	assert.NoError(t, err)
+
+	// Execute / Assert: once removed, its tokens are rejected
+	require.NoError(t, utils.RemoveJWTKey(path, oldID))
+	keys, err = utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+	_, err = utils.ValidateToken(token, keys)
+	assert.Error(t, err)
+}
+
+func TestAddJWTKey_PublishOnly(t *testing.T) {
+	// Setup
+	path, currentID := newKeyFile(t, utils.JWTAlgorithmEdDSA)
+
+	// Execute
+	nextID, err := utils.AddJWTKey(path, utils.JWTAlgorithmRS256, false)
+	require.NoError(t, err)
+	keys, err := utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+
+	// Assert: the new key is published but doesn't sign yet
+	assert.Equal(t, currentID, keys.CurrentKeyID())
+	assert.Len(t, keys.JWKS().Keys, 2)
+
+	require.NoError(t, utils.SetCurrentJWTKey(path, nextID))
+	keys, err = utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+	assert.Equal(t, nextID, keys.CurrentKeyID())
+}
+
+func TestRemoveJWTKey_RefusesCurrentKey(t *testing.T) {
+	// Setup
+	path, id := newKeyFile(t, utils.JWTAlgorithmEdDSA)
+
+	// Execute
+	err := utils.RemoveJWTKey(path, id)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestValidateToken_RejectsHMACToken(t *testing.T) {
+	// Setup: an HS256 token naming a real key, as a shared-secret token would be forged
+	path, id := newKeyFile(t, utils.JWTAlgorithmEdDSA)
+	keys, err := utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+
+	claims := &utils.JWTClaims{UserID: 7, RegisteredClaims: jwt.RegisteredClaims{
+		ID:        "forged",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = id
+	token, err := forged.SignedString([]byte("test-secret"))
+	require.NoError(t, err)
+
+	// Execute
+	_, err = utils.ValidateToken(token, keys)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestValidateToken_RejectsUnknownKey(t *testing.T) {
+	// Setup: a token from another deployment's key file
+	otherPath, _ := newKeyFile(t, utils.JWTAlgorithmEdDSA)
+	otherKeys, err := utils.LoadJWTKeySet(otherPath)
+	require.NoError(t, err)
+	token, _, err := utils.GenerateToken(&utils.JWTClaims{UserID: 7}, time.Minute, otherKeys)
+	require.NoError(t, err)
+
+	path, _ := newKeyFile(t, utils.JWTAlgorithmEdDSA)
+	keys, err := utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+
+	// Execute
+	_, err = utils.ValidateToken(token, keys)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+// signWithKeyFile signs claims with a key from a key file, as a token minted outside
+// GenerateToken would be
+func signWithKeyFile(t *testing.T, path, id string, claims jwt.Claims) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var file utils.JWTKeyFile
+	require.NoError(t, json.Unmarshal(data, &file))
+	block, _ := pem.Decode([]byte(file.Keys[id]))
+	require.NotNil(t, block)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(t, err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = id
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestValidateToken_TellsAccessAndMFATokensApart(t *testing.T) {
+	// Setup
+	path, id := newKeyFile(t, utils.JWTAlgorithmEdDSA)
+	keys, err := utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+
+	access, _, err := utils.GenerateToken(&utils.JWTClaims{UserID: 7, HospitalID: 1}, time.Minute, keys)
+	require.NoError(t, err)
+	mfa, _, err := utils.GenerateMFAToken(7, 1, time.Minute, keys)
+	require.NoError(t, err)
+	noAudience := signWithKeyFile(t, path, id, &utils.JWTClaims{UserID: 7, RegisteredClaims: jwt.RegisteredClaims{
+		ID:        "no-audience",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}})
+
+	// Execute
+	_, accessAsAccessErr := utils.ValidateToken(access, keys)
+	_, accessAsMFAErr := utils.ValidateMFAToken(access, keys)
+	_, mfaAsAccessErr := utils.ValidateToken(mfa, keys)
+	_, mfaAsMFAErr := utils.ValidateMFAToken(mfa, keys)
+	_, noAudienceErr := utils.ValidateToken(noAudience, keys)
+
+	// Assert: each kind is accepted only where it belongs, and its audience says which it is
+	assert.NoError(t, accessAsAccessErr)
+	assert.Error(t, accessAsMFAErr)
+	assert.Error(t, mfaAsAccessErr)
+	assert.NoError(t, mfaAsMFAErr)
+	assert.Error(t, noAudienceErr)
+
+	for token, audience := range map[string]string{access: utils.TokenAudienceAccess, mfa: utils.TokenAudienceMFA} {
+		claims := &utils.JWTClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(token, claims)
+		require.NoError(t, err)
+		assert.Equal(t, jwt.ClaimStrings{audience}, claims.Audience)
+	}
+}
+
+func TestLoadJWTKeySet_RequiresAKey(t *testing.T) {
+	// Execute
+	_, missingErr := utils.LoadJWTKeySet(filepath.Join(t.TempDir(), "missing.json"))
+	_, emptyErr := utils.NewJWTKeySet(&utils.JWTKeyFile{Keys: map[string]string{}})
+
+	// Assert
+	assert.Error(t, missingErr)
+	assert.Error(t, emptyErr)
+}
+
+func TestJWKS_PublishesOnlyPublicKeys(t *testing.T) {
+	// Setup
+	path, rsaID := newKeyFile(t, utils.JWTAlgorithmRS256)
+	edID, err := utils.AddJWTKey(path, utils.JWTAlgorithmEdDSA, false)
+	require.NoError(t, err)
+	keys, err := utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+
+	// Execute
+	jwks := keys.JWKS()
+
+	// Assert
+	byID := map[string]utils.JWK{}
+	for _, key := range jwks.Keys {
+		byID[key.Kid] = key
+	}
+	require.Len(t, byID, 2)
+
+	rsaKey := byID[rsaID]
+	assert.Equal(t, "RSA", rsaKey.Kty)
+	assert.Equal(t, "RS256", rsaKey.Alg)
+	assert.Equal(t, "sig", rsaKey.Use)
+	assert.Equal(t, "AQAB", rsaKey.E)
+	assert.NotEmpty(t, rsaKey.N)
+
+	edKey := byID[edID]
+	assert.Equal(t, "OKP", edKey.Kty)
+	assert.Equal(t, "Ed25519", edKey.Crv)
+	assert.Equal(t, "EdDSA", edKey.Alg)
+	assert.NotEmpty(t, edKey.X)
+
+	encoded, err := json.Marshal(jwks)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), `"d"`)
+}
+
+func TestJWK_PublicKeyRoundTrip(t *testing.T) {
+	// Setup
+	path, _ := newKeyFile(t, utils.JWTAlgorithmRS256)
+	_, err := utils.AddJWTKey(path, utils.JWTAlgorithmEdDSA, false)
+	require.NoError(t, err)
+	keys, err := utils.LoadJWTKeySet(path)
+	require.NoError(t, err)
+	token, _, err := utils.GenerateToken(&utils.JWTClaims{UserID: 7}, time.Minute, keys)
+	require.NoError(t, err)
+
+	// Execute: verify with the public keys as another service would, from the JWKS
+	published := map[string]interface{}{}
+	for _, jwk := range keys.JWKS().Keys {
+		key, err := jwk.PublicKey()
+		require.NoError(t, err)
+		published[jwk.Kid] = key
+	}
+	_, err = jwt.ParseWithClaims(token, &utils.JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return published[token.Header["kid"].(string)], nil
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = utils.JWK{Kty: "oct", Kid: "shared"}.PublicKey()
+	assert.Error(t, err)
+}
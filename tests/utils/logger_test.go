@@ -0,0 +1,127 @@
+package utils_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/DingDong039/hms/internal/models"
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// piiValues are the personal data the logger must never write
+var piiValues = []string{
+	"1234567890121",
+	"1-2345-67890-12-1",
+	"AB1234567",
+	"0812345678",
+	"+66 81 234 5678",
+	"somchai@example.com",
+}
+
+// assertNoPII fails if any PII value appears in the log output
+func assertNoPII(t *testing.T, output string) {
+	t.Helper()
+	for _, value := range piiValues {
+		assert.NotContains(t, output, value)
+	}
+}
+
+func TestNewLogger_RedactsPII(t *testing.T) {
+	testCases := []struct {
+		name string
+		log  func(logger *slog.Logger)
+	}{
+		{
+			name: "Message",
+			log: func(logger *slog.Logger) {
+				logger.Info("lookup of 1234567890121 by somchai@example.com at 0812345678")
+			},
+		},
+		{
+			name: "PII Keys",
+			log: func(logger *slog.Logger) {
+				logger.Info("patient", slog.String("national_id", "AB1234567"), slog.String("Email", "x"), slog.String("phone_number", "+66 81 234 5678"))
+			},
+		},
+		{
+			name: "Embedded In Strings",
+			log: func(logger *slog.Logger) {
+				logger.Warn("request", slog.String("path", "/api/v1/patients/search/1-2345-67890-12-1?passport=AB1234567"))
+			},
+		},
+		{
+			name: "Errors",
+			log: func(logger *slog.Logger) {
+				logger.Error("refresh failed", slog.Any("error", errors.New("upstream has no patient 1234567890121")))
+			},
+		},
+		{
+			name: "Structs",
+			log: func(logger *slog.Logger) {
+				logger.Info("patient", slog.Any("patient", models.Patient{NationalID: "1234567890121", PassportID: "AB1234567", PhoneNumber: "0812345678", Email: "somchai@example.com"}))
+			},
+		},
+		{
+			name: "Groups And Logger Attributes",
+			log: func(logger *slog.Logger) {
+				logger.With(slog.String("caller", "somchai@example.com")).WithGroup("patient").Info("found", slog.Group("contact", slog.String("note", "call 0812345678")))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, format := range []string{"json", "text"} {
+				// Setup
+				var output bytes.Buffer
+				logger := utils.NewLogger(&output, slog.LevelInfo, format)
+
+				// Execute
+				tc.log(logger)
+
+				// Assert
+				assert.Contains(t, output.String(), utils.RedactedValue, format)
+				assertNoPII(t, output.String())
+			}
+		})
+	}
+}
+
+func TestNewLogger_KeepsOtherValues(t *testing.T) {
+	// Setup
+	var output bytes.Buffer
+	logger := utils.NewLogger(&output, slog.LevelInfo, "json")
+	ctx := utils.WithRequestID(context.Background(), "req-42")
+
+	// Execute
+	logger.InfoContext(ctx, "patient sync refreshed records", slog.String("patient_hn", "HN12345"), slog.Int("refreshed", 3))
+
+	// Assert
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(output.Bytes(), &record))
+	assert.Equal(t, "patient sync refreshed records", record["msg"])
+	assert.Equal(t, "HN12345", record["patient_hn"])
+	assert.Equal(t, float64(3), record["refreshed"])
+	assert.Equal(t, "req-42", record["request_id"])
+}
+
+func TestNewLogger_FiltersByLevel(t *testing.T) {
+	// Setup
+	var output bytes.Buffer
+	logger := utils.NewLogger(&output, slog.LevelWarn, "text")
+
+	// Execute
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	// Assert
+	assert.NotContains(t, output.String(), "dropped")
+	assert.Equal(t, 1, strings.Count(output.String(), "kept"))
+}
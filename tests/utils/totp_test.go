@@ -0,0 +1,80 @@
+package utils_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DingDong039/hms/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA-1 test key of RFC 6238, "12345678901234567890", in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, want := range vectors {
+		// Execute
+		code, err := utils.TOTPCode(rfcSecret, time.Unix(unix, 0))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, want, code, "at %d", unix)
+	}
+}
+
+func TestValidateTOTPCode_AllowsOneStepOfSkew(t *testing.T) {
+	// Setup
+	now := time.Unix(1234567890, 0)
+	previous, err := utils.TOTPCode(rfcSecret, now.Add(-30*time.Second))
+	require.NoError(t, err)
+	tooOld, err := utils.TOTPCode(rfcSecret, now.Add(-90*time.Second))
+	require.NoError(t, err)
+
+	// Execute
+	counter, ok := utils.ValidateTOTPCode(rfcSecret, previous, now)
+	_, tooOldOK := utils.ValidateTOTPCode(rfcSecret, tooOld, now)
+
+	// Assert: the time step of the code is returned, so it can be refused next time
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/30-1, counter)
+	assert.False(t, tooOldOK)
+}
+
+func TestValidateTOTPCode_RejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+
+	for _, code := range []string{"", "00592", "0059240", "abcdef"} {
+		_, ok := utils.ValidateTOTPCode(rfcSecret, code, now)
+		assert.False(t, ok, "code %q", code)
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	// Execute
+	secret, err := utils.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	// Assert: the secret is usable and its provisioning URI carries it
+	_, err = utils.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+
+	uri, err := url.Parse(utils.TOTPProvisioningURI("HMS", "nurse1", secret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.True(t, strings.HasSuffix(uri.Path, "HMS:nurse1"))
+	assert.Equal(t, secret, uri.Query().Get("secret"))
+	assert.Equal(t, "HMS", uri.Query().Get("issuer"))
+}